@@ -6,13 +6,16 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
-	"github.com/rabbitmq/amqp091-go"
 
 	// Driver PGX (Novo) - Compatível com Supabase Pooler
 	"github.com/jackc/pgx/v5/stdlib"
@@ -23,6 +26,7 @@ import (
 	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
 
 	// Importações Internas do CorePay
+	"github.com/xavierca1/ligue-payments/internal/entity"
 	"github.com/xavierca1/ligue-payments/internal/infra/database"
 	"github.com/xavierca1/ligue-payments/internal/infra/http/handlers"
 	httpMiddleware "github.com/xavierca1/ligue-payments/internal/infra/http/middleware"
@@ -30,6 +34,7 @@ import (
 	"github.com/xavierca1/ligue-payments/internal/infra/integration/doc24"
 	"github.com/xavierca1/ligue-payments/internal/infra/integration/docuseal"
 	"github.com/xavierca1/ligue-payments/internal/infra/integration/kommo"
+	"github.com/xavierca1/ligue-payments/internal/infra/integration/tem"
 	"github.com/xavierca1/ligue-payments/internal/infra/mail"
 	"github.com/xavierca1/ligue-payments/internal/infra/pdf"
 	"github.com/xavierca1/ligue-payments/internal/infra/queue"
@@ -47,12 +52,27 @@ type KommoAdapter struct {
 	client *kommo.Client
 }
 
+// O encerramento inteiro (readinessDrainDelay + shutdownTimeout) deve caber no
+// stop_grace_period do stack.yml.
+const (
+	readinessDrainDelay = 5 * time.Second  // /ready responde 503 antes de fechar o listener
+	shutdownTimeout     = 45 * time.Second // requisições HTTP e workers em andamento
+)
+
 type noopQueueProducer struct{}
 
 func (n *noopQueueProducer) PublishActivation(ctx context.Context, payload queue.ActivationPayload) error {
 	return nil
 }
 
+func (n *noopQueueProducer) PublishDeactivation(ctx context.Context, payload queue.DeactivationPayload) error {
+	return nil
+}
+
+func (n *noopQueueProducer) PublishUpdate(ctx context.Context, payload queue.ActivationPayload) error {
+	return nil
+}
+
 func (a *KommoAdapter) CreateLead(customerName, phone, email, planName string, price int) (int, error) {
 	return a.client.CreateLead(kommo.CreateLeadInput{
 		CustomerName: customerName,
@@ -101,22 +121,30 @@ func main() {
 	tracer.Start()
 	defer tracer.Stop()
 
+	// Cancelado no SIGINT/SIGTERM: workers param de pegar trabalho novo e terminam o que está em andamento.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var background sync.WaitGroup
+
 	// 2. Conexões de Infraestrutura (DB e Filas)
 	db := setupDatabase()
 	defer db.Close()
 
-	rabbitMQ, err := queue.NewRabbitMQ(
-		os.Getenv("RABBITMQ_USER"),
-		os.Getenv("RABBITMQ_PASS"),
-		os.Getenv("RABBITMQ_HOST"),
-		os.Getenv("RABBITMQ_PORT"),
-	)
-	if err != nil {
-		log.Printf("⚠️ RabbitMQ indisponível, iniciando API sem fila: %v", err)
-	}
-	if rabbitMQ != nil {
-		defer rabbitMQ.Conn.Close()
-		defer rabbitMQ.Ch.Close()
+	// O ConnectionManager reconecta sozinho; produtores e worker pegam canais novos dele.
+	var rabbitMQ *queue.ConnectionManager
+	if rabbitHost := strings.TrimSpace(os.Getenv("RABBITMQ_HOST")); rabbitHost != "" {
+		rabbitMQ = queue.NewConnectionManager(
+			os.Getenv("RABBITMQ_USER"),
+			os.Getenv("RABBITMQ_PASS"),
+			rabbitHost,
+			os.Getenv("RABBITMQ_PORT"),
+		)
+		// A conexão só é encerrada depois que worker e relay terminarem (ver shutdown no fim do main).
+		rabbitCtx, stopRabbitMQ := context.WithCancel(context.Background())
+		defer stopRabbitMQ()
+		go rabbitMQ.Start(rabbitCtx)
+	} else {
+		log.Println("⚠️ RABBITMQ_HOST não configurado, iniciando API sem fila")
 	}
 
 	// 3. Inicialização de Repositórios
@@ -126,6 +154,11 @@ func main() {
 	leadRepo := &database.LeadRepository{DB: db}
 	dependentRepo := database.NewDependentRepository(db)
 	couponRepo := database.NewCouponRepository(db)
+	webhookEventRepo := database.NewWebhookEventRepository(db)
+	outboxRepo := database.NewOutboxRepository(db)
+	pixReconciliationRepo := database.NewPixReconciliationRepository(db)
+	schedulerLeaseRepo := database.NewSchedulerLeaseRepository(db)
+	cancellationRepo := database.NewCancellationRepository(db)
 	usecase.SetCouponTracker(couponRepo)
 
 	// 4. Integrações e Serviços Externos
@@ -133,7 +166,7 @@ func main() {
 	gateway := asaas.NewClient(os.Getenv("ASAAS_API_KEY"), os.Getenv("ASAAS_URL"))
 	var producer usecase.QueueProducerInterface = &noopQueueProducer{}
 	if rabbitMQ != nil {
-		producer = queue.NewProducer(rabbitMQ.Conn, rabbitMQ.Ch)
+		producer = queue.NewProducer(rabbitMQ)
 	}
 	kommoAdapter := &KommoAdapter{client: kommo.NewClient()}
 
@@ -152,18 +185,70 @@ func main() {
 		strings.TrimSpace(os.Getenv("DOC24_CLIENT_ID")),
 		strings.TrimSpace(os.Getenv("DOC24_CLIENT_SECRET")),
 	)
+	temClient := tem.NewClient(
+		strings.TrimSpace(os.Getenv("TEM_BASE_URL")),
+		strings.TrimSpace(os.Getenv("TEM_CLIENT_ID")),
+		strings.TrimSpace(os.Getenv("TEM_CLIENT_SECRET")),
+	)
+
+	// Provedores de telemedicina, chaveados por plans.provider
+	providers := queue.NewProviderRegistry()
+	if err := providers.Register("DOC24", docClient); err != nil {
+		log.Fatalf("❌ Falha ao registrar provedor DOC24: %v", err)
+	}
+	if err := providers.Register("TEM", temClient); err != nil {
+		log.Fatalf("❌ Falha ao registrar provedor TEM: %v", err)
+	}
 
 	// DocuSeal client (opcional - usa variáveis de ambiente DOCUSEAL_API_URL e DOCUSEAL_API_KEY)
 	docuSealClient := docuseal.NewClient(strings.TrimSpace(os.Getenv("DOCUSEAL_API_URL")), strings.TrimSpace(os.Getenv("DOCUSEAL_API_KEY")))
 
 	// 5. Workers de Background
 	if rabbitMQ != nil {
-		queueWorker := queue.NewWorker(rabbitMQ.Ch, docClient, customerRepo)
-		go queueWorker.Start(queue.QueueName)
+		queueWorker := queue.NewWorker(rabbitMQ, providers, customerRepo)
+		if raw := strings.TrimSpace(os.Getenv("ACTIVATION_MAX_ATTEMPTS")); raw != "" {
+			if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
+				queueWorker.Retry.MaxAttempts = parsed
+			}
+		}
+		if raw := strings.TrimSpace(os.Getenv("ACTIVATION_WORKER_CONCURRENCY")); raw != "" {
+			if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
+				queueWorker.Concurrency = parsed
+			}
+		}
+		if raw := strings.TrimSpace(os.Getenv("ACTIVATION_WORKER_PREFETCH")); raw != "" {
+			if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
+				queueWorker.Prefetch = parsed
+			}
+		}
+		if raw := strings.TrimSpace(os.Getenv("ACTIVATION_PROVIDER_LIMITS")); raw != "" {
+			limits, err := queue.ParseProviderLimits(raw)
+			if err != nil {
+				log.Fatalf("❌ ACTIVATION_PROVIDER_LIMITS: %v", err)
+			}
+			queueWorker.ProviderLimits = limits
+		}
+
+		runInBackground(&background, func() { queueWorker.Start(ctx, queue.QueueName) })
 	}
 
-	pixWorker := worker.NewPixExpirationWorker(db)
-	go pixWorker.Start(context.Background())
+	// O outbox é gravado mesmo com o RabbitMQ fora; os eventos ficam PENDING até o relay rodar.
+	if rabbitMQ != nil {
+		relayProducer := queue.NewProducer(rabbitMQ)
+		defer relayProducer.Close()
+		outboxRelay := worker.NewOutboxRelay(outboxRepo, relayProducer)
+		runInBackground(&background, func() { outboxRelay.Start(ctx) })
+	}
+
+	// Janela de pagamento do PIX: vale para o vencimento no Asaas, o pix_expires_at do
+	// checkout e o PixExpirationWorker. Planos/produtos podem sobrescrever no banco.
+	pixExpirationWindow := entity.DefaultPixExpirationWindow
+	if raw := strings.TrimSpace(os.Getenv("PIX_EXPIRATION_MINUTES")); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
+			pixExpirationWindow = time.Duration(parsed) * time.Minute
+		}
+	}
+	pixPolicy := entity.NewPixExpirationPolicy(pixExpirationWindow)
 
 	// 6. Casos de Uso (Business Logic)
 	createCustomerUC := usecase.NewCreateCustomerUseCase(
@@ -171,10 +256,15 @@ func main() {
 		os.Getenv("SUPABASE_STORAGE_URL"),
 		dependentRepo,
 	)
+	createCustomerUC.PixPolicy = pixPolicy
+	createCustomerUC.LeadRepo = leadRepo
+	createCustomerUC.CardRepo = customerRepo
 
 	activateSubUC := usecase.NewActivateSubscriptionUseCase(
 		subRepo, customerRepo, planRepo, dependentRepo, producer, mailSender, kommoAdapter,
 	)
+	activateSubUC.Outbox = outboxRepo
+	activateSubUC.LeadRepo = leadRepo
 	activateSubUC.ContractUC = usecase.NewGenerateContractUseCase(
 		pdf.NewContractGenerator("internal/infra/storage/plans_templates"),
 		contractStorage,
@@ -187,21 +277,94 @@ func main() {
 	activateSubUC.DocuSealUseCase = docuSealUseCase
 	log.Println("✅ Gerador de contrato PDF e DocuSeal inicializado")
 
+	pixWorker := worker.NewPixExpirationWorker(pixReconciliationRepo, gateway, activateSubUC, pixPolicy)
+
+	// Jobs periódicos rodam em uma réplica por vez (lease em scheduler_leases).
+	scheduler := worker.NewScheduler(schedulerLeaseRepo, "")
+	pixWorker.Schedule(scheduler)
+
+	leadRecoveryWorker := worker.NewLeadRecoveryWorker(leadRepo, mailSender, os.Getenv("CHECKOUT_URL"))
+	leadRecoveryWorker.UnsubscribeBaseURL = strings.TrimRight(os.Getenv("API_PUBLIC_URL"), "/")
+	if leadRecoveryWorker.UnsubscribeBaseURL == "" {
+		log.Println("⚠️  API_PUBLIC_URL não configurada: emails de recuperação de leads desativados (sem link de descadastro)")
+	}
+	if raw := strings.TrimSpace(os.Getenv("LEAD_RECOVERY_CADENCE")); raw != "" {
+		cadence, err := worker.ParseLeadRecoveryCadence(raw)
+		if err != nil {
+			log.Fatalf("❌ LEAD_RECOVERY_CADENCE: %v", err)
+		}
+		leadRecoveryWorker.Cadence = cadence
+	}
+	if raw := strings.TrimSpace(os.Getenv("LEAD_RECOVERY_MAX_AGE")); raw != "" {
+		maxAge, err := time.ParseDuration(raw)
+		if err != nil || maxAge <= 0 {
+			log.Fatalf("❌ LEAD_RECOVERY_MAX_AGE inválido: %q (esperado algo como 168h)", raw)
+		}
+		leadRecoveryWorker.MaxAge = maxAge
+	}
+	if last := leadRecoveryWorker.Cadence[len(leadRecoveryWorker.Cadence)-1]; leadRecoveryWorker.MaxAge <= last {
+		log.Fatalf("❌ LEAD_RECOVERY_MAX_AGE (%s) precisa ser maior que a última etapa da cadência (%s)", leadRecoveryWorker.MaxAge, last)
+	}
+	leadRecoveryWorker.Schedule(scheduler)
+
+	var deactivationPublisher usecase.DeactivationPublisher = &noopQueueProducer{}
+	if rabbitMQ != nil {
+		deactivationPublisher = queue.NewProducer(rabbitMQ)
+	}
+	lifecycleUC := usecase.NewSubscriptionLifecycleUseCase(subRepo, customerRepo, planRepo, dependentRepo, deactivationPublisher)
+	lifecycleUC.Outbox = outboxRepo
+
+	cancelSubUC := usecase.NewCancelSubscriptionUseCase(subRepo, customerRepo, planRepo, cancellationRepo, gateway, lifecycleUC)
+	cancelSubUC.EmailService = mailSender
+	cancelSubUC.RetentionCouponCode = os.Getenv("RETENTION_COUPON_CODE")
+
+	worker.NewScheduledCancellationWorker(cancellationRepo, lifecycleUC).Schedule(scheduler)
+	runInBackground(&background, func() { scheduler.Start(ctx) })
+
+	var planChangePublisher usecase.PlanChangePublisher = &noopQueueProducer{}
+	if rabbitMQ != nil {
+		planChangePublisher = queue.NewProducer(rabbitMQ)
+	}
+	changePlanUC := usecase.NewChangePlanUseCase(subRepo, customerRepo, planRepo, dependentRepo, subRepo, gateway, planChangePublisher)
+	changePlanUC.ContractUC = activateSubUC.ContractUC
+	changePlanUC.DocuSealUseCase = docuSealUseCase
+	changePlanUC.Coupons = couponRepo
+
+	// Inclusões, baixas e correções de dependentes vão para o outbox junto com a gravação.
+	manageDependentsUC := usecase.NewManageDependentsUseCase(subRepo, customerRepo, planRepo, dependentRepo, dependentRepo, gateway)
+	manageDependentsUC.EmailService = mailSender
+
+	processWebhookUC := usecase.NewProcessWebhookEventUseCase(customerRepo, activateSubUC, lifecycleUC)
+	processWebhookUC.CouponCycles = usecase.NewCouponCyclesUseCase(subRepo, gateway)
+	webhookDispatcher := worker.NewWebhookDispatcher(webhookEventRepo, processWebhookUC)
+	if raw := strings.TrimSpace(os.Getenv("WEBHOOK_MAX_ATTEMPTS")); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
+			webhookDispatcher.MaxAttempts = parsed
+		}
+	}
+	runInBackground(&background, func() { webhookDispatcher.Start(ctx) })
+
 	// 7. Handlers (Controllers HTTP)
 	customerHandler := handlers.NewCustomerHandler(createCustomerUC, subRepo, customerRepo)
-	webhookHandler := handlers.NewWebhookHandler(customerRepo, activateSubUC)
+	customerHandler.CardGateway = gateway
+	webhookHandler := handlers.NewWebhookHandler(webhookEventRepo)
 	docusealWebhookHandler := handlers.NewDocuSealWebhookHandler(docuSealClient, mailSender)
 	docusealTestHandler := handlers.NewDocuSealTestHandler(docuSealClient)
 	docusealStatusHandler := handlers.NewDocuSealStatusHandler(docuSealClient)
 	validationHandler := handlers.NewValidationHandler(customerRepo)
 	leadHandler := handlers.NewLeadHandler(leadRepo)
-	var rabbitMQConn *amqp091.Connection
+	var rabbitMQStatus handlers.RabbitMQStatus
 	if rabbitMQ != nil {
-		rabbitMQConn = rabbitMQ.Conn
+		rabbitMQStatus = rabbitMQ
 	}
-	healthHandler := handlers.NewHealthHandler(db, rabbitMQConn)
+	healthHandler := handlers.NewHealthHandler(db, rabbitMQStatus)
 	emailHandler := handlers.NewEmailHandler(mailSender)
-	couponHandler := handlers.NewCouponHandler()
+	couponHandler := handlers.NewCouponHandler(planRepo)
+	couponAdminHandler := handlers.NewCouponAdminHandler(couponRepo)
+	planHandler := handlers.NewPlanHandler(planRepo)
+	planChangeHandler := handlers.NewPlanChangeHandler(changePlanUC)
+	cancellationHandler := handlers.NewCancellationHandler(cancelSubUC)
+	dependentHandler := handlers.NewDependentHandler(manageDependentsUC)
 
 	// 8. Roteamento (Chi)
 	r := chi.NewRouter()
@@ -219,13 +382,31 @@ func main() {
 	r.Get("/customers/{id}/status", customerHandler.GetStatusHandler)
 	r.Post("/customers/status", customerHandler.PostStatusHandler)
 	r.Post("/asaas/webhook", webhookHandler.Handle)
+	r.With(httpMiddleware.RequireAdminToken).Post("/asaas/webhook/events/{id}/replay", webhookHandler.Replay)
 	r.Post("/docuseal/webhook", docusealWebhookHandler.Handle)
 	r.Post("/docuseal/test", docusealTestHandler.Handle)
 	r.Post("/docuseal/status", docusealStatusHandler.Handle)
 	r.Post("/validate-user", validationHandler.Handle)
 	r.Post("/leads/capture", leadHandler.CaptureLead)
+	r.Get("/leads/{id}/unsubscribe", leadHandler.Unsubscribe)
+	r.Post("/leads/{id}/unsubscribe", leadHandler.Unsubscribe)
+	r.With(httpMiddleware.RequireAdminToken).Get("/admin/reports/funnel", leadHandler.FunnelReport)
 	r.Post("/test-email", emailHandler.SendTestWelcomeEmail)
 	r.Post("/coupons/validate", couponHandler.Validate)
+	r.Get("/plans", planHandler.List)
+	r.With(httpMiddleware.RequireAdminToken).Post("/admin/customers/{id}/change-plan", planChangeHandler.ChangePlan)
+	r.With(httpMiddleware.RequireAdminToken).Post("/admin/customers/{id}/change-plan/abandon", planChangeHandler.AbandonPlanChange)
+	r.With(httpMiddleware.RequireAdminToken).Post("/admin/customers/{id}/cancel", cancellationHandler.Cancel)
+	r.With(httpMiddleware.RequireAdminToken).Get("/admin/customers/{id}/dependents", dependentHandler.List)
+	r.With(httpMiddleware.RequireAdminToken).Post("/admin/customers/{id}/dependents", dependentHandler.Add)
+	r.With(httpMiddleware.RequireAdminToken).Put("/admin/customers/{id}/dependents/{dependentId}", dependentHandler.Update)
+	r.With(httpMiddleware.RequireAdminToken).Delete("/admin/customers/{id}/dependents/{dependentId}", dependentHandler.Remove)
+	r.With(httpMiddleware.RequireAdminToken).Get("/admin/coupons", couponAdminHandler.List)
+	r.With(httpMiddleware.RequireAdminToken).Post("/admin/coupons", couponAdminHandler.Create)
+	r.With(httpMiddleware.RequireAdminToken).Get("/admin/coupons/{code}", couponAdminHandler.Get)
+	r.With(httpMiddleware.RequireAdminToken).Patch("/admin/coupons/{code}", couponAdminHandler.Update)
+	r.With(httpMiddleware.RequireAdminToken).Delete("/admin/coupons/{code}", couponAdminHandler.Deactivate)
+	r.With(httpMiddleware.RequireAdminToken).Get("/admin/reports/sellers", couponAdminHandler.SellerReport)
 
 	// Health Checks
 	r.Get("/health", healthHandler.Handle)
@@ -234,16 +415,7 @@ func main() {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{"status":"healthy"}`))
 	})
-	r.Get("/ready", func(w http.ResponseWriter, r *http.Request) {
-		if err := db.Ping(); err != nil {
-			w.WriteHeader(http.StatusServiceUnavailable)
-			w.Write([]byte(`{"status":"unhealthy","reason":"database"}`))
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"ready"}`))
-	})
+	r.Get("/ready", healthHandler.Ready)
 
 	// 9. Start do Servidor
 	port := ":8080"
@@ -253,10 +425,54 @@ func main() {
 		}
 		port = rawPort
 	}
-	log.Printf("🚀 Server CorePay rodando na porta %s", port)
-	if err := http.ListenAndServe(port, r); err != nil {
+	server := &http.Server{Addr: port, Handler: r}
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("🚀 Server CorePay rodando na porta %s", port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
 		log.Fatalf("❌ Falha fatal no servidor HTTP: %v", err)
+	case <-ctx.Done():
 	}
+	stop() // um segundo sinal volta a encerrar o processo na hora
+
+	// 10. Encerramento gracioso
+	log.Println("🛑 Sinal de encerramento recebido, drenando...")
+	healthHandler.SetDraining(true)
+	time.Sleep(readinessDrainDelay)
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("⚠️ Servidor HTTP não encerrou a tempo: %v", err)
+	} else {
+		log.Println("✅ Servidor HTTP encerrado")
+	}
+
+	workersDone := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+		log.Println("✅ Workers encerrados")
+	case <-shutdownCtx.Done():
+		log.Println("⚠️ Workers não terminaram a tempo; mensagens sem ack voltam para a fila")
+	}
+}
+
+// runInBackground roda um worker de longa duração registrado no WaitGroup do encerramento.
+func runInBackground(wg *sync.WaitGroup, run func()) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		run()
+	}()
 }
 
 // ==========================================
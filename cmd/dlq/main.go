@@ -0,0 +1,204 @@
+// Inspeciona e reprocessa mensagens mortas de ativação (q.activations.dlq ou q.activations.parking).
+//
+// Listar tudo da DLQ:
+//   go run ./cmd/dlq
+//
+// Filtrar por provedor e período (datas YYYY-MM-DD ou RFC3339):
+//   go run ./cmd/dlq --provider=DOC24 --since=2026-10-01 --until=2026-10-15
+//
+// Ver o que seria reprocessado, sem publicar:
+//   go run ./cmd/dlq --replay --customer=UUID1,UUID2 --dry-run
+//
+// Reprocessar tudo que estiver estacionado para a TEM:
+//   go run ./cmd/dlq --queue=q.activations.parking --provider=TEM --replay --all
+//
+// Mensagens não selecionadas voltam para a fila de origem ao final da execução.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joho/godotenv"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/xavierca1/ligue-payments/internal/infra/queue"
+)
+
+func main() {
+	_ = godotenv.Load()
+
+	queueName := flag.String("queue", queue.DLQName, "Fila a inspecionar (q.activations.dlq ou q.activations.parking)")
+	provider := flag.String("provider", "", "Filtra pelo provedor (DOC24, TEM...)")
+	since := flag.String("since", "", "Falhas a partir desta data (YYYY-MM-DD ou RFC3339)")
+	until := flag.String("until", "", "Falhas até esta data (YYYY-MM-DD ou RFC3339)")
+	customers := flag.String("customer", "", "IDs de customer separados por vírgula")
+	replay := flag.Bool("replay", false, "Republica as mensagens selecionadas em ex.checkout")
+	all := flag.Bool("all", false, "Com --replay, seleciona todas as mensagens que passarem nos filtros")
+	dryRun := flag.Bool("dry-run", false, "Mostra o que seria reprocessado sem publicar nem remover nada")
+	limit := flag.Int("limit", 0, "Máximo de mensagens lidas (0 = fila inteira)")
+	flag.Parse()
+
+	filter := queue.DeadLetterFilter{Provider: strings.TrimSpace(*provider)}
+	var err error
+	if filter.Since, err = parseDate(*since, false); err != nil {
+		log.Fatalf("--since inválido: %v", err)
+	}
+	if filter.Until, err = parseDate(*until, true); err != nil {
+		log.Fatalf("--until inválido: %v", err)
+	}
+	for _, id := range strings.Split(*customers, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			filter.CustomerIDs = append(filter.CustomerIDs, id)
+		}
+	}
+
+	if *replay && len(filter.CustomerIDs) == 0 && !*all {
+		log.Fatal("--replay exige --customer=<ids> ou --all")
+	}
+
+	rabbitMQ, err := queue.NewRabbitMQ(
+		os.Getenv("RABBITMQ_USER"),
+		os.Getenv("RABBITMQ_PASS"),
+		os.Getenv("RABBITMQ_HOST"),
+		os.Getenv("RABBITMQ_PORT"),
+	)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	defer rabbitMQ.Conn.Close()
+	defer rabbitMQ.Ch.Close()
+
+	ch := rabbitMQ.Ch
+	if *replay && !*dryRun {
+		if err := ch.Confirm(false); err != nil {
+			log.Fatalf("❌ Falha ao habilitar publisher confirms: %v", err)
+		}
+	}
+
+	state, err := ch.QueueInspect(*queueName)
+	if err != nil {
+		log.Fatalf("❌ Fila %s indisponível: %v", *queueName, err)
+	}
+	total := state.Messages
+	if *limit > 0 && *limit < total {
+		total = *limit
+	}
+
+	// As mensagens ficam sem ack durante a execução para que o basic.get não as devolva de novo;
+	// o que não for reprocessado volta para a fila com Nack(requeue=true).
+	var held []amqp.Delivery
+	defer func() {
+		for _, d := range held {
+			d.Nack(false, true)
+		}
+	}()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TAG\tTIPO\tCUSTOMER\tPROVIDER\tTENTATIVAS\tFALHOU EM\tMOTIVO\tAÇÃO")
+
+	matched, replayed := 0, 0
+	for i := 0; i < total; i++ {
+		d, ok, err := ch.Get(*queueName, false)
+		if err != nil {
+			log.Fatalf("❌ Falha ao ler %s: %v", *queueName, err)
+		}
+		if !ok {
+			break
+		}
+
+		dl := queue.InspectDeadLetter(d)
+		if !filter.Match(dl) {
+			held = append(held, d)
+			continue
+		}
+		matched++
+
+		action := "-"
+		switch {
+		case *replay && *dryRun:
+			action = "reprocessaria"
+			held = append(held, d)
+		case *replay:
+			if err := republish(ch, d); err != nil {
+				action = "erro: " + err.Error()
+				held = append(held, d)
+			} else {
+				action = "reprocessada"
+				replayed++
+				d.Ack(false)
+			}
+		default:
+			held = append(held, d)
+		}
+
+		failedAt := "-"
+		if !dl.FailedAt.IsZero() {
+			failedAt = dl.FailedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			dl.DeliveryTag, dl.Type, dl.CustomerID, dl.Provider, dl.Attempts, failedAt, truncate(dl.Reason, 80), action)
+	}
+	w.Flush()
+
+	fmt.Printf("\n%s: %d lidas, %d selecionadas", *queueName, total, matched)
+	if *replay {
+		if *dryRun {
+			fmt.Print(" (dry-run: nada foi publicado)")
+		} else {
+			fmt.Printf(", %d reprocessadas em %s", replayed, queue.ExchangeName)
+		}
+	}
+	fmt.Println()
+}
+
+// republish publica em ex.checkout e só retorna nil após o ack do broker.
+func republish(ch *amqp.Channel, d amqp.Delivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	confirmation, err := ch.PublishWithDeferredConfirmWithContext(ctx, queue.ExchangeName, queue.RoutingKey, false, false, queue.ReplayPublishing(d))
+	if err != nil {
+		return err
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return fmt.Errorf("broker recusou a mensagem")
+	}
+	return nil
+}
+
+func parseDate(value string, endOfDay bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
+
+func truncate(value string, max int) string {
+	runes := []rune(strings.ReplaceAll(value, "\n", " "))
+	if len(runes) <= max {
+		return string(runes)
+	}
+	return string(runes[:max-3]) + "..."
+}
@@ -0,0 +1,89 @@
+package entity
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Motivos de cancelamento informados pelo cliente.
+const (
+	CancellationReasonPrice          = "PRICE"
+	CancellationReasonNotUsing       = "NOT_USING"
+	CancellationReasonServiceQuality = "SERVICE_QUALITY"
+	CancellationReasonOtherProvider  = "OTHER_PROVIDER"
+	CancellationReasonFinancial      = "FINANCIAL"
+	CancellationReasonOther          = "OTHER"
+)
+
+var cancellationReasons = map[string]bool{
+	CancellationReasonPrice:          true,
+	CancellationReasonNotUsing:       true,
+	CancellationReasonServiceQuality: true,
+	CancellationReasonOtherProvider:  true,
+	CancellationReasonFinancial:      true,
+	CancellationReasonOther:          true,
+}
+
+// IsValidCancellationReason indica se o motivo é um dos códigos aceitos.
+func IsValidCancellationReason(reason string) bool {
+	return cancellationReasons[strings.ToUpper(strings.TrimSpace(reason))]
+}
+
+// Resultado de cada pedido de cancelamento.
+const (
+	CancellationRetentionOffered = "RETENTION_OFFERED" // cliente viu a oferta e ainda não decidiu
+	CancellationRetained         = "RETAINED"          // aceitou o cupom de retenção
+	CancellationScheduled        = "SCHEDULED"         // cancela no fim do ciclo pago
+	CancellationCanceled         = "CANCELED"          // cancelada na hora
+)
+
+// SubscriptionCancellation registra cada pedido de cancelamento, com o motivo e o desfecho.
+type SubscriptionCancellation struct {
+	ID                  string     `json:"id"`
+	SubscriptionID      string     `json:"subscription_id"`
+	CustomerID          string     `json:"customer_id"`
+	Reason              string     `json:"reason"`
+	Comment             string     `json:"comment,omitempty"`
+	Outcome             string     `json:"outcome"`
+	RetentionCouponCode string     `json:"retention_coupon_code,omitempty"`
+	EffectiveAt         *time.Time `json:"effective_at,omitempty"` // fim do acesso (SCHEDULED/CANCELED)
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+func NewSubscriptionCancellation(sub *Subscription, reason, comment, outcome string) *SubscriptionCancellation {
+	return &SubscriptionCancellation{
+		ID:             uuid.New().String(),
+		SubscriptionID: sub.ID,
+		CustomerID:     sub.CustomerID,
+		Reason:         strings.ToUpper(strings.TrimSpace(reason)),
+		Comment:        strings.TrimSpace(comment),
+		Outcome:        outcome,
+		CreatedAt:      time.Now(),
+	}
+}
+
+// RetentionDiscount é o novo valor da assinatura depois de aceitar o cupom de retenção.
+// Cycles nil mantém o desconto em todas as cobranças.
+type RetentionDiscount struct {
+	AmountCents     int
+	FullAmountCents int
+	Cycles          *int
+}
+
+type CancellationRepositoryInterface interface {
+	// Save grava o pedido de cancelamento (oferta exibida ou cancelamento imediato).
+	Save(ctx context.Context, cancellation *SubscriptionCancellation) error
+	// RetentionAccepted indica se a assinatura já foi retida com cupom uma vez.
+	RetentionAccepted(ctx context.Context, subscriptionID string) (bool, error)
+	// ApplyRetention aplica o desconto de retenção na assinatura e grava o pedido.
+	ApplyRetention(ctx context.Context, cancellation *SubscriptionCancellation, discount RetentionDiscount) error
+	// ScheduleCancellation grava subscriptions.cancel_at = EffectiveAt e o pedido.
+	ScheduleCancellation(ctx context.Context, cancellation *SubscriptionCancellation) error
+	// UnscheduleCancellation desfaz o ScheduleCancellation quando a remoção no Asaas falha.
+	UnscheduleCancellation(ctx context.Context, cancellation *SubscriptionCancellation) error
+	// FindDueCancellations retorna assinaturas com cancel_at vencido ainda não canceladas.
+	FindDueCancellations(ctx context.Context, now time.Time, limit int) ([]*Subscription, error)
+}
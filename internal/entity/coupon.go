@@ -0,0 +1,117 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	ErrCouponNotFound      = errors.New("cupom não encontrado")
+	ErrCouponAlreadyExists = errors.New("cupom já existe")
+	ErrCouponExhausted     = errors.New("cupom esgotado")
+	ErrCouponAlreadyUsed   = errors.New("cupom já utilizado por este CPF")
+)
+
+const (
+	CouponDiscountPercent = "PERCENT"
+	CouponDiscountFixed   = "FIXED"
+)
+
+var couponCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,64}$`)
+
+// Coupon é o cadastro do cupom administrado em /admin/coupons. O código é a chave usada
+// em coupon_sales e não muda depois de criado.
+type Coupon struct {
+	ID                  string     `json:"id"`
+	Code                string     `json:"code"`
+	SellerName          string     `json:"seller_name"`
+	DiscountType        string     `json:"discount_type"` // PERCENT ou FIXED
+	DiscountPercent     int        `json:"discount_percent"`
+	DiscountAmountCents int        `json:"discount_amount_cents"`
+	PlanIDs             []string   `json:"plan_ids"`
+	ProductIDs          []string   `json:"product_ids"`
+	MaxRedemptions      int        `json:"max_redemptions"`         // 0 = sem limite
+	MaxPerCPF           int        `json:"max_redemptions_per_cpf"` // 0 = sem limite
+	DurationCycles      int        `json:"duration_cycles"`         // 0 = todas as cobranças
+	IsActive            bool       `json:"is_active"`
+	StartsAt            *time.Time `json:"starts_at,omitempty"`
+	EndsAt              *time.Time `json:"ends_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// Normalize padroniza código, vendedor, tipo e escopo antes de validar e gravar.
+func (c *Coupon) Normalize() {
+	c.Code = strings.ToUpper(strings.TrimSpace(c.Code))
+	c.SellerName = strings.TrimSpace(c.SellerName)
+	c.DiscountType = strings.ToUpper(strings.TrimSpace(c.DiscountType))
+	if c.DiscountType == "" {
+		c.DiscountType = CouponDiscountPercent
+	}
+	c.PlanIDs = compactIDs(c.PlanIDs)
+	c.ProductIDs = compactIDs(c.ProductIDs)
+}
+
+func (c *Coupon) Validate() error {
+	if !couponCodePattern.MatchString(c.Code) {
+		return errors.New("code deve ter de 3 a 64 caracteres (letras, números, _ ou -)")
+	}
+	if c.SellerName == "" {
+		return errors.New("seller_name é obrigatório")
+	}
+
+	switch c.DiscountType {
+	case CouponDiscountPercent:
+		if c.DiscountPercent < 1 || c.DiscountPercent > 100 {
+			return errors.New("discount_percent deve estar entre 1 e 100")
+		}
+	case CouponDiscountFixed:
+		if c.DiscountAmountCents <= 0 {
+			return errors.New("discount_amount_cents deve ser maior que zero")
+		}
+	default:
+		return fmt.Errorf("discount_type deve ser %s ou %s", CouponDiscountPercent, CouponDiscountFixed)
+	}
+
+	if c.MaxRedemptions < 0 || c.MaxPerCPF < 0 || c.DurationCycles < 0 {
+		return errors.New("limites e duration_cycles não podem ser negativos")
+	}
+	if c.StartsAt != nil && c.EndsAt != nil && !c.EndsAt.After(*c.StartsAt) {
+		return errors.New("ends_at deve ser posterior a starts_at")
+	}
+	return nil
+}
+
+func compactIDs(ids []string) []string {
+	compacted := []string{}
+	for _, id := range ids {
+		if id = strings.TrimSpace(id); id != "" {
+			compacted = append(compacted, id)
+		}
+	}
+	return compacted
+}
+
+// SellerSalesRow agrega as vendas com cupom de um vendedor no período.
+type SellerSalesRow struct {
+	SellerName    string `json:"seller_name"`
+	Sales         int    `json:"sales"`
+	GrossCents    int64  `json:"gross_cents"`
+	DiscountCents int64  `json:"discount_cents"`
+	NetCents      int64  `json:"net_cents"`
+}
+
+type CouponRepositoryInterface interface {
+	// Create retorna ErrCouponAlreadyExists se o código já estiver em uso.
+	Create(ctx context.Context, coupon *Coupon) error
+	// Update grava todos os campos editáveis; o código não muda.
+	Update(ctx context.Context, coupon *Coupon) error
+	FindByCode(ctx context.Context, code string) (*Coupon, error)
+	List(ctx context.Context, activeOnly bool) ([]*Coupon, error)
+	// SellerReport considera só vendas cuja assinatura chegou a ficar ACTIVE.
+	SellerReport(ctx context.Context, from, to time.Time) ([]SellerSalesRow, error)
+}
@@ -33,18 +33,27 @@ type Customer struct {
 
 	ProductID string `json:"product_id"`
 
-	GatewayID      string    `json:"gateway_id"`
-	SubscriptionID string    `json:"subscription_id"`
-	ProviderID     string    `json:"provider_id"`
-	Status         string    `json:"status"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	GatewayID      string          `json:"gateway_id"`
+	SubscriptionID string          `json:"subscription_id"`
+	ProviderID     string          `json:"provider_id"`
+	Status         string          `json:"status"`
+	Card           CreditCardToken `json:"card"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
 
 	TermsAccepted   bool      `json:"terms_accepted"`
 	TermsAcceptedAt time.Time `json:"terms_accepted_at"`
 	TermsVersion    string    `json:"terms_version"`
 }
 
+// CreditCardToken é o cartão tokenizado no Asaas para o cliente. Só o token e os dados de
+// exibição ficam conosco; número e CVV nunca saem do handler do checkout.
+type CreditCardToken struct {
+	Token      string `json:"-"`
+	Brand      string `json:"brand,omitempty"`
+	LastDigits string `json:"last_digits,omitempty"`
+}
+
 func NewCustomer(name, email, cpf, phone, birthDate string, gender int, address Address) (*Customer, error) {
 	customer := &Customer{
 		ID:        uuid.New().String(),
@@ -96,6 +105,12 @@ type CustomerRepositoryInterface interface {
 	UpdateGatewayID(ctx context.Context, customerID, gatewayID string) error
 	UpdateStatus(ctx context.Context, customerID, status string) error
 }
+
+// CustomerCardRepositoryInterface guarda o cartão tokenizado para as próximas compras do cliente.
+type CustomerCardRepositoryInterface interface {
+	SaveCardToken(ctx context.Context, customerID string, card CreditCardToken) error
+}
+
 type PlanRepositoryInterface interface {
 	FindByID(ctx context.Context, id string) (*Plan, error)
 }
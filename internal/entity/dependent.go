@@ -18,11 +18,55 @@ type Dependent struct {
 	CPF                 string    `json:"cpf"`
 	BirthDate           string    `json:"birth_date"` // Formato: YYYY-MM-DD
 	Gender              int       `json:"gender"`     // 1=Masculino, 2=Feminino, 3=Outro
-	Kinship             string    `json:"kinship"`    // FILHO, CONJUGE, PAI, MAE, etc
+	Kinship             string    `json:"kinship"`    // FILHO, ENTEADO, CONJUGE, PAI ou MAE
+	Student             bool      `json:"student"`    // filho/enteado estudante: limite de idade maior
 	CreatedAt           time.Time `json:"created_at"`
 	UpdatedAt           time.Time `json:"updated_at"`
 }
 
+// Parentescos aceitos para dependentes.
+const (
+	KinshipChild     = "FILHO"
+	KinshipStepchild = "ENTEADO"
+	KinshipSpouse    = "CONJUGE"
+	KinshipFather    = "PAI"
+	KinshipMother    = "MAE"
+)
+
+var validKinships = map[string]bool{
+	KinshipChild:     true,
+	KinshipStepchild: true,
+	KinshipSpouse:    true,
+	KinshipFather:    true,
+	KinshipMother:    true,
+}
+
+var kinshipAliases = map[string]string{
+	"FILHA":       KinshipChild,
+	"ENTEADA":     KinshipStepchild,
+	"ESPOSA":      KinshipSpouse,
+	"ESPOSO":      KinshipSpouse,
+	"COMPANHEIRA": KinshipSpouse,
+	"COMPANHEIRO": KinshipSpouse,
+}
+
+var accentReplacer = strings.NewReplacer("Á", "A", "Â", "A", "Ã", "A", "É", "E", "Ê", "E", "Í", "I", "Ó", "O", "Ô", "O", "Õ", "O", "Ú", "U", "Ç", "C")
+
+// NormalizeKinship padroniza o parentesco informado: maiúsculas, sem acento e com
+// femininos e sinônimos mapeados (FILHA → FILHO, CÔNJUGE/ESPOSA → CONJUGE, MÃE → MAE).
+func NormalizeKinship(kinship string) string {
+	k := accentReplacer.Replace(strings.ToUpper(strings.TrimSpace(kinship)))
+	if alias, ok := kinshipAliases[k]; ok {
+		return alias
+	}
+	return k
+}
+
+// IsValidKinship indica se o parentesco, depois de normalizado, é um dos aceitos.
+func IsValidKinship(kinship string) bool {
+	return validKinships[NormalizeKinship(kinship)]
+}
+
 // NewDependent cria um novo dependente com validações básicas
 func NewDependent(customerID, nroDocumentoTitular, name, cpf, birthDate string, gender int, kinship string) (*Dependent, error) {
 	if customerID == "" {
@@ -46,6 +90,9 @@ func NewDependent(customerID, nroDocumentoTitular, name, cpf, birthDate string,
 	if kinship == "" {
 		return nil, errors.New("kinship é obrigatório")
 	}
+	if !IsValidKinship(kinship) {
+		return nil, errors.New("kinship deve ser FILHO, ENTEADO, CONJUGE, PAI ou MAE")
+	}
 
 	return &Dependent{
 		ID:                  uuid.New().String(),
@@ -55,7 +102,7 @@ func NewDependent(customerID, nroDocumentoTitular, name, cpf, birthDate string,
 		CPF:                 cpf,
 		BirthDate:           birthDate,
 		Gender:              gender,
-		Kinship:             kinship,
+		Kinship:             NormalizeKinship(kinship),
 		CreatedAt:           time.Now(),
 		UpdatedAt:           time.Now(),
 	}, nil
@@ -69,3 +116,30 @@ type DependentRepositoryInterface interface {
 	Delete(ctx context.Context, id string) error
 	Update(ctx context.Context, dependent *Dependent) error
 }
+
+// ErrDependentsChanged indica que o valor da assinatura ou os dependentes mudaram (outra
+// inclusão/remoção concorrente) entre a leitura e a gravação.
+var ErrDependentsChanged = errors.New("dependentes ou valor da assinatura alterados durante a operação")
+
+// DependentSubscriptionChange é a inclusão ou remoção de um dependente numa assinatura ativa.
+// ExpectedAmountCents e ExpectedDependents são os valores lidos antes da validação do limite e
+// do ajuste no gateway; a gravação confere os dois com a assinatura travada.
+type DependentSubscriptionChange struct {
+	Dependent           *Dependent
+	SubscriptionID      string
+	DeltaCents          int
+	ExpectedAmountCents int
+	ExpectedDependents  int
+	Events              []*OutboxEvent // provisionamento no provedor
+}
+
+// DependentChangeRepositoryInterface inclui, remove ou corrige um dependente de uma assinatura
+// ativa. Na mesma transação ajusta o valor da assinatura pela cobrança de dependente extra e
+// grava no outbox os eventos de provisionamento, para o provedor não ficar para trás do Asaas.
+type DependentChangeRepositoryInterface interface {
+	// AddToSubscription e RemoveFromSubscription travam a assinatura (SELECT ... FOR UPDATE) e
+	// retornam ErrDependentsChanged se o valor ou a quantidade de dependentes não são mais os esperados.
+	AddToSubscription(ctx context.Context, change DependentSubscriptionChange) error
+	RemoveFromSubscription(ctx context.Context, change DependentSubscriptionChange) error
+	UpdateWithEvents(ctx context.Context, dependent *Dependent, events []*OutboxEvent) error
+}
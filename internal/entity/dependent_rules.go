@@ -0,0 +1,118 @@
+package entity
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DependentRules são as regras de elegibilidade de dependentes de um plano. Valor zero
+// desliga a regra; os padrões (filhos até 21 anos ou 24 se estudantes, um cônjuge e pais
+// só nos planos família) vêm das colunas da migration 017.
+type DependentRules struct {
+	ChildMaxAge        int      // filhos e enteados precisam ter menos que essa idade
+	StudentChildMaxAge int      // idem para estudantes; 0 usa ChildMaxAge
+	MaxSpouses         int      // cônjuges por titular
+	AllowedKinships    []string // parentescos aceitos no plano; vazio = todos
+}
+
+// AllowsKinship indica se o plano aceita dependentes com esse parentesco.
+func (r DependentRules) AllowsKinship(kinship string) bool {
+	if len(r.AllowedKinships) == 0 {
+		return true
+	}
+	kinship = NormalizeKinship(kinship)
+	for _, allowed := range r.AllowedKinships {
+		if NormalizeKinship(allowed) == kinship {
+			return true
+		}
+	}
+	return false
+}
+
+// childMaxAge retorna a idade limite de um filho ou enteado; 0 = sem limite.
+func (r DependentRules) childMaxAge(student bool) int {
+	if student && r.StudentChildMaxAge > 0 {
+		return r.StudentChildMaxAge
+	}
+	return r.ChildMaxAge
+}
+
+// DependentViolation é uma regra de elegibilidade descumprida pelo dependente Index.
+type DependentViolation struct {
+	Index   int
+	Field   string
+	Message string
+}
+
+var nonDigits = regexp.MustCompile(`\D`)
+
+// CheckDependents confere a lista de dependentes do titular contra as regras do plano:
+// parentesco do enum e aceito no plano, idade de filhos e enteados, quantidade de cônjuges e
+// CPF único entre titular e dependentes. Cônjuges e CPFs excedentes são atribuídos às últimas
+// ocorrências da lista.
+func (p *Plan) CheckDependents(titularCPF string, dependents []*Dependent, now time.Time) []DependentViolation {
+	var violations []DependentViolation
+	rules := p.DependentRules
+	seenCPFs := map[string]bool{nonDigits.ReplaceAllString(titularCPF, ""): true}
+	spouses := 0
+
+	for i, dep := range dependents {
+		kinship := NormalizeKinship(dep.Kinship)
+
+		cpf := nonDigits.ReplaceAllString(dep.CPF, "")
+		if cpf != "" && seenCPFs[cpf] {
+			violations = append(violations, DependentViolation{Index: i, Field: "cpf", Message: "CPF já informado para o titular ou outro dependente"})
+		}
+		seenCPFs[cpf] = true
+
+		if !validKinships[kinship] {
+			violations = append(violations, DependentViolation{Index: i, Field: "kinship", Message: "deve ser FILHO, ENTEADO, CONJUGE, PAI ou MAE"})
+			continue
+		}
+		if !rules.AllowsKinship(kinship) {
+			violations = append(violations, DependentViolation{Index: i, Field: "kinship", Message: fmt.Sprintf("o plano não aceita dependentes com parentesco %s", kinship)})
+			continue
+		}
+
+		switch kinship {
+		case KinshipSpouse:
+			spouses++
+			if rules.MaxSpouses > 0 && spouses > rules.MaxSpouses {
+				violations = append(violations, DependentViolation{Index: i, Field: "kinship", Message: fmt.Sprintf("o plano permite no máximo %d cônjuge(s)", rules.MaxSpouses)})
+			}
+		case KinshipChild, KinshipStepchild:
+			limit := rules.childMaxAge(dep.Student)
+			if limit == 0 {
+				continue
+			}
+			age, ok := ageAt(dep.BirthDate, now)
+			if !ok {
+				continue
+			}
+			if age >= limit {
+				message := fmt.Sprintf("filhos e enteados devem ter menos de %d anos", limit)
+				if !dep.Student && rules.StudentChildMaxAge > limit {
+					message += fmt.Sprintf(" (%d se estudantes)", rules.StudentChildMaxAge)
+				}
+				violations = append(violations, DependentViolation{Index: i, Field: "birth_date", Message: message})
+			}
+		}
+	}
+
+	return violations
+}
+
+// ageAt calcula a idade em anos completos na data now.
+func ageAt(birthDate string, now time.Time) (int, bool) {
+	birth, err := time.Parse("2006-01-02", strings.TrimSpace(birthDate))
+	if err != nil {
+		return 0, false
+	}
+	age := now.Year() - birth.Year()
+	if now.Month() < birth.Month() || (now.Month() == birth.Month() && now.Day() < birth.Day()) {
+		age--
+	}
+	return age, true
+}
@@ -2,9 +2,12 @@ package entity
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+var ErrLeadNotFound = errors.New("lead não encontrado")
+
 
 type Lead struct {
 	ID              string     `json:"id"`
@@ -14,12 +17,72 @@ type Lead struct {
 	Status          string     `json:"status"` // PENDING, RECOVERED, CONVERTED
 	EmailStage      int        `json:"email_stage"`
 	LastEmailSentAt *time.Time `json:"last_email_sent_at,omitempty"`
+	PlanID          string     `json:"plan_id,omitempty"`     // plano escolhido no checkout
+	CheckoutAt      *time.Time `json:"checkout_at,omitempty"` // primeiro checkout com este e-mail
+	ConvertedAt     *time.Time `json:"converted_at,omitempty"`
+	UnsubscribedAt  *time.Time `json:"unsubscribed_at,omitempty"` // pediu para sair da cadência de recuperação
 	CreatedAt       time.Time  `json:"created_at"`
 	UpdatedAt       time.Time  `json:"updated_at"`
+
+	LeadAttribution
+}
+
+
+// LeadAttribution é a origem do lead (UTMs e página de entrada), enviada pelo front
+// na captura e no checkout. A atribuição é first-touch: a primeira origem gravada é mantida.
+type LeadAttribution struct {
+	UTMSource   string `json:"utm_source,omitempty"`
+	UTMMedium   string `json:"utm_medium,omitempty"`
+	UTMCampaign string `json:"utm_campaign,omitempty"`
+	UTMContent  string `json:"utm_content,omitempty"`
+	UTMTerm     string `json:"utm_term,omitempty"`
+	LandingPage string `json:"landing_page,omitempty"`
 }
 
+// LeadFunnelRow agrega captura → checkout → pagamento de uma origem e plano.
+type LeadFunnelRow struct {
+	Source    string `json:"source"`
+	PlanID    string `json:"plan_id"`
+	PlanName  string `json:"plan_name"`
+	Captured  int    `json:"captured"`
+	Checkout  int    `json:"checkout"`
+	Converted int    `json:"converted"`
+}
+
+const (
+	LeadStatusPending   = "PENDING"
+	LeadStatusRecovered = "RECOVERED"
+	LeadStatusConverted = "CONVERTED"
+)
+
+// DefaultLeadRecoveryCadence é o atraso de cada e-mail de recuperação, contado a partir
+// da captura do lead: a etapa 1 sai após 1h, a 2 após 24h e a 3 (última) após 72h.
+var DefaultLeadRecoveryCadence = []time.Duration{1 * time.Hour, 24 * time.Hour, 72 * time.Hour}
+
+// DefaultLeadRecoveryMaxAge limita a cadência a leads recentes: leads mais antigos (ex.: o
+// histórico PENDING no primeiro deploy) nunca recebem e-mail de recuperação.
+const DefaultLeadRecoveryMaxAge = 7 * 24 * time.Hour
 
 type LeadRepositoryInterface interface {
 
 	Upsert(ctx context.Context, lead *Lead) error
+	// AttachCheckout registra o checkout no lead do e-mail (criando o lead se não houver
+	// captura), preenche lead.ID e vincula o customer em customers.lead_id.
+	AttachCheckout(ctx context.Context, lead *Lead, customerID string) error
+	// FunnelReport agrega os leads capturados em [from, to) por origem e plano.
+	FunnelReport(ctx context.Context, from, to time.Time) ([]LeadFunnelRow, error)
+	// FindDueForRecovery busca leads PENDING e não descadastrados em email_stage = stage capturados
+	// há mais de sinceCapture (e há no máximo maxAge) e cujo último e-mail saiu há mais de sinceLastEmail.
+	FindDueForRecovery(ctx context.Context, stage int, sinceCapture, sinceLastEmail, maxAge time.Duration, limit int) ([]*Lead, error)
+	// AdvanceEmailStage avança de fromStage para fromStage+1; false se o lead mudou nesse meio tempo.
+	AdvanceEmailStage(ctx context.Context, id string, fromStage int) (bool, error)
+	// RevertEmailStage desfaz o AdvanceEmailStage de fromStage quando o envio falha,
+	// devolvendo o last_email_sent_at anterior.
+	RevertEmailStage(ctx context.Context, id string, fromStage int, lastEmailSentAt *time.Time) error
+	// Unsubscribe tira o lead da cadência de recuperação; ErrLeadNotFound se o ID não existe.
+	Unsubscribe(ctx context.Context, id string) error
+	// MarkConvertedByEmail marca como CONVERTED o lead do e-mail, se houver.
+	MarkConvertedByEmail(ctx context.Context, email string) error
+	// MarkConvertedFromActiveCustomers converte os leads cujo e-mail já é de um customer ACTIVE.
+	MarkConvertedFromActiveCustomers(ctx context.Context) (int64, error)
 }
@@ -0,0 +1,57 @@
+package entity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	OutboxEventPending    = "PENDING"
+	OutboxEventPublishing = "PUBLISHING"
+	OutboxEventSent       = "SENT"
+)
+
+// OutboxEvent é uma mensagem para o RabbitMQ gravada na mesma transação da mudança
+// de estado que a originou. O relay publica os eventos pendentes e os marca como SENT.
+type OutboxEvent struct {
+	ID            string     `json:"id"`
+	AggregateID   string     `json:"aggregate_id"` // customer_id
+	MessageType   string     `json:"message_type"` // activation, deactivation...
+	Payload       []byte     `json:"-"`
+	Status        string     `json:"status"` // PENDING, PUBLISHING, SENT
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func NewOutboxEvent(messageType, aggregateID string, payload interface{}) (*OutboxEvent, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao converter payload do outbox: %w", err)
+	}
+
+	return &OutboxEvent{
+		ID:          uuid.New().String(),
+		AggregateID: aggregateID,
+		MessageType: messageType,
+		Payload:     body,
+		Status:      OutboxEventPending,
+		CreatedAt:   time.Now(),
+	}, nil
+}
+
+type OutboxRepositoryInterface interface {
+	// UpdateStatusWithOutbox altera o status da assinatura e do cliente e grava o evento
+	// numa única transação: ou tudo é persistido, ou nada.
+	UpdateStatusWithOutbox(ctx context.Context, customerID, status, customerStatus string, event *OutboxEvent) error
+	// ClaimPending reserva até limit eventos prontos para publicação.
+	ClaimPending(ctx context.Context, limit int) ([]*OutboxEvent, error)
+	MarkSent(ctx context.Context, id string) error
+	MarkRetry(ctx context.Context, id string, attempts int, nextAttemptAt time.Time, reason string) error
+}
@@ -0,0 +1,35 @@
+package entity
+
+import "time"
+
+// DefaultPixExpirationWindow é a janela de pagamento do PIX quando nada é configurado.
+const DefaultPixExpirationWindow = 30 * time.Minute
+
+// PixExpirationPolicy define por quanto tempo um PIX gerado no checkout aguarda pagamento.
+// É a mesma regra usada no vencimento enviado ao Asaas, no pix_expires_at devolvido ao
+// front e no PixExpirationWorker.
+type PixExpirationPolicy struct {
+	Default time.Duration
+}
+
+func NewPixExpirationPolicy(defaultWindow time.Duration) PixExpirationPolicy {
+	if defaultWindow <= 0 {
+		defaultWindow = DefaultPixExpirationWindow
+	}
+	return PixExpirationPolicy{Default: defaultWindow}
+}
+
+// Window retorna a janela do plano (ou do produto dele) e, sem configuração, o padrão.
+func (p PixExpirationPolicy) Window(plan *Plan) time.Duration {
+	if plan != nil && plan.PixExpirationMinutes > 0 {
+		return time.Duration(plan.PixExpirationMinutes) * time.Minute
+	}
+	if p.Default > 0 {
+		return p.Default
+	}
+	return DefaultPixExpirationWindow
+}
+
+func (p PixExpirationPolicy) ExpiresAt(plan *Plan, from time.Time) time.Time {
+	return from.Add(p.Window(plan))
+}
@@ -0,0 +1,53 @@
+package entity
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Resultados da conciliação de um PIX pendente com o Asaas.
+const (
+	PixReconciliationActivated = "ACTIVATED" // pagamento encontrado: assinatura ativada
+	PixReconciliationExpired   = "EXPIRED"   // sem pagamento: assinatura cancelada no Asaas e expirada
+	PixReconciliationError     = "ERROR"     // falha na consulta/cancelamento; tenta de novo após o backoff
+)
+
+// PixReconciliation registra o que o worker de expiração fez com um PIX pendente.
+type PixReconciliation struct {
+	ID                    string    `json:"id"`
+	SubscriptionID        string    `json:"subscription_id"`
+	CustomerID            string    `json:"customer_id"`
+	GatewaySubscriptionID string    `json:"gateway_subscription_id"`
+	PaymentStatus         string    `json:"payment_status"` // status da cobrança no Asaas
+	Action                string    `json:"action"`         // ACTIVATED, EXPIRED, ERROR
+	Detail                string    `json:"detail,omitempty"`
+	CreatedAt             time.Time `json:"created_at"`
+}
+
+func NewPixReconciliation(sub *Subscription, paymentStatus, action, detail string) *PixReconciliation {
+	return &PixReconciliation{
+		ID:                    uuid.New().String(),
+		SubscriptionID:        sub.ID,
+		CustomerID:            sub.CustomerID,
+		GatewaySubscriptionID: sub.PaymentMethodID,
+		PaymentStatus:         paymentStatus,
+		Action:                action,
+		Detail:                detail,
+		CreatedAt:             time.Now(),
+	}
+}
+
+type PixReconciliationRepositoryInterface interface {
+	// FindStalePending retorna assinaturas PIX ainda PENDING com pix_expires_at vencido;
+	// assinaturas sem prazo gravado vencem olderThan após a criação. Ficam de fora as que
+	// estão em backoff ou já falharam maxAttempts vezes.
+	FindStalePending(ctx context.Context, olderThan time.Duration, maxAttempts, limit int) ([]*Subscription, error)
+	// Expire marca a assinatura como expirada se ela ainda estiver PENDING.
+	Expire(ctx context.Context, subscriptionID string) (bool, error)
+	// RegisterFailure conta mais uma falha e adia a próxima tentativa (baseDelay dobrando a
+	// cada falha, até maxDelay). Retorna o total de falhas.
+	RegisterFailure(ctx context.Context, subscriptionID string, baseDelay, maxDelay time.Duration) (int, error)
+	Record(ctx context.Context, reconciliation *PixReconciliation) error
+}
@@ -1,9 +1,35 @@
 package entity
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
 
 var ErrPlanNotFound = errors.New("plano não encontrado")
 
+// Ciclos de cobrança aceitos pelo Asaas que oferecemos nos planos.
+const (
+	BillingCycleMonthly      = "MONTHLY"
+	BillingCycleQuarterly    = "QUARTERLY"
+	BillingCycleSemiannually = "SEMIANNUALLY"
+	BillingCycleYearly       = "YEARLY"
+)
+
+var billingCycleMonths = map[string]int{
+	BillingCycleMonthly:      1,
+	BillingCycleQuarterly:    3,
+	BillingCycleSemiannually: 6,
+	BillingCycleYearly:       12,
+}
+
+// IsValidBillingCycle indica se o ciclo é um dos oferecidos nos planos.
+func IsValidBillingCycle(cycle string) bool {
+	_, ok := billingCycleMonths[strings.ToUpper(strings.TrimSpace(cycle))]
+	return ok
+}
+
 type Plan struct {
 	ID               string
 	Name             string
@@ -11,4 +37,82 @@ type Plan struct {
 	PriceCents       int
 	Provider         string
 	ProductID        string
+
+	// PixExpirationMinutes é a janela de pagamento do PIX (do plano ou, na falta, do produto);
+	// 0 usa o padrão da PixExpirationPolicy.
+	PixExpirationMinutes int
+
+	BillingCycle string // MONTHLY (padrão), QUARTERLY, SEMIANNUALLY ou YEARLY
+
+	// Dependentes: IncludedDependents já estão no PriceCents; cada um acima disso custa
+	// ExtraDependentPriceCents por ciclo. MaxDependents nil = sem limite.
+	MaxDependents            *int
+	IncludedDependents       int
+	ExtraDependentPriceCents int
+
+	// DependentRules define quem pode ser dependente no plano (idade, cônjuge, pais).
+	DependentRules DependentRules
+
+	// Inactive bloqueia novas vendas; Hidden só tira o plano do catálogo (continua
+	// vendável por link direto). Espelham plans.is_active e plans.is_visible.
+	Inactive bool
+	Hidden   bool
+
+	// Metadados de exibição do catálogo.
+	Description  string
+	Features     []string
+	Badge        string
+	DisplayOrder int
+}
+
+// Cycle retorna o ciclo de cobrança normalizado, MONTHLY quando não configurado.
+func (p *Plan) Cycle() string {
+	cycle := strings.ToUpper(strings.TrimSpace(p.BillingCycle))
+	if _, ok := billingCycleMonths[cycle]; !ok {
+		return BillingCycleMonthly
+	}
+	return cycle
+}
+
+// CycleMonths retorna quantos meses cada cobrança cobre.
+func (p *Plan) CycleMonths() int {
+	return billingCycleMonths[p.Cycle()]
+}
+
+// NextBillingDate retorna a data da próxima cobrança a partir de from.
+func (p *Plan) NextBillingDate(from time.Time) time.Time {
+	return from.AddDate(0, p.CycleMonths(), 0)
+}
+
+// CurrentCycle retorna o ciclo de cobrança que contém now, a partir de qualquer data de
+// cobrança da assinatura (next_billing_date não avança sozinho a cada pagamento).
+func (p *Plan) CurrentCycle(anchor, now time.Time) (time.Time, time.Time) {
+	months := p.CycleMonths()
+	end := anchor
+	for !end.After(now) {
+		end = end.AddDate(0, months, 0)
+	}
+	for end.AddDate(0, -months, 0).After(now) {
+		end = end.AddDate(0, -months, 0)
+	}
+	return end.AddDate(0, -months, 0), end
+}
+
+// AcceptsDependents indica se o plano comporta essa quantidade de dependentes.
+func (p *Plan) AcceptsDependents(count int) bool {
+	return p.MaxDependents == nil || count <= *p.MaxDependents
+}
+
+// PriceFor retorna o preço por ciclo com os dependentes extras.
+func (p *Plan) PriceFor(dependents int) int {
+	extra := dependents - p.IncludedDependents
+	if extra <= 0 {
+		return p.PriceCents
+	}
+	return p.PriceCents + extra*p.ExtraDependentPriceCents
+}
+
+type PlanCatalogRepositoryInterface interface {
+	// ListCatalog retorna os planos ativos e visíveis, opcionalmente de um produto.
+	ListCatalog(ctx context.Context, productID string) ([]*Plan, error)
 }
@@ -0,0 +1,118 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrPlanChangeConflict indica que a assinatura mudou de plano (ou de status) enquanto a troca era calculada.
+var ErrPlanChangeConflict = errors.New("assinatura alterada durante a troca de plano")
+
+// Status da troca de plano. A troca é gravada PENDING antes das chamadas ao gateway e só
+// vira APPLIED quando plano e valor são trocados no banco. FAILED é a troca abandonada, com
+// o valor no gateway revertido e a cobrança do pró-rata cancelada.
+const (
+	PlanChangePending = "PENDING"
+	PlanChangeApplied = "APPLIED"
+	PlanChangeFailed  = "FAILED"
+)
+
+// PlanChange registra um upgrade/downgrade de uma assinatura ativa. O ID é a chave de
+// idempotência da troca: vai no externalReference da cobrança do pró-rata.
+type PlanChange struct {
+	ID              string    `json:"id"`
+	SubscriptionID  string    `json:"subscription_id"`
+	CustomerID      string    `json:"customer_id"`
+	FromPlanID      string    `json:"from_plan_id"`
+	ToPlanID        string    `json:"to_plan_id"`
+	FromAmountCents int       `json:"from_amount_cents"` // valor do ciclo no plano antigo
+	ToAmountCents   int       `json:"to_amount_cents"`   // valor cheio do ciclo no plano novo (com o cupom permanente, se houver)
+	NextAmountCents int       `json:"next_amount_cents"` // próxima cobrança: ToAmountCents menos o crédito
+	ProratedCents   int       `json:"prorated_cents"`    // > 0 cobrado agora, < 0 crédito na próxima cobrança
+	ChargeID        string    `json:"charge_id,omitempty"`
+	CouponCode      string    `json:"coupon_code,omitempty"`      // cupom permanente mantido no plano novo
+	CreditFromDate  time.Time `json:"credit_from_date,omitempty"` // vencimento da próxima cobrança; só ela consome o crédito
+	Status          string    `json:"status"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+func NewPlanChange(sub *Subscription, toPlanID string, toAmountCents int) *PlanChange {
+	return &PlanChange{
+		ID:              uuid.New().String(),
+		SubscriptionID:  sub.ID,
+		CustomerID:      sub.CustomerID,
+		FromPlanID:      sub.PlanID,
+		ToPlanID:        toPlanID,
+		FromAmountCents: sub.Amount,
+		ToAmountCents:   toAmountCents,
+		NextAmountCents: toAmountCents,
+		Status:          PlanChangePending,
+		CreatedAt:       time.Now(),
+	}
+}
+
+// CreditCents é o crédito aplicado na próxima cobrança (0 em upgrades).
+func (c *PlanChange) CreditCents() int {
+	return c.ToAmountCents - c.NextAmountCents
+}
+
+type PlanChangeRepositoryInterface interface {
+	// FindPendingPlanChange retorna a troca PENDING da assinatura, ou nil se não houver.
+	FindPendingPlanChange(ctx context.Context, subscriptionID string) (*PlanChange, error)
+	// CreatePendingPlanChange grava a troca como PENDING antes de qualquer chamada ao gateway.
+	// Retorna ErrPlanChangeConflict se a assinatura já tem outra troca pendente.
+	CreatePendingPlanChange(ctx context.Context, change *PlanChange) error
+	// SetPlanChangeCharge registra na troca pendente a cobrança do pró-rata recém-criada.
+	SetPlanChangeCharge(ctx context.Context, changeID, chargeID string) error
+	// ApplyPlanChange troca o plano e o valor da assinatura e do cliente e marca a troca como
+	// APPLIED na mesma transação. Retorna ErrPlanChangeConflict se a assinatura não está mais
+	// ativa no plano de origem ou se a troca não está mais pendente.
+	ApplyPlanChange(ctx context.Context, change *PlanChange) error
+	// FailPlanChange marca a troca PENDING como FAILED com o motivo. Retorna ErrPlanChangeConflict
+	// se ela não está mais pendente.
+	FailPlanChange(ctx context.Context, changeID, reason string) error
+}
+
+// PlanChangeProration é a diferença proporcional aos dias que faltam no ciclo já pago.
+type PlanChangeProration struct {
+	CycleStart    time.Time
+	CycleEnd      time.Time
+	CycleDays     int
+	RemainingDays int
+	AmountCents   int // > 0 cobrar agora, < 0 crédito
+}
+
+// ProratePlanChange cobra (ou credita) a diferença entre os valores do ciclo na proporção
+// dos dias restantes, contados em dias de calendário.
+func ProratePlanChange(currentCents, newCents int, cycleStart, cycleEnd, now time.Time) PlanChangeProration {
+	proration := PlanChangeProration{
+		CycleStart: cycleStart,
+		CycleEnd:   cycleEnd,
+		CycleDays:  daysBetween(cycleStart, cycleEnd),
+	}
+	if proration.CycleDays <= 0 {
+		return proration
+	}
+
+	proration.RemainingDays = daysBetween(now, cycleEnd)
+	if proration.RemainingDays < 0 {
+		proration.RemainingDays = 0
+	}
+	if proration.RemainingDays > proration.CycleDays {
+		proration.RemainingDays = proration.CycleDays
+	}
+
+	diff := float64(newCents - currentCents)
+	proration.AmountCents = int(math.Round(diff * float64(proration.RemainingDays) / float64(proration.CycleDays)))
+	return proration
+}
+
+func daysBetween(from, to time.Time) int {
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	end := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(end.Sub(start).Hours() / 24)
+}
@@ -0,0 +1,13 @@
+package entity
+
+import (
+	"context"
+	"time"
+)
+
+// SchedulerLeaseRepositoryInterface guarda qual réplica detém cada job periódico.
+// TryAcquire retorna true se o holder assumiu (ou renovou) o lease do job por ttl.
+type SchedulerLeaseRepositoryInterface interface {
+	TryAcquire(ctx context.Context, job, holder string, ttl time.Duration) (bool, error)
+	Release(ctx context.Context, job, holder string) error
+}
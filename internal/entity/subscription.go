@@ -8,18 +8,26 @@ import (
 )
 
 type Subscription struct {
-	ID              string    `json:"id"`
-	CustomerID      string    `json:"customer_id"`
-	PlanID          string    `json:"plan_id"`
-	ProductID       string    `json:"product_id"`
-	Amount          int       `json:"amount"`   // Em centavos, como o Asaas gosta
-	Status          string    `json:"status"`   // PENDING, ACTIVE, EXPIRED, etc
-	Interval        string    `json:"interval"` // MONTHLY, YEARLY
-	NextBillingDate time.Time `json:"next_billing_date"`
-	PaymentMethod   string    `json:"payment_method"` // PIX, CREDIT_CARD, BOLETO
-	PaymentMethodID string    `json:"payment_method_id"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID              string     `json:"id"`
+	CustomerID      string     `json:"customer_id"`
+	PlanID          string     `json:"plan_id"`
+	ProductID       string     `json:"product_id"`
+	Amount          int        `json:"amount"`   // Em centavos, como o Asaas gosta
+	Status          string     `json:"status"`   // PENDING, ACTIVE, EXPIRED, etc
+	Interval        string     `json:"interval"` // MONTHLY, YEARLY
+	NextBillingDate time.Time  `json:"next_billing_date"`
+	PaymentMethod   string     `json:"payment_method"` // PIX, CREDIT_CARD, BOLETO
+	PaymentMethodID string     `json:"payment_method_id"`
+	PixExpiresAt    *time.Time `json:"pix_expires_at,omitempty"` // prazo de pagamento do PIX
+	// Cupom de N ciclos: Amount vale até DiscountCyclesRemaining chegar a zero, depois volta a FullAmountCents.
+	FullAmountCents         int  `json:"full_amount_cents,omitempty"`
+	DiscountCyclesRemaining *int `json:"discount_cycles_remaining,omitempty"`
+	// CancelAt é o fim do ciclo pago de um cancelamento agendado; o acesso continua até lá.
+	CancelAt *time.Time `json:"cancel_at,omitempty"`
+	// ActivatedAt é a primeira ativação: a data de alta enviada aos provedores.
+	ActivatedAt *time.Time `json:"activated_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
 }
 type SubscriptionRepository interface {
 	Create(ctx context.Context, sub *Subscription) error
@@ -29,6 +37,30 @@ type SubscriptionRepository interface {
 	DeleteByID(ctx context.Context, id string) error
 }
 
+// SubscriptionStatusRepository grava o status de uma assinatura específica (pelo id), sem
+// tocar nas demais assinaturas do mesmo cliente.
+type SubscriptionStatusRepository interface {
+	FindLastByCustomerID(ctx context.Context, customerID string) (*Subscription, error)
+	UpdateStatusByID(ctx context.Context, subscriptionID, status string) error
+}
+
+// DiscountCycleState é o desconto temporário de uma assinatura depois de contar um pagamento.
+type DiscountCycleState struct {
+	SubscriptionID  string
+	FullAmountCents int
+	CyclesRemaining int
+}
+
+type DiscountCycleRepositoryInterface interface {
+	// ConsumeDiscountCycle conta o pagamento (uma vez por paymentID) contra o desconto da
+	// assinatura do Asaas. Pagamentos com vencimento anterior ao início do desconto (crédito
+	// de troca de plano) não consomem ciclo. Retorna nil se a assinatura não tem desconto
+	// temporário pendente.
+	ConsumeDiscountCycle(ctx context.Context, gatewaySubscriptionID, paymentID string, dueDate time.Time) (*DiscountCycleState, error)
+	// RestoreFullAmount volta o amount ao valor cheio e encerra o desconto.
+	RestoreFullAmount(ctx context.Context, subscriptionID string) error
+}
+
 func NewSubscription(customerID, planID string, amount int) *Subscription {
 	return &Subscription{
 		ID:         uuid.New().String(),
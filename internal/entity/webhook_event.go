@@ -0,0 +1,48 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrWebhookEventNotFound = errors.New("webhook não encontrado")
+
+const (
+	WebhookEventPending    = "PENDING"
+	WebhookEventProcessing = "PROCESSING"
+	WebhookEventProcessed  = "PROCESSED"
+	WebhookEventFailed     = "FAILED"
+)
+
+// WebhookEvent representa um evento recebido de um gateway e persistido na inbox
+// antes de qualquer processamento. EventKey é único por origem e garante que
+// reentregas do mesmo evento não sejam processadas duas vezes.
+type WebhookEvent struct {
+	ID            string     `json:"id"`
+	Source        string     `json:"source"`    // ASAAS
+	EventKey      string     `json:"event_key"` // ID do evento no gateway (ou EVENTO:payment_id)
+	EventType     string     `json:"event_type"`
+	PaymentID     string     `json:"payment_id"`
+	CustomerRef   string     `json:"customer_ref"`
+	Payload       []byte     `json:"-"`
+	Status        string     `json:"status"` // PENDING, PROCESSING, PROCESSED, FAILED
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	ProcessedAt   *time.Time `json:"processed_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type WebhookEventRepositoryInterface interface {
+	// Save grava o evento na inbox. Retorna false quando o EventKey já existe (reentrega).
+	Save(ctx context.Context, event *WebhookEvent) (bool, error)
+	// ClaimDue reserva até limit eventos prontos para processamento.
+	ClaimDue(ctx context.Context, limit int) ([]*WebhookEvent, error)
+	MarkProcessed(ctx context.Context, id string) error
+	MarkRetry(ctx context.Context, id string, attempts int, nextAttemptAt time.Time, reason string) error
+	MarkFailed(ctx context.Context, id string, attempts int, reason string) error
+	// Requeue devolve um evento (por ID ou EventKey) para PENDING para ser reprocessado.
+	Requeue(ctx context.Context, idOrKey string) error
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/xavierca1/ligue-payments/internal/entity"
+)
+
+type CancellationRepository struct {
+	DB *sql.DB
+}
+
+func NewCancellationRepository(db *sql.DB) *CancellationRepository {
+	return &CancellationRepository{DB: db}
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func insertCancellation(ctx context.Context, db execer, c *entity.SubscriptionCancellation) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO subscription_cancellations (
+			id, subscription_id, customer_id, reason, comment, outcome, retention_coupon_code, effective_at, created_at
+		) VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, NULLIF($7, ''), $8, $9)`,
+		c.ID, c.SubscriptionID, c.CustomerID, c.Reason, c.Comment, c.Outcome, c.RetentionCouponCode, c.EffectiveAt, c.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("erro ao registrar pedido de cancelamento: %w", err)
+	}
+	return nil
+}
+
+func (r *CancellationRepository) Save(ctx context.Context, cancellation *entity.SubscriptionCancellation) error {
+	return insertCancellation(ctx, r.DB, cancellation)
+}
+
+func (r *CancellationRepository) RetentionAccepted(ctx context.Context, subscriptionID string) (bool, error) {
+	var accepted bool
+	err := r.DB.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM subscription_cancellations WHERE subscription_id = $1 AND outcome = $2)`,
+		subscriptionID, entity.CancellationRetained,
+	).Scan(&accepted)
+	if err != nil {
+		return false, fmt.Errorf("erro ao verificar retenção da assinatura %s: %w", subscriptionID, err)
+	}
+	return accepted, nil
+}
+
+// ApplyRetention troca o valor da assinatura pelo valor com o cupom de retenção. Com Cycles,
+// o valor cheio volta pelo CouponCyclesUseCase, como nos cupons de checkout.
+func (r *CancellationRepository) ApplyRetention(ctx context.Context, cancellation *entity.SubscriptionCancellation, discount entity.RetentionDiscount) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback()
+
+	var fullAmount any
+	if discount.Cycles != nil {
+		fullAmount = discount.FullAmountCents
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE subscriptions
+		 SET amount = $2, full_amount_cents = $3, discount_cycles_remaining = $4, discount_from_due_date = NULL, updated_at = NOW()
+		 WHERE id = $1`,
+		cancellation.SubscriptionID, discount.AmountCents, fullAmount, discount.Cycles,
+	); err != nil {
+		return fmt.Errorf("erro ao aplicar desconto de retenção na assinatura %s: %w", cancellation.SubscriptionID, err)
+	}
+
+	if err := insertCancellation(ctx, tx, cancellation); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("erro ao confirmar transação: %w", err)
+	}
+	return nil
+}
+
+func (r *CancellationRepository) ScheduleCancellation(ctx context.Context, cancellation *entity.SubscriptionCancellation) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE subscriptions SET cancel_at = $2, updated_at = NOW() WHERE id = $1`,
+		cancellation.SubscriptionID, cancellation.EffectiveAt,
+	); err != nil {
+		return fmt.Errorf("erro ao agendar cancelamento da assinatura %s: %w", cancellation.SubscriptionID, err)
+	}
+
+	if err := insertCancellation(ctx, tx, cancellation); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("erro ao confirmar transação: %w", err)
+	}
+	return nil
+}
+
+func (r *CancellationRepository) UnscheduleCancellation(ctx context.Context, cancellation *entity.SubscriptionCancellation) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE subscriptions SET cancel_at = NULL, updated_at = NOW() WHERE id = $1 AND cancel_at = $2`,
+		cancellation.SubscriptionID, cancellation.EffectiveAt,
+	); err != nil {
+		return fmt.Errorf("erro ao desfazer agendamento da assinatura %s: %w", cancellation.SubscriptionID, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM subscription_cancellations WHERE id = $1`, cancellation.ID); err != nil {
+		return fmt.Errorf("erro ao remover pedido de cancelamento %s: %w", cancellation.ID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("erro ao confirmar transação: %w", err)
+	}
+	return nil
+}
+
+func (r *CancellationRepository) FindDueCancellations(ctx context.Context, now time.Time, limit int) ([]*entity.Subscription, error) {
+	rows, err := r.DB.QueryContext(ctx,
+		`SELECT id, customer_id, COALESCE(plan_id::text, ''), status, COALESCE(payment_method_id, ''), cancel_at
+		 FROM subscriptions
+		 WHERE cancel_at IS NOT NULL AND cancel_at <= $1
+		   AND status NOT IN ('CANCELED', 'REFUNDED')
+		 ORDER BY cancel_at
+		 LIMIT $2`,
+		now, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar cancelamentos agendados: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []*entity.Subscription{}
+	for rows.Next() {
+		var sub entity.Subscription
+		if err := rows.Scan(&sub.ID, &sub.CustomerID, &sub.PlanID, &sub.Status, &sub.PaymentMethodID, &sub.CancelAt); err != nil {
+			return nil, fmt.Errorf("erro ao ler cancelamento agendado: %w", err)
+		}
+		subs = append(subs, &sub)
+	}
+	return subs, rows.Err()
+}
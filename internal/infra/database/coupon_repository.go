@@ -3,10 +3,12 @@ package database
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/xavierca1/ligue-payments/internal/entity"
 	"github.com/xavierca1/ligue-payments/internal/usecase"
 )
 
@@ -22,7 +24,17 @@ func (r *CouponRepository) GetActiveCoupon(ctx context.Context, code string) (*u
 	normalizedCode := strings.ToUpper(strings.TrimSpace(code))
 
 	query := `
-		SELECT code, seller_name, COALESCE(discount_percent, 10)
+		SELECT
+			code,
+			seller_name,
+			UPPER(COALESCE(discount_type, 'PERCENT')),
+			COALESCE(discount_percent, 0),
+			COALESCE(discount_amount_cents, 0),
+			COALESCE(array_to_string(plan_ids, ','), ''),
+			COALESCE(array_to_string(product_ids, ','), ''),
+			COALESCE(max_redemptions, 0),
+			COALESCE(max_redemptions_per_cpf, 0),
+			COALESCE(duration_cycles, 0)
 		FROM coupons
 		WHERE UPPER(code) = $1
 		  AND is_active = TRUE
@@ -32,26 +44,138 @@ func (r *CouponRepository) GetActiveCoupon(ctx context.Context, code string) (*u
 	`
 
 	var details usecase.CouponDetails
-	if err := r.DB.QueryRowContext(ctx, query, normalizedCode).Scan(&details.Code, &details.SellerName, &details.DiscountPercent); err != nil {
+	var planIDs, productIDs string
+	if err := r.DB.QueryRowContext(ctx, query, normalizedCode).Scan(
+		&details.Code,
+		&details.SellerName,
+		&details.DiscountType,
+		&details.DiscountPercent,
+		&details.DiscountAmountCents,
+		&planIDs,
+		&productIDs,
+		&details.MaxRedemptions,
+		&details.MaxPerCPF,
+		&details.DurationCycles,
+	); err != nil {
 		return nil, err
 	}
 
-	if details.DiscountPercent <= 0 {
-		details.DiscountPercent = 10
+	details.PlanIDs = splitCouponScope(planIDs)
+	details.ProductIDs = splitCouponScope(productIDs)
+
+	// Cupom mal cadastrado é recusado em vez de virar um desconto padrão.
+	switch details.DiscountType {
+	case usecase.CouponDiscountFixed:
+		if details.DiscountAmountCents <= 0 {
+			return nil, fmt.Errorf("cupom %s sem valor de desconto", details.Code)
+		}
+	case usecase.CouponDiscountPercent:
+		if details.DiscountPercent <= 0 || details.DiscountPercent > 100 {
+			return nil, fmt.Errorf("cupom %s com discount_percent inválido (%d)", details.Code, details.DiscountPercent)
+		}
+	default:
+		return nil, fmt.Errorf("cupom %s com discount_type desconhecido: %s", details.Code, details.DiscountType)
 	}
 
 	return &details, nil
 }
 
+func splitCouponScope(raw string) []string {
+	var ids []string
+	for _, id := range strings.Split(raw, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// saleCPF é o CPF da venda só com dígitos; vendas antigas sem customer_cpf usam o do cliente.
+const saleCPF = `regexp_replace(COALESCE(NULLIF(cs.customer_cpf, ''), c.cpf, ''), '\D', '', 'g')`
+
+// CountRedemptions conta as vendas não anuladas do cupom. O checkout PENDING do próprio CPF
+// fica de fora: ao refazer o checkout ele é descartado e a venda anulada (VoidSales).
+func (r *CouponRepository) CountRedemptions(ctx context.Context, code, cpf string) (usecase.CouponRedemptions, error) {
+	query := `
+		SELECT
+			COUNT(*),
+			COUNT(*) FILTER (WHERE $2 <> '' AND ` + saleCPF + ` = $2)
+		FROM coupon_sales cs
+		LEFT JOIN customers c ON c.id = cs.customer_id
+		LEFT JOIN subscriptions s ON s.id = cs.subscription_id
+		WHERE UPPER(cs.coupon_code) = $1
+		  AND cs.voided_at IS NULL
+		  AND NOT ($2 <> '' AND ` + saleCPF + ` = $2 AND s.status = 'PENDING')
+	`
+
+	var redemptions usecase.CouponRedemptions
+	err := r.DB.QueryRowContext(ctx, query, strings.ToUpper(strings.TrimSpace(code)), onlyDigits(cpf)).
+		Scan(&redemptions.Total, &redemptions.ByCPF)
+	if err != nil {
+		return usecase.CouponRedemptions{}, fmt.Errorf("erro ao contar usos do cupom %s: %w", code, err)
+	}
+	return redemptions, nil
+}
+
+func onlyDigits(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// TrackSale grava a venda só se os limites do cupom ainda permitirem. A linha do cupom fica
+// travada (FOR UPDATE) entre a contagem e o insert, então checkouts simultâneos não passam do
+// limite; estourado, retorna entity.ErrCouponExhausted ou entity.ErrCouponAlreadyUsed.
 func (r *CouponRepository) TrackSale(ctx context.Context, sale usecase.CouponSaleRecord) error {
 	normalizedCode := strings.ToUpper(strings.TrimSpace(sale.CouponCode))
 	if normalizedCode == "" {
 		return nil
 	}
 
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback()
+
+	var sellerName string
+	var maxRedemptions, maxPerCPF int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT seller_name, COALESCE(max_redemptions, 0), COALESCE(max_redemptions_per_cpf, 0) FROM coupons WHERE UPPER(code) = $1 LIMIT 1 FOR UPDATE`,
+		normalizedCode,
+	).Scan(&sellerName, &maxRedemptions, &maxPerCPF); err != nil {
+		return fmt.Errorf("erro ao travar cupom %s: %w", normalizedCode, err)
+	}
 	if strings.TrimSpace(sale.SellerName) == "" {
-		querySeller := `SELECT seller_name FROM coupons WHERE UPPER(code) = $1 LIMIT 1`
-		_ = r.DB.QueryRowContext(ctx, querySeller, normalizedCode).Scan(&sale.SellerName)
+		sale.SellerName = sellerName
+	}
+
+	cpf := onlyDigits(sale.CustomerCPF)
+	if maxRedemptions > 0 || maxPerCPF > 0 {
+		var total, byCPF int
+		if err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(*), COUNT(*) FILTER (WHERE $2 <> '' AND `+saleCPF+` = $2)
+			FROM coupon_sales cs
+			LEFT JOIN customers c ON c.id = cs.customer_id
+			WHERE UPPER(cs.coupon_code) = $1 AND cs.voided_at IS NULL
+		`, normalizedCode, cpf).Scan(&total, &byCPF); err != nil {
+			return fmt.Errorf("erro ao contar usos do cupom %s: %w", normalizedCode, err)
+		}
+		if maxRedemptions > 0 && total >= maxRedemptions {
+			return entity.ErrCouponExhausted
+		}
+		if maxPerCPF > 0 && cpf != "" && byCPF >= maxPerCPF {
+			return entity.ErrCouponAlreadyUsed
+		}
+	}
+
+	discountType := strings.ToUpper(strings.TrimSpace(sale.DiscountType))
+	if discountType == "" {
+		discountType = usecase.CouponDiscountPercent
 	}
 
 	query := `
@@ -66,13 +190,16 @@ func (r *CouponRepository) TrackSale(ctx context.Context, sale usecase.CouponSal
 			discount_percent,
 			discount_amount_cents,
 			final_amount_cents,
-			created_at
+			created_at,
+			customer_cpf,
+			discount_type,
+			duration_cycles
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), $13, NULLIF($14, 0)
 		)
 	`
 
-	_, err := r.DB.ExecContext(
+	if _, err := tx.ExecContext(
 		ctx,
 		query,
 		uuid.New().String(),
@@ -86,7 +213,280 @@ func (r *CouponRepository) TrackSale(ctx context.Context, sale usecase.CouponSal
 		sale.DiscountAmountCents,
 		sale.FinalAmountCents,
 		time.Now(),
+		cpf,
+		discountType,
+		sale.DurationCycles,
+	); err != nil {
+		return fmt.Errorf("erro ao registrar venda do cupom %s: %w", normalizedCode, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("erro ao confirmar transação: %w", err)
+	}
+	return nil
+}
+
+// VoidSales anula as vendas de cupom de uma assinatura que nunca foi paga (checkout refeito,
+// recusado ou PIX expirado), liberando o uso para o limite total e o do CPF.
+func (r *CouponRepository) VoidSales(ctx context.Context, subscriptionID string) error {
+	if _, err := r.DB.ExecContext(ctx,
+		`UPDATE coupon_sales SET voided_at = NOW() WHERE subscription_id = $1 AND voided_at IS NULL`,
+		subscriptionID,
+	); err != nil {
+		return fmt.Errorf("erro ao anular vendas de cupom da assinatura %s: %w", subscriptionID, err)
+	}
+	return nil
+}
+
+// FindSubscriptionCoupon retorna a venda de cupom em vigor na assinatura (a última não anulada),
+// ou nil se ela não tem cupom.
+func (r *CouponRepository) FindSubscriptionCoupon(ctx context.Context, subscriptionID string) (*usecase.CouponSaleRecord, error) {
+	var sale usecase.CouponSaleRecord
+	err := r.DB.QueryRowContext(ctx,
+		`SELECT coupon_code, seller_name, customer_id, COALESCE(customer_cpf, ''), subscription_id, plan_id,
+		        UPPER(discount_type), COALESCE(duration_cycles, 0), original_amount_cents,
+		        discount_percent, discount_amount_cents, final_amount_cents
+		 FROM coupon_sales
+		 WHERE subscription_id = $1 AND voided_at IS NULL
+		 ORDER BY created_at DESC
+		 LIMIT 1`,
+		subscriptionID,
+	).Scan(
+		&sale.CouponCode, &sale.SellerName, &sale.CustomerID, &sale.CustomerCPF, &sale.SubscriptionID, &sale.PlanID,
+		&sale.DiscountType, &sale.DurationCycles, &sale.OriginalAmountCents,
+		&sale.DiscountPercent, &sale.DiscountAmountCents, &sale.FinalAmountCents,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar cupom da assinatura %s: %w", subscriptionID, err)
+	}
+	return &sale, nil
+}
+
+const couponColumns = `
+	id,
+	code,
+	seller_name,
+	UPPER(COALESCE(discount_type, 'PERCENT')),
+	COALESCE(discount_percent, 0),
+	COALESCE(discount_amount_cents, 0),
+	COALESCE(array_to_string(plan_ids, ','), ''),
+	COALESCE(array_to_string(product_ids, ','), ''),
+	COALESCE(max_redemptions, 0),
+	COALESCE(max_redemptions_per_cpf, 0),
+	COALESCE(duration_cycles, 0),
+	is_active,
+	starts_at,
+	ends_at,
+	created_at,
+	updated_at`
+
+func scanCoupon(scanner interface{ Scan(dest ...any) error }) (*entity.Coupon, error) {
+	var coupon entity.Coupon
+	var planIDs, productIDs string
+	var startsAt, endsAt sql.NullTime
+	if err := scanner.Scan(
+		&coupon.ID,
+		&coupon.Code,
+		&coupon.SellerName,
+		&coupon.DiscountType,
+		&coupon.DiscountPercent,
+		&coupon.DiscountAmountCents,
+		&planIDs,
+		&productIDs,
+		&coupon.MaxRedemptions,
+		&coupon.MaxPerCPF,
+		&coupon.DurationCycles,
+		&coupon.IsActive,
+		&startsAt,
+		&endsAt,
+		&coupon.CreatedAt,
+		&coupon.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	coupon.PlanIDs = compactScope(planIDs)
+	coupon.ProductIDs = compactScope(productIDs)
+	if startsAt.Valid {
+		coupon.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		coupon.EndsAt = &endsAt.Time
+	}
+	return &coupon, nil
+}
+
+func compactScope(raw string) []string {
+	ids := splitCouponScope(raw)
+	if ids == nil {
+		return []string{}
+	}
+	return ids
+}
+
+func (r *CouponRepository) Create(ctx context.Context, coupon *entity.Coupon) error {
+	if strings.TrimSpace(coupon.ID) == "" {
+		coupon.ID = uuid.New().String()
+	}
+	now := time.Now()
+	coupon.CreatedAt = now
+	coupon.UpdatedAt = now
+
+	// Códigos antigos podem estar em minúsculas; a unicidade é sem diferenciar maiúsculas.
+	query := `
+		INSERT INTO coupons (
+			id, code, seller_name, discount_type, discount_percent, discount_amount_cents,
+			plan_ids, product_ids, max_redemptions, max_redemptions_per_cpf, duration_cycles,
+			is_active, starts_at, ends_at, created_at, updated_at
+		)
+		SELECT
+			$1, $2, $3, $4, $5, NULLIF($6, 0),
+			string_to_array(NULLIF($7, ''), ','), string_to_array(NULLIF($8, ''), ','),
+			NULLIF($9, 0), NULLIF($10, 0), NULLIF($11, 0),
+			$12, $13, $14, $15, $15
+		WHERE NOT EXISTS (SELECT 1 FROM coupons WHERE UPPER(code) = UPPER($2))
+		ON CONFLICT (code) DO NOTHING
+	`
+
+	result, err := r.DB.ExecContext(ctx, query,
+		coupon.ID,
+		coupon.Code,
+		coupon.SellerName,
+		coupon.DiscountType,
+		coupon.DiscountPercent,
+		coupon.DiscountAmountCents,
+		strings.Join(coupon.PlanIDs, ","),
+		strings.Join(coupon.ProductIDs, ","),
+		coupon.MaxRedemptions,
+		coupon.MaxPerCPF,
+		coupon.DurationCycles,
+		coupon.IsActive,
+		coupon.StartsAt,
+		coupon.EndsAt,
+		now,
 	)
+	if err != nil {
+		return fmt.Errorf("erro ao criar cupom %s: %w", coupon.Code, err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return entity.ErrCouponAlreadyExists
+	}
+	return nil
+}
+
+func (r *CouponRepository) Update(ctx context.Context, coupon *entity.Coupon) error {
+	coupon.UpdatedAt = time.Now()
 
-	return err
+	query := `
+		UPDATE coupons SET
+			seller_name = $2,
+			discount_type = $3,
+			discount_percent = $4,
+			discount_amount_cents = NULLIF($5, 0),
+			plan_ids = string_to_array(NULLIF($6, ''), ','),
+			product_ids = string_to_array(NULLIF($7, ''), ','),
+			max_redemptions = NULLIF($8, 0),
+			max_redemptions_per_cpf = NULLIF($9, 0),
+			duration_cycles = NULLIF($10, 0),
+			is_active = $11,
+			starts_at = $12,
+			ends_at = $13,
+			updated_at = $14
+		WHERE id = $1
+	`
+
+	result, err := r.DB.ExecContext(ctx, query,
+		coupon.ID,
+		coupon.SellerName,
+		coupon.DiscountType,
+		coupon.DiscountPercent,
+		coupon.DiscountAmountCents,
+		strings.Join(coupon.PlanIDs, ","),
+		strings.Join(coupon.ProductIDs, ","),
+		coupon.MaxRedemptions,
+		coupon.MaxPerCPF,
+		coupon.DurationCycles,
+		coupon.IsActive,
+		coupon.StartsAt,
+		coupon.EndsAt,
+		coupon.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar cupom %s: %w", coupon.Code, err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return entity.ErrCouponNotFound
+	}
+	return nil
+}
+
+func (r *CouponRepository) FindByCode(ctx context.Context, code string) (*entity.Coupon, error) {
+	query := `SELECT ` + couponColumns + ` FROM coupons WHERE UPPER(code) = $1 LIMIT 1`
+
+	coupon, err := scanCoupon(r.DB.QueryRowContext(ctx, query, strings.ToUpper(strings.TrimSpace(code))))
+	if err == sql.ErrNoRows {
+		return nil, entity.ErrCouponNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar cupom %s: %w", code, err)
+	}
+	return coupon, nil
+}
+
+func (r *CouponRepository) List(ctx context.Context, activeOnly bool) ([]*entity.Coupon, error) {
+	query := `SELECT ` + couponColumns + ` FROM coupons WHERE ($1 = FALSE OR is_active = TRUE) ORDER BY seller_name, code`
+
+	rows, err := r.DB.QueryContext(ctx, query, activeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao listar cupons: %w", err)
+	}
+	defer rows.Close()
+
+	coupons := []*entity.Coupon{}
+	for rows.Next() {
+		coupon, err := scanCoupon(rows)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler cupom: %w", err)
+		}
+		coupons = append(coupons, coupon)
+	}
+	return coupons, rows.Err()
+}
+
+// SellerReport soma as vendas do período por vendedor, só das assinaturas que foram ativadas
+// (subscriptions.activated_at); checkouts PIX que expiraram não geram comissão.
+func (r *CouponRepository) SellerReport(ctx context.Context, from, to time.Time) ([]entity.SellerSalesRow, error) {
+	query := `
+		SELECT
+			cs.seller_name,
+			COUNT(*),
+			COALESCE(SUM(cs.original_amount_cents), 0),
+			COALESCE(SUM(cs.discount_amount_cents), 0),
+			COALESCE(SUM(cs.final_amount_cents), 0)
+		FROM coupon_sales cs
+		JOIN subscriptions s ON s.id = cs.subscription_id
+		WHERE s.activated_at IS NOT NULL
+		  AND cs.created_at >= $1 AND cs.created_at < $2
+		GROUP BY cs.seller_name
+		ORDER BY 5 DESC, cs.seller_name
+	`
+
+	rows, err := r.DB.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao gerar relatório de vendedores: %w", err)
+	}
+	defer rows.Close()
+
+	var report []entity.SellerSalesRow
+	for rows.Next() {
+		var row entity.SellerSalesRow
+		if err := rows.Scan(&row.SellerName, &row.Sales, &row.GrossCents, &row.DiscountCents, &row.NetCents); err != nil {
+			return nil, fmt.Errorf("erro ao ler relatório de vendedores: %w", err)
+		}
+		report = append(report, row)
+	}
+	return report, rows.Err()
 }
@@ -22,6 +22,7 @@ func (r *CustomerRepository) FindByID(ctx context.Context, id string) (*entity.C
 	query := `
 		SELECT id, name, email, cpf_cnpj, COALESCE(phone, ''), COALESCE(birth_date, ''), COALESCE(gender, 0),
 		       COALESCE(marital_status, ''),
+		       COALESCE(gateway_id, ''), COALESCE(status, ''), COALESCE(provider_id, ''),
 		       COALESCE(street, ''), COALESCE(number, ''), COALESCE(complement, ''),
 		       COALESCE(district, ''), COALESCE(city, ''), COALESCE(state, ''), COALESCE(zip_code, '')
 		FROM customers
@@ -40,6 +41,9 @@ func (r *CustomerRepository) FindByID(ctx context.Context, id string) (*entity.C
 		&c.BirthDate,
 		&c.Gender,
 		&c.MaritalStatus,
+		&c.GatewayID,
+		&c.Status,
+		&c.ProviderID,
 		&c.Address.Street,
 		&c.Address.Number,
 		&c.Address.Complement,
@@ -67,6 +71,7 @@ func (r *CustomerRepository) FindByCPF(ctx context.Context, cpf string) (*entity
 		SELECT id, name, email, cpf_cnpj, COALESCE(phone, ''), COALESCE(birth_date, ''), COALESCE(gender, 0),
 		       COALESCE(marital_status, ''),
 		       COALESCE(gateway_id, ''), COALESCE(subscription_id, ''), COALESCE(status, ''),
+		       COALESCE(credit_card_token, ''), COALESCE(credit_card_brand, ''), COALESCE(credit_card_last_digits, ''),
 		       COALESCE(street, ''), COALESCE(number, ''), COALESCE(complement, ''),
 		       COALESCE(district, ''), COALESCE(city, ''), COALESCE(state, ''), COALESCE(zip_code, '')
 		FROM customers
@@ -89,6 +94,9 @@ func (r *CustomerRepository) FindByCPF(ctx context.Context, cpf string) (*entity
 		&c.GatewayID,
 		&c.SubscriptionID,
 		&c.Status,
+		&c.Card.Token,
+		&c.Card.Brand,
+		&c.Card.LastDigits,
 		&c.Address.Street,
 		&c.Address.Number,
 		&c.Address.Complement,
@@ -110,6 +118,7 @@ func (r *CustomerRepository) FindByEmailAndProductID(ctx context.Context, email,
 		SELECT id, name, email, cpf_cnpj, COALESCE(phone, ''), COALESCE(birth_date, ''), COALESCE(gender, 0),
 		       COALESCE(marital_status, ''),
 		       COALESCE(gateway_id, ''), COALESCE(subscription_id, ''), COALESCE(status, ''),
+		       COALESCE(credit_card_token, ''), COALESCE(credit_card_brand, ''), COALESCE(credit_card_last_digits, ''),
 		       COALESCE(street, ''), COALESCE(number, ''), COALESCE(complement, ''),
 		       COALESCE(district, ''), COALESCE(city, ''), COALESCE(state, ''), COALESCE(zip_code, '')
 		FROM customers
@@ -133,6 +142,9 @@ func (r *CustomerRepository) FindByEmailAndProductID(ctx context.Context, email,
 		&c.GatewayID,
 		&c.SubscriptionID,
 		&c.Status,
+		&c.Card.Token,
+		&c.Card.Brand,
+		&c.Card.LastDigits,
 		&c.Address.Street,
 		&c.Address.Number,
 		&c.Address.Complement,
@@ -292,3 +304,20 @@ func (r *CustomerRepository) UpdateStatus(ctx context.Context, customerID, statu
 	log.Printf("🔄 UpdateStatus (customer): customer_id=%s status=%s rows_affected=%d", customerID, status, rowsAffected)
 	return nil
 }
+
+// SaveCardToken guarda o cartão tokenizado no Asaas; brand e final vazios mantêm os atuais
+// (token recebido pronto do front não traz esses dados).
+func (r *CustomerRepository) SaveCardToken(ctx context.Context, customerID string, card entity.CreditCardToken) error {
+	query := `
+		UPDATE customers
+		SET credit_card_token = $1,
+		    credit_card_brand = COALESCE(NULLIF($2, ''), CASE WHEN credit_card_token = $1 THEN credit_card_brand END),
+		    credit_card_last_digits = COALESCE(NULLIF($3, ''), CASE WHEN credit_card_token = $1 THEN credit_card_last_digits END),
+		    updated_at = NOW()
+		WHERE id = $4`
+	_, err := r.DB.ExecContext(ctx, query, card.Token, card.Brand, card.LastDigits, customerID)
+	if err != nil {
+		return fmt.Errorf("erro ao salvar cartão tokenizado: %w", err)
+	}
+	return nil
+}
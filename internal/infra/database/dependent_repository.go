@@ -17,8 +17,8 @@ func NewDependentRepository(db *sql.DB) *DependentRepository {
 }
 
 func (r *DependentRepository) Create(ctx context.Context, dependent *entity.Dependent) error {
-	query := `INSERT INTO dependents (id, customer_id, nro_documento_titular, name, cpf, birth_date, gender, kinship, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
-	_, err := r.DB.ExecContext(ctx, query, dependent.ID, dependent.CustomerID, dependent.NroDocumentoTitular, dependent.Name, dependent.CPF, dependent.BirthDate, dependent.Gender, dependent.Kinship, dependent.CreatedAt, dependent.UpdatedAt)
+	query := `INSERT INTO dependents (id, customer_id, nro_documento_titular, name, cpf, birth_date, gender, kinship, is_student, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err := r.DB.ExecContext(ctx, query, dependent.ID, dependent.CustomerID, dependent.NroDocumentoTitular, dependent.Name, dependent.CPF, dependent.BirthDate, dependent.Gender, dependent.Kinship, dependent.Student, dependent.CreatedAt, dependent.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("erro ao criar dependente: %w", err)
 	}
@@ -26,7 +26,7 @@ func (r *DependentRepository) Create(ctx context.Context, dependent *entity.Depe
 }
 
 func (r *DependentRepository) FindByCustomerID(ctx context.Context, customerID string) ([]*entity.Dependent, error) {
-	query := `SELECT id, customer_id, nro_documento_titular, name, cpf, birth_date, gender, kinship, created_at, updated_at FROM dependents WHERE customer_id = $1 ORDER BY created_at ASC`
+	query := `SELECT id, customer_id, nro_documento_titular, name, cpf, birth_date, gender, kinship, is_student, created_at, updated_at FROM dependents WHERE customer_id = $1 ORDER BY created_at ASC`
 	rows, err := r.DB.QueryContext(ctx, query, customerID)
 	if err != nil {
 		return nil, fmt.Errorf("erro ao buscar dependentes: %w", err)
@@ -35,7 +35,7 @@ func (r *DependentRepository) FindByCustomerID(ctx context.Context, customerID s
 	var dependents []*entity.Dependent
 	for rows.Next() {
 		dep := &entity.Dependent{}
-		err := rows.Scan(&dep.ID, &dep.CustomerID, &dep.NroDocumentoTitular, &dep.Name, &dep.CPF, &dep.BirthDate, &dep.Gender, &dep.Kinship, &dep.CreatedAt, &dep.UpdatedAt)
+		err := rows.Scan(&dep.ID, &dep.CustomerID, &dep.NroDocumentoTitular, &dep.Name, &dep.CPF, &dep.BirthDate, &dep.Gender, &dep.Kinship, &dep.Student, &dep.CreatedAt, &dep.UpdatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("erro ao escanear dependente: %w", err)
 		}
@@ -45,9 +45,9 @@ func (r *DependentRepository) FindByCustomerID(ctx context.Context, customerID s
 }
 
 func (r *DependentRepository) FindByID(ctx context.Context, id string) (*entity.Dependent, error) {
-	query := `SELECT id, customer_id, nro_documento_titular, name, cpf, birth_date, gender, kinship, created_at, updated_at FROM dependents WHERE id = $1`
+	query := `SELECT id, customer_id, nro_documento_titular, name, cpf, birth_date, gender, kinship, is_student, created_at, updated_at FROM dependents WHERE id = $1`
 	dep := &entity.Dependent{}
-	err := r.DB.QueryRowContext(ctx, query, id).Scan(&dep.ID, &dep.CustomerID, &dep.NroDocumentoTitular, &dep.Name, &dep.CPF, &dep.BirthDate, &dep.Gender, &dep.Kinship, &dep.CreatedAt, &dep.UpdatedAt)
+	err := r.DB.QueryRowContext(ctx, query, id).Scan(&dep.ID, &dep.CustomerID, &dep.NroDocumentoTitular, &dep.Name, &dep.CPF, &dep.BirthDate, &dep.Gender, &dep.Kinship, &dep.Student, &dep.CreatedAt, &dep.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("dependente não encontrado")
@@ -58,8 +58,8 @@ func (r *DependentRepository) FindByID(ctx context.Context, id string) (*entity.
 }
 
 func (r *DependentRepository) Update(ctx context.Context, dependent *entity.Dependent) error {
-	query := `UPDATE dependents SET nro_documento_titular = $2, name = $3, cpf = $4, birth_date = $5, gender = $6, kinship = $7, updated_at = $8 WHERE id = $1`
-	_, err := r.DB.ExecContext(ctx, query, dependent.ID, dependent.NroDocumentoTitular, dependent.Name, dependent.CPF, dependent.BirthDate, dependent.Gender, dependent.Kinship, dependent.UpdatedAt)
+	query := `UPDATE dependents SET nro_documento_titular = $2, name = $3, cpf = $4, birth_date = $5, gender = $6, kinship = $7, is_student = $8, kinship_needs_review = FALSE, updated_at = $9 WHERE id = $1`
+	_, err := r.DB.ExecContext(ctx, query, dependent.ID, dependent.NroDocumentoTitular, dependent.Name, dependent.CPF, dependent.BirthDate, dependent.Gender, dependent.Kinship, dependent.Student, dependent.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("erro ao atualizar dependente: %w", err)
 	}
@@ -74,3 +74,146 @@ func (r *DependentRepository) Delete(ctx context.Context, id string) error {
 	}
 	return nil
 }
+
+// AddToSubscription grava o dependente, soma deltaCents ao valor da assinatura e grava os
+// eventos de provisionamento no outbox. Com cupom de N ciclos em andamento, o valor cheio
+// também muda, para o desconto não apagar a diferença.
+func (r *DependentRepository) AddToSubscription(ctx context.Context, change entity.DependentSubscriptionChange) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback()
+
+	dependent := change.Dependent
+	if err := lockDependentsSubscription(ctx, tx, change); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO dependents (id, customer_id, nro_documento_titular, name, cpf, birth_date, gender, kinship, is_student, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		dependent.ID, dependent.CustomerID, dependent.NroDocumentoTitular, dependent.Name, dependent.CPF, dependent.BirthDate, dependent.Gender, dependent.Kinship, dependent.Student, dependent.CreatedAt, dependent.UpdatedAt,
+	); err != nil {
+		return fmt.Errorf("erro ao criar dependente: %w", err)
+	}
+
+	if err := adjustSubscriptionAmount(ctx, tx, change.SubscriptionID, change.DeltaCents); err != nil {
+		return err
+	}
+	if err := insertOutboxEvents(ctx, tx, change.Events); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("erro ao confirmar transação: %w", err)
+	}
+	return nil
+}
+
+// RemoveFromSubscription apaga o dependente, soma deltaCents (zero ou negativo) ao valor da
+// assinatura e grava a baixa no outbox.
+func (r *DependentRepository) RemoveFromSubscription(ctx context.Context, change entity.DependentSubscriptionChange) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockDependentsSubscription(ctx, tx, change); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM dependents WHERE id = $1 AND customer_id = $2`, change.Dependent.ID, change.Dependent.CustomerID)
+	if err != nil {
+		return fmt.Errorf("erro ao deletar dependente: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return entity.ErrDependentsChanged
+	}
+
+	if err := adjustSubscriptionAmount(ctx, tx, change.SubscriptionID, change.DeltaCents); err != nil {
+		return err
+	}
+	if err := insertOutboxEvents(ctx, tx, change.Events); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("erro ao confirmar transação: %w", err)
+	}
+	return nil
+}
+
+// UpdateWithEvents corrige os dados do dependente e grava a atualização no provedor no outbox.
+func (r *DependentRepository) UpdateWithEvents(ctx context.Context, dependent *entity.Dependent, events []*entity.OutboxEvent) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE dependents SET nro_documento_titular = $2, name = $3, cpf = $4, birth_date = $5, gender = $6, kinship = $7, is_student = $8, kinship_needs_review = FALSE, updated_at = $9 WHERE id = $1`,
+		dependent.ID, dependent.NroDocumentoTitular, dependent.Name, dependent.CPF, dependent.BirthDate, dependent.Gender, dependent.Kinship, dependent.Student, dependent.UpdatedAt,
+	); err != nil {
+		return fmt.Errorf("erro ao atualizar dependente: %w", err)
+	}
+	if err := insertOutboxEvents(ctx, tx, events); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("erro ao confirmar transação: %w", err)
+	}
+	return nil
+}
+
+// lockDependentsSubscription trava a assinatura até o fim da transação e confere que valor e
+// quantidade de dependentes são os lidos antes do ajuste no gateway: duas inclusões
+// simultâneas não passam juntas pelo limite do plano nem somam o delta duas vezes no banco.
+func lockDependentsSubscription(ctx context.Context, tx *sql.Tx, change entity.DependentSubscriptionChange) error {
+	var amount int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT amount FROM subscriptions WHERE id = $1 FOR UPDATE`,
+		change.SubscriptionID,
+	).Scan(&amount); err != nil {
+		return fmt.Errorf("erro ao travar assinatura %s: %w", change.SubscriptionID, err)
+	}
+
+	var count int
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM dependents WHERE customer_id = $1`,
+		change.Dependent.CustomerID,
+	).Scan(&count); err != nil {
+		return fmt.Errorf("erro ao contar dependentes: %w", err)
+	}
+
+	if amount != change.ExpectedAmountCents || count != change.ExpectedDependents {
+		return entity.ErrDependentsChanged
+	}
+	return nil
+}
+
+func insertOutboxEvents(ctx context.Context, tx *sql.Tx, events []*entity.OutboxEvent) error {
+	for _, event := range events {
+		if err := insertOutboxEvent(ctx, tx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func adjustSubscriptionAmount(ctx context.Context, tx *sql.Tx, subscriptionID string, deltaCents int) error {
+	if deltaCents == 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE subscriptions
+		 SET amount = amount + $2, full_amount_cents = full_amount_cents + $2, updated_at = NOW()
+		 WHERE id = $1`,
+		subscriptionID, deltaCents,
+	); err != nil {
+		return fmt.Errorf("erro ao ajustar valor da assinatura %s: %w", subscriptionID, err)
+	}
+	return nil
+}
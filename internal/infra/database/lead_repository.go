@@ -3,6 +3,10 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/xavierca1/ligue-payments/internal/entity"
 )
@@ -13,13 +17,20 @@ type LeadRepository struct {
 
 
 func (r *LeadRepository) Upsert(ctx context.Context, lead *entity.Lead) error {
+	// Nome e telefone ficam com o valor mais recente; a origem (UTMs/landing page) é first-touch.
 	query := `
-		INSERT INTO leads (email, name, phone, updated_at)
-		VALUES ($1, $2, $3, NOW())
+		INSERT INTO leads (email, name, phone, utm_source, utm_medium, utm_campaign, utm_content, utm_term, landing_page, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
 		ON CONFLICT (email)
 		DO UPDATE SET
 			name = COALESCE(EXCLUDED.name, leads.name),
 			phone = COALESCE(EXCLUDED.phone, leads.phone),
+			utm_source = COALESCE(leads.utm_source, EXCLUDED.utm_source),
+			utm_medium = COALESCE(leads.utm_medium, EXCLUDED.utm_medium),
+			utm_campaign = COALESCE(leads.utm_campaign, EXCLUDED.utm_campaign),
+			utm_content = COALESCE(leads.utm_content, EXCLUDED.utm_content),
+			utm_term = COALESCE(leads.utm_term, EXCLUDED.utm_term),
+			landing_page = COALESCE(leads.landing_page, EXCLUDED.landing_page),
 			updated_at = NOW()
 		RETURNING id, created_at, updated_at, status, email_stage
 	`
@@ -30,6 +41,12 @@ func (r *LeadRepository) Upsert(ctx context.Context, lead *entity.Lead) error {
 		lead.Email,
 		nullString(lead.Name),
 		nullString(lead.Phone),
+		nullString(lead.UTMSource),
+		nullString(lead.UTMMedium),
+		nullString(lead.UTMCampaign),
+		nullString(lead.UTMContent),
+		nullString(lead.UTMTerm),
+		nullString(lead.LandingPage),
 	).Scan(
 		&lead.ID,
 		&lead.CreatedAt,
@@ -41,6 +58,102 @@ func (r *LeadRepository) Upsert(ctx context.Context, lead *entity.Lead) error {
 	return err
 }
 
+func (r *LeadRepository) AttachCheckout(ctx context.Context, lead *entity.Lead, customerID string) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback()
+
+	args := []interface{}{
+		strings.TrimSpace(lead.Email),
+		nullString(lead.Name),
+		nullString(lead.Phone),
+		nullString(lead.PlanID),
+		nullString(lead.UTMSource),
+		nullString(lead.UTMMedium),
+		nullString(lead.UTMCampaign),
+		nullString(lead.UTMContent),
+		nullString(lead.UTMTerm),
+		nullString(lead.LandingPage),
+	}
+
+	// O lead capturado pode ter o e-mail com outra caixa; a busca ignora maiúsculas.
+	err = tx.QueryRowContext(ctx, `
+		UPDATE leads SET
+			name = COALESCE(name, $2),
+			phone = COALESCE(phone, $3),
+			plan_id = COALESCE($4, plan_id),
+			checkout_at = COALESCE(checkout_at, NOW()),
+			utm_source = COALESCE(utm_source, $5),
+			utm_medium = COALESCE(utm_medium, $6),
+			utm_campaign = COALESCE(utm_campaign, $7),
+			utm_content = COALESCE(utm_content, $8),
+			utm_term = COALESCE(utm_term, $9),
+			landing_page = COALESCE(landing_page, $10),
+			updated_at = NOW()
+		WHERE id = (SELECT id FROM leads WHERE LOWER(email) = LOWER($1) ORDER BY created_at ASC LIMIT 1)
+		RETURNING id
+	`, args...).Scan(&lead.ID)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		// Checkout sem captura prévia: o lead nasce aqui para entrar no funil.
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO leads (email, name, phone, plan_id, checkout_at, utm_source, utm_medium, utm_campaign, utm_content, utm_term, landing_page, updated_at)
+			VALUES ($1, $2, $3, $4, NOW(), $5, $6, $7, $8, $9, $10, NOW())
+			ON CONFLICT (email) DO UPDATE SET
+				plan_id = COALESCE(EXCLUDED.plan_id, leads.plan_id),
+				checkout_at = COALESCE(leads.checkout_at, NOW()),
+				updated_at = NOW()
+			RETURNING id
+		`, args...).Scan(&lead.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao registrar checkout no lead: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE customers SET lead_id = $1 WHERE id = $2`, lead.ID, customerID); err != nil {
+		return fmt.Errorf("erro ao vincular customer %s ao lead: %w", customerID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("erro ao confirmar transação: %w", err)
+	}
+	return nil
+}
+
+func (r *LeadRepository) FunnelReport(ctx context.Context, from, to time.Time) ([]entity.LeadFunnelRow, error) {
+	query := `
+		SELECT
+			COALESCE(NULLIF(l.utm_source, ''), '(direto)') AS source,
+			COALESCE(l.plan_id, '') AS plan_id,
+			COALESCE(p.name, '') AS plan_name,
+			COUNT(*) AS captured,
+			COUNT(l.checkout_at) AS checkout,
+			COUNT(*) FILTER (WHERE l.status = 'CONVERTED') AS converted
+		FROM leads l
+		LEFT JOIN plans p ON p.id::text = l.plan_id
+		WHERE l.created_at >= $1 AND l.created_at < $2
+		GROUP BY 1, 2, 3
+		ORDER BY captured DESC, source, plan_name
+	`
+
+	rows, err := r.DB.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao gerar funil de leads: %w", err)
+	}
+	defer rows.Close()
+
+	var report []entity.LeadFunnelRow
+	for rows.Next() {
+		var row entity.LeadFunnelRow
+		if err := rows.Scan(&row.Source, &row.PlanID, &row.PlanName, &row.Captured, &row.Checkout, &row.Converted); err != nil {
+			return nil, fmt.Errorf("erro ao ler funil de leads: %w", err)
+		}
+		report = append(report, row)
+	}
+	return report, rows.Err()
+}
 
 func nullString(s string) *string {
 	if s == "" {
@@ -48,3 +161,120 @@ func nullString(s string) *string {
 	}
 	return &s
 }
+
+func (r *LeadRepository) FindDueForRecovery(ctx context.Context, stage int, sinceCapture, sinceLastEmail, maxAge time.Duration, limit int) ([]*entity.Lead, error) {
+	query := `
+		SELECT id, email, COALESCE(name, ''), COALESCE(phone, ''), status, email_stage,
+		       last_email_sent_at, created_at, updated_at
+		FROM leads
+		WHERE status = 'PENDING'
+		  AND unsubscribed_at IS NULL
+		  AND email_stage = $1
+		  AND created_at <= NOW() - $2::interval
+		  AND created_at > NOW() - $4::interval
+		  AND (last_email_sent_at IS NULL OR last_email_sent_at <= NOW() - $3::interval)
+		ORDER BY created_at ASC
+		LIMIT $5
+	`
+
+	captureInterval := fmt.Sprintf("%d seconds", int(sinceCapture.Seconds()))
+	lastEmailInterval := fmt.Sprintf("%d seconds", int(sinceLastEmail.Seconds()))
+	maxAgeInterval := fmt.Sprintf("%d seconds", int(maxAge.Seconds()))
+	rows, err := r.DB.QueryContext(ctx, query, stage, captureInterval, lastEmailInterval, maxAgeInterval, limit)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar leads para recuperação: %w", err)
+	}
+	defer rows.Close()
+
+	var leads []*entity.Lead
+	for rows.Next() {
+		var lead entity.Lead
+		var lastEmailSentAt sql.NullTime
+		if err := rows.Scan(
+			&lead.ID,
+			&lead.Email,
+			&lead.Name,
+			&lead.Phone,
+			&lead.Status,
+			&lead.EmailStage,
+			&lastEmailSentAt,
+			&lead.CreatedAt,
+			&lead.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("erro ao ler lead: %w", err)
+		}
+		if lastEmailSentAt.Valid {
+			lead.LastEmailSentAt = &lastEmailSentAt.Time
+		}
+		leads = append(leads, &lead)
+	}
+	return leads, rows.Err()
+}
+
+func (r *LeadRepository) AdvanceEmailStage(ctx context.Context, id string, fromStage int) (bool, error) {
+	query := `
+		UPDATE leads
+		SET email_stage = email_stage + 1, last_email_sent_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND email_stage = $2 AND status = 'PENDING'
+	`
+	result, err := r.DB.ExecContext(ctx, query, id, fromStage)
+	if err != nil {
+		return false, fmt.Errorf("erro ao avançar email_stage do lead %s: %w", id, err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+func (r *LeadRepository) RevertEmailStage(ctx context.Context, id string, fromStage int, lastEmailSentAt *time.Time) error {
+	query := `
+		UPDATE leads
+		SET email_stage = $2, last_email_sent_at = $3, updated_at = NOW()
+		WHERE id = $1 AND email_stage = $2 + 1
+	`
+	if _, err := r.DB.ExecContext(ctx, query, id, fromStage, lastEmailSentAt); err != nil {
+		return fmt.Errorf("erro ao desfazer email_stage do lead %s: %w", id, err)
+	}
+	return nil
+}
+
+func (r *LeadRepository) Unsubscribe(ctx context.Context, id string) error {
+	// id::text evita erro de cast com um ID malformado no link.
+	query := `
+		UPDATE leads SET unsubscribed_at = COALESCE(unsubscribed_at, NOW()), updated_at = NOW()
+		WHERE id::text = $1
+	`
+	result, err := r.DB.ExecContext(ctx, query, strings.TrimSpace(id))
+	if err != nil {
+		return fmt.Errorf("erro ao descadastrar lead %s: %w", id, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return entity.ErrLeadNotFound
+	}
+	return nil
+}
+
+func (r *LeadRepository) MarkConvertedByEmail(ctx context.Context, email string) error {
+	query := `
+		UPDATE leads SET status = 'CONVERTED', converted_at = NOW(), updated_at = NOW()
+		WHERE LOWER(email) = LOWER($1) AND status <> 'CONVERTED'
+	`
+	if _, err := r.DB.ExecContext(ctx, query, strings.TrimSpace(email)); err != nil {
+		return fmt.Errorf("erro ao converter lead %s: %w", email, err)
+	}
+	return nil
+}
+
+func (r *LeadRepository) MarkConvertedFromActiveCustomers(ctx context.Context) (int64, error) {
+	query := `
+		UPDATE leads l SET status = 'CONVERTED', converted_at = NOW(), updated_at = NOW()
+		FROM customers c
+		WHERE (c.lead_id = l.id OR LOWER(c.email) = LOWER(l.email))
+		  AND c.status = 'ACTIVE'
+		  AND l.status <> 'CONVERTED'
+	`
+	result, err := r.DB.ExecContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("erro ao converter leads de clientes ativos: %w", err)
+	}
+	return result.RowsAffected()
+}
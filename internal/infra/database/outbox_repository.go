@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/xavierca1/ligue-payments/internal/entity"
+)
+
+// outboxLease é o tempo que um evento fica reservado para um relay.
+const outboxLease = 2 * time.Minute
+
+type OutboxRepository struct {
+	DB *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) *OutboxRepository {
+	return &OutboxRepository{DB: db}
+}
+
+func (r *OutboxRepository) UpdateStatusWithOutbox(ctx context.Context, customerID, status, customerStatus string, event *entity.OutboxEvent) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE subscriptions SET status = $1, `+activatedAtOnActive+`, updated_at = NOW()
+		 WHERE id = (SELECT id FROM subscriptions WHERE customer_id = $2 ORDER BY created_at DESC LIMIT 1)`,
+		status, customerID,
+	)
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar status da subscription: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return fmt.Errorf("nenhuma subscription encontrada para customer_id=%s", customerID)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE customers SET status = $1, updated_at = NOW() WHERE id = $2`,
+		customerStatus, customerID,
+	); err != nil {
+		return fmt.Errorf("erro ao atualizar status do customer: %w", err)
+	}
+
+	if event != nil {
+		if err := insertOutboxEvent(ctx, tx, event); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("erro ao confirmar transação: %w", err)
+	}
+	return nil
+}
+
+// insertOutboxEvent grava o evento na transação da mudança de estado que o originou.
+func insertOutboxEvent(ctx context.Context, tx *sql.Tx, event *entity.OutboxEvent) error {
+	if strings.TrimSpace(event.ID) == "" {
+		event.ID = uuid.New().String()
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO outbox_events (id, aggregate_id, message_type, payload, status, attempts, next_attempt_at, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4::jsonb, 'PENDING', 0, NOW(), NOW(), NOW())`,
+		event.ID, event.AggregateID, event.MessageType, string(event.Payload),
+	); err != nil {
+		return fmt.Errorf("erro ao gravar evento no outbox: %w", err)
+	}
+	return nil
+}
+
+func (r *OutboxRepository) ClaimPending(ctx context.Context, limit int) ([]*entity.OutboxEvent, error) {
+	query := `
+		UPDATE outbox_events
+		SET status = 'PUBLISHING',
+			locked_until = NOW() + $2::interval,
+			updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE (status = 'PENDING' AND next_attempt_at <= NOW())
+			   OR (status = 'PUBLISHING' AND locked_until < NOW())
+			ORDER BY created_at ASC
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, aggregate_id, message_type, payload::text, status, attempts,
+		          COALESCE(last_error, ''), next_attempt_at, created_at
+	`
+
+	lease := fmt.Sprintf("%d seconds", int(outboxLease.Seconds()))
+	rows, err := r.DB.QueryContext(ctx, query, limit, lease)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao reservar eventos do outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*entity.OutboxEvent
+	for rows.Next() {
+		var event entity.OutboxEvent
+		var payload string
+		if err := rows.Scan(
+			&event.ID,
+			&event.AggregateID,
+			&event.MessageType,
+			&payload,
+			&event.Status,
+			&event.Attempts,
+			&event.LastError,
+			&event.NextAttemptAt,
+			&event.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("erro ao escanear evento do outbox: %w", err)
+		}
+		event.Payload = []byte(payload)
+		events = append(events, &event)
+	}
+
+	return events, rows.Err()
+}
+
+func (r *OutboxRepository) MarkSent(ctx context.Context, id string) error {
+	query := `
+		UPDATE outbox_events
+		SET status = 'SENT', attempts = attempts + 1, last_error = NULL,
+			locked_until = NULL, sent_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+	`
+	if _, err := r.DB.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("erro ao marcar evento %s como enviado: %w", id, err)
+	}
+	return nil
+}
+
+func (r *OutboxRepository) MarkRetry(ctx context.Context, id string, attempts int, nextAttemptAt time.Time, reason string) error {
+	query := `
+		UPDATE outbox_events
+		SET status = 'PENDING', attempts = $2, next_attempt_at = $3, last_error = $4,
+			locked_until = NULL, updated_at = NOW()
+		WHERE id = $1
+	`
+	if _, err := r.DB.ExecContext(ctx, query, id, attempts, nextAttemptAt, reason); err != nil {
+		return fmt.Errorf("erro ao reagendar evento %s do outbox: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/xavierca1/ligue-payments/internal/entity"
+)
+
+type PixReconciliationRepository struct {
+	DB *sql.DB
+}
+
+func NewPixReconciliationRepository(db *sql.DB) *PixReconciliationRepository {
+	return &PixReconciliationRepository{DB: db}
+}
+
+func (r *PixReconciliationRepository) FindStalePending(ctx context.Context, olderThan time.Duration, maxAttempts, limit int) ([]*entity.Subscription, error) {
+	query := `
+		SELECT
+			id,
+			customer_id,
+			plan_id,
+			product_id,
+			amount,
+			status,
+			created_at,
+			updated_at,
+			COALESCE(payment_method_id, ''),
+			COALESCE(payment_method, ''),
+			pix_expires_at
+		FROM subscriptions
+		WHERE
+			status = 'PENDING'
+			AND payment_method = 'PIX'
+			AND COALESCE(pix_expires_at, created_at + $1::interval) < NOW()
+			AND pix_reconcile_attempts < $2
+			AND (pix_next_reconcile_at IS NULL OR pix_next_reconcile_at <= NOW())
+		ORDER BY created_at ASC
+		LIMIT $3
+	`
+
+	rows, err := r.DB.QueryContext(ctx, query, intervalSeconds(olderThan), maxAttempts, limit)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar PIX pendentes: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []*entity.Subscription
+	for rows.Next() {
+		var sub entity.Subscription
+		if err := rows.Scan(
+			&sub.ID,
+			&sub.CustomerID,
+			&sub.PlanID,
+			&sub.ProductID,
+			&sub.Amount,
+			&sub.Status,
+			&sub.CreatedAt,
+			&sub.UpdatedAt,
+			&sub.PaymentMethodID,
+			&sub.PaymentMethod,
+			&sub.PixExpiresAt,
+		); err != nil {
+			return nil, fmt.Errorf("erro ao escanear PIX pendente: %w", err)
+		}
+		subs = append(subs, &sub)
+	}
+
+	return subs, rows.Err()
+}
+
+func (r *PixReconciliationRepository) Expire(ctx context.Context, subscriptionID string) (bool, error) {
+	expiredStatus, err := r.resolveExpiredStatusLabel(ctx)
+	if err != nil {
+		return false, fmt.Errorf("erro ao resolver enum sub_status para expiração PIX: %w", err)
+	}
+	if expiredStatus == "" {
+		return false, fmt.Errorf("nenhum status de expiração válido encontrado no enum sub_status (EXPIRED/CANCELED/CANCELLED)")
+	}
+
+	// A venda de cupom do checkout expirado é anulada no mesmo comando e deixa de contar no limite.
+	query := `
+		WITH expired AS (
+			UPDATE subscriptions SET status = $1::sub_status, ` + activatedAtOnActive + `, updated_at = NOW()
+			WHERE id = $2 AND status = 'PENDING'
+			RETURNING id
+		), voided AS (
+			UPDATE coupon_sales SET voided_at = NOW()
+			WHERE subscription_id IN (SELECT id FROM expired) AND voided_at IS NULL
+		)
+		SELECT COUNT(*) FROM expired
+	`
+
+	var expired int
+	if err := r.DB.QueryRowContext(ctx, query, expiredStatus, subscriptionID).Scan(&expired); err != nil {
+		return false, fmt.Errorf("erro ao expirar assinatura %s: %w", subscriptionID, err)
+	}
+	return expired > 0, nil
+}
+
+func (r *PixReconciliationRepository) RegisterFailure(ctx context.Context, subscriptionID string, baseDelay, maxDelay time.Duration) (int, error) {
+	query := `
+		UPDATE subscriptions
+		SET pix_reconcile_attempts = pix_reconcile_attempts + 1,
+			pix_next_reconcile_at = NOW() + LEAST($2::interval * power(2, pix_reconcile_attempts), $3::interval)
+		WHERE id = $1
+		RETURNING pix_reconcile_attempts
+	`
+
+	var attempts int
+	if err := r.DB.QueryRowContext(ctx, query, subscriptionID, intervalSeconds(baseDelay), intervalSeconds(maxDelay)).Scan(&attempts); err != nil {
+		return 0, fmt.Errorf("erro ao registrar falha da conciliação PIX %s: %w", subscriptionID, err)
+	}
+	return attempts, nil
+}
+
+func (r *PixReconciliationRepository) Record(ctx context.Context, reconciliation *entity.PixReconciliation) error {
+	query := `
+		INSERT INTO pix_reconciliations (id, subscription_id, customer_id, gateway_subscription_id, payment_status, action, detail, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, NULLIF($7, ''), $8)
+	`
+	if _, err := r.DB.ExecContext(ctx, query,
+		reconciliation.ID,
+		reconciliation.SubscriptionID,
+		reconciliation.CustomerID,
+		reconciliation.GatewaySubscriptionID,
+		reconciliation.PaymentStatus,
+		reconciliation.Action,
+		reconciliation.Detail,
+		reconciliation.CreatedAt,
+	); err != nil {
+		return fmt.Errorf("erro ao registrar conciliação PIX: %w", err)
+	}
+	return nil
+}
+
+func intervalSeconds(d time.Duration) string {
+	return fmt.Sprintf("%d seconds", int(d.Seconds()))
+}
+
+// resolveExpiredStatusLabel escolhe o rótulo de expiração existente no enum sub_status.
+func (r *PixReconciliationRepository) resolveExpiredStatusLabel(ctx context.Context) (string, error) {
+	rows, err := r.DB.QueryContext(ctx, `SELECT unnest(enum_range(NULL::sub_status)::text[])`)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	labels := map[string]bool{}
+	for rows.Next() {
+		var label string
+		if err := rows.Scan(&label); err != nil {
+			return "", err
+		}
+		labels[label] = true
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	if labels["EXPIRED"] {
+		return "EXPIRED", nil
+	}
+	if labels["CANCELED"] {
+		return "CANCELED", nil
+	}
+	if labels["CANCELLED"] {
+		return "CANCELLED", nil
+	}
+
+	return "", nil
+}
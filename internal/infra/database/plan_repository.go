@@ -3,6 +3,8 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
 
 	"github.com/xavierca1/ligue-payments/internal/entity"
 )
@@ -15,23 +17,101 @@ func NewPlanRepository(db *sql.DB) *PlanRepository {
 	return &PlanRepository{DB: db}
 }
 
-func (r *PlanRepository) FindByID(ctx context.Context, id string) (*entity.Plan, error) {
-
-	query := `SELECT id, name, price_cents, provider, product_id, COALESCE(provider_plan_code, '') FROM plans WHERE id = $1`
+const planColumns = `
+	p.id, p.name, p.price_cents, p.provider, p.product_id, COALESCE(p.provider_plan_code, ''),
+	COALESCE(p.pix_expiration_minutes, pr.pix_expiration_minutes, 0),
+	p.billing_cycle, p.max_dependents, p.included_dependents, p.extra_dependent_price_cents,
+	p.child_max_age, p.student_child_max_age, p.max_spouses, p.allowed_kinships,
+	p.is_active, p.is_visible,
+	COALESCE(p.description, ''), p.features, COALESCE(p.badge, ''), p.display_order`
 
+func scanPlan(scanner interface{ Scan(dest ...any) error }) (*entity.Plan, error) {
 	var plan entity.Plan
+	var maxDependents sql.NullInt64
+	var isActive, isVisible bool
+	var features, allowedKinships []byte
 
-	err := r.DB.QueryRowContext(ctx, query, id).Scan(
+	err := scanner.Scan(
 		&plan.ID,
 		&plan.Name,
 		&plan.PriceCents,
 		&plan.Provider,
 		&plan.ProductID,
 		&plan.ProviderPlanCode,
+		&plan.PixExpirationMinutes,
+		&plan.BillingCycle,
+		&maxDependents,
+		&plan.IncludedDependents,
+		&plan.ExtraDependentPriceCents,
+		&plan.DependentRules.ChildMaxAge,
+		&plan.DependentRules.StudentChildMaxAge,
+		&plan.DependentRules.MaxSpouses,
+		&allowedKinships,
+		&isActive,
+		&isVisible,
+		&plan.Description,
+		&features,
+		&plan.Badge,
+		&plan.DisplayOrder,
 	)
-
 	if err != nil {
 		return nil, err
 	}
+
+	if maxDependents.Valid {
+		limit := int(maxDependents.Int64)
+		plan.MaxDependents = &limit
+	}
+	plan.Inactive = !isActive
+	plan.Hidden = !isVisible
+
+	plan.Features = []string{}
+	if len(features) > 0 {
+		if err := json.Unmarshal(features, &plan.Features); err != nil {
+			return nil, fmt.Errorf("features inválidas no plano %s: %w", plan.ID, err)
+		}
+	}
+	if len(allowedKinships) > 0 {
+		if err := json.Unmarshal(allowedKinships, &plan.DependentRules.AllowedKinships); err != nil {
+			return nil, fmt.Errorf("allowed_kinships inválido no plano %s: %w", plan.ID, err)
+		}
+	}
 	return &plan, nil
 }
+
+func (r *PlanRepository) FindByID(ctx context.Context, id string) (*entity.Plan, error) {
+
+	query := `
+		SELECT ` + planColumns + `
+		FROM plans p
+		LEFT JOIN products pr ON pr.id = p.product_id
+		WHERE p.id = $1`
+
+	return scanPlan(r.DB.QueryRowContext(ctx, query, id))
+}
+
+func (r *PlanRepository) ListCatalog(ctx context.Context, productID string) ([]*entity.Plan, error) {
+	query := `
+		SELECT ` + planColumns + `
+		FROM plans p
+		LEFT JOIN products pr ON pr.id = p.product_id
+		WHERE p.is_active = TRUE AND p.is_visible = TRUE
+		  AND ($1 = '' OR p.product_id::text = $1)
+		ORDER BY p.product_id, p.display_order, p.price_cents`
+
+	rows, err := r.DB.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao listar planos: %w", err)
+	}
+	defer rows.Close()
+
+	plans := []*entity.Plan{}
+	for rows.Next() {
+		plan, err := scanPlan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler plano: %w", err)
+		}
+		plans = append(plans, plan)
+	}
+	return plans, rows.Err()
+}
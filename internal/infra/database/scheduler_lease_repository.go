@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SchedulerLeaseRepository implementa a eleição de líder dos jobs periódicos com uma
+// linha por job em scheduler_leases. Diferente de pg_advisory_lock, não depende de
+// manter uma sessão aberta, então funciona atrás do pooler em modo transação.
+type SchedulerLeaseRepository struct {
+	DB *sql.DB
+}
+
+func NewSchedulerLeaseRepository(db *sql.DB) *SchedulerLeaseRepository {
+	return &SchedulerLeaseRepository{DB: db}
+}
+
+// TryAcquire assume o lease se ele estiver vencido ou já for do próprio holder.
+// Todas as comparações usam o relógio do Postgres, não o das réplicas.
+func (r *SchedulerLeaseRepository) TryAcquire(ctx context.Context, job, holder string, ttl time.Duration) (bool, error) {
+	query := `
+		INSERT INTO scheduler_leases (job_name, holder, leased_until, acquired_at, updated_at)
+		VALUES ($1, $2, NOW() + $3::interval, NOW(), NOW())
+		ON CONFLICT (job_name) DO UPDATE
+		SET holder = EXCLUDED.holder,
+			leased_until = EXCLUDED.leased_until,
+			acquired_at = CASE WHEN scheduler_leases.holder = EXCLUDED.holder
+				THEN scheduler_leases.acquired_at ELSE NOW() END,
+			updated_at = NOW()
+		WHERE scheduler_leases.leased_until <= NOW()
+		   OR scheduler_leases.holder = EXCLUDED.holder
+		RETURNING holder
+	`
+
+	lease := fmt.Sprintf("%d milliseconds", ttl.Milliseconds())
+	var current string
+	err := r.DB.QueryRowContext(ctx, query, job, holder, lease).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Outra réplica detém o lease vigente.
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("erro ao disputar lease do job %s: %w", job, err)
+	}
+	return current == holder, nil
+}
+
+// Release vence o lease do holder para outra réplica assumir no próximo tick.
+func (r *SchedulerLeaseRepository) Release(ctx context.Context, job, holder string) error {
+	query := `UPDATE scheduler_leases SET leased_until = NOW(), updated_at = NOW() WHERE job_name = $1 AND holder = $2`
+	if _, err := r.DB.ExecContext(ctx, query, job, holder); err != nil {
+		return fmt.Errorf("erro ao liberar lease do job %s: %w", job, err)
+	}
+	return nil
+}
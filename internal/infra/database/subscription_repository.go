@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/xavierca1/ligue-payments/internal/entity"
 )
@@ -32,12 +33,20 @@ func (r *SubscriptionRepository) Create(ctx context.Context, sub *entity.Subscri
 			created_at, 
 			updated_at,
 			payment_method,    -- $10
-            payment_method_id  -- $11 (Pode ser Null)
+            payment_method_id, -- $11 (Pode ser Null)
+			pix_expires_at,    -- $12 (só PIX)
+			full_amount_cents, -- $13 (só cupom de N ciclos)
+			discount_cycles_remaining, -- $14
+			interval           -- $15 (ciclo do plano)
 		) VALUES (
 			$1, 
             $2::uuid,          -- 🆕 Forçamos o UUID aqui
             $3, $4, $5, $6, $7, $8, $9, $10,
-			NULLIF($11, '')
+			NULLIF($11, ''),
+			$12,
+			NULLIF($13, 0),
+			$14,
+			COALESCE(NULLIF($15, ''), 'MONTHLY')::sub_interval
 		)
 	`
 
@@ -47,17 +56,21 @@ func (r *SubscriptionRepository) Create(ctx context.Context, sub *entity.Subscri
 	_, err := r.DB.ExecContext(
 		ctx,
 		query,
-		sub.ID,              // $1
-		pid,                 // $2 (AQUI ESTÁ A CORREÇÃO)
-		sub.CustomerID,      // $3
-		sub.PlanID,          // $4
-		sub.Amount,          // $5
-		sub.Status,          // $6
-		sub.NextBillingDate, // $7
-		sub.CreatedAt,       // $8
-		sub.UpdatedAt,       // $9
-		sub.PaymentMethod,   // $10
-		sub.PaymentMethodID, // $11
+		sub.ID,                      // $1
+		pid,                         // $2 (AQUI ESTÁ A CORREÇÃO)
+		sub.CustomerID,              // $3
+		sub.PlanID,                  // $4
+		sub.Amount,                  // $5
+		sub.Status,                  // $6
+		sub.NextBillingDate,         // $7
+		sub.CreatedAt,               // $8
+		sub.UpdatedAt,               // $9
+		sub.PaymentMethod,           // $10
+		sub.PaymentMethodID,         // $11
+		sub.PixExpiresAt,            // $12
+		sub.FullAmountCents,         // $13
+		sub.DiscountCyclesRemaining, // $14
+		sub.Interval,                // $15
 	)
 
 	if err != nil {
@@ -67,9 +80,13 @@ func (r *SubscriptionRepository) Create(ctx context.Context, sub *entity.Subscri
 	return nil
 }
 
+// activatedAtOnActive registra a primeira ativação da assinatura ($1 é o novo status). Usado
+// pelo relatório de vendedores: a assinatura conta mesmo que depois seja cancelada.
+const activatedAtOnActive = `activated_at = CASE WHEN $1 = 'ACTIVE' THEN COALESCE(activated_at, NOW()) ELSE activated_at END`
+
 func (r *SubscriptionRepository) UpdateStatus(id string, status string) error {
 	// Tentamos atualizar por customer_id (ID local do cliente)
-	query := `UPDATE subscriptions SET status = $1, updated_at = NOW() WHERE customer_id = $2`
+	query := `UPDATE subscriptions SET status = $1, ` + activatedAtOnActive + `, updated_at = NOW() WHERE customer_id = $2`
 	result, err := r.DB.Exec(query, status, id)
 	if err != nil {
 		fmt.Printf("❌ UpdateStatus: SQL error (customer_id=%s) para status=%s: %v\n", id, status, err)
@@ -82,7 +99,7 @@ func (r *SubscriptionRepository) UpdateStatus(id string, status string) error {
 	}
 
 	// Se nada foi atualizado, talvez o identificador recebido seja o payment_method_id (gateway subscription/payment id)
-	query = `UPDATE subscriptions SET status = $1, updated_at = NOW() WHERE payment_method_id = $2`
+	query = `UPDATE subscriptions SET status = $1, ` + activatedAtOnActive + `, updated_at = NOW() WHERE payment_method_id = $2`
 	result, err = r.DB.Exec(query, status, id)
 	if err != nil {
 		fmt.Printf("❌ UpdateStatus: SQL error (payment_method_id=%s) para status=%s: %v\n", id, status, err)
@@ -95,7 +112,7 @@ func (r *SubscriptionRepository) UpdateStatus(id string, status string) error {
 	}
 
 	// Por fim, tente atualizar pela própria subscription.id
-	query = `UPDATE subscriptions SET status = $1, updated_at = NOW() WHERE id = $2`
+	query = `UPDATE subscriptions SET status = $1, ` + activatedAtOnActive + `, updated_at = NOW() WHERE id = $2`
 	result, err = r.DB.Exec(query, status, id)
 	if err != nil {
 		fmt.Printf("❌ UpdateStatus: SQL error (id=%s) para status=%s: %v\n", id, status, err)
@@ -109,6 +126,19 @@ func (r *SubscriptionRepository) UpdateStatus(id string, status string) error {
 	return nil
 }
 
+// UpdateStatusByID atualiza só a assinatura informada; as demais do cliente ficam como estão.
+func (r *SubscriptionRepository) UpdateStatusByID(ctx context.Context, subscriptionID, status string) error {
+	query := `UPDATE subscriptions SET status = $1, ` + activatedAtOnActive + `, updated_at = NOW() WHERE id = $2`
+	result, err := r.DB.ExecContext(ctx, query, status, subscriptionID)
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar status da subscription %s: %w", subscriptionID, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("subscription %s não encontrada", subscriptionID)
+	}
+	return nil
+}
+
 func (r *SubscriptionRepository) GetStatusByCustomerID(customerID string) (string, error) {
 	query := `SELECT status FROM subscriptions WHERE customer_id = $1 ORDER BY created_at DESC LIMIT 1`
 	var status string
@@ -141,7 +171,11 @@ func (r *SubscriptionRepository) FindLastByCustomerID(ctx context.Context, custo
 			created_at,
 			updated_at,
 			COALESCE(payment_method_id, ''),
-			COALESCE(payment_method, '')
+			COALESCE(payment_method, ''),
+			COALESCE(full_amount_cents, 0),
+			discount_cycles_remaining,
+			cancel_at,
+			activated_at
 		FROM subscriptions
 		WHERE customer_id = $1
 		ORDER BY created_at DESC
@@ -162,6 +196,10 @@ func (r *SubscriptionRepository) FindLastByCustomerID(ctx context.Context, custo
 		&sub.UpdatedAt,
 		&sub.PaymentMethodID,
 		&sub.PaymentMethod,
+		&sub.FullAmountCents,
+		&sub.DiscountCyclesRemaining,
+		&sub.CancelAt,
+		&sub.ActivatedAt,
 	)
 
 	if err != nil {
@@ -170,3 +208,225 @@ func (r *SubscriptionRepository) FindLastByCustomerID(ctx context.Context, custo
 
 	return &sub, nil
 }
+
+// ConsumeDiscountCycle desconta um ciclo do cupom da assinatura do Asaas. O pagamento fica
+// registrado em subscription_discount_payments, então eventos repetidos da mesma cobrança
+// não consomem outro ciclo, mas continuam retornando o estado (para o retry restaurar o valor).
+// Com discount_from_due_date (crédito de downgrade), cobranças que vencem antes dessa data,
+// como um PAYMENT_RECEIVED atrasado do ciclo já pago, não consomem o desconto.
+func (r *SubscriptionRepository) ConsumeDiscountCycle(ctx context.Context, gatewaySubscriptionID, paymentID string, dueDate time.Time) (*entity.DiscountCycleState, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback()
+
+	var state entity.DiscountCycleState
+	var fromDueDate sql.NullTime
+	err = tx.QueryRowContext(ctx,
+		`SELECT id, COALESCE(full_amount_cents, amount), discount_cycles_remaining, discount_from_due_date
+		 FROM subscriptions
+		 WHERE payment_method_id = $1 AND discount_cycles_remaining IS NOT NULL
+		 ORDER BY created_at DESC
+		 LIMIT 1
+		 FOR UPDATE`,
+		gatewaySubscriptionID,
+	).Scan(&state.SubscriptionID, &state.FullAmountCents, &state.CyclesRemaining, &fromDueDate)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar desconto da assinatura %s: %w", gatewaySubscriptionID, err)
+	}
+
+	if fromDueDate.Valid && (dueDate.IsZero() || dueDate.Before(fromDueDate.Time)) {
+		return &state, nil
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO subscription_discount_payments (payment_id, subscription_id, created_at)
+		 VALUES ($1, $2, NOW())
+		 ON CONFLICT (payment_id) DO NOTHING`,
+		paymentID, state.SubscriptionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao registrar pagamento do desconto: %w", err)
+	}
+
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 {
+		err = tx.QueryRowContext(ctx,
+			`UPDATE subscriptions
+			 SET discount_cycles_remaining = GREATEST(discount_cycles_remaining - 1, 0), updated_at = NOW()
+			 WHERE id = $1
+			 RETURNING discount_cycles_remaining`,
+			state.SubscriptionID,
+		).Scan(&state.CyclesRemaining)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao consumir ciclo de desconto: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("erro ao confirmar transação: %w", err)
+	}
+	return &state, nil
+}
+
+func (r *SubscriptionRepository) RestoreFullAmount(ctx context.Context, subscriptionID string) error {
+	_, err := r.DB.ExecContext(ctx,
+		`UPDATE subscriptions
+		 SET amount = COALESCE(full_amount_cents, amount), discount_cycles_remaining = NULL, discount_from_due_date = NULL, updated_at = NOW()
+		 WHERE id = $1`,
+		subscriptionID,
+	)
+	if err != nil {
+		return fmt.Errorf("erro ao restaurar valor cheio da assinatura %s: %w", subscriptionID, err)
+	}
+	return nil
+}
+
+const planChangeColumns = `
+	id, subscription_id, customer_id, from_plan_id, to_plan_id,
+	from_amount_cents, to_amount_cents, next_amount_cents, prorated_cents,
+	COALESCE(charge_id, ''), COALESCE(coupon_code, ''), credit_from_date, status, created_at`
+
+// FindPendingPlanChange retorna a troca que ficou PENDING (gateway chamado, banco não trocado).
+func (r *SubscriptionRepository) FindPendingPlanChange(ctx context.Context, subscriptionID string) (*entity.PlanChange, error) {
+	var change entity.PlanChange
+	var creditFrom sql.NullTime
+	err := r.DB.QueryRowContext(ctx,
+		`SELECT `+planChangeColumns+`
+		 FROM subscription_plan_changes
+		 WHERE subscription_id = $1 AND status = 'PENDING'`,
+		subscriptionID,
+	).Scan(
+		&change.ID, &change.SubscriptionID, &change.CustomerID, &change.FromPlanID, &change.ToPlanID,
+		&change.FromAmountCents, &change.ToAmountCents, &change.NextAmountCents, &change.ProratedCents,
+		&change.ChargeID, &change.CouponCode, &creditFrom, &change.Status, &change.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar troca de plano pendente da assinatura %s: %w", subscriptionID, err)
+	}
+	if creditFrom.Valid {
+		change.CreditFromDate = creditFrom.Time
+	}
+	return &change, nil
+}
+
+// CreatePendingPlanChange grava a troca antes de cobrar o pró-rata ou alterar o valor no Asaas.
+// O índice único de trocas PENDING impede duas trocas simultâneas da mesma assinatura.
+func (r *SubscriptionRepository) CreatePendingPlanChange(ctx context.Context, change *entity.PlanChange) error {
+	var creditFrom any
+	if !change.CreditFromDate.IsZero() {
+		creditFrom = change.CreditFromDate
+	}
+
+	result, err := r.DB.ExecContext(ctx,
+		`INSERT INTO subscription_plan_changes (
+			id, subscription_id, customer_id, from_plan_id, to_plan_id,
+			from_amount_cents, to_amount_cents, next_amount_cents, prorated_cents,
+			coupon_code, credit_from_date, status, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), $11, 'PENDING', $12)
+		ON CONFLICT DO NOTHING`,
+		change.ID, change.SubscriptionID, change.CustomerID, change.FromPlanID, change.ToPlanID,
+		change.FromAmountCents, change.ToAmountCents, change.NextAmountCents, change.ProratedCents,
+		change.CouponCode, creditFrom, change.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("erro ao registrar troca de plano: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return entity.ErrPlanChangeConflict
+	}
+	return nil
+}
+
+func (r *SubscriptionRepository) SetPlanChangeCharge(ctx context.Context, changeID, chargeID string) error {
+	_, err := r.DB.ExecContext(ctx,
+		`UPDATE subscription_plan_changes SET charge_id = $2 WHERE id = $1 AND status = 'PENDING'`,
+		changeID, chargeID,
+	)
+	if err != nil {
+		return fmt.Errorf("erro ao registrar cobrança %s da troca de plano %s: %w", chargeID, changeID, err)
+	}
+	return nil
+}
+
+// ApplyPlanChange troca o plano da assinatura e do cliente. Um crédito de downgrade vira um
+// desconto de um ciclo (full_amount_cents/discount_cycles_remaining) que só a cobrança com
+// vencimento em credit_from_date em diante consome; o CouponCyclesUseCase restaura o valor
+// cheio depois dela. A troca encerra qualquer desconto temporário anterior.
+func (r *SubscriptionRepository) ApplyPlanChange(ctx context.Context, change *entity.PlanChange) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("erro ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback()
+
+	var fullAmount, cyclesRemaining, creditFrom any
+	if change.CreditCents() > 0 {
+		fullAmount, cyclesRemaining = change.ToAmountCents, 1
+		if !change.CreditFromDate.IsZero() {
+			creditFrom = change.CreditFromDate
+		}
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE subscriptions
+		 SET plan_id = $3, amount = $4, full_amount_cents = $5, discount_cycles_remaining = $6,
+		     discount_from_due_date = $7, updated_at = NOW()
+		 WHERE id = $1 AND plan_id = $2 AND status = 'ACTIVE'`,
+		change.SubscriptionID, change.FromPlanID, change.ToPlanID, change.NextAmountCents, fullAmount, cyclesRemaining, creditFrom,
+	)
+	if err != nil {
+		return fmt.Errorf("erro ao trocar plano da assinatura %s: %w", change.SubscriptionID, err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return entity.ErrPlanChangeConflict
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE customers SET plan_id = $2, updated_at = NOW() WHERE id = $1`,
+		change.CustomerID, change.ToPlanID,
+	); err != nil {
+		return fmt.Errorf("erro ao trocar plano do cliente %s: %w", change.CustomerID, err)
+	}
+
+	result, err = tx.ExecContext(ctx,
+		`UPDATE subscription_plan_changes
+		 SET status = 'APPLIED', charge_id = COALESCE(NULLIF($2, ''), charge_id), applied_at = NOW()
+		 WHERE id = $1 AND status = 'PENDING'`,
+		change.ID, change.ChargeID,
+	)
+	if err != nil {
+		return fmt.Errorf("erro ao concluir troca de plano %s: %w", change.ID, err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return entity.ErrPlanChangeConflict
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("erro ao confirmar transação: %w", err)
+	}
+	change.Status = entity.PlanChangeApplied
+	return nil
+}
+
+func (r *SubscriptionRepository) FailPlanChange(ctx context.Context, changeID, reason string) error {
+	result, err := r.DB.ExecContext(ctx,
+		`UPDATE subscription_plan_changes
+		 SET status = 'FAILED', failure_reason = $2, failed_at = NOW()
+		 WHERE id = $1 AND status = 'PENDING'`,
+		changeID, reason,
+	)
+	if err != nil {
+		return fmt.Errorf("erro ao abandonar troca de plano %s: %w", changeID, err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return entity.ErrPlanChangeConflict
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/xavierca1/ligue-payments/internal/entity"
+)
+
+// webhookEventLease é o tempo que um evento fica reservado para um dispatcher.
+// Se o processo morrer no meio do processamento, o evento volta a ser elegível após o lease.
+const webhookEventLease = 5 * time.Minute
+
+type WebhookEventRepository struct {
+	DB *sql.DB
+}
+
+func NewWebhookEventRepository(db *sql.DB) *WebhookEventRepository {
+	return &WebhookEventRepository{DB: db}
+}
+
+func (r *WebhookEventRepository) Save(ctx context.Context, event *entity.WebhookEvent) (bool, error) {
+	if strings.TrimSpace(event.ID) == "" {
+		event.ID = uuid.New().String()
+	}
+	if strings.TrimSpace(event.Source) == "" {
+		event.Source = "ASAAS"
+	}
+	if strings.TrimSpace(event.Status) == "" {
+		event.Status = entity.WebhookEventPending
+	}
+
+	query := `
+		INSERT INTO webhook_events (
+			id, source, event_key, event_type, payment_id, customer_ref, payload,
+			status, attempts, last_error, next_attempt_at, created_at, updated_at
+		) VALUES (
+			$1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7::jsonb,
+			$8, 0, NULLIF($9, ''), NOW(), NOW(), NOW()
+		)
+		ON CONFLICT (source, event_key) DO NOTHING
+	`
+
+	result, err := r.DB.ExecContext(ctx, query,
+		event.ID,
+		event.Source,
+		event.EventKey,
+		event.EventType,
+		event.PaymentID,
+		event.CustomerRef,
+		string(event.Payload),
+		event.Status,
+		event.LastError,
+	)
+	if err != nil {
+		return false, fmt.Errorf("erro ao gravar webhook na inbox: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+// ClaimDue reserva os eventos pendentes e os de lease vencido. O lease vencido significa que o
+// processo caiu no meio da tentativa, então ela conta em attempts: um evento que derruba o
+// processo chega ao limite e vira FAILED em vez de ser reprocessado para sempre.
+func (r *WebhookEventRepository) ClaimDue(ctx context.Context, limit int) ([]*entity.WebhookEvent, error) {
+	query := `
+		UPDATE webhook_events
+		SET attempts = attempts + CASE WHEN status = 'PROCESSING' THEN 1 ELSE 0 END,
+			status = 'PROCESSING',
+			locked_until = NOW() + $2::interval,
+			updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM webhook_events
+			WHERE (status = 'PENDING' AND next_attempt_at <= NOW())
+			   OR (status = 'PROCESSING' AND locked_until < NOW())
+			ORDER BY created_at ASC
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, source, event_key, event_type, COALESCE(payment_id, ''), COALESCE(customer_ref, ''),
+		          payload::text, status, attempts, COALESCE(last_error, ''), next_attempt_at, created_at, updated_at
+	`
+
+	lease := fmt.Sprintf("%d seconds", int(webhookEventLease.Seconds()))
+	rows, err := r.DB.QueryContext(ctx, query, limit, lease)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao reservar webhooks pendentes: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*entity.WebhookEvent
+	for rows.Next() {
+		var event entity.WebhookEvent
+		var payload string
+		if err := rows.Scan(
+			&event.ID,
+			&event.Source,
+			&event.EventKey,
+			&event.EventType,
+			&event.PaymentID,
+			&event.CustomerRef,
+			&payload,
+			&event.Status,
+			&event.Attempts,
+			&event.LastError,
+			&event.NextAttemptAt,
+			&event.CreatedAt,
+			&event.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("erro ao escanear webhook: %w", err)
+		}
+		event.Payload = []byte(payload)
+		events = append(events, &event)
+	}
+
+	return events, rows.Err()
+}
+
+func (r *WebhookEventRepository) MarkProcessed(ctx context.Context, id string) error {
+	query := `
+		UPDATE webhook_events
+		SET status = 'PROCESSED', attempts = attempts + 1, last_error = NULL,
+			locked_until = NULL, processed_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+	`
+	if _, err := r.DB.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("erro ao marcar webhook %s como processado: %w", id, err)
+	}
+	return nil
+}
+
+func (r *WebhookEventRepository) MarkRetry(ctx context.Context, id string, attempts int, nextAttemptAt time.Time, reason string) error {
+	query := `
+		UPDATE webhook_events
+		SET status = 'PENDING', attempts = $2, next_attempt_at = $3, last_error = $4,
+			locked_until = NULL, updated_at = NOW()
+		WHERE id = $1
+	`
+	if _, err := r.DB.ExecContext(ctx, query, id, attempts, nextAttemptAt, reason); err != nil {
+		return fmt.Errorf("erro ao reagendar webhook %s: %w", id, err)
+	}
+	return nil
+}
+
+func (r *WebhookEventRepository) MarkFailed(ctx context.Context, id string, attempts int, reason string) error {
+	query := `
+		UPDATE webhook_events
+		SET status = 'FAILED', attempts = $2, last_error = $3, locked_until = NULL, updated_at = NOW()
+		WHERE id = $1
+	`
+	if _, err := r.DB.ExecContext(ctx, query, id, attempts, reason); err != nil {
+		return fmt.Errorf("erro ao marcar webhook %s como falho: %w", id, err)
+	}
+	return nil
+}
+
+func (r *WebhookEventRepository) Requeue(ctx context.Context, idOrKey string) error {
+	query := `
+		UPDATE webhook_events
+		SET status = 'PENDING', attempts = 0, next_attempt_at = NOW(), last_error = NULL,
+			locked_until = NULL, processed_at = NULL, updated_at = NOW()
+		WHERE id::text = $1 OR event_key = $1
+	`
+	result, err := r.DB.ExecContext(ctx, query, strings.TrimSpace(idOrKey))
+	if err != nil {
+		return fmt.Errorf("erro ao reenfileirar webhook %s: %w", idOrKey, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return entity.ErrWebhookEventNotFound
+	}
+	return nil
+}
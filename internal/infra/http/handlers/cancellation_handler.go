@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/xavierca1/ligue-payments/internal/usecase"
+)
+
+type CancellationHandler struct {
+	CancelUC usecase.CancelSubscriptionInterface
+}
+
+func NewCancellationHandler(uc usecase.CancelSubscriptionInterface) *CancellationHandler {
+	return &CancellationHandler{CancelUC: uc}
+}
+
+// Cancel recebe o pedido de cancelamento do cliente. Sem "confirm" e com cupom de retenção
+// disponível, devolve a oferta; "accept_retention" aplica o cupom em vez de cancelar.
+func (h *CancellationHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	var input usecase.CancelSubscriptionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "INVALID_JSON", "JSON inválido")
+		return
+	}
+	input.CustomerID = chi.URLParam(r, "id")
+
+	output, err := h.CancelUC.Execute(r.Context(), input)
+	if err != nil {
+		if de, ok := err.(*usecase.DomainError); ok {
+			writeErrorResponse(w, cancellationErrorStatus(de.Code), de.Code, de.Message)
+			return
+		}
+		log.Printf("❌ Cancelamento (customer=%s): %v", input.CustomerID, err)
+		if te, ok := err.(*usecase.TechnicalError); ok {
+			writeErrorResponse(w, http.StatusInternalServerError, te.Code, te.Message)
+			return
+		}
+		writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, output)
+}
+
+func cancellationErrorStatus(code string) int {
+	switch code {
+	case "CUSTOMER_NOT_FOUND", "SUBSCRIPTION_NOT_FOUND":
+		return http.StatusNotFound
+	case "CPF_MISMATCH":
+		return http.StatusForbidden
+	case "SUBSCRIPTION_ALREADY_CANCELED", "CANCELLATION_ALREADY_SCHEDULED":
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
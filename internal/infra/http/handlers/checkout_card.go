@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/infra/integration/asaas"
+	"github.com/xavierca1/ligue-payments/internal/usecase"
+)
+
+// CardTokenizationGateway troca o cartão digitado pelo creditCardToken do Asaas.
+type CardTokenizationGateway interface {
+	TokenizeCreditCard(input asaas.TokenizeCreditCardInput) (*asaas.CreditCardToken, error)
+}
+
+// checkoutCard guarda o cartão digitado no checkout. É o único lugar onde PAN e CVV
+// existem: o use case só o enxerga como CardTokenizer, e os dados são apagados após a
+// tokenização ou, se ela não acontecer, quando o handler retorna.
+type checkoutCard struct {
+	Holder string `json:"card_holder"`
+	Number string `json:"card_number"`
+	Month  string `json:"card_month"`
+	Year   string `json:"card_year"`
+	CVV    string `json:"card_cvv"`
+
+	holderEmail string
+	holderCPF   string
+	holderZip   string
+	holderNum   string
+	holderPhone string
+	remoteIP    string
+
+	gateway CardTokenizationGateway
+	token   *entity.CreditCardToken
+}
+
+// wipe descarta os dados do cartão e do portador; só o token, se houver, continua.
+func (c *checkoutCard) wipe() {
+	c.Holder, c.Number, c.Month, c.Year, c.CVV = "", "", "", "", ""
+	c.holderEmail, c.holderCPF, c.holderZip, c.holderNum, c.holderPhone = "", "", "", "", ""
+}
+
+func (c *checkoutCard) empty() bool {
+	return strings.TrimSpace(c.Number) == "" && strings.TrimSpace(c.CVV) == "" && strings.TrimSpace(c.Holder) == ""
+}
+
+func (c *checkoutCard) Validate() []usecase.ValidationError {
+	if c.token != nil {
+		return nil
+	}
+	return usecase.ValidateCardFields(c.Holder, c.Number, c.Month, c.Year, c.CVV)
+}
+
+// Tokenize chama o Asaas uma única vez; chamadas seguintes devolvem o mesmo token.
+func (c *checkoutCard) Tokenize(gatewayCustomerID string) (*entity.CreditCardToken, error) {
+	if c.token != nil {
+		return c.token, nil
+	}
+	if c.gateway == nil {
+		return nil, errors.New("tokenização de cartão indisponível")
+	}
+
+	result, err := c.gateway.TokenizeCreditCard(asaas.TokenizeCreditCardInput{
+		CustomerID:       gatewayCustomerID,
+		CardHolderName:   strings.TrimSpace(c.Holder),
+		CardNumber:       strings.ReplaceAll(strings.ReplaceAll(c.Number, " ", ""), "-", ""),
+		CardMonth:        strings.TrimSpace(c.Month),
+		CardYear:         strings.TrimSpace(c.Year),
+		CardCCV:          strings.TrimSpace(c.CVV),
+		HolderEmail:      c.holderEmail,
+		HolderCpfCnpj:    c.holderCPF,
+		HolderPostalCode: c.holderZip,
+		HolderAddressNum: c.holderNum,
+		HolderPhone:      c.holderPhone,
+		RemoteIP:         c.remoteIP,
+	})
+	c.wipe()
+	if err != nil {
+		return nil, err
+	}
+
+	c.token = &entity.CreditCardToken{Token: result.Token, Brand: result.Brand, LastDigits: result.LastDigits}
+	return c.token, nil
+}
+
+// clientIP prefere o primeiro IP do X-Forwarded-For (atrás do load balancer).
+func clientIP(forwardedFor, remoteAddr string) string {
+	if first := strings.TrimSpace(strings.Split(forwardedFor, ",")[0]); first != "" {
+		return first
+	}
+	if i := strings.LastIndex(remoteAddr, ":"); i > 0 {
+		return strings.Trim(remoteAddr[:i], "[]")
+	}
+	return remoteAddr
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/xavierca1/ligue-payments/internal/entity"
+)
+
+// CouponAdminHandler cadastra cupons e gera o relatório de comissão dos vendedores.
+// Todas as rotas ficam atrás de RequireAdminToken.
+type CouponAdminHandler struct {
+	CouponRepo entity.CouponRepositoryInterface
+}
+
+func NewCouponAdminHandler(couponRepo entity.CouponRepositoryInterface) *CouponAdminHandler {
+	return &CouponAdminHandler{CouponRepo: couponRepo}
+}
+
+// CouponRequest é o corpo de criação e edição. Na edição, campos ausentes mantêm o valor atual.
+type CouponRequest struct {
+	Code                *string    `json:"code"`
+	SellerName          *string    `json:"seller_name"`
+	DiscountType        *string    `json:"discount_type"`
+	DiscountPercent     *int       `json:"discount_percent"`
+	DiscountAmountCents *int       `json:"discount_amount_cents"`
+	PlanIDs             *[]string  `json:"plan_ids"`
+	ProductIDs          *[]string  `json:"product_ids"`
+	MaxRedemptions      *int       `json:"max_redemptions"`
+	MaxPerCPF           *int       `json:"max_redemptions_per_cpf"`
+	DurationCycles      *int       `json:"duration_cycles"`
+	IsActive            *bool      `json:"is_active"`
+	StartsAt            *time.Time `json:"starts_at"`
+	EndsAt              *time.Time `json:"ends_at"`
+}
+
+func (req CouponRequest) applyTo(coupon *entity.Coupon) {
+	if req.SellerName != nil {
+		coupon.SellerName = *req.SellerName
+	}
+	if req.DiscountType != nil {
+		coupon.DiscountType = *req.DiscountType
+	}
+	if req.DiscountPercent != nil {
+		coupon.DiscountPercent = *req.DiscountPercent
+	}
+	if req.DiscountAmountCents != nil {
+		coupon.DiscountAmountCents = *req.DiscountAmountCents
+	}
+	if req.PlanIDs != nil {
+		coupon.PlanIDs = *req.PlanIDs
+	}
+	if req.ProductIDs != nil {
+		coupon.ProductIDs = *req.ProductIDs
+	}
+	if req.MaxRedemptions != nil {
+		coupon.MaxRedemptions = *req.MaxRedemptions
+	}
+	if req.MaxPerCPF != nil {
+		coupon.MaxPerCPF = *req.MaxPerCPF
+	}
+	if req.DurationCycles != nil {
+		coupon.DurationCycles = *req.DurationCycles
+	}
+	if req.IsActive != nil {
+		coupon.IsActive = *req.IsActive
+	}
+	if req.StartsAt != nil {
+		coupon.StartsAt = req.StartsAt
+	}
+	if req.EndsAt != nil {
+		coupon.EndsAt = req.EndsAt
+	}
+}
+
+// List retorna os cupons; ?active=true filtra só os ativos.
+func (h *CouponAdminHandler) List(w http.ResponseWriter, r *http.Request) {
+	activeOnly, _ := strconv.ParseBool(r.URL.Query().Get("active"))
+
+	coupons, err := h.CouponRepo.List(r.Context(), activeOnly)
+	if err != nil {
+		log.Printf("❌ Cupons: %v", err)
+		writeErrorResponse(w, http.StatusInternalServerError, "DATABASE_ERROR", "falha ao listar cupons")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"coupons": coupons})
+}
+
+func (h *CouponAdminHandler) Get(w http.ResponseWriter, r *http.Request) {
+	coupon, ok := h.findCoupon(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, coupon)
+}
+
+func (h *CouponAdminHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req CouponRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "INVALID_JSON", "JSON inválido")
+		return
+	}
+
+	coupon := &entity.Coupon{IsActive: true}
+	if req.Code != nil {
+		coupon.Code = *req.Code
+	}
+	req.applyTo(coupon)
+
+	coupon.Normalize()
+	if err := coupon.Validate(); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "INVALID_COUPON", err.Error())
+		return
+	}
+
+	if err := h.CouponRepo.Create(r.Context(), coupon); err != nil {
+		if errors.Is(err, entity.ErrCouponAlreadyExists) {
+			writeErrorResponse(w, http.StatusConflict, "COUPON_ALREADY_EXISTS", "já existe um cupom com este código")
+			return
+		}
+		log.Printf("❌ Cupons: %v", err)
+		writeErrorResponse(w, http.StatusInternalServerError, "DATABASE_ERROR", "falha ao criar cupom")
+		return
+	}
+
+	log.Printf("🏷️ Cupom %s criado (vendedor=%s)", coupon.Code, coupon.SellerName)
+	writeJSON(w, http.StatusCreated, coupon)
+}
+
+// Update edita um cupom existente. O código não pode ser alterado: as vendas o referenciam.
+func (h *CouponAdminHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req CouponRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "INVALID_JSON", "JSON inválido")
+		return
+	}
+
+	coupon, ok := h.findCoupon(w, r)
+	if !ok {
+		return
+	}
+	if req.Code != nil && !strings.EqualFold(strings.TrimSpace(*req.Code), coupon.Code) {
+		writeErrorResponse(w, http.StatusBadRequest, "INVALID_COUPON", "code não pode ser alterado")
+		return
+	}
+
+	req.applyTo(coupon)
+	h.save(w, r, coupon)
+}
+
+// Deactivate desativa o cupom. Não há exclusão: o histórico de vendas continua apontando para ele.
+func (h *CouponAdminHandler) Deactivate(w http.ResponseWriter, r *http.Request) {
+	coupon, ok := h.findCoupon(w, r)
+	if !ok {
+		return
+	}
+
+	coupon.IsActive = false
+	h.save(w, r, coupon)
+}
+
+func (h *CouponAdminHandler) save(w http.ResponseWriter, r *http.Request, coupon *entity.Coupon) {
+	coupon.Normalize()
+	if err := coupon.Validate(); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "INVALID_COUPON", err.Error())
+		return
+	}
+
+	if err := h.CouponRepo.Update(r.Context(), coupon); err != nil {
+		log.Printf("❌ Cupons: %v", err)
+		writeErrorResponse(w, http.StatusInternalServerError, "DATABASE_ERROR", "falha ao atualizar cupom")
+		return
+	}
+
+	log.Printf("🏷️ Cupom %s atualizado (ativo=%t)", coupon.Code, coupon.IsActive)
+	writeJSON(w, http.StatusOK, coupon)
+}
+
+func (h *CouponAdminHandler) findCoupon(w http.ResponseWriter, r *http.Request) (*entity.Coupon, bool) {
+	code := strings.TrimSpace(chi.URLParam(r, "code"))
+	if code == "" {
+		writeErrorResponse(w, http.StatusBadRequest, "MISSING_FIELDS", "código do cupom é obrigatório")
+		return nil, false
+	}
+
+	coupon, err := h.CouponRepo.FindByCode(r.Context(), code)
+	if err != nil {
+		if errors.Is(err, entity.ErrCouponNotFound) {
+			writeErrorResponse(w, http.StatusNotFound, "COUPON_NOT_FOUND", "cupom não encontrado")
+			return nil, false
+		}
+		log.Printf("❌ Cupons: %v", err)
+		writeErrorResponse(w, http.StatusInternalServerError, "DATABASE_ERROR", "falha ao buscar cupom")
+		return nil, false
+	}
+	return coupon, true
+}
+
+type SellerReportResponse struct {
+	From   string                  `json:"from"`
+	To     string                  `json:"to"`
+	Rows   []entity.SellerSalesRow `json:"rows"`
+	Totals entity.SellerSalesRow   `json:"totals"`
+}
+
+// SellerReport soma bruto, desconto e líquido das vendas com cupom por vendedor, contando
+// só assinaturas ativadas. Período como em FunnelReport; ?format=csv exporta para a comissão.
+func (h *CouponAdminHandler) SellerReport(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := parseReportPeriod(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := h.CouponRepo.SellerReport(r.Context(), from, to)
+	if err != nil {
+		log.Printf("❌ Relatório de vendedores: %v", err)
+		writeErrorResponse(w, http.StatusInternalServerError, "DATABASE_ERROR", "falha ao gerar relatório")
+		return
+	}
+
+	response := SellerReportResponse{
+		From: from.Format("2006-01-02"),
+		To:   to.AddDate(0, 0, -1).Format("2006-01-02"),
+		Rows: make([]entity.SellerSalesRow, 0, len(rows)),
+	}
+	response.Totals.SellerName = "TOTAL"
+	for _, row := range rows {
+		response.Rows = append(response.Rows, row)
+		response.Totals.Sales += row.Sales
+		response.Totals.GrossCents += row.GrossCents
+		response.Totals.DiscountCents += row.DiscountCents
+		response.Totals.NetCents += row.NetCents
+	}
+
+	if strings.EqualFold(strings.TrimSpace(r.URL.Query().Get("format")), "csv") {
+		writeSellerReportCSV(w, response)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func writeSellerReportCSV(w http.ResponseWriter, report SellerReportResponse) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="vendedores_%s_%s.csv"`, report.From, report.To))
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"seller_name", "sales", "gross_brl", "discount_brl", "net_brl"})
+	for _, row := range append(report.Rows, report.Totals) {
+		writer.Write([]string{
+			row.SellerName,
+			strconv.Itoa(row.Sales),
+			formatCents(row.GrossCents),
+			formatCents(row.DiscountCents),
+			formatCents(row.NetCents),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("⚠️ Relatório de vendedores: falha ao escrever CSV: %v", err)
+	}
+}
+
+// formatCents escreve centavos como reais com ponto decimal (1234 -> 12.34).
+func formatCents(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
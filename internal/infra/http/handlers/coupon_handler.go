@@ -5,18 +5,27 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/xavierca1/ligue-payments/internal/entity"
 	"github.com/xavierca1/ligue-payments/internal/usecase"
 )
 
-type CouponHandler struct{}
+type CouponHandler struct {
+	PlanRepo entity.PlanRepositoryInterface
+}
 
-func NewCouponHandler() *CouponHandler {
-	return &CouponHandler{}
+func NewCouponHandler(planRepo entity.PlanRepositoryInterface) *CouponHandler {
+	return &CouponHandler{PlanRepo: planRepo}
 }
 
+// Validate aplica as mesmas regras do checkout. Com plan_id, valida o escopo do cupom e
+// retorna os valores (com dependents, sobre o preço com os dependentes extras); com cpf,
+// verifica o limite de uso por CPF.
 func (h *CouponHandler) Validate(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		CouponCode string `json:"coupon_code"`
+		PlanID     string `json:"plan_id"`
+		Dependents int    `json:"dependents"`
+		CPF        string `json:"cpf"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
@@ -30,18 +39,54 @@ func (h *CouponHandler) Validate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	details, err := usecase.ValidateCoupon(r.Context(), code)
+	var plan *entity.Plan
+	if planID := strings.TrimSpace(input.PlanID); planID != "" {
+		found, err := h.PlanRepo.FindByID(r.Context(), planID)
+		if err != nil || found == nil {
+			writeErrorResponse(w, http.StatusBadRequest, "INVALID_PLAN", "Plano não encontrado")
+			return
+		}
+		plan = found
+	}
+
+	if input.Dependents < 0 {
+		writeErrorResponse(w, http.StatusBadRequest, "VALIDATION_ERROR", "dependents não pode ser negativo")
+		return
+	}
+
+	evaluation, err := usecase.EvaluateCoupon(r.Context(), code, plan, input.Dependents, input.CPF)
 	if err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "COUPON_INVALID", "cupom inválido ou inativo")
+		if de, ok := err.(*usecase.DomainError); ok {
+			writeErrorResponse(w, http.StatusBadRequest, de.Code, de.Message)
+			return
+		}
+		if te, ok := err.(*usecase.TechnicalError); ok {
+			writeErrorResponse(w, http.StatusInternalServerError, te.Code, te.Message)
+			return
+		}
+		writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
 		return
 	}
 
+	details := evaluation.Coupon
+	response := map[string]interface{}{
+		"valid":                 true,
+		"coupon_code":           details.Code,
+		"seller_name":           details.SellerName,
+		"discount_type":         details.DiscountType,
+		"discount_percent":      details.DiscountPercent,
+		"discount_amount_cents": details.DiscountAmountCents,
+		"duration_cycles":       details.DurationCycles,
+	}
+	if plan != nil {
+		// Com plano, discount_amount_cents é o desconto efetivo sobre o preço do ciclo.
+		response["plan_id"] = plan.ID
+		response["original_amount_cents"] = evaluation.OriginalAmountCents
+		response["discount_amount_cents"] = evaluation.DiscountAmountCents
+		response["final_amount_cents"] = evaluation.FinalAmountCents
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"valid":            true,
-		"coupon_code":      details.Code,
-		"seller_name":      details.SellerName,
-		"discount_percent": details.DiscountPercent,
-	})
+	json.NewEncoder(w).Encode(response)
 }
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -22,6 +23,7 @@ type CustomerHandler struct {
 	CustomerRepo     interface {
 		FindByCPF(ctx context.Context, cpf string) (*entity.Customer, error)
 	}
+	CardGateway CardTokenizationGateway // tokeniza o cartão digitado no checkout
 }
 
 func NewCustomerHandler(uc *usecase.CreateCustomerUseCase, subRepo entity.SubscriptionRepository, customerRepo interface {
@@ -37,22 +39,44 @@ func NewCustomerHandler(uc *usecase.CreateCustomerUseCase, subRepo entity.Subscr
 func (h *CustomerHandler) CreateCheckoutHandler(w http.ResponseWriter, r *http.Request) {
 	startedAt := time.Now()
 	var input usecase.CreateCustomerInput
-
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+	var card checkoutCard
+
+	body, err := io.ReadAll(r.Body)
+	// O corpo e o cartão são zerados em toda saída, inclusive nas que não chegam a tokenizar
+	// (validação, cupom, cliente já ativo).
+	defer clear(body)
+	defer card.wipe()
+	if err == nil {
+		err = json.Unmarshal(body, &input)
+	}
+	if err == nil {
+		err = json.Unmarshal(body, &card)
+	}
+	if err != nil {
 		log.Printf("[checkout] invalid_json remote=%s err=%v duration=%s", r.RemoteAddr, err, time.Since(startedAt))
 		writeErrorResponse(w, http.StatusBadRequest, "INVALID_JSON", "JSON inválido: "+err.Error())
 		return
 	}
 
+	input.RemoteIP = clientIP(r.Header.Get("X-Forwarded-For"), r.RemoteAddr)
+	if strings.TrimSpace(input.CreditCardToken) == "" && !card.empty() {
+		// Cartão digitado: o Asaas tokeniza assim que o customer do gateway existir;
+		// daqui para frente só o token circula.
+		card.holderEmail, card.holderCPF, card.holderPhone = input.Email, input.CPF, input.Phone
+		card.holderZip, card.holderNum = input.ZipCode, input.Number
+		card.remoteIP, card.gateway = input.RemoteIP, h.CardGateway
+		input.Card = &card
+	}
+
 	log.Printf("[checkout] request_received remote=%s %s", r.RemoteAddr, checkoutLogContext(input))
-	log.Printf("[checkout] full_payload name=%q email=%q cpf=%q phone=%q card_holder=%q",
-		input.Name, input.Email, maskDigits(input.CPF), maskDigits(input.Phone), input.CardHolder)
+	log.Printf("[checkout] full_payload name=%q email=%q cpf=%q phone=%q",
+		input.Name, input.Email, maskDigits(input.CPF), maskDigits(input.Phone))
 
 	output, err := h.CreateCustomerUC.Execute(r.Context(), input)
 	if err != nil {
 		if de, ok := err.(*usecase.DomainError); ok {
 			log.Printf("[checkout] validation_error remote=%s %s err=%v duration=%s", r.RemoteAddr, checkoutLogContext(input), err, time.Since(startedAt))
-			writeErrorResponse(w, http.StatusBadRequest, de.Code, de.Message)
+			writeDomainError(w, http.StatusBadRequest, de)
 			return
 		}
 
@@ -177,15 +201,17 @@ func (h *CustomerHandler) LookupCPFHandler(w http.ResponseWriter, r *http.Reques
 		message = "Encontramos um cadastro pendente. Selecione PIX para continuar o pagamento."
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]any{
+	response := map[string]any{
 		"exists":      true,
 		"status":      status,
 		"customer_id": customer.ID,
 		"gateway_id":  customer.GatewayID,
 		"message":     message,
-	})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
 }
 
 func writeErrorResponse(w http.ResponseWriter, statusCode int, code string, message string) {
@@ -201,6 +227,20 @@ func writeErrorResponse(w http.ResponseWriter, statusCode int, code string, mess
 	})
 }
 
+// writeDomainError responde o DomainError; erros de validação trazem os campos em "fields".
+func writeDomainError(w http.ResponseWriter, statusCode int, de *usecase.DomainError) {
+	if len(de.Fields) == 0 {
+		writeErrorResponse(w, statusCode, de.Code, de.Message)
+		return
+	}
+
+	writeJSON(w, statusCode, map[string]any{
+		"error":   de.Code,
+		"message": translateError(de.Code, de.Message),
+		"fields":  de.Fields,
+	})
+}
+
 func translateError(code string, message string) string {
 	// Se a mensagem já começa com "validation failed:", extrai os erros específicos
 	if strings.Contains(message, "validation failed:") {
@@ -262,7 +302,8 @@ func checkoutLogContext(input usecase.CreateCustomerInput) string {
 		" email=" + maskEmail(input.Email) +
 		" cpf=" + maskDigits(input.CPF) +
 		" phone=" + maskDigits(input.Phone) +
-		" has_card=" + boolToString(input.PaymentMethod == "CREDIT_CARD") +
+		" has_card=" + boolToString(input.Card != nil) +
+		" has_card_token=" + boolToString(strings.TrimSpace(input.CreditCardToken) != "") +
 		" dependents=" + itoa(len(input.Dependents))
 }
 
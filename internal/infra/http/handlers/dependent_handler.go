@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/xavierca1/ligue-payments/internal/usecase"
+)
+
+// DependentHandler gerencia os dependentes de assinaturas ativas.
+// Todas as rotas ficam atrás de RequireAdminToken.
+type DependentHandler struct {
+	DependentsUC usecase.ManageDependentsInterface
+}
+
+func NewDependentHandler(uc usecase.ManageDependentsInterface) *DependentHandler {
+	return &DependentHandler{DependentsUC: uc}
+}
+
+func (h *DependentHandler) List(w http.ResponseWriter, r *http.Request) {
+	customerID := chi.URLParam(r, "id")
+
+	dependents, err := h.DependentsUC.List(r.Context(), customerID)
+	if err != nil {
+		h.writeError(w, customerID, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"dependents": dependents})
+}
+
+func (h *DependentHandler) Add(w http.ResponseWriter, r *http.Request) {
+	var input usecase.DependentInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "INVALID_JSON", "JSON inválido")
+		return
+	}
+	customerID := chi.URLParam(r, "id")
+
+	output, err := h.DependentsUC.Add(r.Context(), customerID, input)
+	if err != nil {
+		h.writeError(w, customerID, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, output)
+}
+
+func (h *DependentHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var input usecase.DependentInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "INVALID_JSON", "JSON inválido")
+		return
+	}
+	customerID := chi.URLParam(r, "id")
+
+	output, err := h.DependentsUC.Update(r.Context(), customerID, chi.URLParam(r, "dependentId"), input)
+	if err != nil {
+		h.writeError(w, customerID, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, output)
+}
+
+func (h *DependentHandler) Remove(w http.ResponseWriter, r *http.Request) {
+	customerID := chi.URLParam(r, "id")
+
+	output, err := h.DependentsUC.Remove(r.Context(), customerID, chi.URLParam(r, "dependentId"))
+	if err != nil {
+		h.writeError(w, customerID, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, output)
+}
+
+func (h *DependentHandler) writeError(w http.ResponseWriter, customerID string, err error) {
+	if de, ok := err.(*usecase.DomainError); ok {
+		writeDomainError(w, dependentErrorStatus(de.Code), de)
+		return
+	}
+	log.Printf("❌ Dependentes (customer=%s): %v", customerID, err)
+	if te, ok := err.(*usecase.TechnicalError); ok {
+		writeErrorResponse(w, http.StatusInternalServerError, te.Code, te.Message)
+		return
+	}
+	writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+}
+
+func dependentErrorStatus(code string) int {
+	switch code {
+	case "CUSTOMER_NOT_FOUND", "SUBSCRIPTION_NOT_FOUND", "DEPENDENT_NOT_FOUND":
+		return http.StatusNotFound
+	case "SUBSCRIPTION_CANCELLATION_SCHEDULED":
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
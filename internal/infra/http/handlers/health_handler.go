@@ -6,15 +6,21 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sync/atomic"
 	"time"
-
-	"github.com/rabbitmq/amqp091-go"
 )
 
+// RabbitMQStatus é satisfeita por *amqp091.Connection e por queue.ConnectionManager.
+type RabbitMQStatus interface {
+	IsClosed() bool
+}
+
 type HealthHandler struct {
 	DB        *sql.DB
-	RabbitMQ  *amqp091.Connection
+	RabbitMQ  RabbitMQStatus
 	StartTime time.Time
+
+	draining atomic.Bool
 }
 
 type HealthResponse struct {
@@ -24,7 +30,7 @@ type HealthResponse struct {
 	Dependencies map[string]string `json:"dependencies"`
 }
 
-func NewHealthHandler(db *sql.DB, rabbitMQ *amqp091.Connection) *HealthHandler {
+func NewHealthHandler(db *sql.DB, rabbitMQ RabbitMQStatus) *HealthHandler {
 	return &HealthHandler{
 		DB:        db,
 		RabbitMQ:  rabbitMQ,
@@ -92,3 +98,30 @@ func (h *HealthHandler) Handle(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(response)
 }
+
+// SetDraining marca o processo como em encerramento: /ready passa a responder 503
+// para o balanceador parar de enviar tráfego enquanto as requisições em andamento terminam.
+func (h *HealthHandler) SetDraining(draining bool) {
+	h.draining.Store(draining)
+}
+
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.draining.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"status":"draining"}`))
+		return
+	}
+
+	if h.DB != nil {
+		if err := h.DB.Ping(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":"unhealthy","reason":"database"}`))
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status":"ready"}`))
+}
@@ -2,10 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/xavierca1/ligue-payments/internal/entity"
 )
 
@@ -28,6 +33,8 @@ type CaptureLeadRequest struct {
 	Email string `json:"email"`
 	Name  string `json:"name,omitempty"`
 	Phone string `json:"phone,omitempty"`
+
+	entity.LeadAttribution
 }
 
 
@@ -77,9 +84,10 @@ func (h *LeadHandler) CaptureLead(w http.ResponseWriter, r *http.Request) {
 
 
 	lead := &entity.Lead{
-		Email: req.Email,
-		Name:  req.Name,
-		Phone: req.Phone,
+		Email:           req.Email,
+		Name:            req.Name,
+		Phone:           req.Phone,
+		LeadAttribution: req.LeadAttribution,
 	}
 
 	if err := h.leadRepo.Upsert(ctx, lead); err != nil {
@@ -101,6 +109,124 @@ func (h *LeadHandler) CaptureLead(w http.ResponseWriter, r *http.Request) {
 }
 
 
+type FunnelReportRow struct {
+	entity.LeadFunnelRow
+	CheckoutRate   float64 `json:"checkout_rate"`   // checkout / captured, em %
+	ConversionRate float64 `json:"conversion_rate"` // converted / captured, em %
+}
+
+type FunnelReportResponse struct {
+	From   string            `json:"from"`
+	To     string            `json:"to"`
+	Rows   []FunnelReportRow `json:"rows"`
+	Totals FunnelReportRow   `json:"totals"`
+}
+
+// FunnelReport agrega captura → checkout → pagamento por origem e plano.
+// Período em ?from=YYYY-MM-DD&to=YYYY-MM-DD (inclusivo); padrão: últimos 30 dias.
+func (h *LeadHandler) FunnelReport(w http.ResponseWriter, r *http.Request) {
+	from, to, ok := parseReportPeriod(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := h.leadRepo.FunnelReport(r.Context(), from, to)
+	if err != nil {
+		log.Printf("❌ Funil de leads: %v", err)
+		writeErrorResponse(w, http.StatusInternalServerError, "DATABASE_ERROR", "falha ao gerar funil")
+		return
+	}
+
+	response := FunnelReportResponse{
+		From: from.Format("2006-01-02"),
+		To:   to.AddDate(0, 0, -1).Format("2006-01-02"),
+		Rows: make([]FunnelReportRow, 0, len(rows)),
+	}
+	for _, row := range rows {
+		response.Rows = append(response.Rows, newFunnelReportRow(row))
+		response.Totals.Captured += row.Captured
+		response.Totals.Checkout += row.Checkout
+		response.Totals.Converted += row.Converted
+	}
+	response.Totals = newFunnelReportRow(response.Totals.LeadFunnelRow)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Unsubscribe descadastra o lead dos emails de recuperação de checkout.
+// GET vem do link no rodapé do email; POST atende o List-Unsubscribe-Post (RFC 8058).
+func (h *LeadHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	leadID := chi.URLParam(r, "id")
+
+	if err := h.leadRepo.Unsubscribe(r.Context(), leadID); err != nil {
+		if errors.Is(err, entity.ErrLeadNotFound) {
+			writeErrorResponse(w, http.StatusNotFound, "LEAD_NOT_FOUND", "lead não encontrado")
+			return
+		}
+		log.Printf("❌ Descadastro do lead %s: %v", leadID, err)
+		writeErrorResponse(w, http.StatusInternalServerError, "DATABASE_ERROR", "falha ao descadastrar")
+		return
+	}
+
+	log.Printf("📭 Lead %s descadastrado dos emails de recuperação", leadID)
+	if r.Method == http.MethodPost {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(unsubscribedPage))
+}
+
+const unsubscribedPage = `<!DOCTYPE html>
+<html lang="pt-BR">
+<head><meta charset="UTF-8"><title>Descadastro confirmado</title></head>
+<body style="font-family: Arial, sans-serif; text-align: center; padding: 40px;">
+<h2>Pronto!</h2>
+<p>Você não receberá mais lembretes sobre o seu cadastro na Ligue Saúde.</p>
+</body>
+</html>`
+
+// parseReportPeriod lê ?from e ?to (YYYY-MM-DD, inclusivos) dos relatórios administrativos.
+// Retorna to exclusivo (dia seguinte). Em erro, já escreve a resposta 400.
+func parseReportPeriod(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	to := time.Now().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	from := to.AddDate(0, 0, -30)
+
+	if raw := strings.TrimSpace(r.URL.Query().Get("from")); raw != "" {
+		parsed, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			writeErrorResponse(w, http.StatusBadRequest, "INVALID_DATE", "from deve estar no formato YYYY-MM-DD")
+			return time.Time{}, time.Time{}, false
+		}
+		from = parsed
+	}
+	if raw := strings.TrimSpace(r.URL.Query().Get("to")); raw != "" {
+		parsed, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			writeErrorResponse(w, http.StatusBadRequest, "INVALID_DATE", "to deve estar no formato YYYY-MM-DD")
+			return time.Time{}, time.Time{}, false
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+	if !from.Before(to) {
+		writeErrorResponse(w, http.StatusBadRequest, "INVALID_PERIOD", "from deve ser anterior a to")
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+func newFunnelReportRow(row entity.LeadFunnelRow) FunnelReportRow {
+	report := FunnelReportRow{LeadFunnelRow: row}
+	if row.Captured > 0 {
+		report.CheckoutRate = math.Round(float64(row.Checkout)*10000/float64(row.Captured)) / 100
+		report.ConversionRate = math.Round(float64(row.Converted)*10000/float64(row.Captured)) / 100
+	}
+	return report
+}
+
 func getClientIP(r *http.Request) string {
 
 	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/xavierca1/ligue-payments/internal/usecase"
+)
+
+type PlanChangeHandler struct {
+	ChangePlanUC usecase.ChangePlanInterface
+}
+
+func NewPlanChangeHandler(uc usecase.ChangePlanInterface) *PlanChangeHandler {
+	return &PlanChangeHandler{ChangePlanUC: uc}
+}
+
+// ChangePlan troca o plano da assinatura ativa do cliente. Com "preview": true só devolve o pró-rata.
+func (h *PlanChangeHandler) ChangePlan(w http.ResponseWriter, r *http.Request) {
+	var input usecase.ChangePlanInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "INVALID_JSON", "JSON inválido")
+		return
+	}
+	input.CustomerID = chi.URLParam(r, "id")
+
+	output, err := h.ChangePlanUC.Execute(r.Context(), input)
+	if err != nil {
+		if de, ok := err.(*usecase.DomainError); ok {
+			writeErrorResponse(w, planChangeErrorStatus(de.Code), de.Code, de.Message)
+			return
+		}
+		log.Printf("❌ Troca de plano (customer=%s): %v", input.CustomerID, err)
+		if te, ok := err.(*usecase.TechnicalError); ok {
+			writeErrorResponse(w, http.StatusInternalServerError, te.Code, te.Message)
+			return
+		}
+		writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, output)
+}
+
+// AbandonPlanChange libera a assinatura de uma troca de plano pendente: reverte o valor no
+// Asaas, cancela a cobrança do pró-rata e marca a troca como FAILED.
+func (h *PlanChangeHandler) AbandonPlanChange(w http.ResponseWriter, r *http.Request) {
+	customerID := chi.URLParam(r, "id")
+
+	change, err := h.ChangePlanUC.Abandon(r.Context(), customerID)
+	if err != nil {
+		if de, ok := err.(*usecase.DomainError); ok {
+			writeErrorResponse(w, planChangeErrorStatus(de.Code), de.Code, de.Message)
+			return
+		}
+		log.Printf("❌ Abandono de troca de plano (customer=%s): %v", customerID, err)
+		if te, ok := err.(*usecase.TechnicalError); ok {
+			writeErrorResponse(w, http.StatusInternalServerError, te.Code, te.Message)
+			return
+		}
+		writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, change)
+}
+
+func planChangeErrorStatus(code string) int {
+	switch code {
+	case "CUSTOMER_NOT_FOUND", "SUBSCRIPTION_NOT_FOUND", "PLAN_CHANGE_NOT_FOUND":
+		return http.StatusNotFound
+	case "PLAN_CHANGE_CONFLICT":
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
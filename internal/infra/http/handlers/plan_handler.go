@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/xavierca1/ligue-payments/internal/entity"
+)
+
+type PlanHandler struct {
+	PlanRepo entity.PlanCatalogRepositoryInterface
+}
+
+func NewPlanHandler(planRepo entity.PlanCatalogRepositoryInterface) *PlanHandler {
+	return &PlanHandler{PlanRepo: planRepo}
+}
+
+// PlanCatalogItem é o plano como o front exibe no catálogo.
+type PlanCatalogItem struct {
+	ID                       string   `json:"id"`
+	ProductID                string   `json:"product_id"`
+	Name                     string   `json:"name"`
+	Description              string   `json:"description,omitempty"`
+	Features                 []string `json:"features"`
+	Badge                    string   `json:"badge,omitempty"`
+	PriceCents               int      `json:"price_cents"`
+	BillingCycle             string   `json:"billing_cycle"`
+	CycleMonths              int      `json:"cycle_months"`
+	MonthlyPriceCents        int      `json:"monthly_price_cents"` // preço do ciclo dividido pelos meses
+	MaxDependents            *int     `json:"max_dependents"`      // null = sem limite
+	IncludedDependents       int      `json:"included_dependents"`
+	ExtraDependentPriceCents int      `json:"extra_dependent_price_cents"`
+}
+
+func newPlanCatalogItem(plan *entity.Plan) PlanCatalogItem {
+	return PlanCatalogItem{
+		ID:                       plan.ID,
+		ProductID:                plan.ProductID,
+		Name:                     plan.Name,
+		Description:              plan.Description,
+		Features:                 plan.Features,
+		Badge:                    plan.Badge,
+		PriceCents:               plan.PriceCents,
+		BillingCycle:             plan.Cycle(),
+		CycleMonths:              plan.CycleMonths(),
+		MonthlyPriceCents:        plan.PriceCents / plan.CycleMonths(),
+		MaxDependents:            plan.MaxDependents,
+		IncludedDependents:       plan.IncludedDependents,
+		ExtraDependentPriceCents: plan.ExtraDependentPriceCents,
+	}
+}
+
+// List é o catálogo público: planos ativos e visíveis, filtráveis por ?product_id=.
+func (h *PlanHandler) List(w http.ResponseWriter, r *http.Request) {
+	productID := strings.TrimSpace(r.URL.Query().Get("product_id"))
+
+	plans, err := h.PlanRepo.ListCatalog(r.Context(), productID)
+	if err != nil {
+		log.Printf("❌ Catálogo de planos: %v", err)
+		writeErrorResponse(w, http.StatusInternalServerError, "DATABASE_ERROR", "falha ao listar planos")
+		return
+	}
+
+	items := make([]PlanCatalogItem, 0, len(plans))
+	for _, plan := range plans {
+		items = append(items, newPlanCatalogItem(plan))
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, map[string]interface{}{"plans": items})
+}
@@ -1,9 +1,8 @@
 package handlers
 
 import (
-	"context"
 	"crypto/subtle"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -11,22 +10,18 @@ import (
 	"os"
 	"strings"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/xavierca1/ligue-payments/internal/entity"
 	"github.com/xavierca1/ligue-payments/internal/usecase"
 )
 
 type WebhookHandler struct {
-	CustomerRepo  entity.CustomerRepositoryInterface
-	ActivateSubUC usecase.ActivateSubscriptionInterface
+	Inbox entity.WebhookEventRepositoryInterface
 }
 
-func NewWebhookHandler(
-	customerRepo entity.CustomerRepositoryInterface,
-	activateSubUC usecase.ActivateSubscriptionInterface,
-) *WebhookHandler {
+func NewWebhookHandler(inbox entity.WebhookEventRepositoryInterface) *WebhookHandler {
 	return &WebhookHandler{
-		CustomerRepo:  customerRepo,
-		ActivateSubUC: activateSubUC,
+		Inbox: inbox,
 	}
 }
 
@@ -46,80 +41,59 @@ func (h *WebhookHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var event struct {
-		Event   string `json:"event"`
-		Payment struct {
-			ID       string `json:"id"`
-			Customer string `json:"customer"`
-			Status   string `json:"status"`
-		} `json:"payment"`
-	}
-
-	if err := json.Unmarshal(body, &event); err != nil {
-
+	event, err := usecase.NewAsaasWebhookEvent(body)
+	if err != nil {
+		// Guardado como FAILED para análise; responder erro só faria o Asaas reenviar o mesmo corpo.
 		log.Printf("⚠️ Webhook: Invalid JSON: %v", err)
+		if _, saveErr := h.Inbox.Save(r.Context(), usecase.NewInvalidAsaasWebhookEvent(body, err.Error())); saveErr != nil {
+			log.Printf("❌ Webhook: falha ao gravar payload inválido na inbox: %v", saveErr)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	eventName := strings.ToUpper(strings.TrimSpace(event.Event))
-	paymentStatus := strings.ToUpper(strings.TrimSpace(event.Payment.Status))
-
-	// Eventos de cobrança de multa/juros não devem reativar assinatura.
-	isNonActivationEvent := eventName == "PAYMENT_FINE_CHARGED" ||
-		eventName == "PAYMENT_INTEREST_CHARGED" ||
-		eventName == "PAYMENT_PENALTY_CHARGED"
-
-	isPaymentEvent := strings.HasPrefix(eventName, "PAYMENT_")
-	isPaidStatus := paymentStatus == "RECEIVED" || paymentStatus == "CONFIRMED" || paymentStatus == "RECEIVED_IN_CASH"
-	isActivationEvent := eventName == "PAYMENT_RECEIVED" || eventName == "PAYMENT_CONFIRMED" || eventName == "PAYMENT_APPROVED"
-	shouldActivate := !isNonActivationEvent && ((isPaymentEvent && isPaidStatus) || isActivationEvent)
-
-	if !shouldActivate {
-		if isPaymentEvent {
-			log.Printf("ℹ️ Webhook: Evento ignorado: %s (status=%s, payment_id=%s, customer=%s)", eventName, paymentStatus, strings.TrimSpace(event.Payment.ID), strings.TrimSpace(event.Payment.Customer))
-		} else {
-			log.Printf("ℹ️ Webhook: Evento ignorado: %s", eventName)
-		}
-		w.WriteHeader(http.StatusOK)
+	// O evento é gravado na inbox antes de responder. Se a gravação falhar, devolvemos 500
+	// para que o Asaas reenvie; o processamento acontece no WebhookDispatcher.
+	inserted, err := h.Inbox.Save(r.Context(), event)
+	if err != nil {
+		log.Printf("❌ Webhook: falha ao gravar evento na inbox (%s key=%s): %v", event.EventType, event.EventKey, err)
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("📥 Webhook: Evento de ativação: %s (status=%s, payment_id=%s, customer=%s)", eventName, paymentStatus, strings.TrimSpace(event.Payment.ID), strings.TrimSpace(event.Payment.Customer))
-
-	customerRef := strings.TrimSpace(event.Payment.Customer)
-	localCustomer, err := h.CustomerRepo.FindByGatewayID(customerRef)
-	if err != nil {
-		log.Printf("⚠️ Webhook: FindByGatewayID falhou para %q, tentando FindByID como fallback: %v", customerRef, err)
-		localCustomer, err = h.CustomerRepo.FindByID(r.Context(), customerRef)
-		if err != nil {
-			log.Printf("❌ Webhook: Customer not found por GatewayID nem por ID (%s): %v", customerRef, err)
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		log.Printf("✅ Webhook: Customer encontrado via fallback FindByID (%s)", customerRef)
+	if !inserted {
+		log.Printf("ℹ️ Webhook: Evento duplicado ignorado: %s (key=%s, payment_id=%s)", event.EventType, event.EventKey, event.PaymentID)
+	} else {
+		log.Printf("📥 Webhook: Evento gravado na inbox: %s (key=%s, payment_id=%s, customer=%s)", event.EventType, event.EventKey, event.PaymentID, event.CustomerRef)
 	}
 
-	// Responde 200 imediatamente para evitar timeout do Asaas.
-	// A ativação é processada em background para não bloquear a resposta.
 	w.WriteHeader(http.StatusOK)
+}
 
-	input := usecase.ActivateSubscriptionInput{
-		CustomerID: localCustomer.ID,
-		GatewayID:  event.Payment.ID,
+// Replay devolve um evento da inbox (por ID ou event_key) para a fila de processamento.
+func (h *WebhookHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSpace(chi.URLParam(r, "id"))
+	if id == "" {
+		writeErrorResponse(w, http.StatusBadRequest, "MISSING_ID", "ID do evento é obrigatório")
+		return
 	}
-	customerID := localCustomer.ID
-	gatewayID := localCustomer.GatewayID
-
-	go func() {
-		ctx := context.Background()
-		if err := h.ActivateSubUC.Execute(ctx, input); err != nil {
-			log.Printf("❌ Webhook: Activation error: %v", err)
-			log.Printf("❌ Webhook: Detalhes - CustomerID=%s, GatewayID=%s, PaymentID=%s", customerID, gatewayID, input.GatewayID)
+
+	if err := h.Inbox.Requeue(r.Context(), id); err != nil {
+		if errors.Is(err, entity.ErrWebhookEventNotFound) {
+			writeErrorResponse(w, http.StatusNotFound, "WEBHOOK_NOT_FOUND", "evento não encontrado")
 			return
 		}
-		log.Printf("✅ Webhook: Subscription activated for customer %s (GatewayID=%s, PaymentID=%s)", customerID, gatewayID, input.GatewayID)
-	}()
+		log.Printf("❌ Webhook: falha ao reenfileirar evento %s: %v", id, err)
+		writeErrorResponse(w, http.StatusInternalServerError, "DATABASE_ERROR", "falha ao reenfileirar evento")
+		return
+	}
+
+	log.Printf("🔁 Webhook: evento %s reenfileirado para reprocessamento", id)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte(`{"status":"requeued"}`))
 }
 
 func verifyWebhookSignature(body, signature string, r *http.Request) bool {
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// RequireAdminToken protege rotas operacionais exigindo o header
+// "Authorization: Bearer <ADMIN_API_TOKEN>". Sem ADMIN_API_TOKEN configurado, as rotas ficam fechadas.
+func RequireAdminToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expected := strings.TrimSpace(os.Getenv("ADMIN_API_TOKEN"))
+		if expected == "" {
+			log.Printf("❌ Admin: ADMIN_API_TOKEN não configurado, negando %s %s", r.Method, r.URL.Path)
+			writeUnauthorized(w)
+			return
+		}
+
+		received := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if subtle.ConstantTimeCompare([]byte(received), []byte(expected)) != 1 {
+			writeUnauthorized(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeUnauthorized(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	w.Write([]byte(`{"error":"UNAUTHORIZED","message":"token administrativo inválido"}`))
+}
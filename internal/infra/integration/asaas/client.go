@@ -3,9 +3,11 @@ package asaas
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -14,6 +16,9 @@ import (
 	httptrace "github.com/DataDog/dd-trace-go/contrib/net/http/v2"
 )
 
+// ErrNotFound indica 404 no Asaas: o recurso não existe ou já foi removido.
+var ErrNotFound = errors.New("recurso não encontrado no asaas")
+
 type Client struct {
 	baseURL    string
 	apiKey     string
@@ -62,6 +67,9 @@ func (c *Client) post(endpoint string, body interface{}) ([]byte, error) {
 		return nil, fmt.Errorf("erro ao ler resposta: %w", err)
 	}
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("erro na api asaas (%d): %w", resp.StatusCode, ErrNotFound)
+	}
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		return nil, fmt.Errorf("erro na api asaas (%d): %s", resp.StatusCode, string(respBytes))
 	}
@@ -91,6 +99,9 @@ func (c *Client) get(endpoint string) ([]byte, error) {
 		return nil, fmt.Errorf("erro ao ler resposta: %w", err)
 	}
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("erro na api asaas (%d): %w", resp.StatusCode, ErrNotFound)
+	}
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		return nil, fmt.Errorf("erro na api asaas (%d): %s", resp.StatusCode, string(respBytes))
 	}
@@ -172,26 +183,11 @@ func (c *Client) Subscribe(input SubscribeInput) (string, string, error) {
 		BillingType: "CREDIT_CARD",
 		Value:       input.Price,
 		NextDueDate: today,
-		Cycle:       "MONTHLY",
+		Cycle:       subscriptionCycle(input.Cycle),
 		Description: "Assinatura Ligue Saúde", // Descrição na fatura
 
-		CreditCard: creditCard{
-			HolderName:  input.CardHolderName,
-			Number:      input.CardNumber,
-			ExpiryMonth: input.CardMonth,
-			ExpiryYear:  input.CardYear,
-			CCV:         input.CardCCV,
-		},
-
-		CreditCardHolderInfo: creditCardHolderInfo{
-			Name:          input.CardHolderName,
-			Email:         input.HolderEmail,
-			CpfCnpj:       input.HolderCpfCnpj,
-			PostalCode:    input.HolderPostalCode,
-			AddressNumber: input.HolderAddressNum,
-			Phone:         input.HolderPhone,
-			MobilePhone:   input.HolderPhone,
-		},
+		CreditCardToken: input.CreditCardToken,
+		RemoteIP:        input.RemoteIP,
 	}
 
 	jsonBody, err := json.Marshal(payload)
@@ -199,8 +195,8 @@ func (c *Client) Subscribe(input SubscribeInput) (string, string, error) {
 		return "", "", fmt.Errorf("erro ao gerar json: %w", err)
 	}
 
-	fmt.Printf("[asaas] Subscribe payload: customer=%q value=%.2f card_holder=%q phone=%q email=%q\n",
-		input.CustomerID, input.Price, input.CardHolderName, input.HolderPhone, input.HolderEmail)
+	fmt.Printf("[asaas] Subscribe payload: customer=%q value=%.2f cycle=%q\n",
+		input.CustomerID, input.Price, payload.Cycle)
 
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
 	if err != nil {
@@ -252,24 +248,70 @@ func (c *Client) Subscribe(input SubscribeInput) (string, string, error) {
 	return response.ID, response.Status, nil
 }
 
+// TokenizeCreditCard troca os dados do cartão pelo creditCardToken do cliente no Asaas.
+// O token vale só para esse customer e substitui número e CVV nas assinaturas.
+func (c *Client) TokenizeCreditCard(input TokenizeCreditCardInput) (*CreditCardToken, error) {
+	payload := tokenizeCreditCardRequest{
+		Customer: input.CustomerID,
+		CreditCard: creditCard{
+			HolderName:  input.CardHolderName,
+			Number:      input.CardNumber,
+			ExpiryMonth: input.CardMonth,
+			ExpiryYear:  input.CardYear,
+			CCV:         input.CardCCV,
+		},
+		CreditCardHolderInfo: creditCardHolderInfo{
+			Name:          input.CardHolderName,
+			Email:         input.HolderEmail,
+			CpfCnpj:       input.HolderCpfCnpj,
+			PostalCode:    input.HolderPostalCode,
+			AddressNumber: input.HolderAddressNum,
+			Phone:         input.HolderPhone,
+			MobilePhone:   input.HolderPhone,
+		},
+		RemoteIP: input.RemoteIP,
+	}
+
+	respBytes, err := c.post("/creditCard/tokenizeCreditCard", payload)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao tokenizar cartão: %w", err)
+	}
+
+	var token CreditCardToken
+	if err := json.Unmarshal(respBytes, &token); err != nil {
+		return nil, fmt.Errorf("erro ao ler token do cartão: %w", err)
+	}
+	if strings.TrimSpace(token.Token) == "" {
+		return nil, fmt.Errorf("asaas não retornou o token do cartão")
+	}
+
+	fmt.Printf("[asaas] Cartão tokenizado: customer=%q brand=%q final=%q\n", input.CustomerID, token.Brand, token.LastDigits)
+	return &token, nil
+}
+
 func (c *Client) SubscribePix(input SubscribePixInput) (string, *PixOutput, error) {
 	priceFloat := float64(input.Price) / 100.0
 
+	// O prazo vem sempre da PixExpirationPolicy do checkout; não há janela padrão aqui.
+	if input.ExpiresAt.IsZero() {
+		return "", nil, fmt.Errorf("prazo de expiração do PIX não informado")
+	}
+
+	// O Asaas só aceita vencimento por dia: a primeira cobrança vence no dia do prazo
+	// (horário de Brasília). O limite em minutos é aplicado pelo PixExpirationWorker.
 	loc, _ := time.LoadLocation("America/Sao_Paulo")
-	nowBrazil := time.Now().In(loc)
-	expirationDate := nowBrazil.Add(30 * time.Minute) // PIX expira em 30 min
+	firstDueDate := input.ExpiresAt.In(loc)
 
 	reqBody := map[string]interface{}{
 		"customer":    input.CustomerID,
 		"billingType": "PIX",
 		"value":       priceFloat,
-		"cycle":       "MONTHLY",
-		"nextDueDate": nowBrazil.Format("2006-01-02"),      // Vence hoje
-		"dueDate":     expirationDate.Format("2006-01-02"), // Data de expiração
+		"cycle":       subscriptionCycle(input.Cycle),
+		"nextDueDate": firstDueDate.Format("2006-01-02"),
 		"description": "Plano Ligue - Assinatura",
 	}
 
-	fmt.Printf("[asaas] SubscribePix: customer=%q value=%.2f cycle=MONTHLY\n", input.CustomerID, priceFloat)
+	fmt.Printf("[asaas] SubscribePix: customer=%q value=%.2f cycle=%s\n", input.CustomerID, priceFloat, subscriptionCycle(input.Cycle))
 
 	respBody, err := c.post("/subscriptions", reqBody)
 	if err != nil {
@@ -349,6 +391,35 @@ func (c *Client) GetPixBySubscriptionID(subscriptionID string) (*PixOutput, erro
 	}, nil
 }
 
+// GetSubscriptionPayments lista as cobranças de uma assinatura (as mais recentes primeiro).
+func (c *Client) GetSubscriptionPayments(subscriptionID string) ([]Payment, error) {
+	subscriptionID = strings.TrimSpace(subscriptionID)
+	if subscriptionID == "" {
+		return nil, fmt.Errorf("subscriptionID vazio")
+	}
+
+	body, err := c.get(fmt.Sprintf("/subscriptions/%s/payments?limit=10", subscriptionID))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao listar pagamentos: %w", err)
+	}
+
+	var resp asaasPaymentsResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("erro json lista: %w", err)
+	}
+	return resp.Data, nil
+}
+
+// IsPaidStatus indica os status de cobrança em que o dinheiro já foi recebido.
+func IsPaidStatus(status string) bool {
+	switch strings.ToUpper(strings.TrimSpace(status)) {
+	case "RECEIVED", "CONFIRMED", "RECEIVED_IN_CASH":
+		return true
+	default:
+		return false
+	}
+}
+
 func (c *Client) DeleteSubscription(subscriptionID string) error {
 	subscriptionID = strings.TrimSpace(subscriptionID)
 	if subscriptionID == "" {
@@ -368,6 +439,9 @@ func (c *Client) DeleteSubscription(subscriptionID string) error {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("assinatura asaas %s: %w", subscriptionID, ErrNotFound)
+	}
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		body, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("erro ao deletar assinatura asaas (%d): %s", resp.StatusCode, string(body))
@@ -377,6 +451,137 @@ func (c *Client) DeleteSubscription(subscriptionID string) error {
 	return nil
 }
 
+// UpdateSubscriptionValue altera o valor das próximas cobranças da assinatura, incluindo
+// as já geradas e ainda pendentes.
+func (c *Client) UpdateSubscriptionValue(subscriptionID string, valueCents int) error {
+	subscriptionID = strings.TrimSpace(subscriptionID)
+	if subscriptionID == "" {
+		return fmt.Errorf("subscriptionID vazio")
+	}
+
+	jsonBody, err := json.Marshal(updateSubscriptionValueRequest{
+		Value:                 float64(valueCents) / 100.0,
+		UpdatePendingPayments: true,
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao gerar json: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/subscriptions/%s", c.baseURL, subscriptionID)
+	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return fmt.Errorf("erro ao criar request de atualização: %w", err)
+	}
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("erro de conexão ao atualizar assinatura: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("assinatura asaas %s: %w", subscriptionID, ErrNotFound)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("erro ao atualizar assinatura asaas (%d): %s", resp.StatusCode, string(body))
+	}
+
+	fmt.Printf("[asaas] Valor da assinatura atualizado: id=%q value_cents=%d\n", subscriptionID, valueCents)
+	return nil
+}
+
+// CreatePayment gera uma cobrança avulsa para o cliente. Com billingType UNDEFINED o
+// cliente escolhe PIX, boleto ou cartão na fatura.
+func (c *Client) CreatePayment(input CreatePaymentInput) (*PaymentOutput, error) {
+	if strings.TrimSpace(input.CustomerID) == "" {
+		return nil, fmt.Errorf("customerID vazio")
+	}
+
+	respBytes, err := c.post("/payments", createPaymentRequest{
+		Customer:          input.CustomerID,
+		BillingType:       "UNDEFINED",
+		Value:             float64(input.ValueCents) / 100.0,
+		DueDate:           input.DueDate.Format("2006-01-02"),
+		Description:       input.Description,
+		ExternalReference: input.ExternalReference,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar cobrança avulsa: %w", err)
+	}
+
+	var payment PaymentOutput
+	if err := json.Unmarshal(respBytes, &payment); err != nil {
+		return nil, fmt.Errorf("erro ao ler resposta asaas: %w", err)
+	}
+
+	fmt.Printf("[asaas] Cobrança avulsa criada: id=%q value_cents=%d\n", payment.ID, input.ValueCents)
+	return &payment, nil
+}
+
+// DeletePayment cancela uma cobrança avulsa ainda não paga.
+func (c *Client) DeletePayment(paymentID string) error {
+	paymentID = strings.TrimSpace(paymentID)
+	if paymentID == "" {
+		return fmt.Errorf("paymentID vazio")
+	}
+
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/payments/%s", c.baseURL, paymentID), nil)
+	if err != nil {
+		return fmt.Errorf("erro ao criar request de delete: %w", err)
+	}
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("erro de conexão ao cancelar cobrança: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("cobrança asaas %s: %w", paymentID, ErrNotFound)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("erro ao cancelar cobrança asaas (%d): %s", resp.StatusCode, string(body))
+	}
+
+	fmt.Printf("[asaas] Cobrança avulsa cancelada: id=%q\n", paymentID)
+	return nil
+}
+
+// FindPaymentByExternalReference busca a cobrança avulsa criada com o externalReference;
+// retorna nil se não existir. Usado para não duplicar a cobrança num retry.
+func (c *Client) FindPaymentByExternalReference(externalReference string) (*PaymentOutput, error) {
+	externalReference = strings.TrimSpace(externalReference)
+	if externalReference == "" {
+		return nil, fmt.Errorf("externalReference vazio")
+	}
+
+	body, err := c.get("/payments?limit=1&externalReference=" + url.QueryEscape(externalReference))
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar cobrança %s: %w", externalReference, err)
+	}
+
+	var resp asaasPaymentOutputsResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("erro json lista: %w", err)
+	}
+	if len(resp.Data) == 0 {
+		return nil, nil
+	}
+	return &resp.Data[0], nil
+}
+
+// subscriptionCycle usa o ciclo do plano; sem ciclo, a assinatura é mensal.
+func subscriptionCycle(cycle string) string {
+	if cycle = strings.ToUpper(strings.TrimSpace(cycle)); cycle != "" {
+		return cycle
+	}
+	return "MONTHLY"
+}
+
 func (c *Client) setHeaders(req *http.Request) {
 	req.Header.Set("access_token", c.apiKey)
 	req.Header.Set("Content-Type", "application/json")
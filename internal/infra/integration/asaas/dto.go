@@ -1,5 +1,7 @@
 package asaas
 
+import "time"
+
 type PixOutput struct {
 	CopyPaste string
 	URL       string
@@ -19,6 +21,8 @@ type asaasSubscriptionResponse struct {
 type SubscribePixInput struct {
 	CustomerID string
 	Price      int64
+	Cycle      string    // MONTHLY quando vazio
+	ExpiresAt  time.Time // prazo de pagamento (obrigatório, da PixExpirationPolicy); o dia vira o nextDueDate
 }
 
 
@@ -28,14 +32,37 @@ type asaasListPaymentsResponse struct {
 	} `json:"data"`
 }
 
+// Payment é uma cobrança gerada por uma assinatura Asaas.
+type Payment struct {
+	ID          string  `json:"id"`
+	Status      string  `json:"status"` // PENDING, RECEIVED, CONFIRMED, OVERDUE, REFUNDED...
+	Value       float64 `json:"value"`
+	BillingType string  `json:"billingType"`
+	PaymentDate string  `json:"paymentDate"`
+}
+
+type asaasPaymentsResponse struct {
+	Data []Payment `json:"data"`
+}
+
 type asaasQrCodeResponse struct {
 	EncodedImage string `json:"encodedImage"`
 	Payload      string `json:"payload"`
 }
+
+// SubscribeInput cria a assinatura no cartão já tokenizado (ver TokenizeCreditCard).
 type SubscribeInput struct {
-	CustomerID string
-	Price      float64
+	CustomerID      string
+	Price           float64
+	Cycle           string // MONTHLY quando vazio
+	CreditCardToken string
+	RemoteIP        string
+}
 
+// TokenizeCreditCardInput são os dados digitados no checkout. Só o handler monta essa
+// struct, uma única vez, para trocar o cartão pelo creditCardToken.
+type TokenizeCreditCardInput struct {
+	CustomerID string
 
 	CardHolderName string
 	CardNumber     string
@@ -43,12 +70,26 @@ type SubscribeInput struct {
 	CardYear       string
 	CardCCV        string
 
-
 	HolderEmail      string
 	HolderCpfCnpj    string
 	HolderPostalCode string
 	HolderAddressNum string
 	HolderPhone      string
+	RemoteIP         string
+}
+
+// CreditCardToken é a resposta de /creditCard/tokenizeCreditCard.
+type CreditCardToken struct {
+	Token      string `json:"creditCardToken"`
+	Brand      string `json:"creditCardBrand"`
+	LastDigits string `json:"creditCardNumber"` // últimos 4 dígitos
+}
+
+type tokenizeCreditCardRequest struct {
+	Customer             string               `json:"customer"`
+	CreditCard           creditCard           `json:"creditCard"`
+	CreditCardHolderInfo creditCardHolderInfo `json:"creditCardHolderInfo"`
+	RemoteIP             string               `json:"remoteIp,omitempty"`
 }
 
 type CreateCustomerInput struct {
@@ -86,14 +127,14 @@ type customerResponse struct {
 
 
 type createSubscriptionRequest struct {
-	Customer             string               `json:"customer"`
-	BillingType          string               `json:"billingType"`
-	Value                float64              `json:"value"`
-	NextDueDate          string               `json:"nextDueDate"`
-	Cycle                string               `json:"cycle"`
-	Description          string               `json:"description"`
-	CreditCard           creditCard           `json:"creditCard"`
-	CreditCardHolderInfo creditCardHolderInfo `json:"creditCardHolderInfo"`
+	Customer        string  `json:"customer"`
+	BillingType     string  `json:"billingType"`
+	Value           float64 `json:"value"`
+	NextDueDate     string  `json:"nextDueDate"`
+	Cycle           string  `json:"cycle"`
+	Description     string  `json:"description"`
+	CreditCardToken string  `json:"creditCardToken"`
+	RemoteIP        string  `json:"remoteIp,omitempty"`
 }
 
 
@@ -126,3 +167,37 @@ type SubscribePix struct {
 	CustomerID string
 	PriceCents int64
 }
+
+type updateSubscriptionValueRequest struct {
+	Value                 float64 `json:"value"`
+	UpdatePendingPayments bool    `json:"updatePendingPayments"`
+}
+
+// CreatePaymentInput é uma cobrança avulsa, fora do ciclo da assinatura.
+type CreatePaymentInput struct {
+	CustomerID        string
+	ValueCents        int
+	DueDate           time.Time
+	Description       string
+	ExternalReference string
+}
+
+type createPaymentRequest struct {
+	Customer          string  `json:"customer"`
+	BillingType       string  `json:"billingType"`
+	Value             float64 `json:"value"`
+	DueDate           string  `json:"dueDate"`
+	Description       string  `json:"description"`
+	ExternalReference string  `json:"externalReference,omitempty"`
+}
+
+// PaymentOutput é a cobrança avulsa criada, com o link da fatura para o cliente pagar.
+type PaymentOutput struct {
+	ID         string `json:"id"`
+	Status     string `json:"status"`
+	InvoiceURL string `json:"invoiceUrl"`
+}
+
+type asaasPaymentOutputsResponse struct {
+	Data []PaymentOutput `json:"data"`
+}
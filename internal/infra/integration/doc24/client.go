@@ -60,6 +60,7 @@ type afiliado struct {
 	Plan            string `json:"plan"`
 	TelefonoMovil   string `json:"telefono_movil"`
 	Email           string `json:"email"`
+	FechaBaja       string `json:"fecha_baja,omitempty"` // preenchida apenas na baixa do afiliado
 }
 
 func (c *Client) EnsureAuthenticated(ctx context.Context) error {
@@ -155,89 +156,245 @@ func (c *Client) sendAfiliado(ctx context.Context, a afiliado) error {
 	return fmt.Errorf("doc24 retornou estado=%d mensagem=%q", result.Estado, result.Mensaje)
 }
 
-func (c *Client) CreateBeneficiary(ctx context.Context, input queue.ActivationPayload) error {
-	if err := c.EnsureAuthenticated(ctx); err != nil {
-		return err
+// titular descreve o titular do plano, comum aos payloads de ativação e desativação.
+type titular struct {
+	Name      string
+	CPF       string
+	Gender    string
+	BirthDate string
+	Phone     string
+	Email     string
+}
+
+func planOrDefault(providerPlanCode string) string {
+	planName := strings.TrimSpace(providerPlanCode)
+	if planName == "" {
+		log.Println("[Doc24] ProviderPlanCode veio vazio! Usando plano default.")
+		planName = "ligue saude em dia individual"
 	}
+	return planName
+}
 
-	parts := strings.SplitN(input.Name, " ", 2)
-	nome := parts[0]
-	sobrenome := ""
+func splitName(name string) (string, string) {
+	parts := strings.SplitN(name, " ", 2)
 	if len(parts) > 1 {
-		sobrenome = parts[1]
+		return parts[0], parts[1]
 	}
+	return parts[0], ""
+}
+
+func titularAfiliado(t titular, planName, today string) afiliado {
+	nome, sobrenome := splitName(t.Name)
 
 	sexo := "M"
-	if strings.Contains(strings.ToLower(input.Gender), "fem") || strings.ToUpper(input.Gender) == "F" {
+	if strings.Contains(strings.ToLower(t.Gender), "fem") || strings.ToUpper(t.Gender) == "F" {
 		sexo = "F"
 	}
 
-	planName := strings.TrimSpace(input.ProviderPlanCode)
-	if planName == "" {
-		log.Println("[Doc24] ProviderPlanCode veio vazio! Usando plano default.")
-		planName = "ligue saude em dia individual"
-	}
-
-	today := time.Now().Format("2006-01-02")
-	titularCPF := normalizeDigits(input.CPF)
-
-	titular := afiliado{
+	titularCPF := normalizeDigits(t.CPF)
+	return afiliado{
 		NroDocumento:    titularCPF,
 		Credencial:      titularCPF,
 		Apellido:        sobrenome,
 		FechaAlta:       today,
-		FechaNacimiento: input.BirthDate,
+		FechaNacimiento: t.BirthDate,
 		NroDocTitular:   titularCPF,
 		Sexo:            sexo,
 		Empresa:         "Ligue_digital",
 		Nombre:          nome,
 		Plan:            planName,
-		TelefonoMovil:   normalizeDigits(input.Phone),
-		Email:           input.Email,
+		TelefonoMovil:   normalizeDigits(t.Phone),
+		Email:           t.Email,
+	}
+}
+
+func dependentAfiliado(dep queue.DependentPayload, t titular, planName, today string) afiliado {
+	depNombre, depApellido := splitName(dep.Name)
+
+	depSexo := "M"
+	if dep.Gender == 2 {
+		depSexo = "F"
+	}
+
+	return afiliado{
+		NroDocumento:    normalizeDigits(dep.CPF),
+		Credencial:      normalizeDigits(dep.CPF),
+		Apellido:        depApellido,
+		FechaAlta:       today,
+		FechaNacimiento: dep.BirthDate,
+		NroDocTitular:   normalizeDigits(t.CPF),
+		Sexo:            depSexo,
+		Empresa:         "Ligue_digital",
+		Nombre:          depNombre,
+		Plan:            planName,
+		TelefonoMovil:   normalizeDigits(t.Phone),
+		Email:           t.Email,
 	}
+}
+
+func activationTitular(input queue.ActivationPayload) titular {
+	return titular{
+		Name:      input.Name,
+		CPF:       input.CPF,
+		Gender:    input.Gender,
+		BirthDate: input.BirthDate,
+		Phone:     input.Phone,
+		Email:     input.Email,
+	}
+}
+
+func (c *Client) CreateBeneficiary(ctx context.Context, input queue.ActivationPayload) error {
+	if err := c.EnsureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	planName := planOrDefault(input.ProviderPlanCode)
+	today := time.Now().Format("2006-01-02")
+	t := activationTitular(input)
 
-	if err := c.sendAfiliado(ctx, titular); err != nil {
+	if err := c.sendAfiliado(ctx, titularAfiliado(t, planName, today)); err != nil {
 		return err
 	}
 	log.Printf("🚀 [Doc24] Titular %s vinculado ao plano '%s'", input.Name, planName)
 
 	for _, dep := range input.Dependents {
-		depParts := strings.SplitN(dep.Name, " ", 2)
-		depNombre := depParts[0]
-		depApellido := ""
-		if len(depParts) > 1 {
-			depApellido = depParts[1]
+		if err := c.sendAfiliado(ctx, dependentAfiliado(dep, t, planName, today)); err != nil {
+			log.Printf("⚠️ [Doc24] Falha ao vincular dependente %s: %v", dep.Name, err)
+		} else {
+			log.Printf("🚀 [Doc24] Dependente %s vinculado ao plano '%s'", dep.Name, planName)
+		}
+	}
+
+	return nil
+}
+
+// UpdateBeneficiary reenvia o titular e os dependentes do payload à elegibilidade, que
+// atualiza o afiliado existente pelo documento (dados cadastrais ou plano novo). A
+// fecha_alta é a original, como na baixa; a data da correção não é alta nova.
+func (c *Client) UpdateBeneficiary(ctx context.Context, input queue.ActivationPayload) error {
+	if err := c.EnsureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	planName := planOrDefault(input.ProviderPlanCode)
+	today := time.Now().Format("2006-01-02")
+	t := activationTitular(input)
+
+	if err := c.sendAfiliado(ctx, titularAfiliado(t, planName, enrollmentDate(input.CustomerID, today, input.ActivatedAt))); err != nil {
+		return err
+	}
+	log.Printf("✏️ [Doc24] Dados do titular %s atualizados", input.Name)
+
+	for _, dep := range input.Dependents {
+		if err := c.sendAfiliado(ctx, dependentAfiliado(dep, t, planName, enrollmentDate(input.CustomerID, today, dep.ActivatedAt, input.ActivatedAt))); err != nil {
+			return fmt.Errorf("falha ao atualizar dependente %s: %w", dep.Name, err)
 		}
-		depSexo := "M"
-		if dep.Gender == 2 {
-			depSexo = "F"
+		log.Printf("✏️ [Doc24] Dados do dependente %s atualizados", dep.Name)
+	}
+
+	return nil
+}
+
+// AddDependents vincula ao titular os dependentes do payload. Diferente da ativação,
+// falha em um dependente retorna erro: a mensagem existe só para esses dependentes.
+func (c *Client) AddDependents(ctx context.Context, input queue.ActivationPayload) error {
+	if err := c.EnsureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	planName := planOrDefault(input.ProviderPlanCode)
+	today := time.Now().Format("2006-01-02")
+	t := activationTitular(input)
+
+	for _, dep := range input.Dependents {
+		if err := c.sendAfiliado(ctx, dependentAfiliado(dep, t, planName, today)); err != nil {
+			return fmt.Errorf("falha ao vincular dependente %s: %w", dep.Name, err)
 		}
+		log.Printf("🚀 [Doc24] Dependente %s vinculado ao plano '%s'", dep.Name, planName)
+	}
+
+	return nil
+}
+
+// RemoveDependents dá baixa só nos dependentes do payload; o titular continua ativo.
+func (c *Client) RemoveDependents(ctx context.Context, input queue.DeactivationPayload) error {
+	if err := c.EnsureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	planName := planOrDefault(input.ProviderPlanCode)
+	today := time.Now().Format("2006-01-02")
+	t := titular{Name: input.Name, CPF: input.CPF, Phone: input.Phone, Email: input.Email}
+
+	for _, dep := range input.Dependents {
+		dependent := dependentAfiliado(dep, t, planName, enrollmentDate(input.CustomerID, today, dep.ActivatedAt, input.ActivatedAt))
+		dependent.FechaBaja = today
 
-		dependent := afiliado{
-			NroDocumento:    normalizeDigits(dep.CPF),
-			Credencial:      normalizeDigits(dep.CPF),
-			Apellido:        depApellido,
-			FechaAlta:       today,
-			FechaNacimiento: dep.BirthDate,
-			NroDocTitular:   titularCPF,
-			Sexo:            depSexo,
-			Empresa:         "Ligue_digital",
-			Nombre:          depNombre,
-			Plan:            planName,
-			TelefonoMovil:   normalizeDigits(input.Phone),
-			Email:           input.Email,
+		if err := c.sendAfiliado(ctx, dependent); err != nil {
+			return fmt.Errorf("falha ao dar baixa no dependente %s: %w", dep.Name, err)
 		}
+		log.Printf("🛑 [Doc24] Dependente %s desvinculado do titular %s", dep.Name, input.Name)
+	}
+
+	return nil
+}
+
+// DeactivateBeneficiary dá baixa no titular e nos dependentes reenviando o afiliado
+// à elegibilidade com fecha_baja. Os dependentes são baixados antes do titular e
+// qualquer falha retorna erro, para que a mensagem seja reprocessada em vez de deixar acesso ativo.
+func (c *Client) DeactivateBeneficiary(ctx context.Context, input queue.DeactivationPayload) error {
+	if err := c.EnsureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	if normalizeDigits(input.CPF) == "" {
+		return fmt.Errorf("doc24: CPF do titular ausente na desativação (customer=%s)", input.CustomerID)
+	}
+
+	planName := planOrDefault(input.ProviderPlanCode)
+	today := time.Now().Format("2006-01-02")
+	t := titular{
+		Name:      input.Name,
+		CPF:       input.CPF,
+		Gender:    input.Gender,
+		BirthDate: input.BirthDate,
+		Phone:     input.Phone,
+		Email:     input.Email,
+	}
+
+	for _, dep := range input.Dependents {
+		dependent := dependentAfiliado(dep, t, planName, enrollmentDate(input.CustomerID, today, dep.ActivatedAt, input.ActivatedAt))
+		dependent.FechaBaja = today
 
 		if err := c.sendAfiliado(ctx, dependent); err != nil {
-			log.Printf("⚠️ [Doc24] Falha ao vincular dependente %s: %v", dep.Name, err)
-		} else {
-			log.Printf("🚀 [Doc24] Dependente %s vinculado ao plano '%s'", dep.Name, planName)
+			return fmt.Errorf("falha ao dar baixa no dependente %s: %w", dep.Name, err)
 		}
+		log.Printf("🛑 [Doc24] Dependente %s desvinculado do plano '%s'", dep.Name, planName)
 	}
 
+	holder := titularAfiliado(t, planName, enrollmentDate(input.CustomerID, today, input.ActivatedAt))
+	holder.FechaBaja = today
+
+	if err := c.sendAfiliado(ctx, holder); err != nil {
+		return err
+	}
+	log.Printf("🛑 [Doc24] Titular %s desvinculado do plano '%s' (motivo: %s)", input.Name, planName, input.Reason)
+
 	return nil
 }
 
+// enrollmentDate devolve a fecha_alta da baixa e da atualização: a primeira data de alta informada, para não
+// sobrescrever a adesão original. Sem nenhuma (assinatura anterior ao activated_at), usa hoje.
+func enrollmentDate(customerID, today string, dates ...string) string {
+	for _, date := range dates {
+		if date = strings.TrimSpace(date); date != "" {
+			return date
+		}
+	}
+	log.Printf("⚠️ [Doc24] Data de alta desconhecida do customer %s; enviando %s", customerID, today)
+	return today
+}
+
 func normalizeDigits(value string) string {
 	value = strings.TrimSpace(value)
 	if value == "" {
@@ -246,6 +403,7 @@ func normalizeDigits(value string) string {
 	return nonDigitsRegex.ReplaceAllString(value, "")
 }
 
-func (c *Client) GetBeneficiaryID(cpf string) string {
-	return cpf
+// GetBeneficiaryID devolve o CPF: a Doc24 identifica o beneficiário pelo documento.
+func (c *Client) GetBeneficiaryID(ctx context.Context, cpf string) (string, error) {
+	return cpf, nil
 }
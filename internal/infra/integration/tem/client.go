@@ -0,0 +1,432 @@
+package tem
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	httptrace "github.com/DataDog/dd-trace-go/contrib/net/http/v2"
+	"github.com/xavierca1/ligue-payments/internal/infra/queue"
+)
+
+const DefaultBaseURL = "https://api.temsaude.com/v1"
+
+// ErrBeneficiaryNotFound indica que a TEM não tem cadastro para o CPF consultado.
+var ErrBeneficiaryNotFound = errors.New("beneficiário não encontrado na tem")
+
+var (
+	nonDigitsRegex = regexp.MustCompile(`\D`)
+	// documentos/telefones e e-mails mascarados antes de ir para log ou erro
+	longDigitsRegex = regexp.MustCompile(`\d{8,}`)
+	emailRegex      = regexp.MustCompile(`([A-Za-z0-9._%+-]{1,2})[A-Za-z0-9._%+-]*@([A-Za-z0-9.-]+)`)
+)
+
+// apiError é uma resposta de erro da TEM; o corpo já vem mascarado.
+type apiError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("erro api tem (%d): %s", e.StatusCode, e.Body)
+}
+
+func hasStatus(err error, status int) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// maskPII esconde CPFs, telefones e e-mails das respostas da TEM.
+func maskPII(body string) string {
+	body = longDigitsRegex.ReplaceAllStringFunc(body, func(digits string) string {
+		return "***" + digits[len(digits)-2:]
+	})
+	return emailRegex.ReplaceAllString(body, "$1***@$2")
+}
+
+type Client struct {
+	HTTPClient   *http.Client
+	BaseURL      string
+	ClientID     string
+	ClientSecret string
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+func NewClient(baseURL, clientID, clientSecret string) *Client {
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		HTTPClient:   httptrace.WrapClient(&http.Client{Timeout: 30 * time.Second}),
+		BaseURL:      baseURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+	}
+}
+
+func (c *Client) EnsureAuthenticated(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Add(30*time.Second).Before(c.tokenExpiry) {
+		return nil
+	}
+
+	if c.ClientID == "" || c.ClientSecret == "" {
+		return fmt.Errorf("tem não configurado: TEM_CLIENT_ID/TEM_CLIENT_SECRET ausentes")
+	}
+
+	log.Println("🔄 [TEM] Renovando token...")
+
+	body, _ := json.Marshal(map[string]string{
+		"grant_type":    "client_credentials",
+		"client_id":     c.ClientID,
+		"client_secret": c.ClientSecret,
+	})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/oauth/token", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("erro request auth tem: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errBody bytes.Buffer
+		errBody.ReadFrom(resp.Body)
+		log.Printf("❌ [TEM] Erro Auth: %s", maskPII(errBody.String()))
+		return fmt.Errorf("erro auth tem: status %d", resp.StatusCode)
+	}
+
+	var data tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return fmt.Errorf("erro decode auth tem: %w", err)
+	}
+
+	c.token = data.AccessToken
+	exp := data.ExpiresIn
+	if exp == 0 {
+		exp = 3600
+	}
+	c.tokenExpiry = time.Now().Add(time.Duration(exp) * time.Second)
+
+	log.Println("✅ [TEM] Token renovado com sucesso!")
+	return nil
+}
+
+// do envia a requisição autenticada e decodifica a resposta em out (quando não nil). Um 401
+// (token revogado antes do vencimento) renova o token e repete a chamada uma única vez.
+func (c *Client) do(ctx context.Context, method, path string, payload, out interface{}) error {
+	err := c.send(ctx, method, path, payload, out)
+	if !hasStatus(err, http.StatusUnauthorized) {
+		return err
+	}
+
+	log.Printf("🔄 [TEM] 401 em %s %s; renovando token e repetindo", method, path)
+	c.mu.Lock()
+	c.token = ""
+	c.mu.Unlock()
+	if authErr := c.EnsureAuthenticated(ctx); authErr != nil {
+		return authErr
+	}
+	return c.send(ctx, method, path, payload, out)
+}
+
+func (c *Client) send(ctx context.Context, method, path string, payload, out interface{}) error {
+	var body []byte
+	if payload != nil {
+		body, _ = json.Marshal(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	c.mu.Unlock()
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("falha request tem: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var respBuf bytes.Buffer
+	respBuf.ReadFrom(resp.Body)
+	log.Printf("[TEM] %s %s -> HTTP %d (%d bytes)", method, maskPII(path), resp.StatusCode, respBuf.Len())
+
+	if resp.StatusCode >= 400 {
+		return &apiError{StatusCode: resp.StatusCode, Body: maskPII(strings.TrimSpace(respBuf.String()))}
+	}
+
+	if out != nil && respBuf.Len() > 0 {
+		if err := json.Unmarshal(respBuf.Bytes(), out); err != nil {
+			return fmt.Errorf("tem resposta inválida: %s", maskPII(strings.TrimSpace(respBuf.String())))
+		}
+	}
+	return nil
+}
+
+// createBeneficiario cadastra o beneficiário. 409 (já cadastrado) conta como sucesso, para o
+// reprocessamento da mensagem não travar em quem já entrou na tentativa anterior.
+func (c *Client) createBeneficiario(ctx context.Context, b beneficiario, out interface{}) error {
+	err := c.do(ctx, http.MethodPost, "/beneficiarios", b, out)
+	if hasStatus(err, http.StatusConflict) {
+		log.Printf("ℹ️ [TEM] Beneficiário %s já cadastrado", maskPII(b.CPF))
+		return nil
+	}
+	return err
+}
+
+func (c *Client) CreateBeneficiary(ctx context.Context, input queue.ActivationPayload) error {
+	if err := c.EnsureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	planName := strings.TrimSpace(input.ProviderPlanCode)
+	if planName == "" {
+		return fmt.Errorf("tem: ProviderPlanCode vazio para o plano %s", input.PlanID)
+	}
+
+	titularCPF := normalizeDigits(input.CPF)
+	titular := beneficiario{
+		Nome:           strings.TrimSpace(input.Name),
+		CPF:            titularCPF,
+		DataNascimento: input.BirthDate,
+		Sexo:           sexoFromLabel(input.Gender),
+		Email:          input.Email,
+		Celular:        normalizeDigits(input.Phone),
+		Plano:          planName,
+		CodigoExterno:  input.CustomerID,
+	}
+
+	if err := c.createBeneficiario(ctx, titular, nil); err != nil {
+		return err
+	}
+	log.Printf("🚀 [TEM] Titular %s vinculado ao plano '%s'", input.Name, planName)
+
+	// Falha em dependente retorna erro: a mensagem é reprocessada e quem já entrou responde 409.
+	for _, dep := range input.Dependents {
+		if err := c.createBeneficiario(ctx, dependentBeneficiario(dep, input, titularCPF, planName), nil); err != nil {
+			return fmt.Errorf("falha ao vincular dependente %s: %w", dep.Name, err)
+		}
+		log.Printf("🚀 [TEM] Dependente %s vinculado ao plano '%s'", dep.Name, planName)
+	}
+
+	return nil
+}
+
+// UpdateBeneficiary atualiza cadastro e contato do titular e dos dependentes do payload.
+func (c *Client) UpdateBeneficiary(ctx context.Context, input queue.ActivationPayload) error {
+	if err := c.EnsureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	titularCPF := normalizeDigits(input.CPF)
+	update := beneficiario{
+		Nome:           strings.TrimSpace(input.Name),
+		CPF:            titularCPF,
+		DataNascimento: input.BirthDate,
+		Sexo:           sexoFromLabel(input.Gender),
+		Email:          input.Email,
+		Celular:        normalizeDigits(input.Phone),
+		Plano:          strings.TrimSpace(input.ProviderPlanCode),
+		CodigoExterno:  input.CustomerID,
+	}
+
+	titularID, err := c.findBeneficiaryID(ctx, titularCPF)
+	if err != nil {
+		return err
+	}
+	if err := c.do(ctx, http.MethodPut, "/beneficiarios/"+url.PathEscape(titularID), update, nil); err != nil {
+		return err
+	}
+	log.Printf("✏️ [TEM] Dados do titular %s atualizados", input.Name)
+
+	for _, dep := range input.Dependents {
+		depID, err := c.findBeneficiaryID(ctx, normalizeDigits(dep.CPF))
+		if err != nil {
+			return fmt.Errorf("falha ao localizar dependente %s: %w", dep.Name, err)
+		}
+		if err := c.do(ctx, http.MethodPut, "/beneficiarios/"+url.PathEscape(depID), dependentBeneficiario(dep, input, titularCPF, update.Plano), nil); err != nil {
+			return fmt.Errorf("falha ao atualizar dependente %s: %w", dep.Name, err)
+		}
+		log.Printf("✏️ [TEM] Dados do dependente %s atualizados", dep.Name)
+	}
+
+	return nil
+}
+
+// AddDependents vincula ao titular os dependentes do payload; falha em qualquer um retorna erro.
+func (c *Client) AddDependents(ctx context.Context, input queue.ActivationPayload) error {
+	if err := c.EnsureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	planName := strings.TrimSpace(input.ProviderPlanCode)
+	if planName == "" {
+		return fmt.Errorf("tem: ProviderPlanCode vazio para o plano %s", input.PlanID)
+	}
+
+	titularCPF := normalizeDigits(input.CPF)
+	for _, dep := range input.Dependents {
+		if err := c.createBeneficiario(ctx, dependentBeneficiario(dep, input, titularCPF, planName), nil); err != nil {
+			return fmt.Errorf("falha ao vincular dependente %s: %w", dep.Name, err)
+		}
+		log.Printf("🚀 [TEM] Dependente %s vinculado ao plano '%s'", dep.Name, planName)
+	}
+
+	return nil
+}
+
+func dependentBeneficiario(dep queue.DependentPayload, input queue.ActivationPayload, titularCPF, planName string) beneficiario {
+	return beneficiario{
+		Nome:           strings.TrimSpace(dep.Name),
+		CPF:            normalizeDigits(dep.CPF),
+		CPFTitular:     titularCPF,
+		DataNascimento: dep.BirthDate,
+		Sexo:           sexoFromCode(dep.Gender),
+		Plano:          planName,
+		Parentesco:     dep.Kinship,
+		CodigoExterno:  input.CustomerID,
+	}
+}
+
+// RemoveDependents inativa só os dependentes do payload; o titular continua ativo.
+func (c *Client) RemoveDependents(ctx context.Context, input queue.DeactivationPayload) error {
+	if err := c.EnsureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	req := inativacaoRequest{
+		DataInativacao: time.Now().Format("2006-01-02"),
+		Motivo:         input.Reason,
+	}
+
+	for _, dep := range input.Dependents {
+		if err := c.inativar(ctx, normalizeDigits(dep.CPF), req); err != nil {
+			return fmt.Errorf("falha ao inativar dependente %s: %w", dep.Name, err)
+		}
+		log.Printf("🛑 [TEM] Dependente %s inativado (titular %s)", dep.Name, input.Name)
+	}
+
+	return nil
+}
+
+// DeactivateBeneficiary inativa dependentes e titular. Assim como na Doc24, qualquer
+// falha retorna erro para que a mensagem seja reprocessada em vez de deixar acesso ativo.
+func (c *Client) DeactivateBeneficiary(ctx context.Context, input queue.DeactivationPayload) error {
+	if err := c.EnsureAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	titularCPF := normalizeDigits(input.CPF)
+	if titularCPF == "" {
+		return fmt.Errorf("tem: CPF do titular ausente na desativação (customer=%s)", input.CustomerID)
+	}
+
+	req := inativacaoRequest{
+		DataInativacao: time.Now().Format("2006-01-02"),
+		Motivo:         input.Reason,
+	}
+
+	for _, dep := range input.Dependents {
+		if err := c.inativar(ctx, normalizeDigits(dep.CPF), req); err != nil {
+			return fmt.Errorf("falha ao inativar dependente %s: %w", dep.Name, err)
+		}
+		log.Printf("🛑 [TEM] Dependente %s inativado", dep.Name)
+	}
+
+	if err := c.inativar(ctx, titularCPF, req); err != nil {
+		return err
+	}
+	log.Printf("🛑 [TEM] Titular %s inativado (motivo: %s)", input.Name, input.Reason)
+
+	return nil
+}
+
+// GetBeneficiaryID consulta na TEM o ID do beneficiário pelo CPF. O ID não fica em memória:
+// qualquer réplica, depois de um restart ou de um 409 no cadastro, chega ao mesmo valor.
+func (c *Client) GetBeneficiaryID(ctx context.Context, cpf string) (string, error) {
+	if err := c.EnsureAuthenticated(ctx); err != nil {
+		return "", err
+	}
+	return c.findBeneficiaryID(ctx, normalizeDigits(cpf))
+}
+
+// findBeneficiaryID busca o beneficiário por CPF; as rotas de atualização e inativação
+// da TEM usam o ID retornado aqui.
+func (c *Client) findBeneficiaryID(ctx context.Context, cpf string) (string, error) {
+	if cpf == "" {
+		return "", fmt.Errorf("tem: CPF vazio na busca do beneficiário")
+	}
+
+	var found beneficiarioResponse
+	err := c.do(ctx, http.MethodGet, "/beneficiarios/cpf/"+cpf, nil, &found)
+	if hasStatus(err, http.StatusNotFound) {
+		return "", fmt.Errorf("%w: cpf %s", ErrBeneficiaryNotFound, maskPII(cpf))
+	}
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(found.ID) == "" {
+		return "", fmt.Errorf("tem não retornou o ID do beneficiário %s", maskPII(cpf))
+	}
+	return found.ID, nil
+}
+
+// inativar localiza o beneficiário pelo CPF e o inativa. Quem não consta na TEM não tem
+// acesso a revogar: segue sem erro para a mensagem não ficar presa na fila.
+func (c *Client) inativar(ctx context.Context, cpf string, req inativacaoRequest) error {
+	id, err := c.findBeneficiaryID(ctx, cpf)
+	if errors.Is(err, ErrBeneficiaryNotFound) {
+		log.Printf("ℹ️ [TEM] Beneficiário %s não encontrado; nada a inativar", maskPII(cpf))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodPost, "/beneficiarios/"+url.PathEscape(id)+"/inativar", req, nil)
+}
+
+func sexoFromLabel(gender string) string {
+	if strings.Contains(strings.ToLower(gender), "fem") || strings.ToUpper(gender) == "F" {
+		return "F"
+	}
+	return "M"
+}
+
+func sexoFromCode(gender int) string {
+	if gender == 2 {
+		return "F"
+	}
+	return "M"
+}
+
+func normalizeDigits(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	return nonDigitsRegex.ReplaceAllString(value, "")
+}
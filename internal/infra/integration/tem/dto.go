@@ -0,0 +1,31 @@
+package tem
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// beneficiario é o cadastro de vida na TEM Saúde. Dependentes usam CPFTitular
+// para ficarem vinculados ao titular no mesmo plano.
+type beneficiario struct {
+	Nome           string `json:"nome"`
+	CPF            string `json:"cpf"`
+	CPFTitular     string `json:"cpf_titular,omitempty"`
+	DataNascimento string `json:"data_nascimento"`
+	Sexo           string `json:"sexo"` // M, F
+	Email          string `json:"email,omitempty"`
+	Celular        string `json:"celular,omitempty"`
+	Plano          string `json:"plano"`
+	Parentesco     string `json:"parentesco,omitempty"`
+	CodigoExterno  string `json:"codigo_externo,omitempty"` // customer_id no nosso banco (pra rastreio)
+}
+
+type beneficiarioResponse struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+type inativacaoRequest struct {
+	DataInativacao string `json:"data_inativacao"`
+	Motivo         string `json:"motivo,omitempty"`
+}
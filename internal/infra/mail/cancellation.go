@@ -0,0 +1,33 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"text/template"
+	"time"
+)
+
+func buildCancellationEmail(name, planName string, accessUntil time.Time) (CancellationEmailData, string, error) {
+	data := CancellationEmailData{
+		Subject:     "Cancelamento da sua assinatura Ligue Medicina",
+		FirstName:   firstName(name),
+		PlanName:    planName,
+		AccessUntil: accessUntil.Format("02/01/2006"),
+		Immediate:   !accessUntil.After(time.Now()),
+		WhatsAppURL: "https://wa.me/5511915187330",
+	}
+
+	tmplPath := filepath.Join("templates", "cancellation.html")
+	t, err := template.ParseFiles(tmplPath)
+	if err != nil {
+		return data, "", fmt.Errorf("erro ao ler template de email: %w", err)
+	}
+
+	var body bytes.Buffer
+	if err := t.Execute(&body, data); err != nil {
+		return data, "", fmt.Errorf("erro ao processar template: %w", err)
+	}
+
+	return data, body.String(), nil
+}
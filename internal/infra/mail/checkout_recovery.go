@@ -0,0 +1,75 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+const defaultCheckoutURL = "https://www.liguemedicina.com.br"
+
+// checkoutRecoveryCopy é o texto de cada etapa da cadência de recuperação (1, 2, 3...).
+// Etapas além da última reaproveitam o texto da última.
+var checkoutRecoveryCopy = []struct {
+	Subject  string
+	Headline string
+	Message  string
+}{
+	{
+		Subject:  "Sua assinatura Ligue Medicina está quase pronta",
+		Headline: "Faltou pouco para concluir sua assinatura!",
+		Message:  "Vimos que você começou sua assinatura, mas o pagamento não foi concluído. Seus dados continuam salvos: é só voltar e finalizar em poucos cliques para ter consultas por telemedicina sempre que precisar.",
+	},
+	{
+		Subject:  "Ainda dá tempo de concluir sua assinatura",
+		Headline: "Seu plano continua esperando por você",
+		Message:  "Com a Ligue Medicina você fala com um profissional de saúde sem sair de casa, sem fila e sem complicação. Conclua sua assinatura e comece a usar hoje mesmo.",
+	},
+	{
+		Subject:  "Última chamada: conclua sua assinatura Ligue Medicina",
+		Headline: "Esta é a última lembrança que enviamos",
+		Message:  "Não queremos que você fique sem cuidado quando precisar. Se ainda tiver interesse, finalize sua assinatura pelo botão abaixo. Se tiver alguma dúvida, nossa equipe está à disposição.",
+	},
+}
+
+func buildCheckoutRecoveryEmail(name, checkoutURL, unsubscribeURL string, stage int) (CheckoutRecoveryEmailData, string, error) {
+	if strings.TrimSpace(unsubscribeURL) == "" {
+		return CheckoutRecoveryEmailData{}, "", fmt.Errorf("e-mail de recuperação sem link de descadastro")
+	}
+	if stage < 1 {
+		stage = 1
+	}
+	if stage > len(checkoutRecoveryCopy) {
+		stage = len(checkoutRecoveryCopy)
+	}
+	content := checkoutRecoveryCopy[stage-1]
+
+	if strings.TrimSpace(checkoutURL) == "" {
+		checkoutURL = defaultCheckoutURL
+	}
+
+	data := CheckoutRecoveryEmailData{
+		Subject:     content.Subject,
+		Headline:    content.Headline,
+		Message:     content.Message,
+		FirstName:   firstName(name),
+		CheckoutURL:    checkoutURL,
+		WhatsAppURL:    "https://wa.me/5511915187330",
+		UnsubscribeURL: unsubscribeURL,
+	}
+
+	tmplPath := filepath.Join("templates", "checkout_recovery.html")
+	t, err := template.ParseFiles(tmplPath)
+	if err != nil {
+		return data, "", fmt.Errorf("erro ao ler template de email: %w", err)
+	}
+
+	var body bytes.Buffer
+	if err := t.Execute(&body, data); err != nil {
+		return data, "", fmt.Errorf("erro ao processar template: %w", err)
+	}
+
+	return data, body.String(), nil
+}
@@ -16,3 +16,22 @@ type EmailSender struct {
 	Password string
 	From     string
 }
+
+type CheckoutRecoveryEmailData struct {
+	Subject     string
+	Headline    string
+	Message     string
+	FirstName   string
+	CheckoutURL    string
+	WhatsAppURL    string
+	UnsubscribeURL string
+}
+
+type CancellationEmailData struct {
+	Subject     string
+	FirstName   string
+	PlanName    string
+	AccessUntil string // dd/mm/aaaa
+	Immediate   bool
+	WhatsAppURL string
+}
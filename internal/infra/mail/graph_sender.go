@@ -159,41 +159,7 @@ func (s *GraphEmailSender) sendWelcomeInternal(to, name, productName, pdfLink, c
 		message["attachments"] = graphAttachments
 	}
 
-	payloadBytes, err := json.Marshal(graphPayload)
-	if err != nil {
-		return fmt.Errorf("erro ao serializar payload: %w", err)
-	}
-
-	// Fazer request para Graph API
-	endpoint := fmt.Sprintf("https://graph.microsoft.com/v1.0/users/%s/sendMail", s.FromEmail)
-	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(payloadBytes))
-	if err != nil {
-		return fmt.Errorf("erro ao criar request: %w", err)
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("erro ao enviar request Graph: %w", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("erro ao ler response: %w", err)
-	}
-
-	// Graph retorna 202 Accepted para sucesso
-	if resp.StatusCode != 202 {
-		var errResp map[string]interface{}
-		json.Unmarshal(respBody, &errResp)
-		return fmt.Errorf("erro Graph: HTTP %d - %v", resp.StatusCode, errResp)
-	}
-
-	return nil
+	return s.postSendMail(accessToken, graphPayload)
 }
 
 // SendWelcomeEmail wrapper para manter compatibilidade
@@ -239,3 +205,116 @@ func (s *GraphEmailSender) SendWelcomeEmailWithCardAndDependents(name, email, cp
 
 	return s.sendWelcomeInternal(email, name, planName, "", cardNumber, dependents)
 }
+
+// SendCheckoutRecoveryEmail envia a etapa stage da cadência de recuperação de checkout abandonado.
+func (s *GraphEmailSender) SendCheckoutRecoveryEmail(name, email, checkoutURL, unsubscribeURL string, stage int) error {
+	accessToken, err := s.getAccessToken()
+	if err != nil {
+		return fmt.Errorf("falha ao obter token: %w", err)
+	}
+
+	data, body, err := buildCheckoutRecoveryEmail(name, checkoutURL, unsubscribeURL, stage)
+	if err != nil {
+		return err
+	}
+
+	graphPayload := map[string]interface{}{
+		"message": map[string]interface{}{
+			"subject": data.Subject,
+			"body": map[string]string{
+				"contentType": "HTML",
+				"content":     body,
+			},
+			"toRecipients": []map[string]interface{}{
+				{
+					"emailAddress": map[string]string{
+						"address": email,
+					},
+				},
+			},
+			"from": map[string]interface{}{
+				"emailAddress": map[string]string{
+					"address": s.FromEmail,
+				},
+			},
+		},
+	}
+
+	return s.postSendMail(accessToken, graphPayload)
+}
+
+// SendCancellationEmail confirma o cancelamento e informa até quando o acesso continua.
+func (s *GraphEmailSender) SendCancellationEmail(name, email, planName string, accessUntil time.Time) error {
+	accessToken, err := s.getAccessToken()
+	if err != nil {
+		return fmt.Errorf("falha ao obter token: %w", err)
+	}
+
+	data, body, err := buildCancellationEmail(name, planName, accessUntil)
+	if err != nil {
+		return err
+	}
+
+	graphPayload := map[string]interface{}{
+		"message": map[string]interface{}{
+			"subject": data.Subject,
+			"body": map[string]string{
+				"contentType": "HTML",
+				"content":     body,
+			},
+			"toRecipients": []map[string]interface{}{
+				{
+					"emailAddress": map[string]string{
+						"address": email,
+					},
+				},
+			},
+			"from": map[string]interface{}{
+				"emailAddress": map[string]string{
+					"address": s.FromEmail,
+				},
+			},
+		},
+	}
+
+	return s.postSendMail(accessToken, graphPayload)
+}
+
+// postSendMail envia a mensagem montada pelo endpoint sendMail do Graph
+func (s *GraphEmailSender) postSendMail(accessToken string, graphPayload map[string]interface{}) error {
+	payloadBytes, err := json.Marshal(graphPayload)
+	if err != nil {
+		return fmt.Errorf("erro ao serializar payload: %w", err)
+	}
+
+	// Fazer request para Graph API
+	endpoint := fmt.Sprintf("https://graph.microsoft.com/v1.0/users/%s/sendMail", s.FromEmail)
+	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(payloadBytes))
+	if err != nil {
+		return fmt.Errorf("erro ao criar request: %w", err)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("erro ao enviar request Graph: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("erro ao ler response: %w", err)
+	}
+
+	// Graph retorna 202 Accepted para sucesso
+	if resp.StatusCode != 202 {
+		var errResp map[string]interface{}
+		json.Unmarshal(respBody, &errResp)
+		return fmt.Errorf("erro Graph: HTTP %d - %v", resp.StatusCode, errResp)
+	}
+
+	return nil
+}
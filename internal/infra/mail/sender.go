@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/xavierca1/ligue-payments/internal/entity"
 	"gopkg.in/gomail.v2"
@@ -188,3 +189,60 @@ func (s *EmailSender) SendWelcomeEmailWithCardAndDependents(name, email, cpf, pl
 
 	return s.sendWelcomeInternal(email, name, planName, "", cardNumber, dependents, nil)
 }
+
+// SendCheckoutRecoveryEmail envia a etapa stage da cadência de recuperação de checkout abandonado.
+func (s *EmailSender) SendCheckoutRecoveryEmail(name, email, checkoutURL, unsubscribeURL string, stage int) error {
+	data, body, err := buildCheckoutRecoveryEmail(name, checkoutURL, unsubscribeURL, stage)
+	if err != nil {
+		return err
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.From)
+	m.SetHeader("To", email)
+	m.SetHeader("Subject", data.Subject)
+	// Descadastro com um clique no cliente de e-mail (RFC 8058).
+	m.SetHeader("List-Unsubscribe", "<"+data.UnsubscribeURL+">")
+	m.SetHeader("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	m.SetBody("text/html", body)
+	m.AddAlternative("text/plain", fmt.Sprintf("Olá, %s!\n\n%s\n\nConclua sua assinatura: %s\n\nTirar dúvidas com nossa equipe pelo WhatsApp: %s\n\nUm abraço,\nEquipe Ligue Medicina & Grupo Cuidarte\n\nNão quer mais receber estes lembretes? %s", data.FirstName, data.Message, data.CheckoutURL, data.WhatsAppURL, data.UnsubscribeURL))
+
+	d := gomail.NewDialer(s.Host, s.Port, s.User, s.Password)
+	d.TLSConfig = &tls.Config{
+		ServerName: strings.TrimSpace(s.Host),
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if err := d.DialAndSend(m); err != nil {
+		return fmt.Errorf("erro ao enviar email SMTP: %w", err)
+	}
+
+	return nil
+}
+
+// SendCancellationEmail confirma o cancelamento e informa até quando o acesso continua.
+func (s *EmailSender) SendCancellationEmail(name, email, planName string, accessUntil time.Time) error {
+	data, body, err := buildCancellationEmail(name, planName, accessUntil)
+	if err != nil {
+		return err
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.From)
+	m.SetHeader("To", email)
+	m.SetHeader("Subject", data.Subject)
+	m.SetBody("text/html", body)
+	m.AddAlternative("text/plain", fmt.Sprintf("Olá, %s!\n\nConfirmamos o cancelamento da sua assinatura do plano %s. Nenhuma nova cobrança será feita. Acesso às consultas até %s.\n\nSe não foi você que pediu o cancelamento, fale com a gente pelo WhatsApp: %s\n\nUm abraço,\nEquipe Ligue Medicina & Grupo Cuidarte", data.FirstName, data.PlanName, data.AccessUntil, data.WhatsAppURL))
+
+	d := gomail.NewDialer(s.Host, s.Port, s.User, s.Password)
+	d.TLSConfig = &tls.Config{
+		ServerName: strings.TrimSpace(s.Host),
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if err := d.DialAndSend(m); err != nil {
+		return fmt.Errorf("erro ao enviar email SMTP: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ErrNotConnected é retornado enquanto o ConnectionManager não tem conexão ativa com o broker.
+var ErrNotConnected = errors.New("rabbitmq: sem conexão ativa")
+
+// ChannelSource entrega canais novos sobre a conexão corrente. Produtores e consumidores
+// pedem um canal novo sempre que o anterior fecha, em vez de guardar a conexão.
+type ChannelSource interface {
+	Channel() (*amqp.Channel, error)
+}
+
+// ConnectionManager mantém a conexão com o RabbitMQ: observa NotifyClose, refaz o dial
+// com backoff exponencial e redeclara a topologia a cada reconexão.
+type ConnectionManager struct {
+	dsn  string
+	host string
+
+	mu   sync.RWMutex
+	conn *amqp.Connection
+
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+func NewConnectionManager(user, pass, host, port string) *ConnectionManager {
+	return &ConnectionManager{
+		dsn:        fmt.Sprintf("amqp://%s:%s@%s:%s/", user, pass, host, port),
+		host:       fmt.Sprintf("%s:%s", host, port),
+		MinBackoff: 1 * time.Second,
+		MaxBackoff: 30 * time.Second,
+	}
+}
+
+// Start conecta e reconecta até o ctx ser cancelado; deve rodar numa goroutine própria.
+func (m *ConnectionManager) Start(ctx context.Context) {
+	backoff := m.MinBackoff
+
+	for {
+		conn, err := m.connect()
+		if err != nil {
+			log.Printf("⚠️ RabbitMQ indisponível (%s), nova tentativa em %s: %v", m.host, backoff, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = nextBackoff(backoff, m.MaxBackoff)
+			continue
+		}
+
+		backoff = m.MinBackoff
+		closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+		m.setConn(conn)
+		log.Printf("✅ Conectado ao RabbitMQ em %s", m.host)
+
+		select {
+		case <-ctx.Done():
+			m.setConn(nil)
+			conn.Close()
+			log.Println("⚠️ Conexão com RabbitMQ encerrada")
+			return
+		case amqpErr := <-closed:
+			m.setConn(nil)
+			log.Printf("⚠️ Conexão com RabbitMQ perdida: %v. Reconectando...", amqpErr)
+		}
+	}
+}
+
+func (m *ConnectionManager) connect() (*amqp.Connection, error) {
+	conn, err := amqp.Dial(m.dsn)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao conectar no RabbitMQ: %w", err)
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("falha ao abrir canal: %w", err)
+	}
+	defer ch.Close()
+
+	if err := setupTopology(ch); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("falha ao declarar topologia: %w", err)
+	}
+
+	return conn, nil
+}
+
+func (m *ConnectionManager) setConn(conn *amqp.Connection) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.conn = conn
+}
+
+// Channel abre um canal novo na conexão corrente.
+func (m *ConnectionManager) Channel() (*amqp.Channel, error) {
+	m.mu.RLock()
+	conn := m.conn
+	m.mu.RUnlock()
+
+	if conn == nil || conn.IsClosed() {
+		return nil, ErrNotConnected
+	}
+	return conn.Channel()
+}
+
+// IsClosed indica se não há conexão ativa (usado pelo health check).
+func (m *ConnectionManager) IsClosed() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.conn == nil || m.conn.IsClosed()
+}
+
+func nextBackoff(current, max time.Duration) time.Duration {
+	next := current * 2
+	if next > max {
+		return max
+	}
+	return next
+}
+
+// cachedChannel reaproveita um canal enquanto ele estiver aberto e pede outro ao
+// ChannelSource quando ele fecha. Não é seguro para uso concorrente: o dono serializa o acesso.
+type cachedChannel struct {
+	source  ChannelSource
+	confirm bool
+	ch      *amqp.Channel
+	returns chan amqp.Return
+}
+
+func (c *cachedChannel) get() (*amqp.Channel, error) {
+	if c.ch != nil && !c.ch.IsClosed() {
+		return c.ch, nil
+	}
+	c.ch = nil
+
+	if c.source == nil {
+		return nil, ErrNotConnected
+	}
+	ch, err := c.source.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if c.confirm {
+		if err := ch.Confirm(false); err != nil {
+			ch.Close()
+			return nil, fmt.Errorf("falha ao habilitar publisher confirms: %w", err)
+		}
+		// O buffer precisa ser drenado (takeReturn) para não travar o canal.
+		c.returns = ch.NotifyReturn(make(chan amqp.Return, 16))
+	}
+
+	c.ch = ch
+	return ch, nil
+}
+
+// takeReturn drena os basic.return recebidos e informa se algum é da mensagem messageID.
+func (c *cachedChannel) takeReturn(messageID string) (amqp.Return, bool) {
+	var found amqp.Return
+	ok := false
+	for {
+		select {
+		case returned, open := <-c.returns:
+			if !open {
+				return found, ok
+			}
+			if returned.MessageId == messageID {
+				found, ok = returned, true
+			}
+		default:
+			return found, ok
+		}
+	}
+}
+
+// reset descarta o canal após um erro, para que a próxima chamada abra outro.
+func (c *cachedChannel) reset() {
+	if c.ch != nil {
+		c.ch.Close()
+		c.ch = nil
+	}
+	c.returns = nil
+}
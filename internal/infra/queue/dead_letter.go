@@ -0,0 +1,136 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// DeadLetter é a visão de inspeção de uma mensagem em q.activations.dlq ou q.activations.parking.
+type DeadLetter struct {
+	DeliveryTag uint64
+	MessageID   string
+	Type        string
+	CustomerID  string
+	Provider    string
+	Name        string
+	Attempts    int
+	Reason      string
+	FailedAt    time.Time
+	Payload     []byte
+}
+
+// InspectDeadLetter extrai cliente, provedor e motivo da falha de uma mensagem morta.
+// O motivo vem do header x-last-error (gravado pelo retry) ou, na falta dele, do x-death do broker.
+func InspectDeadLetter(d amqp.Delivery) DeadLetter {
+	var payload struct {
+		CustomerID string `json:"customer_id"`
+		Provider   string `json:"provider"`
+		Name       string `json:"name"`
+	}
+	parseErr := json.Unmarshal(d.Body, &payload)
+
+	dl := DeadLetter{
+		DeliveryTag: d.DeliveryTag,
+		MessageID:   d.MessageId,
+		Type:        d.Type,
+		CustomerID:  payload.CustomerID,
+		Provider:    payload.Provider,
+		Name:        payload.Name,
+		Attempts:    AttemptsFromHeaders(d.Headers),
+		FailedAt:    d.Timestamp,
+		Payload:     d.Body,
+	}
+	if dl.Type == "" {
+		dl.Type = MessageTypeActivation
+	}
+
+	if reason, ok := d.Headers[HeaderLastError].(string); ok {
+		dl.Reason = reason
+	}
+	lastErrorAtKnown := false
+	if raw, ok := d.Headers[HeaderLastErrorAt].(string); ok {
+		if parsed, err := time.Parse(time.RFC3339, raw); err == nil {
+			dl.FailedAt = parsed
+			lastErrorAtKnown = true
+		}
+	}
+
+	if deaths, ok := d.Headers["x-death"].([]interface{}); ok && len(deaths) > 0 {
+		if death, ok := deaths[0].(amqp.Table); ok {
+			if dl.Reason == "" {
+				reason, _ := death["reason"].(string)
+				queueName, _ := death["queue"].(string)
+				dl.Reason = fmt.Sprintf("%s em %s", reason, queueName)
+			}
+			if deathTime, ok := death["time"].(time.Time); ok && !lastErrorAtKnown {
+				dl.FailedAt = deathTime
+			}
+		}
+	}
+	if dl.Reason == "" && parseErr != nil {
+		dl.Reason = fmt.Sprintf("payload inválido: %v", parseErr)
+	}
+
+	return dl
+}
+
+// DeadLetterFilter seleciona mensagens por provedor, período e cliente. Campos vazios não filtram.
+type DeadLetterFilter struct {
+	Provider    string
+	Since       time.Time
+	Until       time.Time
+	CustomerIDs []string
+}
+
+func (f DeadLetterFilter) Match(dl DeadLetter) bool {
+	if f.Provider != "" && !strings.EqualFold(f.Provider, dl.Provider) {
+		return false
+	}
+	if !f.Since.IsZero() && dl.FailedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && dl.FailedAt.After(f.Until) {
+		return false
+	}
+	if len(f.CustomerIDs) > 0 {
+		for _, id := range f.CustomerIDs {
+			if id == dl.CustomerID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// ReplayPublishing monta a republicação em ex.checkout. O contador de tentativas é zerado
+// para que a mensagem tenha o ciclo de retry completo; o histórico de erros é mantido.
+func ReplayPublishing(d amqp.Delivery) amqp.Publishing {
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		if k == "x-death" || k == HeaderRetryCount {
+			continue
+		}
+		headers[k] = v
+	}
+	headers["x-replayed-at"] = time.Now().UTC().Format(time.RFC3339)
+
+	contentType := d.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	return amqp.Publishing{
+		Headers:      headers,
+		ContentType:  contentType,
+		Type:         d.Type,
+		MessageId:    d.MessageId,
+		Timestamp:    time.Now().UTC(),
+		Body:         d.Body,
+		DeliveryMode: amqp.Persistent,
+	}
+}
@@ -3,9 +3,12 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/xavierca1/ligue-payments/internal/infra/http/middleware"
 )
@@ -16,6 +19,9 @@ type DependentPayload struct {
 	BirthDate string `json:"birth_date"`
 	Gender    int    `json:"gender"` // 1=Masculino, 2=Feminino, 3=Outro
 	Kinship   string `json:"kinship"`
+	// ActivatedAt é a data de alta (YYYY-MM-DD) do dependente incluído depois do titular;
+	// vazio quando entrou junto com ele. Usado na baixa e na atualização.
+	ActivatedAt string `json:"activated_at,omitempty"`
 }
 
 type ActivationPayload struct {
@@ -26,6 +32,9 @@ type ActivationPayload struct {
 
 	Provider string `json:"provider"`
 	Origin   string `json:"origin"`
+	// ActivatedAt é a data de alta original (YYYY-MM-DD), preenchida só na atualização
+	// para o provedor não trocar a fecha_alta pela data da correção.
+	ActivatedAt string `json:"activated_at,omitempty"`
 
 	Name      string `json:"name"`
 	Email     string `json:"email"`
@@ -37,46 +46,161 @@ type ActivationPayload struct {
 	Dependents []DependentPayload `json:"dependents,omitempty"`
 }
 
+// DeactivationPayload pede ao provedor de telemedicina a revogação do acesso
+// do titular e dos dependentes quando a assinatura deixa de dar direito ao serviço.
+type DeactivationPayload struct {
+	CustomerID string `json:"customer_id"`
+	PlanID     string `json:"plan_id"`
+
+	ProviderPlanCode string `json:"provider_plan_code"`
+
+	Provider string `json:"provider"`
+	Origin   string `json:"origin"`
+	Reason   string `json:"reason"` // status da assinatura que motivou a desativação
+	// ActivatedAt é a data de alta original (YYYY-MM-DD); a baixa reenvia a mesma fecha_alta.
+	ActivatedAt string `json:"activated_at,omitempty"`
+
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	CPF       string `json:"cpf"`
+	Phone     string `json:"phone"`
+	BirthDate string `json:"birth_date"`
+	Gender    string `json:"gender"`
+
+	Dependents []DependentPayload `json:"dependents,omitempty"`
+}
+
+// Tipos de mensagem publicados em ex.checkout, enviados no campo Type da mensagem AMQP.
+// Mensagens sem Type são tratadas como ativação para manter compatibilidade.
+const (
+	MessageTypeActivation      = "activation"
+	MessageTypeDeactivation    = "deactivation"
+	MessageTypeUpdateData      = "update_data"      // ActivationPayload com os dados atualizados do titular e dependentes ou o novo plano
+	MessageTypeAddDependent    = "add_dependent"    // ActivationPayload com apenas os dependentes novos
+	MessageTypeRemoveDependent = "remove_dependent" // DeactivationPayload com apenas os dependentes removidos; o titular continua ativo
+)
+
 type QueueProducerInterface interface {
 	PublishActivation(ctx context.Context, payload ActivationPayload) error
 }
+
+// Erros de publicação que o chamador pode tratar (ex.: manter o evento no outbox ou
+// devolver erro ao webhook para retry).
+var (
+	ErrPublishNacked   = errors.New("rabbitmq: broker recusou a mensagem (nack)")
+	ErrPublishReturned = errors.New("rabbitmq: mensagem sem rota (basic.return)")
+)
+
+// publishConfirmTimeout limita a espera pelo ack quando o contexto não tem deadline.
+const publishConfirmTimeout = 10 * time.Second
+
+// RabbitMQProducer publica em ex.checkout num canal em modo confirm, com mandatory=true:
+// o publish só é bem-sucedido quando o broker confirma e a mensagem foi roteada para uma fila.
+// Se a conexão cair, o próximo publish abre um canal novo assim que o ConnectionManager reconectar.
 type RabbitMQProducer struct {
-	Conn *amqp.Connection
-	Ch   *amqp.Channel
+	mu      sync.Mutex
+	channel cachedChannel
 }
 
-func NewProducer(conn *amqp.Connection, ch *amqp.Channel) *RabbitMQProducer {
+func NewProducer(channels ChannelSource) *RabbitMQProducer {
 	return &RabbitMQProducer{
-		Conn: conn,
-		Ch:   ch,
+		channel: cachedChannel{source: channels, confirm: true},
 	}
 }
 
 func (p *RabbitMQProducer) PublishActivation(ctx context.Context, payload ActivationPayload) error {
+	return p.publish(ctx, MessageTypeActivation, payload)
+}
+
+func (p *RabbitMQProducer) PublishDeactivation(ctx context.Context, payload DeactivationPayload) error {
+	return p.publish(ctx, MessageTypeDeactivation, payload)
+}
+
+// PublishUpdate pede ao provedor a atualização do beneficiário já ativo (dados ou plano).
+func (p *RabbitMQProducer) PublishUpdate(ctx context.Context, payload ActivationPayload) error {
+	return p.publish(ctx, MessageTypeUpdateData, payload)
+}
+
+// PublishAddDependent pede a inclusão dos dependentes do payload no beneficiário já ativo.
+func (p *RabbitMQProducer) PublishAddDependent(ctx context.Context, payload ActivationPayload) error {
+	return p.publish(ctx, MessageTypeAddDependent, payload)
+}
+
+// PublishRemoveDependent pede a baixa só dos dependentes do payload, mantendo o titular.
+func (p *RabbitMQProducer) PublishRemoveDependent(ctx context.Context, payload DeactivationPayload) error {
+	return p.publish(ctx, MessageTypeRemoveDependent, payload)
+}
+
+func (p *RabbitMQProducer) publish(ctx context.Context, messageType string, payload interface{}) error {
 
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("erro ao converter payload: %v", err)
 	}
 
-	err = p.Ch.PublishWithContext(ctx,
+	return p.PublishRaw(ctx, messageType, body)
+}
+
+// PublishRaw publica um corpo JSON já serializado e espera a confirmação do broker
+// dentro do deadline do ctx (ou publishConfirmTimeout, se não houver).
+func (p *RabbitMQProducer) PublishRaw(ctx context.Context, messageType string, body []byte) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, publishConfirmTimeout)
+		defer cancel()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ch, err := p.channel.get()
+	if err != nil {
+		return fmt.Errorf("falha ao publicar no RabbitMQ: %w", err)
+	}
+
+	messageID := uuid.New().String()
+	confirmation, err := ch.PublishWithDeferredConfirmWithContext(ctx,
 		ExchangeName, // ex.checkout
 		RoutingKey,   // k.activation
-		false,        // Mandatory
+		true,         // Mandatory: sem fila vinculada, o broker devolve a mensagem
 		false,        // Immediate
 		amqp.Publishing{
 			ContentType:  "application/json",
+			Type:         messageType,
+			MessageId:    messageID,
 			Body:         body,
 			Timestamp:    time.Now().UTC(),
 			DeliveryMode: amqp.Persistent, // Mensagem salva no disco (segurança!)
 		},
 	)
+	if err != nil {
+		p.channel.reset()
+		return fmt.Errorf("falha ao publicar no RabbitMQ: %w", err)
+	}
 
+	acked, err := confirmation.WaitContext(ctx)
 	if err != nil {
-		return fmt.Errorf("falha ao publicar no RabbitMQ: %v", err)
+		// Confirmações pendentes ficariam órfãs num canal reaproveitado; abre outro na próxima.
+		p.channel.reset()
+		return fmt.Errorf("sem confirmação do RabbitMQ: %w", err)
+	}
+	if !acked {
+		return fmt.Errorf("falha ao publicar %s: %w", messageType, ErrPublishNacked)
+	}
+
+	// O broker envia o basic.return antes do ack, então um retorno desta mensagem já está no buffer.
+	if returned, ok := p.channel.takeReturn(messageID); ok {
+		return fmt.Errorf("falha ao publicar %s (%d %s): %w", messageType, returned.ReplyCode, returned.ReplyText, ErrPublishReturned)
 	}
 
 	middleware.RecordQueuePublished(QueueName, RoutingKey)
 
 	return nil
 }
+
+// Close fecha o canal de publicação corrente.
+func (p *RabbitMQProducer) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.channel.reset()
+}
@@ -0,0 +1,168 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Capability é uma operação que um provedor de telemedicina sabe executar.
+type Capability string
+
+const (
+	CapabilityActivate        Capability = "activate"
+	CapabilityDeactivate      Capability = "deactivate"
+	CapabilityUpdateData      Capability = "update_data"
+	CapabilityAddDependent    Capability = "add_dependent"
+	CapabilityRemoveDependent Capability = "remove_dependent"
+)
+
+// Cada capacidade corresponde a uma interface; um adapter declara as que suporta
+// implementando-as.
+type BeneficiaryActivator interface {
+	CreateBeneficiary(ctx context.Context, input ActivationPayload) error
+	GetBeneficiaryID(ctx context.Context, cpf string) (string, error)
+}
+
+type BeneficiaryDeactivator interface {
+	DeactivateBeneficiary(ctx context.Context, input DeactivationPayload) error
+}
+
+type BeneficiaryUpdater interface {
+	UpdateBeneficiary(ctx context.Context, input ActivationPayload) error
+}
+
+type DependentAdder interface {
+	AddDependents(ctx context.Context, input ActivationPayload) error
+}
+
+type DependentRemover interface {
+	RemoveDependents(ctx context.Context, input DeactivationPayload) error
+}
+
+var (
+	ErrUnknownProvider        = errors.New("provedor não registrado")
+	ErrCapabilityNotSupported = errors.New("operação não suportada pelo provedor")
+)
+
+// ProviderRegistry associa o valor de entity.Plan.Provider ao adapter do parceiro.
+type ProviderRegistry struct {
+	mu       sync.RWMutex
+	adapters map[string]interface{}
+}
+
+func NewProviderRegistry() *ProviderRegistry {
+	return &ProviderRegistry{adapters: make(map[string]interface{})}
+}
+
+// Register adiciona o adapter do provedor. O adapter precisa implementar ao menos uma capacidade.
+func (r *ProviderRegistry) Register(provider string, adapter interface{}) error {
+	key := normalizeProvider(provider)
+	if key == "" {
+		return fmt.Errorf("nome do provedor vazio")
+	}
+	if len(capabilitiesOf(adapter)) == 0 {
+		return fmt.Errorf("provedor %s não implementa nenhuma capacidade", key)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.adapters[key] = adapter
+	return nil
+}
+
+func (r *ProviderRegistry) Activator(provider string) (BeneficiaryActivator, error) {
+	adapter, err := r.lookup(provider)
+	if err != nil {
+		return nil, err
+	}
+	if a, ok := adapter.(BeneficiaryActivator); ok {
+		return a, nil
+	}
+	return nil, unsupported(provider, CapabilityActivate)
+}
+
+func (r *ProviderRegistry) Deactivator(provider string) (BeneficiaryDeactivator, error) {
+	adapter, err := r.lookup(provider)
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := adapter.(BeneficiaryDeactivator); ok {
+		return d, nil
+	}
+	return nil, unsupported(provider, CapabilityDeactivate)
+}
+
+func (r *ProviderRegistry) Updater(provider string) (BeneficiaryUpdater, error) {
+	adapter, err := r.lookup(provider)
+	if err != nil {
+		return nil, err
+	}
+	if u, ok := adapter.(BeneficiaryUpdater); ok {
+		return u, nil
+	}
+	return nil, unsupported(provider, CapabilityUpdateData)
+}
+
+func (r *ProviderRegistry) DependentAdder(provider string) (DependentAdder, error) {
+	adapter, err := r.lookup(provider)
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := adapter.(DependentAdder); ok {
+		return d, nil
+	}
+	return nil, unsupported(provider, CapabilityAddDependent)
+}
+
+func (r *ProviderRegistry) DependentRemover(provider string) (DependentRemover, error) {
+	adapter, err := r.lookup(provider)
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := adapter.(DependentRemover); ok {
+		return d, nil
+	}
+	return nil, unsupported(provider, CapabilityRemoveDependent)
+}
+
+func (r *ProviderRegistry) lookup(provider string) (interface{}, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	adapter, ok := r.adapters[normalizeProvider(provider)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, provider)
+	}
+	return adapter, nil
+}
+
+func capabilitiesOf(adapter interface{}) []Capability {
+	var caps []Capability
+	if _, ok := adapter.(BeneficiaryActivator); ok {
+		caps = append(caps, CapabilityActivate)
+	}
+	if _, ok := adapter.(BeneficiaryDeactivator); ok {
+		caps = append(caps, CapabilityDeactivate)
+	}
+	if _, ok := adapter.(BeneficiaryUpdater); ok {
+		caps = append(caps, CapabilityUpdateData)
+	}
+	if _, ok := adapter.(DependentAdder); ok {
+		caps = append(caps, CapabilityAddDependent)
+	}
+	if _, ok := adapter.(DependentRemover); ok {
+		caps = append(caps, CapabilityRemoveDependent)
+	}
+	return caps
+}
+
+func unsupported(provider string, capability Capability) error {
+	return fmt.Errorf("%w: %s não suporta %s", ErrCapabilityNotSupported, normalizeProvider(provider), capability)
+}
+
+func normalizeProvider(provider string) string {
+	return strings.ToUpper(strings.TrimSpace(provider))
+}
@@ -6,13 +6,13 @@ import (
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-
 const (
-	ExchangeName = "ex.checkout"
-	QueueName    = "q.activations"
-	DLQName      = "q.activations.dlq"
-	DLXName      = "ex.dlx" // Dead Letter Exchange
-	RoutingKey   = "k.activation"
+	ExchangeName     = "ex.checkout"
+	QueueName        = "q.activations"
+	DLQName          = "q.activations.dlq"
+	ParkingQueueName = "q.activations.parking" // mensagens que esgotaram as tentativas
+	DLXName          = "ex.dlx"                // Dead Letter Exchange
+	RoutingKey       = "k.activation"
 )
 
 type RabbitMQ struct {
@@ -20,7 +20,6 @@ type RabbitMQ struct {
 	Ch   *amqp.Channel
 }
 
-
 func NewRabbitMQ(user, pass, host, port string) (*RabbitMQ, error) {
 	dsn := fmt.Sprintf("amqp://%s:%s@%s:%s/", user, pass, host, port)
 
@@ -34,7 +33,6 @@ func NewRabbitMQ(user, pass, host, port string) (*RabbitMQ, error) {
 		return nil, fmt.Errorf("falha ao abrir canal: %w", err)
 	}
 
-
 	err = setupTopology(ch)
 	if err != nil {
 		return nil, err
@@ -43,57 +41,46 @@ func NewRabbitMQ(user, pass, host, port string) (*RabbitMQ, error) {
 	return &RabbitMQ{Conn: conn, Ch: ch}, nil
 }
 
-
 func setupTopology(ch *amqp.Channel) error {
 
-
 	err := ch.ExchangeDeclare(DLXName, "direct", true, false, false, false, nil)
 	if err != nil {
 		return err
 	}
 
-
 	_, err = ch.QueueDeclare(DLQName, true, false, false, false, nil)
 	if err != nil {
 		return err
 	}
 
-
 	err = ch.QueueBind(DLQName, RoutingKey, DLXName, false, nil)
 	if err != nil {
 		return err
 	}
 
-
-
-
 	args := amqp.Table{
 		"x-dead-letter-exchange":    DLXName,    // Se der Nack, manda pra DLX
 		"x-dead-letter-routing-key": RoutingKey, // Com essa chave
 	}
 
-
 	err = ch.ExchangeDeclare(ExchangeName, "direct", true, false, false, false, nil)
 	if err != nil {
 		return err
 	}
 
-
 	_, err = ch.QueueDeclare(QueueName, true, false, false, false, args)
 	if err != nil {
 		return err
 	}
 
-
 	err = ch.QueueBind(QueueName, RoutingKey, ExchangeName, false, nil)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return declareRetryTopology(ch)
 }
 
-
 func (r *RabbitMQ) Publish(body []byte) error {
 	return r.Ch.Publish(
 		ExchangeName, // Exchange
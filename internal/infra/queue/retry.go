@@ -0,0 +1,135 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Headers gravados a cada tentativa que falha, para o ops ver por que a mensagem está parada.
+const (
+	HeaderRetryCount   = "x-retry-count"
+	HeaderLastError    = "x-last-error"
+	HeaderLastErrorAt  = "x-last-error-at"
+	HeaderErrorHistory = "x-error-history"
+)
+
+// RetryDelays são os atrasos das filas de retry; a tentativa N usa RetryDelays[N-1]
+// (as tentativas além da última faixa reaproveitam o maior atraso).
+// Alterar estes valores exige apagar as filas q.activations.retry.* no broker,
+// já que x-message-ttl não pode ser redeclarado.
+var RetryDelays = []time.Duration{
+	10 * time.Second,
+	1 * time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	1 * time.Hour,
+}
+
+// RetryPolicy decide entre reagendar uma mensagem com falha ou estacioná-la em ParkingQueueName.
+type RetryPolicy struct {
+	MaxAttempts int
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 5}
+}
+
+// RetryQueueName é a fila de espera usada na tentativa informada (1-based).
+func RetryQueueName(attempt int) string {
+	return fmt.Sprintf("%s.retry.%d", QueueName, retryTier(attempt)+1)
+}
+
+func retryTier(attempt int) int {
+	tier := attempt - 1
+	if tier < 0 {
+		tier = 0
+	}
+	if tier >= len(RetryDelays) {
+		tier = len(RetryDelays) - 1
+	}
+	return tier
+}
+
+// isPermanentFailure indica erros que não se resolvem com retry (provedor não registrado
+// ou sem a capacidade); essas mensagens vão direto para a DLQ.
+func isPermanentFailure(err error) bool {
+	return errors.Is(err, ErrUnknownProvider) || errors.Is(err, ErrCapabilityNotSupported)
+}
+
+// AttemptsFromHeaders lê quantas tentativas já falharam.
+func AttemptsFromHeaders(headers amqp.Table) int {
+	switch v := headers[HeaderRetryCount].(type) {
+	case int:
+		return v
+	case int8:
+		return int(v)
+	case int16:
+		return int(v)
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+// Next monta a republicação da mensagem que falhou: routingKey é a fila de destino
+// (via exchange default) e parked indica que as tentativas se esgotaram.
+func (p RetryPolicy) Next(d amqp.Delivery, reason string) (routingKey string, msg amqp.Publishing, parked bool) {
+	attempt := AttemptsFromHeaders(d.Headers) + 1
+	now := time.Now().UTC()
+
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+
+	var history []interface{}
+	if previous, ok := d.Headers[HeaderErrorHistory].([]interface{}); ok {
+		history = append(history, previous...)
+	}
+	history = append(history, fmt.Sprintf("%s tentativa %d: %s", now.Format(time.RFC3339), attempt, reason))
+
+	headers[HeaderRetryCount] = int32(attempt)
+	headers[HeaderLastError] = reason
+	headers[HeaderLastErrorAt] = now.Format(time.RFC3339)
+	headers[HeaderErrorHistory] = history
+
+	msg = amqp.Publishing{
+		Headers:      headers,
+		ContentType:  d.ContentType,
+		Type:         d.Type,
+		MessageId:    d.MessageId,
+		Timestamp:    d.Timestamp,
+		Body:         d.Body,
+		DeliveryMode: amqp.Persistent,
+	}
+
+	if attempt >= p.MaxAttempts {
+		return ParkingQueueName, msg, true
+	}
+	return RetryQueueName(attempt), msg, false
+}
+
+// declareRetryTopology cria as filas de espera (TTL -> volta para ex.checkout) e a fila de estacionamento.
+func declareRetryTopology(ch *amqp.Channel) error {
+	for i, delay := range RetryDelays {
+		args := amqp.Table{
+			"x-message-ttl":             int32(delay / time.Millisecond),
+			"x-dead-letter-exchange":    ExchangeName,
+			"x-dead-letter-routing-key": RoutingKey,
+		}
+		if _, err := ch.QueueDeclare(RetryQueueName(i+1), true, false, false, false, args); err != nil {
+			return fmt.Errorf("falha ao declarar fila de retry %d: %w", i+1, err)
+		}
+	}
+
+	if _, err := ch.QueueDeclare(ParkingQueueName, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("falha ao declarar fila de estacionamento: %w", err)
+	}
+	return nil
+}
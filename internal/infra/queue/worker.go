@@ -3,97 +3,369 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/xavierca1/ligue-payments/internal/entity"
 	"github.com/xavierca1/ligue-payments/internal/infra/http/middleware"
 )
 
-type TelemedicinaClient interface {
-	CreateBeneficiary(ctx context.Context, input ActivationPayload) error
-	GetBeneficiaryID(cpf string) string
-}
-
 type Worker struct {
-	Channel      *amqp.Channel
-	DocClient    TelemedicinaClient
+	Channels     ChannelSource
+	Providers    *ProviderRegistry
 	CustomerRepo entity.CustomerRepositoryInterface
+	Retry        RetryPolicy
+
+	// Concurrency é o total de mensagens processadas em paralelo e Prefetch o basic.qos
+	// do canal. Prefetch deve ser maior que Concurrency para que mensagens de um provedor
+	// lento não ocupem toda a janela e impeçam as dos outros de chegar.
+	Concurrency int
+	Prefetch    int
+	// ProviderLimits limita o paralelismo por provedor (chave = plans.provider em maiúsculas);
+	// provedores fora do mapa usam DefaultProviderLimit.
+	ProviderLimits       map[string]int
+	DefaultProviderLimit int
+
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	slotsMu       sync.Mutex
+	providerSlots map[string]chan struct{}
 }
 
-func NewWorker(ch *amqp.Channel, docClient TelemedicinaClient, customerRepo entity.CustomerRepositoryInterface) *Worker {
+func NewWorker(channels ChannelSource, providers *ProviderRegistry, customerRepo entity.CustomerRepositoryInterface) *Worker {
 	return &Worker{
-		Channel:      ch,
-		DocClient:    docClient,
-		CustomerRepo: customerRepo,
+		Channels:             channels,
+		Providers:            providers,
+		CustomerRepo:         customerRepo,
+		Retry:                DefaultRetryPolicy(),
+		Concurrency:          4,
+		Prefetch:             16,
+		DefaultProviderLimit: 2,
+		MinBackoff:           1 * time.Second,
+		MaxBackoff:           30 * time.Second,
 	}
 }
 
-func (w *Worker) Start(queueName string) {
-	w.recordQueueDepth(queueName)
+// Start consome a fila até o ctx ser cancelado. Quando o canal ou a conexão caem, as mensagens
+// sem ack voltam para a fila e o worker pede um canal novo ao ChannelSource, com backoff.
+// No cancelamento o consumidor para de receber, as mensagens em processamento terminam e
+// as que ainda aguardavam vaga voltam para a fila; Start só retorna depois disso.
+func (w *Worker) Start(ctx context.Context, queueName string) {
+	backoff := w.MinBackoff
+
+	for {
+		ch, consumerTag, msgs, err := w.consume(queueName)
+		if err != nil {
+			log.Printf("⚠️ [WORKER] Falha ao registrar consumidor em '%s', nova tentativa em %s: %v", queueName, backoff, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = nextBackoff(backoff, w.MaxBackoff)
+			continue
+		}
+		backoff = w.MinBackoff
+
+		log.Printf(" [*] Worker rodando e aguardando na fila '%s' (concorrência=%d, prefetch=%d)", queueName, w.Concurrency, w.Prefetch)
+		w.recordQueueDepth(ch, queueName)
+
+		if stopped := w.dispatch(ctx, ch, consumerTag, queueName, msgs); stopped {
+			return
+		}
+
+		log.Printf("⚠️ [WORKER] Consumidor de '%s' encerrado pelo broker, reconectando...", queueName)
+	}
+}
+
+// dispatch distribui as mensagens de um canal entre goroutines limitadas por Concurrency
+// e pelo limite do provedor. Retorna true quando parou por cancelamento do ctx.
+func (w *Worker) dispatch(ctx context.Context, ch *amqp.Channel, consumerTag, queueName string, msgs <-chan amqp.Delivery) bool {
+	workers := make(chan struct{}, w.concurrency())
+	var inFlight sync.WaitGroup
+
+	defer func() {
+		inFlight.Wait()
+		ch.Close()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("⏳ [WORKER] Encerrando consumo de '%s', aguardando mensagens em processamento...", queueName)
+			ch.Cancel(consumerTag, false)
+			return true
+		case d, ok := <-msgs:
+			if !ok {
+				return false
+			}
+
+			provider := deliveryProvider(d)
+			slots := w.providerSlot(provider)
+
+			inFlight.Add(1)
+			go func() {
+				defer inFlight.Done()
+
+				// Aguarda vaga do provedor e depois do pool; no encerramento, devolve a mensagem à fila.
+				select {
+				case slots <- struct{}{}:
+				case <-ctx.Done():
+					d.Nack(false, true)
+					return
+				}
+				defer func() { <-slots }()
+
+				select {
+				case workers <- struct{}{}:
+				case <-ctx.Done():
+					d.Nack(false, true)
+					return
+				}
+				defer func() { <-workers }()
+
+				// Canal fechado enquanto aguardava: o broker já devolveu a mensagem à fila.
+				if ch.IsClosed() {
+					return
+				}
+				w.handleDelivery(ch, queueName, d)
+			}()
+		}
+	}
+}
+
+func (w *Worker) handleDelivery(ch *amqp.Channel, queueName string, d amqp.Delivery) {
+	w.recordQueueDepth(ch, queueName)
+	log.Printf("📥 [WORKER] Mensagem Recebida do RabbitMQ (type=%q)", d.Type)
+
+	switch d.Type {
+	case MessageTypeDeactivation:
+		w.handleDeactivation(ch, queueName, d, "desativação", w.processDeactivation)
+	case MessageTypeRemoveDependent:
+		w.handleDeactivation(ch, queueName, d, "baixa de dependentes", w.processRemoveDependents)
+	case MessageTypeUpdateData:
+		w.handleBeneficiaryMessage(ch, queueName, d, "atualização cadastral", w.processUpdate)
+	case MessageTypeAddDependent:
+		w.handleBeneficiaryMessage(ch, queueName, d, "inclusão de dependentes", w.processAddDependents)
+	default:
+		w.handleBeneficiaryMessage(ch, queueName, d, "ativação", w.processMessage)
+	}
+
+	w.recordQueueDepth(ch, queueName)
+}
 
-	msgs, err := w.Channel.Consume(
-		queueName, // fila
-		"",        // consumer
-		false,     // auto-ack (manual é mais seguro)
-		false,     // exclusive
-		false,     // no-local
-		false,     // no-wait
-		nil,       // args
+func (w *Worker) concurrency() int {
+	if w.Concurrency < 1 {
+		return 1
+	}
+	return w.Concurrency
+}
+
+// ProviderLimit é quantas mensagens do provedor podem ser processadas ao mesmo tempo.
+func (w *Worker) ProviderLimit(provider string) int {
+	limit := w.DefaultProviderLimit
+	if configured, ok := w.ProviderLimits[strings.ToUpper(strings.TrimSpace(provider))]; ok {
+		limit = configured
+	}
+	if limit < 1 || limit > w.concurrency() {
+		return w.concurrency()
+	}
+	return limit
+}
+
+func (w *Worker) providerSlot(provider string) chan struct{} {
+	key := strings.ToUpper(strings.TrimSpace(provider))
+
+	w.slotsMu.Lock()
+	defer w.slotsMu.Unlock()
+
+	if w.providerSlots == nil {
+		w.providerSlots = make(map[string]chan struct{})
+	}
+	slots, ok := w.providerSlots[key]
+	if !ok {
+		slots = make(chan struct{}, w.ProviderLimit(key))
+		w.providerSlots[key] = slots
+	}
+	return slots
+}
+
+// deliveryProvider lê só o provedor do payload; JSON inválido cai na chave vazia
+// e é rejeitado pelo handler.
+func deliveryProvider(d amqp.Delivery) string {
+	var payload struct {
+		Provider string `json:"provider"`
+	}
+	_ = json.Unmarshal(d.Body, &payload)
+	return payload.Provider
+}
+
+func (w *Worker) consume(queueName string) (*amqp.Channel, string, <-chan amqp.Delivery, error) {
+	if w.Channels == nil {
+		return nil, "", nil, ErrNotConnected
+	}
+
+	ch, err := w.Channels.Channel()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	prefetch := w.Prefetch
+	if prefetch < w.concurrency() {
+		prefetch = w.concurrency()
+	}
+	if err := ch.Qos(prefetch, 0, false); err != nil {
+		ch.Close()
+		return nil, "", nil, err
+	}
+	// Publisher confirms: o reagendamento só dá ack na original depois que o broker confirma a cópia.
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, "", nil, err
+	}
+
+	consumerTag := fmt.Sprintf("worker-%s-%s", queueName, uuid.New().String())
+	msgs, err := ch.Consume(
+		queueName,   // fila
+		consumerTag, // consumer
+		false,       // auto-ack (manual é mais seguro)
+		false,       // exclusive
+		false,       // no-local
+		false,       // no-wait
+		nil,         // args
 	)
 	if err != nil {
-		log.Fatalf("❌ Falha ao registrar consumidor RabbitMQ: %s", err)
+		ch.Close()
+		return nil, "", nil, err
 	}
 
-	forever := make(chan bool)
+	return ch, consumerTag, msgs, nil
+}
 
-	go func() {
-		for d := range msgs {
-			w.recordQueueDepth(queueName)
-			log.Printf("📥 [WORKER] Mensagem Recebida do RabbitMQ")
+// handleBeneficiaryMessage trata as mensagens que carregam ActivationPayload
+// (ativação, atualização cadastral e inclusão de dependentes).
+func (w *Worker) handleBeneficiaryMessage(ch *amqp.Channel, queueName string, d amqp.Delivery, operation string, process func(context.Context, ActivationPayload) error) {
+	processingStart := time.Now()
 
-			processingStart := time.Now()
+	var payload ActivationPayload
+	if err := json.Unmarshal(d.Body, &payload); err != nil {
+		log.Printf("❌ [WORKER] JSON Inválido: %s", err)
+		middleware.RecordQueueConsumed(queueName, "", "invalid_json")
 
-			var payload ActivationPayload
-			if err := json.Unmarshal(d.Body, &payload); err != nil {
-				log.Printf("❌ [WORKER] JSON Inválido: %s", err)
-				middleware.RecordQueueConsumed(queueName, "", "invalid_json")
+		d.Nack(false, false)
+		return
+	}
 
-				d.Nack(false, false)
-				w.recordQueueDepth(queueName)
-				continue
-			}
+	if !d.Timestamp.IsZero() {
+		middleware.RecordQueueMessageArrivalLatency(queueName, payload.Provider, time.Since(d.Timestamp))
+	}
 
-			if !d.Timestamp.IsZero() {
-				middleware.RecordQueueMessageArrivalLatency(queueName, payload.Provider, time.Since(d.Timestamp))
-			}
+	log.Printf("⚙️ [WORKER] Processando %s para: %s (Provider: %s)", operation, payload.Name, payload.Provider)
 
-			log.Printf("⚙️ [WORKER] Processando ativação para: %s (Provider: %s)", payload.Name, payload.Provider)
+	if err := process(context.Background(), payload); err != nil {
+		log.Printf("❌ [WORKER] Erro na integração: %s", err)
+		middleware.RecordQueueProcessingDuration(queueName, payload.Provider, time.Since(processingStart))
 
-			if err := w.processMessage(context.Background(), payload); err != nil {
-				log.Printf("❌ [WORKER] Erro na integração: %s", err)
-				middleware.RecordQueueConsumed(queueName, payload.Provider, "failed")
-				middleware.RecordQueueProcessingDuration(queueName, payload.Provider, time.Since(processingStart))
+		w.handleFailure(ch, queueName, d, payload.Provider, err)
+		return
+	}
 
-				d.Nack(false, false)
-			} else {
-				log.Printf("✅ [WORKER] Sucesso! Cliente %s integrado na %s.", payload.Name, payload.Provider)
-				middleware.RecordQueueConsumed(queueName, payload.Provider, "success")
-				middleware.RecordQueueProcessingDuration(queueName, payload.Provider, time.Since(processingStart))
-				d.Ack(false) // Confirma o sucesso e remove da fila
-			}
+	log.Printf("✅ [WORKER] Sucesso! %s do cliente %s concluída na %s.", operation, payload.Name, payload.Provider)
+	middleware.RecordQueueConsumed(queueName, payload.Provider, "success")
+	middleware.RecordQueueProcessingDuration(queueName, payload.Provider, time.Since(processingStart))
+	d.Ack(false) // Confirma o sucesso e remove da fila
+}
 
-			w.recordQueueDepth(queueName)
-		}
-	}()
+// handleDeactivation trata as mensagens que carregam DeactivationPayload
+// (desativação do beneficiário e baixa de dependentes).
+func (w *Worker) handleDeactivation(ch *amqp.Channel, queueName string, d amqp.Delivery, operation string, process func(context.Context, DeactivationPayload) error) {
+	processingStart := time.Now()
+
+	var payload DeactivationPayload
+	if err := json.Unmarshal(d.Body, &payload); err != nil {
+		log.Printf("❌ [WORKER] JSON Inválido (%s): %s", operation, err)
+		middleware.RecordQueueConsumed(queueName, "", "invalid_json")
+
+		d.Nack(false, false)
+		return
+	}
+
+	log.Printf("⚙️ [WORKER] Processando %s para: %s (Provider: %s, motivo: %s)", operation, payload.Name, payload.Provider, payload.Reason)
+
+	if err := process(context.Background(), payload); err != nil {
+		log.Printf("❌ [WORKER] Erro na %s: %s", operation, err)
+		middleware.RecordQueueProcessingDuration(queueName, payload.Provider, time.Since(processingStart))
+
+		w.handleFailure(ch, queueName, d, payload.Provider, err)
+		return
+	}
+
+	log.Printf("✅ [WORKER] Sucesso! %s de %s concluída na %s.", operation, payload.Name, payload.Provider)
+	middleware.RecordQueueConsumed(queueName, payload.Provider, "success")
+	middleware.RecordQueueProcessingDuration(queueName, payload.Provider, time.Since(processingStart))
+	d.Ack(false)
+}
 
-	log.Printf(" [*] Worker rodando e aguardando na fila '%s'", queueName)
-	<-forever
+// handleFailure reagenda a mensagem numa fila de retry com TTL ou, esgotadas as tentativas,
+// estaciona em q.activations.parking. Erros permanentes vão para a DLQ. Se o broker não
+// confirmar a cópia, a original também vai para a DLQ (nack sem requeue): devolvê-la à fila
+// reprocessaria na hora, sem atraso nem contagem de tentativa.
+func (w *Worker) handleFailure(ch *amqp.Channel, queueName string, d amqp.Delivery, provider string, cause error) {
+	if isPermanentFailure(cause) {
+		middleware.RecordQueueConsumed(queueName, provider, "failed")
+		d.Nack(false, false)
+		return
+	}
+
+	routingKey, msg, parked := w.Retry.Next(d, cause.Error())
+	attempt := AttemptsFromHeaders(msg.Headers)
+
+	if err := publishConfirmed(ch, routingKey, msg); err != nil {
+		log.Printf("❌ [WORKER] Falha ao reagendar mensagem (tentativa %d), enviando para a DLQ: %v", attempt, err)
+		middleware.RecordQueueConsumed(queueName, provider, "dead_lettered")
+		d.Nack(false, false)
+		return
+	}
+
+	if parked {
+		log.Printf("🅿️ [WORKER] Mensagem estacionada em %s após %d tentativas: %s", routingKey, attempt, cause)
+		middleware.RecordQueueConsumed(queueName, provider, "parked")
+	} else {
+		log.Printf("🔁 [WORKER] Tentativa %d/%d falhou, reagendada em %s", attempt, w.Retry.MaxAttempts, routingKey)
+		middleware.RecordQueueConsumed(queueName, provider, "retry")
+	}
+	d.Ack(false)
+}
+
+// publishConfirmed publica na fila indicada e espera o ack do broker (até publishConfirmTimeout).
+func publishConfirmed(ch *amqp.Channel, routingKey string, msg amqp.Publishing) error {
+	ctx, cancel := context.WithTimeout(context.Background(), publishConfirmTimeout)
+	defer cancel()
+
+	confirmation, err := ch.PublishWithDeferredConfirmWithContext(ctx, "", routingKey, false, false, msg)
+	if err != nil {
+		return err
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("sem confirmação do RabbitMQ: %w", err)
+	}
+	if !acked {
+		return ErrPublishNacked
+	}
+	return nil
 }
 
-func (w *Worker) recordQueueDepth(queueName string) {
-	queueState, err := w.Channel.QueueInspect(queueName)
+func (w *Worker) recordQueueDepth(ch *amqp.Channel, queueName string) {
+	queueState, err := ch.QueueInspect(queueName)
 	if err != nil {
 		return
 	}
@@ -101,35 +373,89 @@ func (w *Worker) recordQueueDepth(queueName string) {
 	middleware.RecordQueueDepth(queueName, queueState.Messages)
 }
 
+// Provedor desconhecido ou sem a capacidade pedida retorna erro: a mensagem vai para a DLQ
+// em vez de ser confirmada sem que o beneficiário seja provisionado.
 func (w *Worker) processMessage(ctx context.Context, payload ActivationPayload) error {
+	client, err := w.Providers.Activator(payload.Provider)
+	if err != nil {
+		return err
+	}
 
-	switch payload.Provider {
-	case "DOC24":
-		log.Println("🩺 Enviando dados completos para API da Doc24...")
+	log.Printf("🩺 Enviando dados completos para API da %s...", payload.Provider)
 
-		if err := w.DocClient.CreateBeneficiary(ctx, payload); err != nil {
-			return err
-		}
+	if err := client.CreateBeneficiary(ctx, payload); err != nil {
+		return err
+	}
 
-		providerID := w.DocClient.GetBeneficiaryID(payload.CPF)
+	// Sem o ID do provedor a mensagem é reprocessada; o cadastro repetido responde 409.
+	providerID, err := client.GetBeneficiaryID(ctx, payload.CPF)
+	if err != nil {
+		return fmt.Errorf("falha ao obter provider_id do customer %s: %w", payload.CustomerID, err)
+	}
 
-		if err := w.CustomerRepo.UpdateProviderID(ctx, payload.CustomerID, providerID); err != nil {
-			log.Printf("⚠️ Falha ao salvar provider_id para customer %s: %v", payload.CustomerID, err)
+	if err := w.CustomerRepo.UpdateProviderID(ctx, payload.CustomerID, providerID); err != nil {
+		log.Printf("⚠️ Falha ao salvar provider_id para customer %s: %v", payload.CustomerID, err)
 
-		} else {
-			log.Printf("✅ Provider ID salvo: customer=%s provider_id=%s", payload.CustomerID, providerID)
-		}
+	} else {
+		log.Printf("✅ Provider ID salvo: customer=%s provider_id=%s", payload.CustomerID, providerID)
+	}
+
+	return nil
+}
 
-		return nil
+func (w *Worker) processDeactivation(ctx context.Context, payload DeactivationPayload) error {
+	client, err := w.Providers.Deactivator(payload.Provider)
+	if err != nil {
+		return err
+	}
 
-	case "TEM":
-		log.Println("🏥 Enviando para API da TEM Saúde...")
+	log.Printf("🩺 Enviando baixa do beneficiário para API da %s...", payload.Provider)
 
-		return nil
+	return client.DeactivateBeneficiary(ctx, payload)
+}
 
-	default:
-		log.Printf("⚠️ Provedor desconhecido: %s. Apenas logando.", payload.Provider)
+func (w *Worker) processUpdate(ctx context.Context, payload ActivationPayload) error {
+	client, err := w.Providers.Updater(payload.Provider)
+	if err != nil {
+		return err
+	}
+
+	return client.UpdateBeneficiary(ctx, payload)
+}
+
+func (w *Worker) processAddDependents(ctx context.Context, payload ActivationPayload) error {
+	client, err := w.Providers.DependentAdder(payload.Provider)
+	if err != nil {
+		return err
+	}
+
+	return client.AddDependents(ctx, payload)
+}
 
-		return nil
+func (w *Worker) processRemoveDependents(ctx context.Context, payload DeactivationPayload) error {
+	client, err := w.Providers.DependentRemover(payload.Provider)
+	if err != nil {
+		return err
+	}
+
+	return client.RemoveDependents(ctx, payload)
+}
+
+// ParseProviderLimits lê limites no formato "DOC24=2,TEM=3".
+func ParseProviderLimits(raw string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, value, found := strings.Cut(entry, "=")
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if !found || strings.TrimSpace(name) == "" || err != nil || limit < 1 {
+			return nil, fmt.Errorf("limite de provedor inválido: %q (esperado PROVEDOR=N)", entry)
+		}
+		limits[strings.ToUpper(strings.TrimSpace(name))] = limit
 	}
+	return limits, nil
 }
@@ -0,0 +1,145 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/usecase"
+)
+
+// LeadRecoveryJobName identifica o job no scheduler_leases.
+const LeadRecoveryJobName = "lead_recovery"
+
+// LeadRecoveryWorker envia a cadência de e-mails de checkout abandonado para leads PENDING.
+// Cada etapa sai quando o lead atinge o atraso configurado desde a captura (e respeitando o
+// intervalo desde o e-mail anterior); depois da última etapa o lead não recebe mais nada.
+// Leads capturados há mais de MaxAge ou descadastrados ficam fora da cadência.
+type LeadRecoveryWorker struct {
+	repo         entity.LeadRepositoryInterface
+	mailer       usecase.EmailService
+	tickInterval time.Duration
+	batchSize    int
+
+	Cadence     []time.Duration
+	MaxAge      time.Duration
+	CheckoutURL string
+	// UnsubscribeBaseURL é a URL pública da API; cada e-mail leva o link
+	// <base>/leads/{id}/unsubscribe. Sem ela nenhum e-mail é enviado.
+	UnsubscribeBaseURL string
+}
+
+func NewLeadRecoveryWorker(repo entity.LeadRepositoryInterface, mailer usecase.EmailService, checkoutURL string) *LeadRecoveryWorker {
+	return &LeadRecoveryWorker{
+		repo:         repo,
+		mailer:       mailer,
+		tickInterval: 5 * time.Minute,
+		batchSize:    50,
+		Cadence:      entity.DefaultLeadRecoveryCadence,
+		MaxAge:       entity.DefaultLeadRecoveryMaxAge,
+		CheckoutURL:  checkoutURL,
+	}
+}
+
+// Schedule registra a cadência no scheduler para que só uma réplica envie e-mails por tick.
+func (w *LeadRecoveryWorker) Schedule(s *Scheduler) {
+	log.Printf("📨 Lead Recovery Worker agendado (cadência %v, leads de até %s)", w.Cadence, w.MaxAge)
+	s.Every(LeadRecoveryJobName, w.tickInterval, func(ctx context.Context) {
+		w.RecoverPending(ctx)
+	})
+}
+
+// RecoverPending converte leads que já viraram clientes ativos e envia as etapas vencidas.
+// Retorna quantos e-mails foram enviados.
+func (w *LeadRecoveryWorker) RecoverPending(ctx context.Context) int {
+	if strings.TrimSpace(w.UnsubscribeBaseURL) == "" {
+		log.Printf("⚠️ Lead Recovery Worker: sem URL de descadastro configurada; nenhum e-mail enviado")
+		return 0
+	}
+
+	// Antes de enviar, tira da cadência quem já pagou por outro caminho.
+	if converted, err := w.repo.MarkConvertedFromActiveCustomers(ctx); err != nil {
+		log.Printf("⚠️ Lead Recovery Worker: %v", err)
+		return 0
+	} else if converted > 0 {
+		log.Printf("🎯 %d lead(s) convertido(s) por e-mail de cliente ativo", converted)
+	}
+
+	sent := 0
+	for stage := 0; stage < len(w.Cadence); stage++ {
+		sinceLastEmail := time.Duration(0)
+		if stage > 0 {
+			sinceLastEmail = w.Cadence[stage] - w.Cadence[stage-1]
+		}
+
+		leads, err := w.repo.FindDueForRecovery(ctx, stage, w.Cadence[stage], sinceLastEmail, w.MaxAge, w.batchSize)
+		if err != nil {
+			log.Printf("❌ Erro ao buscar leads da etapa %d: %v", stage+1, err)
+			continue
+		}
+
+		for _, lead := range leads {
+			if w.send(ctx, lead, stage) {
+				sent++
+			}
+		}
+	}
+	return sent
+}
+
+// send reserva a etapa antes de enviar, para que um tick concorrente ou uma falha ao gravar
+// não reenviem o mesmo e-mail. Falha no envio devolve a etapa: o lead volta no próximo tick.
+func (w *LeadRecoveryWorker) send(ctx context.Context, lead *entity.Lead, stage int) bool {
+	advanced, err := w.repo.AdvanceEmailStage(ctx, lead.ID, stage)
+	if err != nil {
+		log.Printf("❌ Falha ao reservar etapa %d do lead=%s: %v", stage+1, lead.ID, err)
+		return false
+	}
+	if !advanced {
+		log.Printf("ℹ️ Lead %s mudou antes do envio da etapa %d", lead.ID, stage+1)
+		return false
+	}
+
+	if err := w.mailer.SendCheckoutRecoveryEmail(lead.Name, lead.Email, w.CheckoutURL, w.unsubscribeURL(lead), stage+1); err != nil {
+		log.Printf("❌ Falha ao enviar recuperação etapa %d para lead=%s: %v", stage+1, lead.ID, err)
+		if revertErr := w.repo.RevertEmailStage(ctx, lead.ID, stage, lead.LastEmailSentAt); revertErr != nil {
+			log.Printf("⚠️ Etapa %d do lead=%s segue marcada como enviada: %v", stage+1, lead.ID, revertErr)
+		}
+		return false
+	}
+
+	log.Printf("📨 Recuperação etapa %d/%d enviada para lead=%s", stage+1, len(w.Cadence), lead.ID)
+	return true
+}
+
+func (w *LeadRecoveryWorker) unsubscribeURL(lead *entity.Lead) string {
+	return strings.TrimRight(strings.TrimSpace(w.UnsubscribeBaseURL), "/") + "/leads/" + url.PathEscape(lead.ID) + "/unsubscribe"
+}
+
+// ParseLeadRecoveryCadence lê atrasos como "1h,24h,72h". Cada etapa precisa ser maior que a anterior.
+func ParseLeadRecoveryCadence(raw string) ([]time.Duration, error) {
+	var cadence []time.Duration
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		delay, err := time.ParseDuration(entry)
+		if err != nil || delay <= 0 {
+			return nil, fmt.Errorf("atraso de recuperação inválido: %q (esperado algo como 24h)", entry)
+		}
+		if len(cadence) > 0 && delay <= cadence[len(cadence)-1] {
+			return nil, fmt.Errorf("cadência de recuperação fora de ordem: %s não é maior que %s", delay, cadence[len(cadence)-1])
+		}
+		cadence = append(cadence, delay)
+	}
+	if len(cadence) == 0 {
+		return nil, fmt.Errorf("cadência de recuperação vazia")
+	}
+	return cadence, nil
+}
@@ -0,0 +1,104 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/xavierca1/ligue-payments/internal/entity"
+)
+
+// OutboxPublisher publica um evento já serializado e só retorna nil após a confirmação do broker.
+type OutboxPublisher interface {
+	PublishRaw(ctx context.Context, messageType string, body []byte) error
+}
+
+// OutboxRelay publica no RabbitMQ os eventos gravados no outbox. Um evento só vira SENT
+// depois do ack do broker; falhas são reagendadas com backoff sem limite de tentativas,
+// já que desistir significaria um cliente pago nunca provisionado.
+type OutboxRelay struct {
+	repo         entity.OutboxRepositoryInterface
+	publisher    OutboxPublisher
+	tickInterval time.Duration
+	batchSize    int
+
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+func NewOutboxRelay(repo entity.OutboxRepositoryInterface, publisher OutboxPublisher) *OutboxRelay {
+	return &OutboxRelay{
+		repo:         repo,
+		publisher:    publisher,
+		tickInterval: 1 * time.Second,
+		batchSize:    50,
+		BaseBackoff:  5 * time.Second,
+		MaxBackoff:   5 * time.Minute,
+	}
+}
+
+func (r *OutboxRelay) Start(ctx context.Context) {
+	log.Println("📤 Outbox Relay iniciado")
+
+	ticker := time.NewTicker(r.tickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("⚠️ Outbox Relay encerrado")
+			return
+		case <-ticker.C:
+			// O lote reservado termina mesmo se o encerramento começar no meio dele.
+			r.RelayPending(context.WithoutCancel(ctx))
+		}
+	}
+}
+
+// RelayPending reserva e publica um lote de eventos pendentes. Retorna quantos foram reservados.
+func (r *OutboxRelay) RelayPending(ctx context.Context) int {
+	events, err := r.repo.ClaimPending(ctx, r.batchSize)
+	if err != nil {
+		log.Printf("❌ Outbox Relay: erro ao buscar eventos pendentes: %v", err)
+		return 0
+	}
+
+	for _, event := range events {
+		r.relay(ctx, event)
+	}
+
+	return len(events)
+}
+
+func (r *OutboxRelay) relay(ctx context.Context, event *entity.OutboxEvent) {
+	attempts := event.Attempts + 1
+
+	if err := r.publisher.PublishRaw(ctx, event.MessageType, event.Payload); err != nil {
+		reason := err.Error()
+		nextAttemptAt := time.Now().Add(r.backoff(attempts))
+		log.Printf("🔁 Outbox %s (%s customer=%s) falhou na tentativa %d, nova tentativa em %s: %s", event.ID, event.MessageType, event.AggregateID, attempts, nextAttemptAt.Format(time.RFC3339), reason)
+		if markErr := r.repo.MarkRetry(ctx, event.ID, attempts, nextAttemptAt, reason); markErr != nil {
+			log.Printf("⚠️ Outbox Relay: %v", markErr)
+		}
+		return
+	}
+
+	// Se MarkSent falhar, o lease expira e o evento é publicado de novo:
+	// a entrega é at-least-once e o cadastro no provedor é feito pelo CPF.
+	if err := r.repo.MarkSent(ctx, event.ID); err != nil {
+		log.Printf("⚠️ Outbox Relay: %v", err)
+		return
+	}
+	log.Printf("✅ Outbox %s (%s customer=%s) publicado", event.ID, event.MessageType, event.AggregateID)
+}
+
+func (r *OutboxRelay) backoff(attempts int) time.Duration {
+	delay := r.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= r.MaxBackoff {
+			return r.MaxBackoff
+		}
+	}
+	return delay
+}
@@ -2,123 +2,164 @@ package worker
 
 import (
 	"context"
-	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
+
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/infra/integration/asaas"
+	"github.com/xavierca1/ligue-payments/internal/usecase"
 )
 
+// PixExpirationJobName identifica o job no scheduler_leases.
+const PixExpirationJobName = "pix_expiration"
+
+// PixPaymentGateway é a parte do cliente Asaas usada na conciliação.
+type PixPaymentGateway interface {
+	GetSubscriptionPayments(subscriptionID string) ([]asaas.Payment, error)
+	DeleteSubscription(subscriptionID string) error
+}
+
+// PixExpirationWorker concilia PIX pendentes além da janela de pagamento: consulta a cobrança
+// no Asaas, ativa a assinatura se o pagamento caiu (webhook atrasado) ou cancela a assinatura
+// no Asaas e a marca como expirada. Cada decisão fica em pix_reconciliations.
 type PixExpirationWorker struct {
-	db               *sql.DB
+	repo             entity.PixReconciliationRepositoryInterface
+	gateway          PixPaymentGateway
+	activator        usecase.ActivateSubscriptionInterface
 	expirationWindow time.Duration
 	tickInterval     time.Duration
+	batchSize        int
+	maxAttempts      int
+	retryBaseDelay   time.Duration
+	retryMaxDelay    time.Duration
 }
 
-func NewPixExpirationWorker(db *sql.DB) *PixExpirationWorker {
+// A policy é a mesma do checkout: pix_expires_at de cada assinatura manda e, para registros
+// antigos sem o campo, vale a janela padrão contada a partir do created_at.
+func NewPixExpirationWorker(repo entity.PixReconciliationRepositoryInterface, gateway PixPaymentGateway, activator usecase.ActivateSubscriptionInterface, policy entity.PixExpirationPolicy) *PixExpirationWorker {
 	return &PixExpirationWorker{
-		db:               db,
-		expirationWindow: 30 * time.Minute, // PIX expira em 30 min
-		tickInterval:     1 * time.Minute,  // Roda a cada 1 min
+		repo:             repo,
+		gateway:          gateway,
+		activator:        activator,
+		expirationWindow: policy.Window(nil),
+		tickInterval:     1 * time.Minute, // Roda a cada 1 min
+		batchSize:        50,
+		maxAttempts:      8,
+		retryBaseDelay:   1 * time.Minute, // 1m, 2m, 4m ... até 1h entre tentativas
+		retryMaxDelay:    1 * time.Hour,
 	}
 }
 
-func (w *PixExpirationWorker) Start(ctx context.Context) {
-	log.Println("🕒 PIX Expiration Worker iniciado (30min window)")
+// Schedule registra a conciliação no scheduler, que garante uma única réplica por tick:
+// a conciliação chama o Asaas e ativa assinaturas, então não pode rodar em paralelo.
+func (w *PixExpirationWorker) Schedule(s *Scheduler) {
+	log.Printf("🕒 PIX Expiration Worker agendado (janela padrão %s)", w.expirationWindow)
+	s.Every(PixExpirationJobName, w.tickInterval, func(ctx context.Context) {
+		w.ReconcileExpired(ctx)
+	})
+}
+
+// ReconcileExpired processa um lote de PIX vencidos. Retorna quantos foram avaliados.
+func (w *PixExpirationWorker) ReconcileExpired(ctx context.Context) int {
+	subs, err := w.repo.FindStalePending(ctx, w.expirationWindow, w.maxAttempts, w.batchSize)
+	if err != nil {
+		log.Printf("❌ Erro ao buscar PIX expirados: %v", err)
+		return 0
+	}
+
+	for _, sub := range subs {
+		w.reconcile(ctx, sub)
+	}
 
-	ticker := time.NewTicker(w.tickInterval)
-	defer ticker.Stop()
+	return len(subs)
+}
 
-	// w.expireOldPix(ctx)
+func (w *PixExpirationWorker) reconcile(ctx context.Context, sub *entity.Subscription) {
+	gatewaySubID := strings.TrimSpace(sub.PaymentMethodID)
+	paymentStatus := ""
 
-	for {
-		select {
-		case <-ctx.Done():
-			log.Println("⚠️ PIX Expiration Worker encerrado")
+	if gatewaySubID != "" {
+		payments, err := w.gateway.GetSubscriptionPayments(gatewaySubID)
+		if err != nil && !errors.Is(err, asaas.ErrNotFound) {
+			w.fail(ctx, sub, "", fmt.Sprintf("falha ao consultar cobrança: %v", err))
 			return
-		case <-ticker.C:
-			w.expireOldPix(ctx)
+		}
+
+		for _, payment := range payments {
+			if paymentStatus == "" {
+				paymentStatus = payment.Status
+			}
+			if asaas.IsPaidStatus(payment.Status) {
+				paymentStatus = payment.Status
+				w.activate(ctx, sub, payment)
+				return
+			}
+		}
+
+		// 404 = assinatura já removida no Asaas (ex.: passada anterior cancelou e falhou ao expirar).
+		if err == nil {
+			if err := w.gateway.DeleteSubscription(gatewaySubID); err != nil && !errors.Is(err, asaas.ErrNotFound) {
+				w.fail(ctx, sub, paymentStatus, fmt.Sprintf("falha ao cancelar assinatura no Asaas: %v", err))
+				return
+			}
 		}
 	}
-}
 
-func (w *PixExpirationWorker) expireOldPix(ctx context.Context) {
-	expiredStatus, err := w.resolveExpiredStatusLabel(ctx)
+	expired, err := w.repo.Expire(ctx, sub.ID)
 	if err != nil {
-		log.Printf("❌ Erro ao resolver enum sub_status para expiração PIX: %v", err)
+		w.fail(ctx, sub, paymentStatus, err.Error())
 		return
 	}
-	if expiredStatus == "" {
-		log.Printf("⚠️ Nenhum status de expiração válido encontrado no enum sub_status (EXPIRED/CANCELED/CANCELLED)")
+	if !expired {
+		// O webhook mudou o status entre a busca e a expiração; nada a fazer.
+		log.Printf("ℹ️ PIX subscription=%s deixou de estar PENDING durante a conciliação", sub.ID)
 		return
 	}
 
-	query := `
-		UPDATE subscriptions
-		SET 
-			status = $1::sub_status,
-			updated_at = NOW()
-		WHERE 
-			status = 'PENDING'
-			AND payment_method = 'PIX'
-			AND created_at < NOW() - INTERVAL '30 minutes'
-		RETURNING id, customer_id, created_at
-	`
-
-	rows, err := w.db.QueryContext(ctx, query, expiredStatus)
-	if err != nil {
-		log.Printf("❌ Erro ao buscar PIX expirados: %v", err)
-		return
+	detail := "sem pagamento; assinatura cancelada no Asaas"
+	if gatewaySubID == "" {
+		detail = "sem assinatura vinculada no Asaas"
 	}
-	defer rows.Close()
-
-	expiredCount := 0
-	for rows.Next() {
-		var subID, customerID string
-		var createdAt time.Time
+	log.Printf("⏱️ PIX expirado: subscription=%s customer=%s elapsed=%s",
+		sub.ID, sub.CustomerID, time.Since(sub.CreatedAt).Round(time.Minute))
+	w.record(ctx, sub, paymentStatus, entity.PixReconciliationExpired, detail)
+}
 
-		if err := rows.Scan(&subID, &customerID, &createdAt); err != nil {
-			log.Printf("⚠️ Erro ao escanear PIX expirado: %v", err)
-			continue
-		}
+func (w *PixExpirationWorker) activate(ctx context.Context, sub *entity.Subscription, payment asaas.Payment) {
+	log.Printf("💰 PIX pago sem webhook processado: subscription=%s customer=%s payment=%s status=%s", sub.ID, sub.CustomerID, payment.ID, payment.Status)
 
-		elapsed := time.Since(createdAt)
-		log.Printf("⏱️ PIX expirado: subscription=%s customer=%s elapsed=%s",
-			subID, customerID, elapsed.Round(time.Minute))
-		expiredCount++
+	if err := w.activator.Execute(ctx, usecase.ActivateSubscriptionInput{CustomerID: sub.CustomerID, GatewayID: payment.ID}); err != nil {
+		w.fail(ctx, sub, payment.Status, fmt.Sprintf("pagamento %s encontrado, mas a ativação falhou: %v", payment.ID, err))
+		return
 	}
 
-	if expiredCount > 0 {
-		log.Printf("✅ %d PIX(s) marcados como %s", expiredCount, expiredStatus)
-	}
+	w.record(ctx, sub, payment.Status, entity.PixReconciliationActivated, fmt.Sprintf("pagamento %s", payment.ID))
 }
 
-func (w *PixExpirationWorker) resolveExpiredStatusLabel(ctx context.Context) (string, error) {
-	rows, err := w.db.QueryContext(ctx, `SELECT unnest(enum_range(NULL::sub_status)::text[])`)
+// fail registra a falha e adia a próxima tentativa; após maxAttempts a assinatura sai da
+// conciliação e fica PENDING para análise manual.
+func (w *PixExpirationWorker) fail(ctx context.Context, sub *entity.Subscription, paymentStatus, detail string) {
+	attempts, err := w.repo.RegisterFailure(ctx, sub.ID, w.retryBaseDelay, w.retryMaxDelay)
 	if err != nil {
-		return "", err
+		log.Printf("⚠️ PIX Expiration Worker: %v", err)
+	} else if attempts >= w.maxAttempts {
+		detail = fmt.Sprintf("%s (tentativa %d/%d; conciliação encerrada, requer análise manual)", detail, attempts, w.maxAttempts)
+	} else {
+		detail = fmt.Sprintf("%s (tentativa %d/%d)", detail, attempts, w.maxAttempts)
 	}
-	defer rows.Close()
 
-	labels := map[string]bool{}
-	for rows.Next() {
-		var label string
-		if err := rows.Scan(&label); err != nil {
-			return "", err
-		}
-		labels[label] = true
-	}
-	if err := rows.Err(); err != nil {
-		return "", err
-	}
+	w.record(ctx, sub, paymentStatus, entity.PixReconciliationError, detail)
+}
 
-	if labels["EXPIRED"] {
-		return "EXPIRED", nil
-	}
-	if labels["CANCELED"] {
-		return "CANCELED", nil
-	}
-	if labels["CANCELLED"] {
-		return "CANCELLED", nil
+func (w *PixExpirationWorker) record(ctx context.Context, sub *entity.Subscription, paymentStatus, action, detail string) {
+	if action == entity.PixReconciliationError {
+		log.Printf("❌ Conciliação PIX subscription=%s customer=%s: %s", sub.ID, sub.CustomerID, detail)
 	}
 
-	return "", nil
+	if err := w.repo.Record(ctx, entity.NewPixReconciliation(sub, paymentStatus, action, detail)); err != nil {
+		log.Printf("⚠️ PIX Expiration Worker: %v", err)
+	}
 }
@@ -0,0 +1,71 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/usecase"
+)
+
+// ScheduledCancellationJobName identifica o job no scheduler_leases.
+const ScheduledCancellationJobName = "scheduled_cancellation"
+
+// ScheduledCancellationWorker conclui os cancelamentos agendados para o fim do ciclo: quando
+// cancel_at vence, aplica SUBSCRIPTION_DELETED pelo lifecycle, que cancela a assinatura e
+// publica a desativação do beneficiário. A assinatura no Asaas já foi removida no pedido.
+type ScheduledCancellationWorker struct {
+	repo         entity.CancellationRepositoryInterface
+	lifecycle    usecase.SubscriptionLifecycleInterface
+	tickInterval time.Duration
+	batchSize    int
+}
+
+func NewScheduledCancellationWorker(repo entity.CancellationRepositoryInterface, lifecycle usecase.SubscriptionLifecycleInterface) *ScheduledCancellationWorker {
+	return &ScheduledCancellationWorker{
+		repo:         repo,
+		lifecycle:    lifecycle,
+		tickInterval: 15 * time.Minute,
+		batchSize:    50,
+	}
+}
+
+// Schedule registra o job no scheduler, que garante uma única réplica por tick.
+func (w *ScheduledCancellationWorker) Schedule(s *Scheduler) {
+	log.Printf("🕒 Scheduled Cancellation Worker agendado (a cada %s)", w.tickInterval)
+	s.Every(ScheduledCancellationJobName, w.tickInterval, func(ctx context.Context) {
+		w.Run(ctx)
+	})
+}
+
+// Run encerra um lote de assinaturas com cancel_at vencido. Retorna quantas foram canceladas.
+func (w *ScheduledCancellationWorker) Run(ctx context.Context) int {
+	subs, err := w.repo.FindDueCancellations(ctx, time.Now(), w.batchSize)
+	if err != nil {
+		log.Printf("❌ Erro ao buscar cancelamentos agendados: %v", err)
+		return 0
+	}
+
+	canceled := 0
+	for _, sub := range subs {
+		status, err := w.lifecycle.Execute(ctx, usecase.SubscriptionLifecycleInput{
+			CustomerID:            sub.CustomerID,
+			Event:                 "SUBSCRIPTION_DELETED",
+			GatewaySubscriptionID: sub.PaymentMethodID,
+			Origin:                "SCHEDULED_CANCELLATION",
+		})
+		if err != nil {
+			log.Printf("❌ Cancelamento agendado subscription=%s customer=%s: %v", sub.ID, sub.CustomerID, err)
+			continue
+		}
+		if status == "" {
+			log.Printf("⚠️ Cancelamento agendado subscription=%s customer=%s não alterou a assinatura", sub.ID, sub.CustomerID)
+			continue
+		}
+		canceled++
+		log.Printf("🛑 Cancelamento agendado concluído: subscription=%s customer=%s", sub.ID, sub.CustomerID)
+	}
+
+	return canceled
+}
@@ -0,0 +1,180 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/xavierca1/ligue-payments/internal/entity"
+)
+
+// Scheduler roda jobs periódicos em apenas uma réplica por vez. A cada tick a réplica
+// disputa o lease do job (com duração de dois intervalos); quem não obtém o lease pula
+// o tick. Durante a execução o líder renova o lease a cada terço de intervalo, então um
+// job mais longo que o intervalo não é assumido por outra réplica; se o líder cair, outra
+// réplica assume quando o lease vence.
+type Scheduler struct {
+	leases entity.SchedulerLeaseRepositoryInterface
+	holder string
+	jobs   []*scheduledJob
+}
+
+type scheduledJob struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context)
+}
+
+func NewScheduler(leases entity.SchedulerLeaseRepositoryInterface, holder string) *Scheduler {
+	if holder == "" {
+		holder = DefaultSchedulerHolder()
+	}
+	return &Scheduler{leases: leases, holder: holder}
+}
+
+// DefaultSchedulerHolder identifica a réplica: no swarm o hostname é o ID do container.
+func DefaultSchedulerHolder() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", hostname, os.Getpid())
+}
+
+func (s *Scheduler) Holder() string {
+	return s.holder
+}
+
+// Every registra um job. Deve ser chamado antes de Start.
+func (s *Scheduler) Every(name string, interval time.Duration, run func(ctx context.Context)) {
+	s.jobs = append(s.jobs, &scheduledJob{name: name, interval: interval, run: run})
+}
+
+// Start roda cada job no seu próprio ticker e só retorna depois que as execuções em
+// andamento terminam; ao sair, libera os leases detidos por esta réplica.
+func (s *Scheduler) Start(ctx context.Context) {
+	log.Printf("🗓️ Scheduler iniciado (holder=%s, jobs=%d)", s.holder, len(s.jobs))
+
+	var wg sync.WaitGroup
+	for _, job := range s.jobs {
+		wg.Add(1)
+		go func(job *scheduledJob) {
+			defer wg.Done()
+			s.loop(ctx, job)
+		}(job)
+	}
+	wg.Wait()
+
+	log.Println("⚠️ Scheduler encerrado")
+}
+
+func (s *Scheduler) loop(ctx context.Context, job *scheduledJob) {
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+
+	leader := false
+	for {
+		select {
+		case <-ctx.Done():
+			if leader {
+				releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+				if err := s.leases.Release(releaseCtx, job.name, s.holder); err != nil {
+					log.Printf("⚠️ Scheduler: %v", err)
+				}
+				cancel()
+			}
+			return
+		case <-ticker.C:
+			// A execução termina mesmo se o encerramento começar no meio dela.
+			leader = s.runIfLeader(context.WithoutCancel(ctx), job, leader)
+		}
+	}
+}
+
+// RunOnce executa o job agora se esta réplica obtiver o lease. Retorna se executou.
+func (s *Scheduler) RunOnce(ctx context.Context, name string) bool {
+	for _, job := range s.jobs {
+		if job.name == name {
+			return s.runIfLeader(ctx, job, false)
+		}
+	}
+	return false
+}
+
+// leaseTTL dá folga de um intervalo além do próprio tick para a renovação acontecer.
+func leaseTTL(interval time.Duration) time.Duration {
+	return 2 * interval
+}
+
+func (s *Scheduler) runIfLeader(ctx context.Context, job *scheduledJob, wasLeader bool) bool {
+	acquired, err := s.leases.TryAcquire(ctx, job.name, s.holder, leaseTTL(job.interval))
+	if err != nil {
+		// Sem confirmação do lease não roda: melhor perder um tick que duplicar efeitos.
+		log.Printf("⚠️ Scheduler: %v", err)
+		return false
+	}
+	if !acquired {
+		if wasLeader {
+			log.Printf("ℹ️ Scheduler: job %s assumido por outra réplica", job.name)
+		}
+		return false
+	}
+	if !wasLeader {
+		log.Printf("👑 Scheduler: %s é líder do job %s", s.holder, job.name)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		s.heartbeat(runCtx, cancel, job)
+	}()
+
+	started := time.Now()
+	job.run(runCtx)
+	cancel()
+	<-heartbeatDone
+
+	if elapsed := time.Since(started); elapsed > job.interval {
+		log.Printf("⚠️ Scheduler: job %s levou %s, acima do intervalo de %s", job.name, elapsed.Round(time.Second), job.interval)
+	}
+	return true
+}
+
+// heartbeat renova o lease enquanto o job roda. Se o lease for perdido (ou não puder ser
+// renovado antes de vencer), cancela o contexto do job para não rodar junto com outra réplica.
+func (s *Scheduler) heartbeat(ctx context.Context, stop context.CancelFunc, job *scheduledJob) {
+	ttl := leaseTTL(job.interval)
+	ticker := time.NewTicker(job.interval / 3)
+	defer ticker.Stop()
+
+	renewedAt := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			acquired, err := s.leases.TryAcquire(ctx, job.name, s.holder, ttl)
+			switch {
+			case err != nil && ctx.Err() != nil:
+				return
+			case err != nil:
+				log.Printf("⚠️ Scheduler: falha ao renovar lease do job %s: %v", job.name, err)
+				if time.Since(renewedAt) >= ttl {
+					log.Printf("⚠️ Scheduler: lease do job %s venceu sem renovação, interrompendo execução", job.name)
+					stop()
+					return
+				}
+			case !acquired:
+				log.Printf("⚠️ Scheduler: lease do job %s perdido durante a execução, interrompendo", job.name)
+				stop()
+				return
+			default:
+				renewedAt = time.Now()
+			}
+		}
+	}
+}
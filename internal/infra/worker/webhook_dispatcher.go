@@ -0,0 +1,136 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/xavierca1/ligue-payments/internal/entity"
+)
+
+// WebhookEventProcessor executa a regra de negócio de um evento da inbox.
+type WebhookEventProcessor interface {
+	Execute(ctx context.Context, event *entity.WebhookEvent) error
+}
+
+// WebhookDispatcher consome a inbox de webhooks, processando cada evento com retry
+// exponencial até MaxAttempts; depois disso o evento fica FAILED até um replay manual.
+type WebhookDispatcher struct {
+	repo         entity.WebhookEventRepositoryInterface
+	processor    WebhookEventProcessor
+	tickInterval time.Duration
+	batchSize    int
+
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+func NewWebhookDispatcher(repo entity.WebhookEventRepositoryInterface, processor WebhookEventProcessor) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		repo:         repo,
+		processor:    processor,
+		tickInterval: 2 * time.Second,
+		batchSize:    20,
+		MaxAttempts:  8,
+		BaseBackoff:  30 * time.Second,
+		MaxBackoff:   1 * time.Hour,
+	}
+}
+
+func (d *WebhookDispatcher) Start(ctx context.Context) {
+	log.Printf("📬 Webhook Dispatcher iniciado (max_attempts=%d)", d.MaxAttempts)
+
+	ticker := time.NewTicker(d.tickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("⚠️ Webhook Dispatcher encerrado")
+			return
+		case <-ticker.C:
+			// O lote reservado termina mesmo se o encerramento começar no meio dele.
+			d.DispatchDue(context.WithoutCancel(ctx))
+		}
+	}
+}
+
+// DispatchDue reserva e processa um lote de eventos prontos. Retorna quantos foram reservados.
+func (d *WebhookDispatcher) DispatchDue(ctx context.Context) int {
+	events, err := d.repo.ClaimDue(ctx, d.batchSize)
+	if err != nil {
+		log.Printf("❌ Webhook Dispatcher: erro ao buscar eventos pendentes: %v", err)
+		return 0
+	}
+
+	for _, event := range events {
+		d.dispatch(ctx, event)
+	}
+
+	return len(events)
+}
+
+func (d *WebhookDispatcher) dispatch(ctx context.Context, event *entity.WebhookEvent) {
+	// Tentativas interrompidas (lease vencido) já vêm contadas do ClaimDue.
+	if event.Attempts >= d.MaxAttempts {
+		reason := "processamento interrompido (lease expirado) em todas as tentativas"
+		if event.LastError != "" {
+			reason += "; último erro: " + event.LastError
+		}
+		log.Printf("❌ Webhook %s (%s key=%s) falhou definitivamente após %d tentativas: %s", event.ID, event.EventType, event.EventKey, event.Attempts, reason)
+		if markErr := d.repo.MarkFailed(ctx, event.ID, event.Attempts, reason); markErr != nil {
+			log.Printf("⚠️ Webhook Dispatcher: %v", markErr)
+		}
+		return
+	}
+
+	attempts := event.Attempts + 1
+
+	if err := d.safeExecute(ctx, event); err != nil {
+		reason := err.Error()
+
+		if attempts >= d.MaxAttempts {
+			log.Printf("❌ Webhook %s (%s key=%s) falhou definitivamente após %d tentativas: %s", event.ID, event.EventType, event.EventKey, attempts, reason)
+			if markErr := d.repo.MarkFailed(ctx, event.ID, attempts, reason); markErr != nil {
+				log.Printf("⚠️ Webhook Dispatcher: %v", markErr)
+			}
+			return
+		}
+
+		nextAttemptAt := time.Now().Add(d.backoff(attempts))
+		log.Printf("🔁 Webhook %s (%s key=%s) falhou na tentativa %d/%d, nova tentativa em %s: %s", event.ID, event.EventType, event.EventKey, attempts, d.MaxAttempts, nextAttemptAt.Format(time.RFC3339), reason)
+		if markErr := d.repo.MarkRetry(ctx, event.ID, attempts, nextAttemptAt, reason); markErr != nil {
+			log.Printf("⚠️ Webhook Dispatcher: %v", markErr)
+		}
+		return
+	}
+
+	if err := d.repo.MarkProcessed(ctx, event.ID); err != nil {
+		log.Printf("⚠️ Webhook Dispatcher: %v", err)
+	}
+}
+
+// safeExecute isola panics do processamento para que um evento problemático
+// vire uma falha com retry em vez de derrubar o dispatcher.
+func (d *WebhookDispatcher) safeExecute(ctx context.Context, event *entity.WebhookEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic ao processar webhook: %v", r)
+		}
+	}()
+
+	return d.processor.Execute(ctx, event)
+}
+
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	delay := d.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= d.MaxBackoff {
+			return d.MaxBackoff
+		}
+	}
+	return delay
+}
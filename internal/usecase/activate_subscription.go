@@ -51,16 +51,7 @@ func (uc *ActivateSubscriptionUseCase) Execute(ctx context.Context, input Activa
 		}
 	}
 
-	// Converter Gender int para string legível
-	var genderStr string
-	switch customer.Gender {
-	case 1:
-		genderStr = "Masculino"
-	case 2:
-		genderStr = "Feminino"
-	case 3:
-		genderStr = "Outro"
-	}
+	genderStr := genderLabel(customer.Gender)
 
 	sub, err := uc.SubRepo.FindLastByCustomerID(ctx, input.CustomerID)
 	if err != nil {
@@ -76,22 +67,6 @@ func (uc *ActivateSubscriptionUseCase) Execute(ctx context.Context, input Activa
 		log.Printf("ℹ️ Assinatura já ativa para CustomerID=%s. Mantendo fluxo para e-mail de boas-vindas.", input.CustomerID)
 	}
 
-	if !alreadyActive {
-		// Atualizar subscription status para ACTIVE
-		if err := uc.SubRepo.UpdateStatus(input.CustomerID, "ACTIVE"); err != nil {
-			return fmt.Errorf("erro ao ativar status da subscription no banco: %w", err)
-		}
-	}
-
-	// Sempre atualizar o status do customer para ACTIVE quando a subscription está ativa
-	// (mesmo em retries/webhooks duplicados, garante sincronização)
-	if err := uc.CustomerRepo.UpdateStatus(ctx, input.CustomerID, "ACTIVE"); err != nil {
-		log.Printf("⚠️ Falha ao atualizar status do customer para ACTIVE (não bloqueia): %v", err)
-		// Não bloqueamos - o subscription já foi ativado
-	} else {
-		log.Printf("✅ Customer status atualizado para ACTIVE (customer_id=%s)", input.CustomerID)
-	}
-
 	plan, err := uc.PlanRepo.FindByID(ctx, sub.PlanID)
 	if err != nil {
 		return fmt.Errorf("falha ao buscar plano (%s): %w", sub.PlanID, err)
@@ -129,12 +104,43 @@ func (uc *ActivateSubscriptionUseCase) Execute(ctx context.Context, input Activa
 		})
 	}
 
-	if uc.Queue != nil {
-		if err := uc.Queue.PublishActivation(ctx, payload); err != nil {
-			log.Printf("⚠️ Assinatura ativada no banco, mas falha ao publicar na fila: %v", err)
+	if uc.Outbox != nil {
+		// Status e evento de ativação na mesma transação: se o RabbitMQ estiver fora,
+		// o OutboxRelay publica quando ele voltar. Em retries o evento é gravado de novo.
+		event, err := entity.NewOutboxEvent(queue.MessageTypeActivation, customer.ID, payload)
+		if err != nil {
+			return err
+		}
+		if err := uc.Outbox.UpdateStatusWithOutbox(ctx, input.CustomerID, "ACTIVE", "ACTIVE", event); err != nil {
+			return fmt.Errorf("erro ao ativar assinatura e gravar outbox: %w", err)
+		}
+		log.Printf("✅ Assinatura ativada e evento %s gravado no outbox (customer_id=%s)", event.ID, input.CustomerID)
+	} else {
+		if !alreadyActive {
+			// Atualizar subscription status para ACTIVE
+			if err := uc.SubRepo.UpdateStatus(input.CustomerID, "ACTIVE"); err != nil {
+				return fmt.Errorf("erro ao ativar status da subscription no banco: %w", err)
+			}
+		}
+
+		// Sempre atualizar o status do customer para ACTIVE quando a subscription está ativa
+		// (mesmo em retries/webhooks duplicados, garante sincronização)
+		if err := uc.CustomerRepo.UpdateStatus(ctx, input.CustomerID, "ACTIVE"); err != nil {
+			log.Printf("⚠️ Falha ao atualizar status do customer para ACTIVE (não bloqueia): %v", err)
+			// Não bloqueamos - o subscription já foi ativado
+		} else {
+			log.Printf("✅ Customer status atualizado para ACTIVE (customer_id=%s)", input.CustomerID)
+		}
+
+		if uc.Queue != nil {
+			if err := uc.Queue.PublishActivation(ctx, payload); err != nil {
+				log.Printf("⚠️ Assinatura ativada no banco, mas falha ao publicar na fila: %v", err)
+			}
 		}
 	}
 
+	uc.markLeadConverted(ctx, customer.Email)
+
 	// Prioridade 1: Usar DocuSeal automático (se disponível)
 	// Isso gera o documento, registra aceitação dos termos com data/hora
 	// e envia o PDF por email com o corpo específico do contrato
@@ -155,7 +161,7 @@ func (uc *ActivateSubscriptionUseCase) Execute(ctx context.Context, input Activa
 			Produto:       plan.Name,
 			Valor:         formatBRL(sub.Amount),
 			Pagamento:     humanizePaymentMethod(sub.PaymentMethod),
-			Periodicidade: "Mensal",
+			Periodicidade: humanizeBillingCycle(plan.Cycle()),
 			Nascimento:    customer.BirthDate,
 			Sexo:          genderStr,
 			Civil:         customer.MaritalStatus,
@@ -195,6 +201,16 @@ func (uc *ActivateSubscriptionUseCase) Execute(ctx context.Context, input Activa
 	return nil
 }
 
+// markLeadConverted tira o lead do checkout da cadência de recuperação assim que o cliente fica ACTIVE.
+func (uc *ActivateSubscriptionUseCase) markLeadConverted(ctx context.Context, email string) {
+	if uc.LeadRepo == nil || strings.TrimSpace(email) == "" {
+		return
+	}
+	if err := uc.LeadRepo.MarkConvertedByEmail(ctx, email); err != nil {
+		log.Printf("⚠️ Falha ao marcar lead como CONVERTED (não bloqueia): %v", err)
+	}
+}
+
 func (uc *ActivateSubscriptionUseCase) sendWelcomeEmail(customer *entity.Customer, plan *entity.Plan, dependents []*entity.Dependent, contractPDF []byte) {
 	if uc.EmailService == nil {
 		return
@@ -247,7 +263,7 @@ func buildContractInput(customer *entity.Customer, plan *entity.Plan) GenerateCo
 		Produto:       plan.Name,
 		Valor:         valor,
 		Pagamento:     "",
-		Periodicidade: "",
+		Periodicidade: humanizeBillingCycle(plan.Cycle()),
 		Nome:          customer.Name,
 		Nascimento:    customer.BirthDate,
 		CPF:           customer.CPF,
@@ -274,6 +290,20 @@ func formatBRL(amountCents int) string {
 	return fmt.Sprintf("R$ %d,%02d", reais, centavos)
 }
 
+// humanizeBillingCycle dá o rótulo de periodicidade do contrato para o ciclo do plano.
+func humanizeBillingCycle(cycle string) string {
+	switch cycle {
+	case entity.BillingCycleQuarterly:
+		return "Trimestral"
+	case entity.BillingCycleSemiannually:
+		return "Semestral"
+	case entity.BillingCycleYearly:
+		return "Anual"
+	default:
+		return "Mensal"
+	}
+}
+
 func humanizePaymentMethod(method string) string {
 	switch strings.ToUpper(strings.TrimSpace(method)) {
 	case "CREDIT_CARD":
@@ -315,3 +345,17 @@ func planTemplateSlug(name string) string {
 
 	return strings.Trim(builder.String(), "_")
 }
+
+// genderLabel converte o Gender int para string legível
+func genderLabel(gender int) string {
+	switch gender {
+	case 1:
+		return "Masculino"
+	case 2:
+		return "Feminino"
+	case 3:
+		return "Outro"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,326 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/infra/integration/asaas"
+)
+
+const (
+	CancellationStatusRetentionOffered = "RETENTION_OFFERED"
+	CancellationStatusRetained         = "RETAINED"
+	CancellationStatusScheduled        = "SCHEDULED"
+	CancellationStatusCanceled         = "CANCELED"
+)
+
+// CancellationGateway remove a assinatura no Asaas e aplica o desconto de retenção.
+type CancellationGateway interface {
+	SubscriptionValueUpdater
+	DeleteSubscription(subscriptionID string) error
+}
+
+type CancelSubscriptionInterface interface {
+	Execute(ctx context.Context, input CancelSubscriptionInput) (*CancelSubscriptionOutput, error)
+}
+
+type CancelSubscriptionInput struct {
+	CustomerID      string `json:"-"`
+	CPF             string `json:"cpf"`     // confirma que o pedido é do titular
+	Reason          string `json:"reason"`  // PRICE, NOT_USING, SERVICE_QUALITY, OTHER_PROVIDER, FINANCIAL, OTHER
+	Comment         string `json:"comment"` // texto livre do cliente
+	Immediate       bool   `json:"immediate"`
+	Confirm         bool   `json:"confirm"`          // cancela mesmo com oferta de retenção disponível
+	AcceptRetention bool   `json:"accept_retention"` // aceita o cupom de retenção em vez de cancelar
+}
+
+type RetentionOffer struct {
+	CouponCode          string `json:"coupon_code"`
+	DiscountAmountCents int    `json:"discount_amount_cents"`
+	FinalAmountCents    int    `json:"final_amount_cents"`
+	DurationCycles      int    `json:"duration_cycles,omitempty"` // 0 = desconto em todas as cobranças
+
+	coupon *CouponDetails
+}
+
+type CancelSubscriptionOutput struct {
+	Status         string          `json:"status"`
+	RetentionOffer *RetentionOffer `json:"retention_offer,omitempty"`
+	AccessUntil    *time.Time      `json:"access_until,omitempty"`
+}
+
+// CancelSubscriptionUseCase atende o pedido de cancelamento do próprio cliente: registra o
+// motivo, oferece uma vez o cupom de retenção (RetentionCouponCode) e, confirmado o pedido,
+// remove a assinatura no Asaas. O cancelamento vale no fim do ciclo pago (o acesso continua
+// até lá e o ScheduledCancellationWorker conclui) ou na hora, com Immediate.
+type CancelSubscriptionUseCase struct {
+	SubRepo      entity.SubscriptionRepository
+	CustomerRepo entity.CustomerRepositoryInterface
+	PlanRepo     entity.PlanRepositoryInterface
+	CancelRepo   entity.CancellationRepositoryInterface
+	Gateway      CancellationGateway
+	Lifecycle    SubscriptionLifecycleInterface
+	EmailService EmailService // optional; confirmação por e-mail
+
+	RetentionCouponCode string // cupom oferecido antes de cancelar; vazio desliga a oferta
+}
+
+func NewCancelSubscriptionUseCase(
+	subRepo entity.SubscriptionRepository,
+	customerRepo entity.CustomerRepositoryInterface,
+	planRepo entity.PlanRepositoryInterface,
+	cancelRepo entity.CancellationRepositoryInterface,
+	gateway CancellationGateway,
+	lifecycle SubscriptionLifecycleInterface,
+) *CancelSubscriptionUseCase {
+	return &CancelSubscriptionUseCase{
+		SubRepo:      subRepo,
+		CustomerRepo: customerRepo,
+		PlanRepo:     planRepo,
+		CancelRepo:   cancelRepo,
+		Gateway:      gateway,
+		Lifecycle:    lifecycle,
+	}
+}
+
+func (uc *CancelSubscriptionUseCase) Execute(ctx context.Context, input CancelSubscriptionInput) (*CancelSubscriptionOutput, error) {
+	if !entity.IsValidCancellationReason(input.Reason) {
+		return nil, &DomainError{Code: "INVALID_CANCELLATION_REASON", Message: "motivo de cancelamento inválido"}
+	}
+
+	customer, err := uc.CustomerRepo.FindByID(ctx, input.CustomerID)
+	if err != nil {
+		return nil, &DomainError{Code: "CUSTOMER_NOT_FOUND", Message: "cliente não encontrado"}
+	}
+	if cpfDigits(input.CPF) == "" || cpfDigits(input.CPF) != cpfDigits(customer.CPF) {
+		return nil, &DomainError{Code: "CPF_MISMATCH", Message: "CPF não confere com o titular da assinatura"}
+	}
+
+	sub, err := uc.SubRepo.FindLastByCustomerID(ctx, customer.ID)
+	if err != nil {
+		return nil, &DomainError{Code: "SUBSCRIPTION_NOT_FOUND", Message: "cliente não possui assinatura"}
+	}
+	status := strings.ToUpper(strings.TrimSpace(sub.Status))
+	switch status {
+	case SubscriptionStatusActive, SubscriptionStatusPastDue:
+	case SubscriptionStatusCanceled, SubscriptionStatusRefunded:
+		return nil, &DomainError{Code: "SUBSCRIPTION_ALREADY_CANCELED", Message: "a assinatura já está cancelada"}
+	default:
+		return nil, &DomainError{Code: "SUBSCRIPTION_NOT_ACTIVE", Message: "só assinaturas ativas ou em atraso podem ser canceladas"}
+	}
+
+	plan, err := uc.PlanRepo.FindByID(ctx, sub.PlanID)
+	if err != nil {
+		return nil, &TechnicalError{Code: "DATABASE_ERROR", Message: fmt.Sprintf("falha ao buscar plano (%s): %v", sub.PlanID, err)}
+	}
+
+	// Com cancelamento já agendado não há mais oferta: o pedido só pode antecipar o fim do acesso.
+	var offer *RetentionOffer
+	if sub.CancelAt == nil {
+		offer = uc.retentionOffer(ctx, sub, plan, customer.CPF)
+	}
+
+	if input.AcceptRetention {
+		if offer == nil {
+			return nil, &DomainError{Code: "RETENTION_UNAVAILABLE", Message: "não há oferta de retenção disponível para esta assinatura"}
+		}
+		return uc.retain(ctx, input, customer, sub, offer)
+	}
+
+	if offer != nil && !input.Confirm {
+		cancellation := entity.NewSubscriptionCancellation(sub, input.Reason, input.Comment, entity.CancellationRetentionOffered)
+		cancellation.RetentionCouponCode = offer.CouponCode
+		if err := uc.CancelRepo.Save(ctx, cancellation); err != nil {
+			log.Printf("⚠️ Cancelamento: falha ao registrar oferta de retenção (customer %s): %v", customer.ID, err)
+		}
+		return &CancelSubscriptionOutput{Status: CancellationStatusRetentionOffered, RetentionOffer: offer}, nil
+	}
+
+	return uc.cancel(ctx, input, customer, sub, plan, status)
+}
+
+// retentionOffer avalia o cupom de retenção para o valor cheio da assinatura. Sem cupom
+// configurado, já retida uma vez, ou com o cupom fora das regras, não há oferta.
+func (uc *CancelSubscriptionUseCase) retentionOffer(ctx context.Context, sub *entity.Subscription, plan *entity.Plan, cpf string) *RetentionOffer {
+	code := strings.ToUpper(strings.TrimSpace(uc.RetentionCouponCode))
+	if code == "" {
+		return nil
+	}
+
+	retained, err := uc.CancelRepo.RetentionAccepted(ctx, sub.ID)
+	if err != nil {
+		log.Printf("⚠️ Cancelamento: falha ao verificar retenção anterior (assinatura %s): %v", sub.ID, err)
+		return nil
+	}
+	if retained {
+		return nil
+	}
+
+	evaluation, err := EvaluateCoupon(ctx, code, plan, 0, cpf)
+	if err != nil {
+		log.Printf("ℹ️ Cancelamento: cupom de retenção %s indisponível: %v", code, err)
+		return nil
+	}
+
+	fullAmount := sub.Amount
+	if sub.DiscountCyclesRemaining != nil && sub.FullAmountCents > 0 {
+		fullAmount = sub.FullAmountCents
+	}
+	discount := evaluation.Coupon.DiscountFor(fullAmount)
+	if discount <= 0 {
+		return nil
+	}
+
+	return &RetentionOffer{
+		CouponCode:          evaluation.Coupon.Code,
+		DiscountAmountCents: discount,
+		FinalAmountCents:    fullAmount - discount,
+		DurationCycles:      evaluation.DurationCycles,
+		coupon:              evaluation.Coupon,
+	}
+}
+
+func (uc *CancelSubscriptionUseCase) retain(ctx context.Context, input CancelSubscriptionInput, customer *entity.Customer, sub *entity.Subscription, offer *RetentionOffer) (*CancelSubscriptionOutput, error) {
+	if err := uc.Gateway.UpdateSubscriptionValue(sub.PaymentMethodID, offer.FinalAmountCents); err != nil {
+		return nil, &TechnicalError{Code: "GATEWAY_ERROR", Message: fmt.Sprintf("falha ao aplicar desconto de retenção: %v", err)}
+	}
+
+	discount := entity.RetentionDiscount{
+		AmountCents:     offer.FinalAmountCents,
+		FullAmountCents: offer.FinalAmountCents + offer.DiscountAmountCents,
+	}
+	if offer.DurationCycles > 0 {
+		cycles := offer.DurationCycles
+		discount.Cycles = &cycles
+	}
+
+	cancellation := entity.NewSubscriptionCancellation(sub, input.Reason, input.Comment, entity.CancellationRetained)
+	cancellation.RetentionCouponCode = offer.CouponCode
+	if err := uc.CancelRepo.ApplyRetention(ctx, cancellation, discount); err != nil {
+		log.Printf("❌ Cancelamento: Asaas já cobra %d centavos na assinatura %s, mas o banco não foi alterado: %v", offer.FinalAmountCents, sub.PaymentMethodID, err)
+		return nil, &TechnicalError{Code: "DATABASE_ERROR", Message: fmt.Sprintf("falha ao gravar retenção: %v", err)}
+	}
+
+	if couponTracker != nil {
+		if err := couponTracker.TrackSale(ctx, CouponSaleRecord{
+			CouponCode:          offer.CouponCode,
+			SellerName:          offer.coupon.SellerName,
+			CustomerID:          customer.ID,
+			CustomerCPF:         customer.CPF,
+			SubscriptionID:      sub.ID,
+			PlanID:              sub.PlanID,
+			DiscountType:        offer.coupon.DiscountType,
+			DurationCycles:      offer.DurationCycles,
+			OriginalAmountCents: discount.FullAmountCents,
+			DiscountPercent:     offer.coupon.DiscountPercent,
+			DiscountAmountCents: offer.DiscountAmountCents,
+			FinalAmountCents:    offer.FinalAmountCents,
+		}); err != nil {
+			log.Printf("⚠️ Cancelamento: falha ao registrar uso do cupom de retenção %s: %v", offer.CouponCode, err)
+		}
+	}
+
+	log.Printf("🤝 Cancelamento: customer %s retido com cupom %s (%d -> %d centavos)", customer.ID, offer.CouponCode, discount.FullAmountCents, offer.FinalAmountCents)
+	return &CancelSubscriptionOutput{Status: CancellationStatusRetained, RetentionOffer: offer}, nil
+}
+
+// cancel remove a assinatura no Asaas para não gerar novas cobranças. Assinatura em atraso é
+// cancelada na hora: o ciclo em aberto não foi pago.
+func (uc *CancelSubscriptionUseCase) cancel(ctx context.Context, input CancelSubscriptionInput, customer *entity.Customer, sub *entity.Subscription, plan *entity.Plan, status string) (*CancelSubscriptionOutput, error) {
+	now := time.Now()
+	immediate := input.Immediate || status == SubscriptionStatusPastDue
+
+	if !immediate {
+		if sub.CancelAt != nil {
+			// Repetição de um pedido cuja remoção no Asaas pode ter falhado: conclui a remoção.
+			if err := uc.deleteGatewaySubscription(sub); err != nil {
+				return nil, err
+			}
+			return nil, &DomainError{Code: "CANCELLATION_ALREADY_SCHEDULED", Message: "o cancelamento já está agendado para o fim do ciclo"}
+		}
+
+		anchor := sub.NextBillingDate
+		if anchor.IsZero() {
+			anchor = sub.CreatedAt
+		}
+		_, accessUntil := plan.CurrentCycle(anchor, now)
+
+		// cancel_at é gravado antes da remoção no Asaas: o SUBSCRIPTION_DELETED que ela dispara
+		// encontra o agendamento e não encerra o ciclo já pago.
+		cancellation := entity.NewSubscriptionCancellation(sub, input.Reason, input.Comment, entity.CancellationScheduled)
+		cancellation.EffectiveAt = &accessUntil
+		if err := uc.CancelRepo.ScheduleCancellation(ctx, cancellation); err != nil {
+			return nil, &TechnicalError{Code: "DATABASE_ERROR", Message: fmt.Sprintf("falha ao agendar cancelamento: %v", err)}
+		}
+
+		if err := uc.deleteGatewaySubscription(sub); err != nil {
+			if clearErr := uc.CancelRepo.UnscheduleCancellation(ctx, cancellation); clearErr != nil {
+				log.Printf("❌ Cancelamento: assinatura %s agendada mas ainda ativa no Asaas; repetir o pedido conclui a remoção: %v", sub.PaymentMethodID, clearErr)
+			}
+			return nil, err
+		}
+
+		log.Printf("📅 Cancelamento: customer %s agendado para %s (motivo=%s)", customer.ID, accessUntil.Format("2006-01-02"), cancellation.Reason)
+		uc.sendConfirmation(customer, plan, accessUntil)
+		return &CancelSubscriptionOutput{Status: CancellationStatusScheduled, AccessUntil: &accessUntil}, nil
+	}
+
+	if err := uc.deleteGatewaySubscription(sub); err != nil {
+		return nil, err
+	}
+
+	if _, err := uc.Lifecycle.Execute(ctx, SubscriptionLifecycleInput{
+		CustomerID:            customer.ID,
+		Event:                 "SUBSCRIPTION_DELETED",
+		GatewaySubscriptionID: sub.PaymentMethodID,
+		Origin:                LifecycleOriginCustomerCancellation,
+	}); err != nil {
+		return nil, &TechnicalError{Code: "LIFECYCLE_ERROR", Message: fmt.Sprintf("falha ao cancelar assinatura: %v", err)}
+	}
+
+	cancellation := entity.NewSubscriptionCancellation(sub, input.Reason, input.Comment, entity.CancellationCanceled)
+	cancellation.EffectiveAt = &now
+	if err := uc.CancelRepo.Save(ctx, cancellation); err != nil {
+		log.Printf("⚠️ Cancelamento: assinatura do customer %s cancelada, mas o motivo não foi registrado: %v", customer.ID, err)
+	}
+
+	log.Printf("🛑 Cancelamento: customer %s cancelado na hora (motivo=%s)", customer.ID, cancellation.Reason)
+	uc.sendConfirmation(customer, plan, now)
+	return &CancelSubscriptionOutput{Status: CancellationStatusCanceled, AccessUntil: &now}, nil
+}
+
+// deleteGatewaySubscription remove a assinatura no Asaas. Assinatura que já não existe lá
+// (pedido repetido ou cancelamento agendado antes) conta como removida.
+func (uc *CancelSubscriptionUseCase) deleteGatewaySubscription(sub *entity.Subscription) error {
+	if strings.TrimSpace(sub.PaymentMethodID) == "" {
+		return nil
+	}
+	if err := uc.Gateway.DeleteSubscription(sub.PaymentMethodID); err != nil && !errors.Is(err, asaas.ErrNotFound) {
+		return &TechnicalError{Code: "GATEWAY_ERROR", Message: fmt.Sprintf("falha ao cancelar assinatura no Asaas: %v", err)}
+	}
+	return nil
+}
+
+func (uc *CancelSubscriptionUseCase) sendConfirmation(customer *entity.Customer, plan *entity.Plan, accessUntil time.Time) {
+	if uc.EmailService == nil || strings.TrimSpace(customer.Email) == "" {
+		return
+	}
+	if err := uc.EmailService.SendCancellationEmail(customer.Name, customer.Email, plan.Name, accessUntil); err != nil {
+		log.Printf("⚠️ Cancelamento: falha ao enviar confirmação para %s (não bloqueia): %v", customer.Email, err)
+	}
+}
+
+func cpfDigits(cpf string) string {
+	var b strings.Builder
+	for _, r := range cpf {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,575 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/infra/integration/asaas"
+	"github.com/xavierca1/ligue-payments/internal/infra/integration/docuseal"
+	"github.com/xavierca1/ligue-payments/internal/infra/queue"
+)
+
+// PlanChangeChargeReferencePrefix marca no externalReference as cobranças avulsas de pró-rata;
+// os webhooks delas não ativam nem alteram o status da assinatura.
+const PlanChangeChargeReferencePrefix = "plan_change:"
+
+const (
+	minGatewayChargeCents   = 500 // menor cobrança aceita pelo Asaas (R$ 5,00)
+	planChangeChargeDueDays = 3
+)
+
+// PlanChangeGateway cobra o pró-rata e ajusta o valor da assinatura no Asaas.
+type PlanChangeGateway interface {
+	SubscriptionValueUpdater
+	CreatePayment(input asaas.CreatePaymentInput) (*asaas.PaymentOutput, error)
+	FindPaymentByExternalReference(externalReference string) (*asaas.PaymentOutput, error)
+	DeletePayment(paymentID string) error
+}
+
+// SubscriptionCouponFinder devolve o cupom em vigor na assinatura (nil se não houver).
+type SubscriptionCouponFinder interface {
+	FindSubscriptionCoupon(ctx context.Context, subscriptionID string) (*CouponSaleRecord, error)
+}
+
+// PlanChangePublisher reprovisiona o beneficiário no provedor com o plano novo.
+type PlanChangePublisher interface {
+	PublishActivation(ctx context.Context, payload queue.ActivationPayload) error
+	PublishDeactivation(ctx context.Context, payload queue.DeactivationPayload) error
+	PublishUpdate(ctx context.Context, payload queue.ActivationPayload) error
+}
+
+type ChangePlanInterface interface {
+	Execute(ctx context.Context, input ChangePlanInput) (*ChangePlanOutput, error)
+	Abandon(ctx context.Context, customerID string) (*entity.PlanChange, error)
+}
+
+type ChangePlanInput struct {
+	CustomerID string `json:"-"`
+	PlanID     string `json:"plan_id"`
+	Preview    bool   `json:"preview"` // só calcula o pró-rata, sem alterar nada
+}
+
+type ChangePlanOutput struct {
+	ChangeID        string    `json:"change_id,omitempty"`
+	CustomerID      string    `json:"customer_id"`
+	FromPlanID      string    `json:"from_plan_id"`
+	ToPlanID        string    `json:"to_plan_id"`
+	FromAmountCents int       `json:"from_amount_cents"`
+	ToAmountCents   int       `json:"to_amount_cents"`
+	NextAmountCents int       `json:"next_amount_cents"`
+	ProratedCents   int       `json:"prorated_cents"`        // > 0 cobrado agora, < 0 crédito na próxima cobrança
+	CouponCode      string    `json:"coupon_code,omitempty"` // cupom permanente mantido no plano novo
+	CycleDays       int       `json:"cycle_days"`
+	RemainingDays   int       `json:"remaining_days"`
+	NextBillingDate time.Time `json:"next_billing_date"`
+	Preview         bool      `json:"preview"`
+
+	ChargeID         string `json:"charge_id,omitempty"`
+	ChargeInvoiceURL string `json:"charge_invoice_url,omitempty"`
+
+	AddendumURL          string `json:"addendum_url,omitempty"`           // PDF do aditivo no storage
+	AddendumSubmissionID string `json:"addendum_submission_id,omitempty"` // submissão DocuSeal do aditivo
+}
+
+// ChangePlanUseCase faz upgrade/downgrade de uma assinatura ativa: cobra a diferença
+// proporcional aos dias restantes do ciclo (ou credita na próxima cobrança), atualiza o
+// valor da assinatura no Asaas, troca o plano no banco, reprovisiona o beneficiário no
+// provedor e gera o aditivo de contrato.
+//
+// A troca é gravada como PENDING antes de qualquer chamada ao Asaas. Se algo falhar no
+// meio, a próxima tentativa para o mesmo plano retoma essa troca: reaproveita a cobrança
+// do pró-rata já criada (pelo externalReference) em vez de cobrar de novo. Troca que não pode
+// mais ser concluída é abandonada (FAILED), pelo próprio fluxo ou pelo Abandon do admin.
+type ChangePlanUseCase struct {
+	SubRepo       entity.SubscriptionRepository
+	CustomerRepo  entity.CustomerRepositoryInterface
+	PlanRepo      entity.PlanRepositoryInterface
+	DependentRepo entity.DependentRepositoryInterface
+	ChangeRepo    entity.PlanChangeRepositoryInterface
+	Gateway       PlanChangeGateway
+	Queue         PlanChangePublisher
+
+	ContractUC      *GenerateContractUseCase             // optional; aditivo em PDF quando não há DocuSeal
+	DocuSealUseCase *GenerateContractWithDocuSealUseCase // optional; aditivo pelo DocuSeal
+	Coupons         SubscriptionCouponFinder             // optional; sem ele o cupom permanente não é mantido no plano novo
+}
+
+func NewChangePlanUseCase(
+	subRepo entity.SubscriptionRepository,
+	customerRepo entity.CustomerRepositoryInterface,
+	planRepo entity.PlanRepositoryInterface,
+	dependentRepo entity.DependentRepositoryInterface,
+	changeRepo entity.PlanChangeRepositoryInterface,
+	gateway PlanChangeGateway,
+	queue PlanChangePublisher,
+) *ChangePlanUseCase {
+	return &ChangePlanUseCase{
+		SubRepo:       subRepo,
+		CustomerRepo:  customerRepo,
+		PlanRepo:      planRepo,
+		DependentRepo: dependentRepo,
+		ChangeRepo:    changeRepo,
+		Gateway:       gateway,
+		Queue:         queue,
+	}
+}
+
+func (uc *ChangePlanUseCase) Execute(ctx context.Context, input ChangePlanInput) (*ChangePlanOutput, error) {
+	input.PlanID = strings.TrimSpace(input.PlanID)
+	if input.PlanID == "" {
+		return nil, &DomainError{Code: "MISSING_FIELDS", Message: "plan_id é obrigatório"}
+	}
+
+	customer, err := uc.CustomerRepo.FindByID(ctx, input.CustomerID)
+	if err != nil {
+		return nil, &DomainError{Code: "CUSTOMER_NOT_FOUND", Message: "cliente não encontrado"}
+	}
+
+	sub, err := uc.SubRepo.FindLastByCustomerID(ctx, customer.ID)
+	if err != nil {
+		return nil, &DomainError{Code: "SUBSCRIPTION_NOT_FOUND", Message: "cliente não possui assinatura"}
+	}
+	if !strings.EqualFold(strings.TrimSpace(sub.Status), SubscriptionStatusActive) {
+		return nil, &DomainError{Code: "SUBSCRIPTION_NOT_ACTIVE", Message: "só assinaturas ativas podem trocar de plano"}
+	}
+	if sub.CancelAt != nil {
+		return nil, &DomainError{Code: "SUBSCRIPTION_CANCELLATION_SCHEDULED", Message: "a assinatura tem cancelamento agendado e não pode trocar de plano"}
+	}
+	if sub.PlanID == input.PlanID {
+		return nil, &DomainError{Code: "SAME_PLAN", Message: "a assinatura já está neste plano"}
+	}
+
+	currentPlan, err := uc.PlanRepo.FindByID(ctx, sub.PlanID)
+	if err != nil {
+		return nil, &TechnicalError{Code: "DATABASE_ERROR", Message: fmt.Sprintf("falha ao buscar plano atual (%s): %v", sub.PlanID, err)}
+	}
+
+	newPlan, err := uc.PlanRepo.FindByID(ctx, input.PlanID)
+	if err != nil {
+		return nil, &DomainError{Code: "PLAN_NOT_FOUND", Message: "plano inválido: " + err.Error()}
+	}
+	if newPlan.Inactive {
+		return nil, &DomainError{Code: "PLAN_UNAVAILABLE", Message: "plano não está mais disponível para venda"}
+	}
+	if newPlan.ProductID != currentPlan.ProductID {
+		return nil, &DomainError{Code: "PLAN_PRODUCT_MISMATCH", Message: "o novo plano é de outro produto"}
+	}
+	if newPlan.Cycle() != currentPlan.Cycle() {
+		return nil, &DomainError{Code: "BILLING_CYCLE_MISMATCH", Message: "a troca de plano não muda o ciclo de cobrança; para isso é preciso uma nova assinatura"}
+	}
+
+	var dependents []*entity.Dependent
+	if uc.DependentRepo != nil {
+		dependents, err = uc.DependentRepo.FindByCustomerID(ctx, customer.ID)
+		if err != nil {
+			return nil, &TechnicalError{Code: "DATABASE_ERROR", Message: fmt.Sprintf("falha ao buscar dependentes: %v", err)}
+		}
+	}
+	if !newPlan.AcceptsDependents(len(dependents)) {
+		return nil, &DomainError{
+			Code:    "DEPENDENTS_LIMIT",
+			Message: fmt.Sprintf("o plano permite no máximo %d dependente(s)", *newPlan.MaxDependents),
+		}
+	}
+	// Parentescos e idades aceitos mudam de plano para plano; dependente recusado pelo plano
+	// novo ficaria provisionado sem direito. Os campos apontam o ID do dependente gravado.
+	if eligibilityErrors := ValidateDependentEligibility(newPlan, customer.CPF, dependents, func(i int) string {
+		return fmt.Sprintf("dependents[%s].", dependents[i].ID)
+	}); len(eligibilityErrors) > 0 {
+		return nil, newValidationError(eligibilityErrors)
+	}
+
+	// Desconto temporário (cupom de N ciclos ou crédito de outra troca) usa o mesmo contador
+	// do crédito de downgrade; trocar agora perderia um dos dois.
+	if sub.DiscountCyclesRemaining != nil && *sub.DiscountCyclesRemaining > 0 {
+		return nil, &DomainError{
+			Code:    "DISCOUNT_IN_PROGRESS",
+			Message: "a assinatura tem um desconto temporário em andamento; a troca de plano fica disponível quando ele terminar",
+		}
+	}
+
+	coupon, err := uc.permanentCoupon(ctx, sub)
+	if err != nil {
+		return nil, err
+	}
+
+	toAmount := newPlan.PriceFor(len(dependents))
+	if coupon != nil {
+		toAmount -= coupon.DiscountFor(toAmount)
+	}
+
+	now := time.Now()
+	anchor := sub.NextBillingDate
+	if anchor.IsZero() {
+		anchor = sub.CreatedAt
+	}
+	cycleStart, cycleEnd := currentPlan.CurrentCycle(anchor, now)
+
+	change := entity.NewPlanChange(sub, newPlan.ID, toAmount)
+	if coupon != nil {
+		change.CouponCode = coupon.Code
+	}
+	proration := entity.ProratePlanChange(change.FromAmountCents, change.ToAmountCents, cycleStart, cycleEnd, now)
+	applyProration(change, proration.AmountCents)
+	if change.CreditCents() > 0 {
+		change.CreditFromDate = cycleEnd
+	}
+
+	if input.Preview {
+		return planChangeOutput(customer, change, proration, cycleEnd, true), nil
+	}
+
+	change, resumed, err := uc.startChange(ctx, sub, change)
+	if err != nil {
+		return nil, err
+	}
+	output := planChangeOutput(customer, change, proration, cycleEnd, false)
+
+	if change.ProratedCents > 0 {
+		if strings.TrimSpace(customer.GatewayID) == "" {
+			return nil, &TechnicalError{Code: "GATEWAY_CUSTOMER_MISSING", Message: "cliente sem cadastro no Asaas para cobrar o pró-rata"}
+		}
+		charge, err := uc.prorationCharge(ctx, sub, change, resumed, asaas.CreatePaymentInput{
+			CustomerID:        customer.GatewayID,
+			ValueCents:        change.ProratedCents,
+			DueDate:           now.AddDate(0, 0, planChangeChargeDueDays),
+			Description:       fmt.Sprintf("Troca de plano: %s para %s (%d dia(s) restantes do ciclo)", currentPlan.Name, newPlan.Name, proration.RemainingDays),
+			ExternalReference: PlanChangeChargeReferencePrefix + change.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		output.ChargeID = charge.ID
+		output.ChargeInvoiceURL = charge.InvoiceURL
+	}
+
+	// Idempotente: um retry grava o mesmo valor.
+	if err := uc.Gateway.UpdateSubscriptionValue(sub.PaymentMethodID, change.NextAmountCents); err != nil {
+		return nil, &TechnicalError{Code: "GATEWAY_ERROR", Message: fmt.Sprintf("falha ao atualizar valor da assinatura (troca %s pendente): %v", change.ID, err)}
+	}
+
+	if err := uc.ChangeRepo.ApplyPlanChange(ctx, change); err != nil {
+		if errors.Is(err, entity.ErrPlanChangeConflict) {
+			// A assinatura mudou (cancelada, outro plano) depois do Asaas: a troca não tem mais como ser aplicada.
+			if abandonErr := uc.abandonChange(ctx, sub, change, "assinatura alterada durante a troca"); abandonErr != nil {
+				return nil, abandonErr
+			}
+			return nil, &DomainError{Code: "PLAN_CHANGE_CONFLICT", Message: "a assinatura foi alterada durante a troca; tente novamente"}
+		}
+		log.Printf("❌ Troca de plano: Asaas já cobra %d centavos na assinatura %s, mas a troca %s segue pendente no banco: %v", change.NextAmountCents, sub.PaymentMethodID, change.ID, err)
+		return nil, &TechnicalError{Code: "DATABASE_ERROR", Message: fmt.Sprintf("falha ao gravar troca de plano: %v", err)}
+	}
+	output.ChangeID = change.ID
+
+	log.Printf("🔁 Troca de plano: customer %s %s -> %s (pró-rata=%d, próxima cobrança=%d)", customer.ID, currentPlan.Name, newPlan.Name, change.ProratedCents, change.NextAmountCents)
+
+	uc.reprovision(ctx, customer, sub, currentPlan, newPlan, dependents)
+	uc.generateAddendum(ctx, customer, newPlan, sub, output)
+
+	return output, nil
+}
+
+// permanentCoupon retorna o cupom sem limite de ciclos da assinatura, que continua valendo no
+// plano novo. Cupons de N ciclos já encerrados não contam.
+func (uc *ChangePlanUseCase) permanentCoupon(ctx context.Context, sub *entity.Subscription) (*CouponDetails, error) {
+	if uc.Coupons == nil {
+		return nil, nil
+	}
+	sale, err := uc.Coupons.FindSubscriptionCoupon(ctx, sub.ID)
+	if err != nil {
+		return nil, &TechnicalError{Code: "DATABASE_ERROR", Message: fmt.Sprintf("falha ao buscar cupom da assinatura: %v", err)}
+	}
+	if sale == nil || sale.DurationCycles > 0 {
+		return nil, nil
+	}
+
+	discountAmount := 0
+	if strings.EqualFold(sale.DiscountType, CouponDiscountFixed) {
+		discountAmount = sale.DiscountAmountCents
+	}
+	return &CouponDetails{
+		Code:                sale.CouponCode,
+		SellerName:          sale.SellerName,
+		DiscountType:        strings.ToUpper(sale.DiscountType),
+		DiscountPercent:     sale.DiscountPercent,
+		DiscountAmountCents: discountAmount,
+	}, nil
+}
+
+// startChange grava a troca como PENDING ou, se a assinatura já tem uma troca pendente para o
+// mesmo plano (tentativa anterior interrompida), retoma essa troca com os valores gravados.
+func (uc *ChangePlanUseCase) startChange(ctx context.Context, sub *entity.Subscription, change *entity.PlanChange) (*entity.PlanChange, bool, error) {
+	pending, err := uc.ChangeRepo.FindPendingPlanChange(ctx, sub.ID)
+	if err != nil {
+		return nil, false, &TechnicalError{Code: "DATABASE_ERROR", Message: fmt.Sprintf("falha ao buscar troca de plano pendente: %v", err)}
+	}
+	if pending != nil {
+		if pending.FromPlanID != change.FromPlanID || pending.ToPlanID != change.ToPlanID {
+			return nil, false, &DomainError{Code: "PLAN_CHANGE_PENDING", Message: "a assinatura tem uma troca para outro plano em andamento"}
+		}
+		log.Printf("🔁 Troca de plano: retomando a troca pendente %s da assinatura %s", pending.ID, sub.ID)
+		return pending, true, nil
+	}
+
+	if err := uc.ChangeRepo.CreatePendingPlanChange(ctx, change); err != nil {
+		if errors.Is(err, entity.ErrPlanChangeConflict) {
+			return nil, false, &DomainError{Code: "PLAN_CHANGE_CONFLICT", Message: "outra troca de plano está em andamento; tente novamente"}
+		}
+		return nil, false, &TechnicalError{Code: "DATABASE_ERROR", Message: fmt.Sprintf("falha ao registrar troca de plano: %v", err)}
+	}
+	return change, false, nil
+}
+
+// prorationCharge cria a cobrança do pró-rata. Ao retomar uma troca, procura antes a cobrança
+// já criada pelo externalReference, para o retry não cobrar duas vezes. Cobrança recusada
+// abandona a troca, para o cliente poder pedir outro plano.
+func (uc *ChangePlanUseCase) prorationCharge(ctx context.Context, sub *entity.Subscription, change *entity.PlanChange, resumed bool, input asaas.CreatePaymentInput) (*asaas.PaymentOutput, error) {
+	var charge *asaas.PaymentOutput
+	if resumed {
+		existing, err := uc.Gateway.FindPaymentByExternalReference(input.ExternalReference)
+		if err != nil {
+			return nil, &TechnicalError{Code: "GATEWAY_ERROR", Message: fmt.Sprintf("falha ao buscar cobrança do pró-rata: %v", err)}
+		}
+		charge = existing
+	}
+
+	if charge == nil {
+		created, err := uc.Gateway.CreatePayment(input)
+		if err != nil {
+			if abandonErr := uc.abandonChange(ctx, sub, change, "cobrança do pró-rata recusada: "+err.Error()); abandonErr != nil {
+				log.Printf("⚠️ Troca de plano: troca %s segue pendente: %v", change.ID, abandonErr)
+			}
+			return nil, &TechnicalError{Code: "GATEWAY_ERROR", Message: fmt.Sprintf("falha ao cobrar pró-rata: %v", err)}
+		}
+		charge = created
+	}
+
+	if change.ChargeID != charge.ID {
+		if err := uc.ChangeRepo.SetPlanChangeCharge(ctx, change.ID, charge.ID); err != nil {
+			log.Printf("❌ Troca de plano: cobrança %s criada, mas não registrada na troca %s: %v", charge.ID, change.ID, err)
+			return nil, &TechnicalError{Code: "DATABASE_ERROR", Message: fmt.Sprintf("falha ao registrar cobrança do pró-rata: %v", err)}
+		}
+		change.ChargeID = charge.ID
+	}
+	return charge, nil
+}
+
+// Abandon libera a assinatura de uma troca PENDING que não vai ser concluída (admin).
+func (uc *ChangePlanUseCase) Abandon(ctx context.Context, customerID string) (*entity.PlanChange, error) {
+	sub, err := uc.SubRepo.FindLastByCustomerID(ctx, customerID)
+	if err != nil {
+		return nil, &DomainError{Code: "SUBSCRIPTION_NOT_FOUND", Message: "cliente não possui assinatura"}
+	}
+
+	change, err := uc.ChangeRepo.FindPendingPlanChange(ctx, sub.ID)
+	if err != nil {
+		return nil, &TechnicalError{Code: "DATABASE_ERROR", Message: fmt.Sprintf("falha ao buscar troca de plano pendente: %v", err)}
+	}
+	if change == nil {
+		return nil, &DomainError{Code: "PLAN_CHANGE_NOT_FOUND", Message: "a assinatura não tem troca de plano pendente"}
+	}
+
+	if err := uc.abandonChange(ctx, sub, change, "abandonada pelo admin"); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// abandonChange desfaz no Asaas o que a troca já fez e a marca como FAILED: o valor da
+// assinatura volta ao do banco (a troca não foi aplicada) e a cobrança do pró-rata é
+// cancelada. Se algo falhar, a troca segue PENDING para nova tentativa.
+func (uc *ChangePlanUseCase) abandonChange(ctx context.Context, sub *entity.Subscription, change *entity.PlanChange, reason string) error {
+	current, err := uc.SubRepo.FindLastByCustomerID(ctx, sub.CustomerID)
+	if err != nil {
+		return &TechnicalError{Code: "DATABASE_ERROR", Message: fmt.Sprintf("falha ao reler assinatura para abandonar a troca %s: %v", change.ID, err)}
+	}
+	if current.ID == sub.ID && strings.TrimSpace(current.PaymentMethodID) != "" {
+		if err := uc.Gateway.UpdateSubscriptionValue(current.PaymentMethodID, current.Amount); err != nil && !errors.Is(err, asaas.ErrNotFound) {
+			return &TechnicalError{Code: "GATEWAY_ERROR", Message: fmt.Sprintf("falha ao reverter valor da assinatura (troca %s pendente): %v", change.ID, err)}
+		}
+	}
+
+	if change.ProratedCents > 0 {
+		chargeID := change.ChargeID
+		if chargeID == "" {
+			// A cobrança pode ter sido criada sem a resposta chegar (timeout).
+			existing, err := uc.Gateway.FindPaymentByExternalReference(PlanChangeChargeReferencePrefix + change.ID)
+			if err != nil {
+				return &TechnicalError{Code: "GATEWAY_ERROR", Message: fmt.Sprintf("falha ao buscar cobrança do pró-rata (troca %s pendente): %v", change.ID, err)}
+			}
+			if existing != nil {
+				chargeID = existing.ID
+			}
+		}
+		if chargeID != "" {
+			if err := uc.Gateway.DeletePayment(chargeID); err != nil && !errors.Is(err, asaas.ErrNotFound) {
+				return &TechnicalError{Code: "GATEWAY_ERROR", Message: fmt.Sprintf("falha ao cancelar cobrança %s do pró-rata (troca %s pendente): %v", chargeID, change.ID, err)}
+			}
+		}
+	}
+
+	if err := uc.ChangeRepo.FailPlanChange(ctx, change.ID, reason); err != nil && !errors.Is(err, entity.ErrPlanChangeConflict) {
+		return &TechnicalError{Code: "DATABASE_ERROR", Message: fmt.Sprintf("falha ao abandonar troca de plano %s: %v", change.ID, err)}
+	}
+	change.Status = entity.PlanChangeFailed
+
+	log.Printf("↩️ Troca de plano: troca %s da assinatura %s abandonada (%s)", change.ID, sub.ID, reason)
+	return nil
+}
+
+func planChangeOutput(customer *entity.Customer, change *entity.PlanChange, proration entity.PlanChangeProration, nextBilling time.Time, preview bool) *ChangePlanOutput {
+	return &ChangePlanOutput{
+		CustomerID:      customer.ID,
+		FromPlanID:      change.FromPlanID,
+		ToPlanID:        change.ToPlanID,
+		FromAmountCents: change.FromAmountCents,
+		ToAmountCents:   change.ToAmountCents,
+		NextAmountCents: change.NextAmountCents,
+		ProratedCents:   change.ProratedCents,
+		CouponCode:      change.CouponCode,
+		CycleDays:       proration.CycleDays,
+		RemainingDays:   proration.RemainingDays,
+		NextBillingDate: nextBilling,
+		Preview:         preview,
+	}
+}
+
+// applyProration transforma o pró-rata em cobrança ou crédito. Cobranças abaixo do mínimo do
+// Asaas são dispensadas; o crédito é limitado para a próxima cobrança ficar acima desse mínimo.
+func applyProration(change *entity.PlanChange, prorated int) {
+	switch {
+	case prorated >= minGatewayChargeCents:
+		change.ProratedCents = prorated
+	case prorated < 0:
+		credit := -prorated
+		if maxCredit := change.ToAmountCents - minGatewayChargeCents; credit > maxCredit {
+			credit = max(maxCredit, 0)
+		}
+		change.ProratedCents = -credit
+		change.NextAmountCents = change.ToAmountCents - credit
+	default:
+		change.ProratedCents = 0
+	}
+}
+
+// reprovision envia o plano novo ao provedor. No mesmo provedor basta a atualização cadastral
+// com o novo ProviderPlanCode; trocando de provedor, desativa no antigo e ativa no novo.
+// Falhas não desfazem a troca (o pagamento já foi ajustado) e ficam no log para reenvio.
+func (uc *ChangePlanUseCase) reprovision(ctx context.Context, customer *entity.Customer, sub *entity.Subscription, currentPlan, newPlan *entity.Plan, dependents []*entity.Dependent) {
+	if uc.Queue == nil {
+		log.Printf("⚠️ Troca de plano: fila não configurada; provedor do customer %s não foi atualizado", customer.ID)
+		return
+	}
+
+	payload := beneficiaryPayload(customer, newPlan, dependents, "PLAN_CHANGE")
+
+	if strings.EqualFold(strings.TrimSpace(currentPlan.Provider), strings.TrimSpace(newPlan.Provider)) {
+		if err := uc.Queue.PublishUpdate(ctx, withUpdateEnrollmentDates(payload, sub, dependents)); err != nil {
+			log.Printf("⚠️ Troca de plano: falha ao publicar atualização do beneficiário %s: %v", customer.ID, err)
+		}
+		return
+	}
+
+	previous := beneficiaryPayload(customer, currentPlan, dependents, "PLAN_CHANGE")
+	deactivation := withEnrollmentDates(deactivationPayload(previous, "PLAN_CHANGE"), sub, dependents)
+	if err := uc.Queue.PublishDeactivation(ctx, deactivation); err != nil {
+		log.Printf("⚠️ Troca de plano: falha ao publicar desativação no %s (customer %s): %v", currentPlan.Provider, customer.ID, err)
+	}
+	if err := uc.Queue.PublishActivation(ctx, payload); err != nil {
+		log.Printf("⚠️ Troca de plano: falha ao publicar ativação no %s (customer %s): %v", newPlan.Provider, customer.ID, err)
+	}
+}
+
+// generateAddendum gera o aditivo com o plano novo pelo mesmo fluxo do contrato de adesão:
+// DocuSeal quando configurado, senão o PDF. Falhas não bloqueiam a troca.
+func (uc *ChangePlanUseCase) generateAddendum(ctx context.Context, customer *entity.Customer, plan *entity.Plan, sub *entity.Subscription, output *ChangePlanOutput) {
+	produto := plan.Name + " (aditivo de troca de plano)"
+	valor := formatBRL(output.ToAmountCents)
+	pagamento := humanizePaymentMethod(sub.PaymentMethod)
+
+	if uc.DocuSealUseCase != nil {
+		submissionUUID, err := uc.DocuSealUseCase.ExecuteAutomatic(ctx, DocuSealContractInput{
+			TemplateName:  docuseal.GetTemplateFromPlanName(plan.Name),
+			CustomerID:    customer.ID,
+			Nome:          customer.Name,
+			Email:         customer.Email,
+			CPF:           customer.CPF,
+			PlanName:      plan.Name,
+			Produto:       produto,
+			Valor:         valor,
+			Pagamento:     pagamento,
+			Periodicidade: humanizeBillingCycle(plan.Cycle()),
+			Nascimento:    customer.BirthDate,
+			Sexo:          genderLabel(customer.Gender),
+			Civil:         customer.MaritalStatus,
+			Celular:       customer.Phone,
+			Endereco:      customer.Address.Street,
+			Numero:        customer.Address.Number,
+			Bairro:        customer.Address.District,
+			Cidade:        customer.Address.City,
+			UF:            customer.Address.State,
+			CEP:           customer.Address.ZipCode,
+		})
+		if err != nil {
+			log.Printf("⚠️ Falha ao gerar aditivo DocuSeal (não bloqueia troca de plano): %v", err)
+			return
+		}
+		output.AddendumSubmissionID = submissionUUID
+		return
+	}
+
+	if uc.ContractUC == nil {
+		return
+	}
+
+	contractInput := buildContractInput(customer, plan)
+	contractInput.Produto = produto
+	contractInput.Valor = valor
+	contractInput.Pagamento = pagamento
+	result, err := uc.ContractUC.Execute(ctx, contractInput)
+	if err != nil {
+		log.Printf("⚠️ Falha ao gerar aditivo em PDF (não bloqueia troca de plano): %v", err)
+		return
+	}
+	output.AddendumURL = result.PublicURL
+}
+
+// beneficiaryPayload monta o titular e os dependentes no formato das mensagens do provedor.
+func beneficiaryPayload(customer *entity.Customer, plan *entity.Plan, dependents []*entity.Dependent, origin string) queue.ActivationPayload {
+	providerPlanCode := strings.TrimSpace(plan.ProviderPlanCode)
+	if providerPlanCode == "" {
+		providerPlanCode = strings.TrimSpace(plan.Name)
+	}
+
+	payload := queue.ActivationPayload{
+		CustomerID:       customer.ID,
+		PlanID:           plan.ID,
+		ProviderPlanCode: providerPlanCode,
+		Provider:         plan.Provider,
+		Origin:           origin,
+		Name:             customer.Name,
+		Email:            customer.Email,
+		CPF:              customer.CPF,
+		Phone:            customer.Phone,
+		BirthDate:        customer.BirthDate,
+		Gender:           genderLabel(customer.Gender),
+	}
+	for _, dep := range dependents {
+		if dep == nil {
+			continue
+		}
+		payload.Dependents = append(payload.Dependents, queue.DependentPayload{
+			Name:      dep.Name,
+			CPF:       dep.CPF,
+			BirthDate: dep.BirthDate,
+			Gender:    dep.Gender,
+			Kinship:   dep.Kinship,
+		})
+	}
+	return payload
+}
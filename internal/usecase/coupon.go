@@ -1,19 +1,86 @@
 package usecase
 
-import "context"
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/xavierca1/ligue-payments/internal/entity"
+)
+
+const (
+	CouponDiscountPercent = entity.CouponDiscountPercent
+	CouponDiscountFixed   = entity.CouponDiscountFixed
+)
 
 type CouponDetails struct {
-	Code            string
-	SellerName      string
-	DiscountPercent int
+	Code                string
+	SellerName          string
+	DiscountType        string // PERCENT ou FIXED
+	DiscountPercent     int
+	DiscountAmountCents int
+	PlanIDs             []string // vazio = todos os planos
+	ProductIDs          []string // vazio = todos os produtos
+	MaxRedemptions      int      // 0 = sem limite
+	MaxPerCPF           int      // 0 = sem limite
+	DurationCycles      int      // 0 = desconto em todas as cobranças
+}
+
+// DiscountFor calcula o desconto em centavos sobre o preço, nunca maior que o próprio preço.
+func (c *CouponDetails) DiscountFor(priceCents int) int {
+	discount := 0
+	switch c.DiscountType {
+	case CouponDiscountFixed:
+		discount = c.DiscountAmountCents
+	default:
+		discount = (priceCents * c.DiscountPercent) / 100
+	}
+
+	if discount < 0 {
+		return 0
+	}
+	if discount > priceCents {
+		return priceCents
+	}
+	return discount
+}
+
+// AppliesTo indica se o cupom vale para o plano. Com restrição de plano e de produto,
+// basta atender a uma delas.
+func (c *CouponDetails) AppliesTo(plan *entity.Plan) bool {
+	if len(c.PlanIDs) == 0 && len(c.ProductIDs) == 0 {
+		return true
+	}
+	if plan == nil {
+		return false
+	}
+	for _, id := range c.PlanIDs {
+		if strings.EqualFold(strings.TrimSpace(id), plan.ID) {
+			return true
+		}
+	}
+	for _, id := range c.ProductIDs {
+		if strings.EqualFold(strings.TrimSpace(id), plan.ProductID) {
+			return true
+		}
+	}
+	return false
+}
+
+type CouponRedemptions struct {
+	Total int
+	ByCPF int
 }
 
 type CouponSaleRecord struct {
 	CouponCode          string
 	SellerName          string
 	CustomerID          string
+	CustomerCPF         string
 	SubscriptionID      string
 	PlanID              string
+	DiscountType        string
+	DurationCycles      int
 	OriginalAmountCents int
 	DiscountPercent     int
 	DiscountAmountCents int
@@ -22,7 +89,13 @@ type CouponSaleRecord struct {
 
 type CouponTracker interface {
 	GetActiveCoupon(ctx context.Context, code string) (*CouponDetails, error)
+	// CountRedemptions conta as vendas não anuladas do cupom em coupon_sales, no total e para o CPF.
+	CountRedemptions(ctx context.Context, code, cpf string) (CouponRedemptions, error)
+	// TrackSale grava a venda conferindo os limites do cupom de forma atômica; estourado,
+	// retorna entity.ErrCouponExhausted ou entity.ErrCouponAlreadyUsed.
 	TrackSale(ctx context.Context, sale CouponSaleRecord) error
+	// VoidSales anula as vendas de uma assinatura que não chegou a ser paga.
+	VoidSales(ctx context.Context, subscriptionID string) error
 }
 
 var couponTracker CouponTracker
@@ -31,7 +104,20 @@ func SetCouponTracker(tracker CouponTracker) {
 	couponTracker = tracker
 }
 
-func ValidateCoupon(ctx context.Context, code string) (*CouponDetails, error) {
+// CouponEvaluation é o resultado das regras do cupom. Sem plano, os valores ficam zerados
+// e só vigência e limites de uso são verificados.
+type CouponEvaluation struct {
+	Coupon              *CouponDetails
+	OriginalAmountCents int
+	DiscountAmountCents int
+	FinalAmountCents    int
+	DurationCycles      int
+}
+
+// EvaluateCoupon concentra as regras de cupom usadas no checkout e em /coupons/validate:
+// vigência, escopo de plano/produto, limites global e por CPF e o valor do desconto, calculado
+// sobre o preço do ciclo com os dependentes informados (plan.PriceFor).
+func EvaluateCoupon(ctx context.Context, code string, plan *entity.Plan, dependents int, cpf string) (*CouponEvaluation, error) {
 	if couponTracker == nil {
 		return nil, &DomainError{
 			Code:    "COUPON_UNAVAILABLE",
@@ -39,5 +125,45 @@ func ValidateCoupon(ctx context.Context, code string) (*CouponDetails, error) {
 		}
 	}
 
-	return couponTracker.GetActiveCoupon(ctx, code)
+	coupon, err := couponTracker.GetActiveCoupon(ctx, code)
+	if err != nil || coupon == nil {
+		return nil, &DomainError{Code: "COUPON_INVALID", Message: "cupom inválido ou inativo"}
+	}
+
+	if plan != nil && !coupon.AppliesTo(plan) {
+		return nil, &DomainError{Code: "COUPON_NOT_APPLICABLE", Message: "cupom não é válido para este plano"}
+	}
+
+	if coupon.MaxRedemptions > 0 || coupon.MaxPerCPF > 0 {
+		redemptions, err := couponTracker.CountRedemptions(ctx, coupon.Code, cpf)
+		if err != nil {
+			return nil, &TechnicalError{Code: "DATABASE_ERROR", Message: "falha ao verificar o uso do cupom"}
+		}
+		if coupon.MaxRedemptions > 0 && redemptions.Total >= coupon.MaxRedemptions {
+			return nil, &DomainError{Code: "COUPON_EXHAUSTED", Message: "cupom esgotado"}
+		}
+		if coupon.MaxPerCPF > 0 && strings.TrimSpace(cpf) != "" && redemptions.ByCPF >= coupon.MaxPerCPF {
+			return nil, &DomainError{Code: "COUPON_ALREADY_USED", Message: "cupom já utilizado por este CPF"}
+		}
+	}
+
+	evaluation := &CouponEvaluation{Coupon: coupon, DurationCycles: coupon.DurationCycles}
+	if plan != nil {
+		evaluation.OriginalAmountCents = plan.PriceFor(dependents)
+		evaluation.DiscountAmountCents = coupon.DiscountFor(evaluation.OriginalAmountCents)
+		evaluation.FinalAmountCents = evaluation.OriginalAmountCents - evaluation.DiscountAmountCents
+	}
+	return evaluation, nil
+}
+
+// couponSaleError traduz a recusa do TrackSale nos mesmos códigos do EvaluateCoupon.
+func couponSaleError(err error) error {
+	switch {
+	case errors.Is(err, entity.ErrCouponExhausted):
+		return &DomainError{Code: "COUPON_EXHAUSTED", Message: "cupom esgotado"}
+	case errors.Is(err, entity.ErrCouponAlreadyUsed):
+		return &DomainError{Code: "COUPON_ALREADY_USED", Message: "cupom já utilizado por este CPF"}
+	default:
+		return &TechnicalError{Code: "DATABASE_ERROR", Message: "falha ao registrar o uso do cupom"}
+	}
 }
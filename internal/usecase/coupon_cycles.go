@@ -0,0 +1,61 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/xavierca1/ligue-payments/internal/entity"
+)
+
+// SubscriptionValueUpdater altera o valor de uma assinatura no gateway.
+type SubscriptionValueUpdater interface {
+	UpdateSubscriptionValue(gatewaySubscriptionID string, valueCents int) error
+}
+
+// CouponCyclesUseCase encerra descontos de cupom limitados às primeiras N cobranças:
+// cada pagamento confirmado consome um ciclo e, quando acabam, o valor cheio volta a
+// valer no Asaas e na assinatura local.
+type CouponCyclesUseCase struct {
+	Repo    entity.DiscountCycleRepositoryInterface
+	Gateway SubscriptionValueUpdater
+}
+
+func NewCouponCyclesUseCase(repo entity.DiscountCycleRepositoryInterface, gateway SubscriptionValueUpdater) *CouponCyclesUseCase {
+	return &CouponCyclesUseCase{Repo: repo, Gateway: gateway}
+}
+
+// RegisterPayment conta o pagamento contra o desconto da assinatura. Retornar erro faz o
+// webhook ser reprocessado; o mesmo pagamento não consome um segundo ciclo. O vencimento
+// impede que a cobrança do ciclo já pago consuma o crédito de uma troca de plano.
+func (uc *CouponCyclesUseCase) RegisterPayment(ctx context.Context, gatewaySubscriptionID, paymentID string, dueDate time.Time) error {
+	gatewaySubscriptionID = strings.TrimSpace(gatewaySubscriptionID)
+	paymentID = strings.TrimSpace(paymentID)
+	if gatewaySubscriptionID == "" || paymentID == "" {
+		return nil
+	}
+
+	state, err := uc.Repo.ConsumeDiscountCycle(ctx, gatewaySubscriptionID, paymentID, dueDate)
+	if err != nil {
+		return fmt.Errorf("falha ao consumir ciclo de desconto: %w", err)
+	}
+	if state == nil {
+		return nil
+	}
+	if state.CyclesRemaining > 0 {
+		log.Printf("🏷️ Cupom: %d cobrança(s) com desconto restante(s) na assinatura %s", state.CyclesRemaining, state.SubscriptionID)
+		return nil
+	}
+
+	if err := uc.Gateway.UpdateSubscriptionValue(gatewaySubscriptionID, state.FullAmountCents); err != nil {
+		return fmt.Errorf("falha ao restaurar valor cheio no gateway: %w", err)
+	}
+	if err := uc.Repo.RestoreFullAmount(ctx, state.SubscriptionID); err != nil {
+		return err
+	}
+
+	log.Printf("🏷️ Cupom: desconto encerrado, assinatura %s volta a %d centavos", state.SubscriptionID, state.FullAmountCents)
+	return nil
+}
@@ -25,6 +25,21 @@ func parseDependentGender(value string) (int, error) {
 	}
 }
 
+// dependentsFromInput monta os dependentes do checkout só para conferir a elegibilidade,
+// antes de o titular existir no banco.
+func dependentsFromInput(inputs []DependentInput) []*entity.Dependent {
+	dependents := make([]*entity.Dependent, 0, len(inputs))
+	for _, in := range inputs {
+		dependents = append(dependents, &entity.Dependent{
+			CPF:       in.CPF,
+			BirthDate: strings.TrimSpace(in.BirthDate),
+			Kinship:   entity.NormalizeKinship(in.Kinship),
+			Student:   in.Student,
+		})
+	}
+	return dependents
+}
+
 func NewCreateCustomerUseCase(
 	repo CustomerRepositoryInterface,
 	subRepo SubscriptionRepository,
@@ -45,6 +60,7 @@ func NewCreateCustomerUseCase(
 		EmailService:     emailService,
 		KommoService:     kommoService,
 		WelcomeBucketURL: welcomeBucketURL,
+		PixPolicy:        entity.NewPixExpirationPolicy(entity.DefaultPixExpirationWindow),
 		DependentRepo:    dependentRepo,
 	}
 }
@@ -54,14 +70,7 @@ func (uc *CreateCustomerUseCase) Execute(ctx context.Context, input CreateCustom
 	// 1. Validação de Input
 	validationErrors := ValidateCreateCustomerInput(input)
 	if len(validationErrors) > 0 {
-		errMsg := "validation failed: "
-		for _, e := range validationErrors {
-			errMsg += e.Field + " (" + e.Message + "), "
-		}
-		return nil, &DomainError{
-			Code:    "VALIDATION_ERROR",
-			Message: errMsg,
-		}
+		return nil, newValidationError(validationErrors)
 	}
 
 	// 2. Busca e Validação do Plano
@@ -73,39 +82,41 @@ func (uc *CreateCustomerUseCase) Execute(ctx context.Context, input CreateCustom
 		}
 	}
 
-	// 3. Lógica de Valores e Cupons
-	originalAmountCents := plan.PriceCents
+	if plan.Inactive {
+		return nil, &DomainError{Code: "PLAN_UNAVAILABLE", Message: "plano não está mais disponível para venda"}
+	}
+	if !plan.AcceptsDependents(len(input.Dependents)) {
+		return nil, &DomainError{
+			Code:    "DEPENDENTS_LIMIT",
+			Message: fmt.Sprintf("o plano permite no máximo %d dependente(s)", *plan.MaxDependents),
+		}
+	}
+	if eligibilityErrors := ValidateDependentEligibility(plan, input.CPF, dependentsFromInput(input.Dependents), func(i int) string {
+		return fmt.Sprintf("dependents[%d].", i)
+	}); len(eligibilityErrors) > 0 {
+		return nil, newValidationError(eligibilityErrors)
+	}
+
+	// 3. Lógica de Valores e Cupons (preço do ciclo, com dependentes extras)
+	originalAmountCents := plan.PriceFor(len(input.Dependents))
 	finalAmountCents := originalAmountCents
 	discountPercent := 0
 	discountAmountCents := 0
 	couponCode := strings.ToUpper(strings.TrimSpace(input.CouponCode))
-	couponSellerName := ""
+	var coupon *CouponDetails
 
 	if couponCode != "" {
-		if couponTracker == nil {
-			return nil, &DomainError{
-				Code:    "COUPON_UNAVAILABLE",
-				Message: "cupom informado, mas o serviço de cupom não está configurado",
-			}
-		}
-		couponDetails, couponErr := couponTracker.GetActiveCoupon(ctx, couponCode)
+		evaluation, couponErr := EvaluateCoupon(ctx, couponCode, plan, len(input.Dependents), input.CPF)
 		if couponErr != nil {
-			return nil, &DomainError{
-				Code:    "COUPON_INVALID",
-				Message: "cupom inválido ou inativo",
-			}
+			return nil, couponErr
 		}
 
-		discountPercent = couponDetails.DiscountPercent
-		if discountPercent <= 0 {
-			discountPercent = 10
-		}
-		couponSellerName = couponDetails.SellerName
-
-		discountAmountCents = (originalAmountCents * discountPercent) / 100
-		finalAmountCents = originalAmountCents - discountAmountCents
-		if finalAmountCents < 0 {
-			finalAmountCents = 0
+		// Mesmo cálculo do /coupons/validate: o valor cobrado é o cotado.
+		coupon = evaluation.Coupon
+		discountAmountCents = evaluation.DiscountAmountCents
+		finalAmountCents = evaluation.FinalAmountCents
+		if originalAmountCents > 0 {
+			discountPercent = (discountAmountCents * 100) / originalAmountCents
 		}
 	}
 
@@ -170,6 +181,8 @@ func (uc *CreateCustomerUseCase) Execute(ctx context.Context, input CreateCustom
 				if deleteErr := uc.SubRepo.DeleteByID(ctx, latestSubscription.ID); deleteErr != nil {
 					log.Printf("[WARN] falha ao deletar subscription %s: %v", latestSubscription.ID, deleteErr)
 				}
+				// A venda de cupom do checkout descartado deixa de contar no limite.
+				voidCouponSales(ctx, latestSubscription.ID)
 			}
 			if deleteErr := uc.Repo.Delete(ctx, existingCustomer.ID); deleteErr != nil {
 				log.Printf("[WARN] falha ao deletar customer %s: %v", existingCustomer.ID, deleteErr)
@@ -180,6 +193,13 @@ func (uc *CreateCustomerUseCase) Execute(ctx context.Context, input CreateCustom
 		}
 	}
 
+	// Cartão: o checkout é público e identifica o cliente só por CPF/e-mail, então o cartão salvo
+	// nunca é cobrado aqui; quem volta precisa enviar token ou cartão digitado.
+	if strings.EqualFold(strings.TrimSpace(input.PaymentMethod), "CREDIT_CARD") &&
+		strings.TrimSpace(input.CreditCardToken) == "" && input.Card == nil {
+		return nil, newValidationError([]ValidationError{{"credit_card_token", "is required for CREDIT_CARD payment"}})
+	}
+
 	// 6. CRIAÇÃO DO NOVO CLIENTE (Se não existir)
 	newCustomerCreated := false
 	if existingCustomer == nil {
@@ -243,6 +263,7 @@ func (uc *CreateCustomerUseCase) Execute(ctx context.Context, input CreateCustom
 				}
 				return nil, &DomainError{Code: "VALIDATION_ERROR", Message: dependentErr.Error()}
 			}
+			dependent.Student = dependentInput.Student
 
 			if err := uc.DependentRepo.Create(ctx, dependent); err != nil {
 				if newCustomerCreated {
@@ -278,38 +299,81 @@ func (uc *CreateCustomerUseCase) Execute(ctx context.Context, input CreateCustom
 		}
 	}
 
+	// Reserva o uso do cupom antes de cobrar: limite conferido e venda gravada de forma atômica.
+	// Se a cobrança não sair, a reserva é anulada.
+	subscriptionID := uuid.New().String()
+	couponReserved := false
+	if coupon != nil && couponTracker != nil {
+		if trackErr := couponTracker.TrackSale(ctx, CouponSaleRecord{
+			CouponCode:          coupon.Code,
+			SellerName:          coupon.SellerName,
+			CustomerID:          existingCustomer.ID,
+			CustomerCPF:         input.CPF,
+			SubscriptionID:      subscriptionID,
+			PlanID:              plan.ID,
+			DiscountType:        coupon.DiscountType,
+			DurationCycles:      coupon.DurationCycles,
+			OriginalAmountCents: originalAmountCents,
+			DiscountPercent:     discountPercent,
+			DiscountAmountCents: discountAmountCents,
+			FinalAmountCents:    finalAmountCents,
+		}); trackErr != nil {
+			log.Printf("[WARN] venda do cupom %s não registrada: %v", couponCode, trackErr)
+			if newCustomerCreated {
+				if deleteErr := uc.Repo.Delete(ctx, existingCustomer.ID); deleteErr != nil {
+					log.Printf("[WARN] rollback delete customer failed after coupon refusal: %v", deleteErr)
+				}
+			}
+			return nil, couponSaleError(trackErr)
+		}
+		couponReserved = true
+	}
+	releaseCoupon := func() {
+		if couponReserved {
+			voidCouponSales(ctx, subscriptionID)
+		}
+	}
+
 	paymentMethod := strings.ToUpper(strings.TrimSpace(input.PaymentMethod))
 	var gatewaySubscriptionID string
 	var pixData *asaas.PixOutput
 	var gatewayStatus string
 	var gatewayErr error
+	var pixExpiresAt *time.Time
 
 	if paymentMethod == "PIX" {
+		expiresAt := uc.PixPolicy.ExpiresAt(plan, time.Now())
+		pixExpiresAt = &expiresAt
 		gatewaySubscriptionID, pixData, gatewayErr = uc.Gateway.SubscribePix(asaas.SubscribePixInput{
 			CustomerID: asaasCustomerID,
 			Price:      int64(finalAmountCents),
+			Cycle:      plan.Cycle(),
+			ExpiresAt:  expiresAt,
 		})
 		gatewayStatus = "PENDING"
 	} else if paymentMethod == "CREDIT_CARD" {
+		card, cardErr := resolveCard(input, asaasCustomerID)
+		if cardErr != nil {
+			releaseCoupon()
+			return nil, cardErr
+		}
 		gatewaySubscriptionID, gatewayStatus, gatewayErr = uc.Gateway.Subscribe(asaas.SubscribeInput{
-			CustomerID:       asaasCustomerID,
-			Price:            float64(finalAmountCents) / 100.0,
-			CardNumber:       input.CardNumber,
-			CardHolderName:   input.CardHolder,
-			CardMonth:        input.CardMonth,
-			CardYear:         input.CardYear,
-			CardCCV:          input.CardCVV,
-			HolderEmail:      input.Email,
-			HolderCpfCnpj:    input.CPF,
-			HolderPostalCode: input.ZipCode,
-			HolderAddressNum: input.Number,
-			HolderPhone:      input.Phone,
+			CustomerID:      asaasCustomerID,
+			Price:           float64(finalAmountCents) / 100.0,
+			Cycle:           plan.Cycle(),
+			CreditCardToken: card.Token,
+			RemoteIP:        input.RemoteIP,
 		})
+		if gatewayErr == nil {
+			uc.saveCard(ctx, existingCustomer, card)
+		}
 	} else {
+		releaseCoupon()
 		return nil, &DomainError{Code: "UNSUPPORTED_PAYMENT", Message: "Método não suportado"}
 	}
 
 	if gatewayErr != nil {
+		releaseCoupon()
 		return nil, &DomainError{Code: "PAYMENT_FAILED", Message: "Asaas recusou o pagamento: " + gatewayErr.Error()}
 	}
 
@@ -319,7 +383,7 @@ func (uc *CreateCustomerUseCase) Execute(ctx context.Context, input CreateCustom
 	}
 
 	newSubscription := &entity.Subscription{
-		ID:              uuid.New().String(),
+		ID:              subscriptionID,
 		CustomerID:      existingCustomer.ID,
 		PlanID:          plan.ID,
 		ProductID:       plan.ProductID,
@@ -327,13 +391,22 @@ func (uc *CreateCustomerUseCase) Execute(ctx context.Context, input CreateCustom
 		Status:          gatewayStatus,
 		PaymentMethod:   paymentMethod,
 		PaymentMethodID: gatewaySubscriptionID,
-		NextBillingDate: time.Now().AddDate(0, 1, 0),
+		PixExpiresAt:    pixExpiresAt,
+		Interval:        plan.Cycle(),
+		NextBillingDate: plan.NextBillingDate(time.Now()),
 		CreatedAt:       time.Now(),
 		UpdatedAt:       time.Now(),
 	}
+	if coupon != nil && coupon.DurationCycles > 0 && discountAmountCents > 0 {
+		// Desconto só nas primeiras N cobranças; depois o valor volta ao preço do plano.
+		cycles := coupon.DurationCycles
+		newSubscription.FullAmountCents = originalAmountCents
+		newSubscription.DiscountCyclesRemaining = &cycles
+	}
 
 	if err := uc.SubRepo.Create(ctx, newSubscription); err != nil {
 		log.Printf("[ERROR] Failed to create subscription: %v", err)
+		releaseCoupon()
 		if newCustomerCreated {
 			if deleteErr := uc.Repo.Delete(ctx, existingCustomer.ID); deleteErr != nil {
 				log.Printf("[WARN] rollback delete customer failed: %v", deleteErr)
@@ -342,21 +415,7 @@ func (uc *CreateCustomerUseCase) Execute(ctx context.Context, input CreateCustom
 		return nil, &TechnicalError{Code: "DATABASE_ERROR", Message: "Falha ao criar assinatura"}
 	}
 
-	if couponCode != "" && couponTracker != nil {
-		if trackErr := couponTracker.TrackSale(ctx, CouponSaleRecord{
-			CouponCode:          couponCode,
-			SellerName:          couponSellerName,
-			CustomerID:          existingCustomer.ID,
-			SubscriptionID:      newSubscription.ID,
-			PlanID:              plan.ID,
-			OriginalAmountCents: originalAmountCents,
-			DiscountPercent:     discountPercent,
-			DiscountAmountCents: discountAmountCents,
-			FinalAmountCents:    finalAmountCents,
-		}); trackErr != nil {
-			log.Printf("[WARN] falha ao registrar venda do cupom %s: %v", couponCode, trackErr)
-		}
-	}
+	uc.attachLead(ctx, input, existingCustomer.ID, plan.ID)
 
 	if paymentMethod == "PIX" {
 		if pixData == nil {
@@ -369,6 +428,7 @@ func (uc *CreateCustomerUseCase) Execute(ctx context.Context, input CreateCustom
 			Status:       "WAITING_PAYMENT",
 			PixCode:      pixData.CopyPaste,
 			PixQRCodeURL: pixData.URL,
+			PixExpiresAt: pixExpiresAt,
 			Msg:          "Cobrança gerada com sucesso!",
 		}, nil
 	}
@@ -379,3 +439,63 @@ func (uc *CreateCustomerUseCase) Execute(ctx context.Context, input CreateCustom
 		Msg:    "Pagamento processado com sucesso!",
 	}, nil
 }
+
+// voidCouponSales anula a venda de cupom de uma assinatura descartada; falha só é registrada.
+func voidCouponSales(ctx context.Context, subscriptionID string) {
+	if couponTracker == nil {
+		return
+	}
+	if err := couponTracker.VoidSales(ctx, subscriptionID); err != nil {
+		log.Printf("[WARN] falha ao anular venda de cupom da subscription %s: %v", subscriptionID, err)
+	}
+}
+
+// resolveCard define o cartão da assinatura: o token enviado pelo front ou o cartão digitado
+// (tokenizado aqui, uma única vez, no customer do Asaas). O cartão salvo do cliente não entra:
+// usá-lo exige autenticar o titular, o que o checkout público não faz.
+func resolveCard(input CreateCustomerInput, gatewayCustomerID string) (entity.CreditCardToken, error) {
+	if token := strings.TrimSpace(input.CreditCardToken); token != "" {
+		return entity.CreditCardToken{Token: token}, nil
+	}
+	if input.Card == nil {
+		return entity.CreditCardToken{}, newValidationError([]ValidationError{{"credit_card_token", "is required for CREDIT_CARD payment"}})
+	}
+	card, err := input.Card.Tokenize(gatewayCustomerID)
+	if err != nil {
+		return entity.CreditCardToken{}, &DomainError{Code: "PAYMENT_FAILED", Message: "Asaas recusou o cartão: " + err.Error()}
+	}
+	return *card, nil
+}
+
+// saveCard guarda o token para as próximas compras do cliente. Falhas não bloqueiam a venda.
+func (uc *CreateCustomerUseCase) saveCard(ctx context.Context, customer *entity.Customer, card entity.CreditCardToken) {
+	if uc.CardRepo == nil || card.Token == "" || card.Token == customer.Card.Token {
+		return
+	}
+	if err := uc.CardRepo.SaveCardToken(ctx, customer.ID, card); err != nil {
+		log.Printf("[WARN] falha ao salvar cartão tokenizado do customer %s: %v", customer.ID, err)
+		return
+	}
+	customer.Card = card
+}
+
+// attachLead registra o checkout no lead do e-mail para o funil captura → checkout → pagamento.
+// Falhas não bloqueiam a venda.
+func (uc *CreateCustomerUseCase) attachLead(ctx context.Context, input CreateCustomerInput, customerID, planID string) {
+	if uc.LeadRepo == nil || strings.TrimSpace(input.Email) == "" {
+		return
+	}
+
+	lead := &entity.Lead{
+		Email:           strings.TrimSpace(input.Email),
+		Name:            strings.TrimSpace(input.Name),
+		Phone:           strings.TrimSpace(input.Phone),
+		PlanID:          planID,
+		LeadAttribution: input.LeadAttribution,
+	}
+	if err := uc.LeadRepo.AttachCheckout(ctx, lead, customerID); err != nil {
+		log.Printf("[WARN] falha ao vincular checkout ao lead: %v", err)
+		return
+	}
+	log.Printf("[checkout] lead_attached customer_id=%s lead_id=%s source=%s", customerID, lead.ID, lead.UTMSource)
+}
@@ -4,6 +4,7 @@ package usecase
 type DomainError struct {
 	Code    string
 	Message string
+	Fields  []ValidationError // campos inválidos, quando Code é VALIDATION_ERROR
 }
 
 func (e *DomainError) Error() string {
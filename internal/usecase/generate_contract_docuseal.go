@@ -134,7 +134,7 @@ func (uc *GenerateContractWithDocuSealUseCase) Execute(ctx context.Context, inpu
 		"product":        input.Produto,
 		"id":             input.CustomerID,
 		"method_payment": "Método de Pagamento: " + input.Pagamento,
-		"monthly":        "Mensal",
+		"monthly":        docuSealPeriodicity(input.Periodicidade),
 		"value":          "Valor: " + formatCurrencyForDocuSeal(input.Valor),
 		"name":           input.Nome,
 		"birthdate":      input.Nascimento,
@@ -254,7 +254,7 @@ func (uc *GenerateContractWithDocuSealUseCase) ExecuteAutomatic(ctx context.Cont
 		"product":        input.Produto,
 		"id":             input.CustomerID,
 		"method_payment": "Método de Pagamento: " + input.Pagamento,
-		"monthly":        "Mensal",
+		"monthly":        docuSealPeriodicity(input.Periodicidade),
 		"value":          "Valor: " + formatCurrencyForDocuSeal(input.Valor),
 		"name":           input.Nome,
 		"birthdate":      input.Nascimento,
@@ -301,6 +301,15 @@ func (uc *GenerateContractWithDocuSealUseCase) ExecuteAutomatic(ctx context.Cont
 	return resp.UUID, nil
 }
 
+// docuSealPeriodicity é a periodicidade do contrato (Mensal, Trimestral, Semestral ou Anual);
+// sem ela, vale a mensal.
+func docuSealPeriodicity(periodicidade string) string {
+	if strings.TrimSpace(periodicidade) == "" {
+		return "Mensal"
+	}
+	return periodicidade
+}
+
 func normalizeDocuSealMonthly(values map[string]string) map[string]string {
 	normalized := make(map[string]string, len(values))
 	for key, value := range values {
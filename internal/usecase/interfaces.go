@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"context"
+	"time"
 
 	"github.com/xavierca1/ligue-payments/internal/entity"
 	"github.com/xavierca1/ligue-payments/internal/infra/integration/asaas"
@@ -19,7 +20,8 @@ type DependentInput struct {
 	CPF       string `json:"cpf"`
 	BirthDate string `json:"birth_date"` // Formato: YYYY-MM-DD
 	Gender    string `json:"gender"`     // "1", "2" ou "3"
-	Kinship   string `json:"kinship"`    // FILHO, CONJUGE, PAI, MAE, etc
+	Kinship   string `json:"kinship"`    // FILHO, ENTEADO, CONJUGE, PAI ou MAE
+	Student   bool   `json:"student"`    // filho/enteado estudante
 }
 
 type CreateCustomerInput struct {
@@ -43,27 +45,31 @@ type CreateCustomerInput struct {
 	State             string `json:"state"`
 	ZipCode           string `json:"zip_code"`
 	ExternalReference string `json:"externalReference,omitempty"`
-	CardHolder        string `json:"card_holder"`
-	CardNumber        string `json:"card_number"`
-	CardMonth         string `json:"card_month"`
-	CardYear          string `json:"card_year"`
-	CardCVV           string `json:"card_cvv"`
+
+	// Cartão: token pronto do Asaas ou, quando o cliente digitou o cartão, o tokenizador
+	// montado pelo handler. Um dos dois é obrigatório em CREDIT_CARD.
+	CreditCardToken string        `json:"credit_card_token,omitempty"`
+	Card            CardTokenizer `json:"-"`
+	RemoteIP        string        `json:"-"`
 
 	TermsAccepted   bool   `json:"terms_accepted"`
 	TermsAcceptedAt string `json:"terms_accepted_at"` // Vem como string ISO do front
 	TermsVersion    string `json:"terms_version"`
 
 	Dependents []DependentInput `json:"dependents,omitempty"` // Lista de dependentes (opcional)
+
+	entity.LeadAttribution // UTMs/landing page, para checkouts sem captura prévia do lead
 }
 
 type CreateCustomerOutput struct {
-	ID           string `json:"id"`
-	Name         string `json:"name"`
-	Email        string `json:"email"`
-	Status       string `json:"status"`
-	Msg          string `json:"msg"`
-	PixCode      string `json:"pix_code"`        // Se for cartão, vai vazio. Se for PIX, vai cheio.
-	PixQRCodeURL string `json:"pix_qr_code_url"` // O Front decide o que mostrar.
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Email        string     `json:"email"`
+	Status       string     `json:"status"`
+	Msg          string     `json:"msg"`
+	PixCode      string     `json:"pix_code"`                 // Se for cartão, vai vazio. Se for PIX, vai cheio.
+	PixQRCodeURL string     `json:"pix_qr_code_url"`          // O Front decide o que mostrar.
+	PixExpiresAt *time.Time `json:"pix_expires_at,omitempty"` // Prazo do PIX para o contador do front.
 }
 
 type CustomerRepositoryInterface interface {
@@ -94,6 +100,14 @@ type PaymentGateway interface {
 	DeleteSubscription(subscriptionID string) error
 }
 
+// CardTokenizer troca o cartão digitado no checkout pelo creditCardToken do cliente no Asaas.
+// É implementado na borda (handler), único lugar onde número e CVV existem; o use case só
+// enxerga o token.
+type CardTokenizer interface {
+	Validate() []ValidationError
+	Tokenize(gatewayCustomerID string) (*entity.CreditCardToken, error)
+}
+
 type QueueProducerInterface interface {
 	PublishActivation(ctx context.Context, payload queue.ActivationPayload) error
 }
@@ -103,6 +117,8 @@ type EmailService interface {
 	SendWelcomeEmailWithCard(name, email, cpf, planName, providerID string) error
 	SendWelcomeEmailWithCardAndDependents(name, email, cpf, planName, providerID string, dependents []*entity.Dependent) error
 	SendWelcomeEmailWithContractAndDependents(name, email, cpf, planName, providerID string, dependents []*entity.Dependent, contractPDF []byte) error
+	SendCheckoutRecoveryEmail(name, email, checkoutURL, unsubscribeURL string, stage int) error
+	SendCancellationEmail(name, email, planName string, accessUntil time.Time) error
 }
 type KommoService interface {
 	CreateLead(customerName, phone, email, planName string, price int) (int, error)
@@ -122,7 +138,10 @@ type CreateCustomerUseCase struct {
 	EmailService     EmailService
 	KommoService     KommoService
 	WelcomeBucketURL string
+	PixPolicy        entity.PixExpirationPolicy
 	DependentRepo    entity.DependentRepositoryInterface
+	LeadRepo         entity.LeadRepositoryInterface         // opcional; vincula o checkout ao lead para o funil
+	CardRepo         entity.CustomerCardRepositoryInterface // opcional; guarda o token do cartão para recompras
 }
 
 type ActivateSubscriptionInput struct {
@@ -136,8 +155,10 @@ type ActivateSubscriptionUseCase struct {
 	PlanRepo        entity.PlanRepositoryInterface
 	DependentRepo   entity.DependentRepositoryInterface
 	Queue           queue.QueueProducerInterface
+	Outbox          entity.OutboxRepositoryInterface // optional; when set, replaces the direct publish to Queue
 	EmailService    EmailService
 	KommoService    KommoService
+	LeadRepo        entity.LeadRepositoryInterface       // optional; marks the checkout lead as CONVERTED
 	ContractUC      *GenerateContractUseCase             // optional; skipped when nil
 	DocuSealUseCase *GenerateContractWithDocuSealUseCase // optional; automatic document generation
 }
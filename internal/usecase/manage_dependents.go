@@ -0,0 +1,442 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/infra/queue"
+)
+
+type ManageDependentsInterface interface {
+	List(ctx context.Context, customerID string) ([]*entity.Dependent, error)
+	Add(ctx context.Context, customerID string, input DependentInput) (*DependentChangeOutput, error)
+	Update(ctx context.Context, customerID, dependentID string, input DependentInput) (*DependentChangeOutput, error)
+	Remove(ctx context.Context, customerID, dependentID string) (*DependentChangeOutput, error)
+}
+
+type DependentChangeOutput struct {
+	Dependent        *entity.Dependent   `json:"dependent,omitempty"`
+	Dependents       []*entity.Dependent `json:"dependents"`
+	AmountCents      int                 `json:"amount_cents"`       // valor das próximas cobranças
+	PriceChangeCents int                 `json:"price_change_cents"` // diferença aplicada na assinatura
+}
+
+// ManageDependentsUseCase inclui, edita e remove dependentes de uma assinatura ativa: valida o
+// limite do plano, ajusta o valor da assinatura quando há cobrança por dependente extra,
+// provisiona a mudança no provedor e reenvia as carteirinhas ao titular. O provisionamento
+// é gravado no outbox na mesma transação do dependente e do novo valor; o OutboxRelay publica.
+type ManageDependentsUseCase struct {
+	SubRepo       entity.SubscriptionRepository
+	CustomerRepo  entity.CustomerRepositoryInterface
+	PlanRepo      entity.PlanRepositoryInterface
+	DependentRepo entity.DependentRepositoryInterface
+	ChangeRepo    entity.DependentChangeRepositoryInterface
+	Gateway       SubscriptionValueUpdater
+	EmailService  EmailService // optional; reenvio das carteirinhas
+}
+
+func NewManageDependentsUseCase(
+	subRepo entity.SubscriptionRepository,
+	customerRepo entity.CustomerRepositoryInterface,
+	planRepo entity.PlanRepositoryInterface,
+	dependentRepo entity.DependentRepositoryInterface,
+	changeRepo entity.DependentChangeRepositoryInterface,
+	gateway SubscriptionValueUpdater,
+) *ManageDependentsUseCase {
+	return &ManageDependentsUseCase{
+		SubRepo:       subRepo,
+		CustomerRepo:  customerRepo,
+		PlanRepo:      planRepo,
+		DependentRepo: dependentRepo,
+		ChangeRepo:    changeRepo,
+		Gateway:       gateway,
+	}
+}
+
+// dependentAccount é o titular com a assinatura ativa, o plano e os dependentes atuais.
+type dependentAccount struct {
+	customer   *entity.Customer
+	sub        *entity.Subscription
+	plan       *entity.Plan
+	dependents []*entity.Dependent
+}
+
+func (uc *ManageDependentsUseCase) List(ctx context.Context, customerID string) ([]*entity.Dependent, error) {
+	if _, err := uc.CustomerRepo.FindByID(ctx, customerID); err != nil {
+		return nil, &DomainError{Code: "CUSTOMER_NOT_FOUND", Message: "cliente não encontrado"}
+	}
+
+	dependents, err := uc.DependentRepo.FindByCustomerID(ctx, customerID)
+	if err != nil {
+		return nil, &TechnicalError{Code: "DATABASE_ERROR", Message: fmt.Sprintf("falha ao buscar dependentes: %v", err)}
+	}
+	if dependents == nil {
+		dependents = []*entity.Dependent{}
+	}
+	return dependents, nil
+}
+
+func (uc *ManageDependentsUseCase) Add(ctx context.Context, customerID string, input DependentInput) (*DependentChangeOutput, error) {
+	account, err := uc.loadAccount(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	dependent, err := buildDependent(account, input, "")
+	if err != nil {
+		return nil, err
+	}
+
+	count := len(account.dependents)
+	if !account.plan.AcceptsDependents(count + 1) {
+		return nil, &DomainError{
+			Code:    "DEPENDENTS_LIMIT",
+			Message: fmt.Sprintf("o plano permite no máximo %d dependente(s)", *account.plan.MaxDependents),
+		}
+	}
+
+	delta := account.plan.PriceFor(count+1) - account.plan.PriceFor(count)
+	event, err := entity.NewOutboxEvent(queue.MessageTypeAddDependent, account.customer.ID,
+		beneficiaryPayload(account.customer, account.plan, []*entity.Dependent{dependent}, "DEPENDENT_ADDED"))
+	if err != nil {
+		return nil, &TechnicalError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+
+	if err := uc.updateGatewayValue(account.sub, delta); err != nil {
+		return nil, err
+	}
+
+	change := entity.DependentSubscriptionChange{
+		Dependent:           dependent,
+		SubscriptionID:      account.sub.ID,
+		DeltaCents:          delta,
+		ExpectedAmountCents: account.sub.Amount,
+		ExpectedDependents:  count,
+		Events:              []*entity.OutboxEvent{event},
+	}
+	if err := uc.ChangeRepo.AddToSubscription(ctx, change); err != nil {
+		return nil, uc.changeFailed(ctx, account, delta, "gravar dependente", err)
+	}
+	dependents := append(account.dependents, dependent)
+
+	log.Printf("👶 Dependentes: %s incluído no customer %s (valor %+d centavos, outbox %s)", dependent.Name, account.customer.ID, delta, event.ID)
+
+	uc.reissueCards(account.customer, account.plan, dependents)
+
+	return &DependentChangeOutput{Dependent: dependent, Dependents: dependents, AmountCents: account.sub.Amount + delta, PriceChangeCents: delta}, nil
+}
+
+// Update corrige os dados de um dependente. Trocando o CPF, o provedor recebe a baixa do
+// documento antigo e a inclusão do novo; senão, a atualização cadastral do beneficiário.
+func (uc *ManageDependentsUseCase) Update(ctx context.Context, customerID, dependentID string, input DependentInput) (*DependentChangeOutput, error) {
+	account, err := uc.loadAccount(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	current := findDependent(account.dependents, dependentID)
+	if current == nil {
+		return nil, &DomainError{Code: "DEPENDENT_NOT_FOUND", Message: "dependente não encontrado"}
+	}
+
+	updated, err := buildDependent(account, input, dependentID)
+	if err != nil {
+		return nil, err
+	}
+	updated.ID = current.ID
+	updated.CreatedAt = current.CreatedAt
+	updated.UpdatedAt = time.Now()
+
+	events, err := dependentUpdateEvents(account, current, updated)
+	if err != nil {
+		return nil, &TechnicalError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+	if err := uc.ChangeRepo.UpdateWithEvents(ctx, updated, events); err != nil {
+		return nil, &TechnicalError{Code: "DATABASE_ERROR", Message: fmt.Sprintf("falha ao atualizar dependente: %v", err)}
+	}
+
+	dependents := make([]*entity.Dependent, 0, len(account.dependents))
+	for _, dep := range account.dependents {
+		if dep.ID == updated.ID {
+			dep = updated
+		}
+		dependents = append(dependents, dep)
+	}
+
+	log.Printf("✏️ Dependentes: %s atualizado no customer %s", updated.Name, account.customer.ID)
+
+	uc.reissueCards(account.customer, account.plan, dependents)
+
+	return &DependentChangeOutput{Dependent: updated, Dependents: dependents, AmountCents: account.sub.Amount}, nil
+}
+
+func (uc *ManageDependentsUseCase) Remove(ctx context.Context, customerID, dependentID string) (*DependentChangeOutput, error) {
+	account, err := uc.loadAccount(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	dependent := findDependent(account.dependents, dependentID)
+	if dependent == nil {
+		return nil, &DomainError{Code: "DEPENDENT_NOT_FOUND", Message: "dependente não encontrado"}
+	}
+
+	count := len(account.dependents)
+	delta := account.plan.PriceFor(count-1) - account.plan.PriceFor(count)
+	removed := []*entity.Dependent{dependent}
+	removal := withEnrollmentDates(deactivationPayload(beneficiaryPayload(account.customer, account.plan, removed, "DEPENDENT_REMOVED"), "DEPENDENT_REMOVED"), account.sub, removed)
+	event, err := entity.NewOutboxEvent(queue.MessageTypeRemoveDependent, account.customer.ID, removal)
+	if err != nil {
+		return nil, &TechnicalError{Code: "INTERNAL_ERROR", Message: err.Error()}
+	}
+
+	if err := uc.updateGatewayValue(account.sub, delta); err != nil {
+		return nil, err
+	}
+
+	change := entity.DependentSubscriptionChange{
+		Dependent:           dependent,
+		SubscriptionID:      account.sub.ID,
+		DeltaCents:          delta,
+		ExpectedAmountCents: account.sub.Amount,
+		ExpectedDependents:  count,
+		Events:              []*entity.OutboxEvent{event},
+	}
+	if err := uc.ChangeRepo.RemoveFromSubscription(ctx, change); err != nil {
+		return nil, uc.changeFailed(ctx, account, delta, "remover dependente", err)
+	}
+
+	dependents := make([]*entity.Dependent, 0, count-1)
+	for _, dep := range account.dependents {
+		if dep.ID != dependent.ID {
+			dependents = append(dependents, dep)
+		}
+	}
+
+	log.Printf("🗑️ Dependentes: %s removido do customer %s (valor %+d centavos, outbox %s)", dependent.Name, account.customer.ID, delta, event.ID)
+
+	uc.reissueCards(account.customer, account.plan, dependents)
+
+	return &DependentChangeOutput{Dependents: dependents, AmountCents: account.sub.Amount + delta, PriceChangeCents: delta}, nil
+}
+
+func (uc *ManageDependentsUseCase) loadAccount(ctx context.Context, customerID string) (*dependentAccount, error) {
+	customer, err := uc.CustomerRepo.FindByID(ctx, customerID)
+	if err != nil {
+		return nil, &DomainError{Code: "CUSTOMER_NOT_FOUND", Message: "cliente não encontrado"}
+	}
+
+	sub, err := uc.SubRepo.FindLastByCustomerID(ctx, customer.ID)
+	if err != nil {
+		return nil, &DomainError{Code: "SUBSCRIPTION_NOT_FOUND", Message: "cliente não possui assinatura"}
+	}
+	if !strings.EqualFold(strings.TrimSpace(sub.Status), SubscriptionStatusActive) {
+		return nil, &DomainError{Code: "SUBSCRIPTION_NOT_ACTIVE", Message: "dependentes só podem ser alterados em assinaturas ativas"}
+	}
+	if sub.CancelAt != nil {
+		return nil, &DomainError{Code: "SUBSCRIPTION_CANCELLATION_SCHEDULED", Message: "a assinatura tem cancelamento agendado"}
+	}
+
+	plan, err := uc.PlanRepo.FindByID(ctx, sub.PlanID)
+	if err != nil {
+		return nil, &TechnicalError{Code: "DATABASE_ERROR", Message: fmt.Sprintf("falha ao buscar plano (%s): %v", sub.PlanID, err)}
+	}
+
+	dependents, err := uc.DependentRepo.FindByCustomerID(ctx, customer.ID)
+	if err != nil {
+		return nil, &TechnicalError{Code: "DATABASE_ERROR", Message: fmt.Sprintf("falha ao buscar dependentes: %v", err)}
+	}
+
+	return &dependentAccount{customer: customer, sub: sub, plan: plan, dependents: dependents}, nil
+}
+
+// updateGatewayValue aplica a diferença de preço na assinatura do Asaas antes de gravar no banco,
+// como na troca de plano: se o Asaas recusar, nada muda.
+func (uc *ManageDependentsUseCase) updateGatewayValue(sub *entity.Subscription, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	if err := uc.Gateway.UpdateSubscriptionValue(sub.PaymentMethodID, sub.Amount+delta); err != nil {
+		return &TechnicalError{Code: "GATEWAY_ERROR", Message: fmt.Sprintf("falha ao atualizar valor da assinatura: %v", err)}
+	}
+	return nil
+}
+
+// changeFailed desfaz o ajuste no Asaas quando a gravação falha. Se outra operação mudou a
+// assinatura no meio (ErrDependentsChanged), o Asaas volta ao valor que está no banco, e não ao
+// lido no início, para não desfazer o ajuste de quem gravou primeiro.
+func (uc *ManageDependentsUseCase) changeFailed(ctx context.Context, account *dependentAccount, delta int, action string, err error) error {
+	sub := account.sub
+	if !errors.Is(err, entity.ErrDependentsChanged) {
+		uc.revertGatewayValue(sub, sub.Amount, delta)
+		return &TechnicalError{Code: "DATABASE_ERROR", Message: fmt.Sprintf("falha ao %s: %v", action, err)}
+	}
+
+	if delta != 0 {
+		if current, findErr := uc.SubRepo.FindLastByCustomerID(ctx, account.customer.ID); findErr != nil {
+			log.Printf("❌ Dependentes: assinatura %s alterada durante a operação e não foi possível reler o valor; conferir o Asaas: %v", sub.PaymentMethodID, findErr)
+		} else {
+			uc.revertGatewayValue(sub, current.Amount, delta)
+		}
+	}
+	return &DomainError{Code: "DEPENDENTS_CONFLICT", Message: "os dependentes da assinatura foram alterados por outra operação; tente novamente"}
+}
+
+// revertGatewayValue volta a assinatura do Asaas para amountCents depois de uma gravação que não aconteceu.
+func (uc *ManageDependentsUseCase) revertGatewayValue(sub *entity.Subscription, amountCents, delta int) {
+	if delta == 0 {
+		return
+	}
+	if err := uc.Gateway.UpdateSubscriptionValue(sub.PaymentMethodID, amountCents); err != nil {
+		log.Printf("❌ Dependentes: Asaas cobra %d centavos na assinatura %s, mas o banco tem %d: %v", sub.Amount+delta, sub.PaymentMethodID, amountCents, err)
+	}
+}
+
+// reissueCards reenvia ao titular o e-mail com as carteirinhas atualizadas. Falhas não desfazem a mudança.
+func (uc *ManageDependentsUseCase) reissueCards(customer *entity.Customer, plan *entity.Plan, dependents []*entity.Dependent) {
+	if uc.EmailService == nil || strings.TrimSpace(customer.Email) == "" {
+		return
+	}
+
+	var err error
+	if len(dependents) > 0 {
+		err = uc.EmailService.SendWelcomeEmailWithCardAndDependents(customer.Name, customer.Email, customer.CPF, plan.Name, customer.ProviderID, dependents)
+	} else {
+		err = uc.EmailService.SendWelcomeEmailWithCard(customer.Name, customer.Email, customer.CPF, plan.Name, customer.ProviderID)
+	}
+	if err != nil {
+		log.Printf("⚠️ Dependentes: falha ao reenviar carteirinhas para %s (não bloqueia): %v", customer.Email, err)
+	}
+}
+
+// dependentUpdateEvents monta o provisionamento da correção: trocando o CPF, a baixa do
+// documento antigo e a inclusão do novo; senão, a atualização cadastral do beneficiário.
+func dependentUpdateEvents(account *dependentAccount, current, updated *entity.Dependent) ([]*entity.OutboxEvent, error) {
+	if cpfDigits(current.CPF) == cpfDigits(updated.CPF) {
+		event, err := entity.NewOutboxEvent(queue.MessageTypeUpdateData, account.customer.ID,
+			withUpdateEnrollmentDates(beneficiaryPayload(account.customer, account.plan, []*entity.Dependent{updated}, "DEPENDENT_UPDATED"), account.sub, []*entity.Dependent{current}))
+		if err != nil {
+			return nil, err
+		}
+		return []*entity.OutboxEvent{event}, nil
+	}
+
+	removed := []*entity.Dependent{current}
+	removal := withEnrollmentDates(deactivationPayload(beneficiaryPayload(account.customer, account.plan, removed, "DEPENDENT_UPDATED"), "DEPENDENT_UPDATED"), account.sub, removed)
+	removeEvent, err := entity.NewOutboxEvent(queue.MessageTypeRemoveDependent, account.customer.ID, removal)
+	if err != nil {
+		return nil, err
+	}
+	addEvent, err := entity.NewOutboxEvent(queue.MessageTypeAddDependent, account.customer.ID,
+		beneficiaryPayload(account.customer, account.plan, []*entity.Dependent{updated}, "DEPENDENT_UPDATED"))
+	if err != nil {
+		return nil, err
+	}
+	return []*entity.OutboxEvent{removeEvent, addEvent}, nil
+}
+
+// buildDependent valida a entrada e as regras do plano contra o titular e os demais
+// dependentes (sem o exceptID, na edição). O candidato vai para o fim da lista, para que
+// cônjuge excedente ou CPF repetido recaiam sobre ele e não sobre quem já está no plano.
+func buildDependent(account *dependentAccount, input DependentInput, exceptID string) (*entity.Dependent, error) {
+	if errs := validateDependentInput(input, ""); len(errs) > 0 {
+		return nil, newValidationError(errs)
+	}
+
+	gender, _ := parseDependentGender(input.Gender)
+	dependent, err := entity.NewDependent(account.customer.ID, account.customer.CPF, strings.TrimSpace(input.Name), strings.TrimSpace(input.CPF), strings.TrimSpace(input.BirthDate), gender, input.Kinship)
+	if err != nil {
+		return nil, &DomainError{Code: "VALIDATION_ERROR", Message: err.Error()}
+	}
+	dependent.Student = input.Student
+
+	candidates := make([]*entity.Dependent, 0, len(account.dependents)+1)
+	for _, dep := range account.dependents {
+		if dep.ID != exceptID {
+			candidates = append(candidates, dep)
+		}
+	}
+	candidates = append(candidates, dependent)
+
+	last := len(candidates) - 1
+	var errs []ValidationError
+	for _, v := range account.plan.CheckDependents(account.customer.CPF, candidates, time.Now()) {
+		if v.Index == last {
+			errs = append(errs, ValidationError{v.Field, v.Message})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, newValidationError(errs)
+	}
+
+	return dependent, nil
+}
+
+func findDependent(dependents []*entity.Dependent, id string) *entity.Dependent {
+	for _, dep := range dependents {
+		if dep != nil && dep.ID == id {
+			return dep
+		}
+	}
+	return nil
+}
+
+// withEnrollmentDates preenche as datas de alta da baixa: a primeira ativação da assinatura
+// para o titular e, para dependentes incluídos depois dela, a data da inclusão.
+func withEnrollmentDates(p queue.DeactivationPayload, sub *entity.Subscription, dependents []*entity.Dependent) queue.DeactivationPayload {
+	if sub == nil || sub.ActivatedAt == nil {
+		return p
+	}
+	p.ActivatedAt = sub.ActivatedAt.Format("2006-01-02")
+	setDependentEnrollmentDates(p.Dependents, *sub.ActivatedAt, dependents)
+	return p
+}
+
+// withUpdateEnrollmentDates faz o mesmo na atualização cadastral ou de plano, que reenvia o
+// beneficiário inteiro ao provedor.
+func withUpdateEnrollmentDates(p queue.ActivationPayload, sub *entity.Subscription, dependents []*entity.Dependent) queue.ActivationPayload {
+	if sub == nil || sub.ActivatedAt == nil {
+		return p
+	}
+	p.ActivatedAt = sub.ActivatedAt.Format("2006-01-02")
+	setDependentEnrollmentDates(p.Dependents, *sub.ActivatedAt, dependents)
+	return p
+}
+
+func setDependentEnrollmentDates(payloads []queue.DependentPayload, activatedAt time.Time, dependents []*entity.Dependent) {
+	addedAt := make(map[string]time.Time, len(dependents))
+	for _, dep := range dependents {
+		if dep != nil && dep.CreatedAt.After(activatedAt) {
+			addedAt[cpfDigits(dep.CPF)] = dep.CreatedAt
+		}
+	}
+	for i, dep := range payloads {
+		if at, ok := addedAt[cpfDigits(dep.CPF)]; ok {
+			payloads[i].ActivatedAt = at.Format("2006-01-02")
+		}
+	}
+}
+
+// deactivationPayload converte o beneficiário no formato da mensagem de baixa.
+func deactivationPayload(p queue.ActivationPayload, reason string) queue.DeactivationPayload {
+	return queue.DeactivationPayload{
+		CustomerID:       p.CustomerID,
+		PlanID:           p.PlanID,
+		ProviderPlanCode: p.ProviderPlanCode,
+		Provider:         p.Provider,
+		Origin:           p.Origin,
+		Reason:           reason,
+		Name:             p.Name,
+		Email:            p.Email,
+		CPF:              p.CPF,
+		Phone:            p.Phone,
+		BirthDate:        p.BirthDate,
+		Gender:           p.Gender,
+		Dependents:       p.Dependents,
+	}
+}
@@ -0,0 +1,238 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/xavierca1/ligue-payments/internal/entity"
+)
+
+// AsaasWebhookPayload é o subconjunto do corpo do webhook Asaas usado no processamento.
+type AsaasWebhookPayload struct {
+	ID      string `json:"id"`
+	Event   string `json:"event"`
+	Payment struct {
+		ID           string `json:"id"`
+		Customer     string `json:"customer"`
+		Status       string `json:"status"`
+		Subscription string `json:"subscription"`
+		DueDate      string `json:"dueDate"` // YYYY-MM-DD
+		// ExternalReference identifica cobranças avulsas geradas por nós (ex.: pró-rata de troca de plano).
+		ExternalReference string `json:"externalReference"`
+	} `json:"payment"`
+	Subscription struct {
+		ID       string `json:"id"`
+		Customer string `json:"customer"`
+		Status   string `json:"status"`
+	} `json:"subscription"`
+}
+
+// customerRef retorna o cliente Asaas do evento, seja de cobrança (PAYMENT_*) ou de assinatura (SUBSCRIPTION_*).
+func (p AsaasWebhookPayload) customerRef() string {
+	if ref := strings.TrimSpace(p.Payment.Customer); ref != "" {
+		return ref
+	}
+	return strings.TrimSpace(p.Subscription.Customer)
+}
+
+// subscriptionID retorna a assinatura Asaas à qual o evento se refere.
+func (p AsaasWebhookPayload) subscriptionID() string {
+	if id := strings.TrimSpace(p.Payment.Subscription); id != "" {
+		return id
+	}
+	return strings.TrimSpace(p.Subscription.ID)
+}
+
+// NewAsaasWebhookEvent converte o corpo bruto de um webhook Asaas em um evento da inbox.
+// A chave de deduplicação é o ID do evento enviado pelo Asaas; na ausência dele,
+// usa EVENTO:payment_id (ou EVENTO:subscription_id), e por último o hash do corpo.
+func NewAsaasWebhookEvent(body []byte) (*entity.WebhookEvent, error) {
+	var payload AsaasWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("payload de webhook inválido: %w", err)
+	}
+
+	eventType := strings.ToUpper(strings.TrimSpace(payload.Event))
+	paymentID := strings.TrimSpace(payload.Payment.ID)
+
+	eventKey := strings.TrimSpace(payload.ID)
+	if eventKey == "" && paymentID != "" {
+		eventKey = eventType + ":" + paymentID
+	}
+	if eventKey == "" && payload.subscriptionID() != "" {
+		eventKey = eventType + ":" + payload.subscriptionID()
+	}
+	if eventKey == "" {
+		eventKey = fmt.Sprintf("sha256:%x", sha256.Sum256(body))
+	}
+
+	return &entity.WebhookEvent{
+		Source:      "ASAAS",
+		EventKey:    eventKey,
+		EventType:   eventType,
+		PaymentID:   paymentID,
+		CustomerRef: payload.customerRef(),
+		Payload:     body,
+		Status:      entity.WebhookEventPending,
+	}, nil
+}
+
+// NewInvalidAsaasWebhookEvent guarda na inbox, já como FAILED, um corpo que não é JSON válido,
+// para não perdê-lo (o Asaas não reenvia o que recebeu 200). O corpo vai como string JSON,
+// já que a coluna payload é jsonb.
+func NewInvalidAsaasWebhookEvent(body []byte, reason string) *entity.WebhookEvent {
+	payload, _ := json.Marshal(string(body))
+	return &entity.WebhookEvent{
+		Source:    "ASAAS",
+		EventKey:  fmt.Sprintf("invalid:sha256:%x", sha256.Sum256(body)),
+		EventType: "INVALID_PAYLOAD",
+		Payload:   payload,
+		Status:    entity.WebhookEventFailed,
+		LastError: reason,
+	}
+}
+
+type SubscriptionLifecycleInterface interface {
+	Execute(ctx context.Context, input SubscriptionLifecycleInput) (string, error)
+}
+
+type ProcessWebhookEventUseCase struct {
+	CustomerRepo  entity.CustomerRepositoryInterface
+	ActivateSubUC ActivateSubscriptionInterface
+	LifecycleUC   SubscriptionLifecycleInterface // optional; eventos de ciclo de vida são ignorados quando nil
+	CouponCycles  CouponCyclesInterface          // optional; descontos de N ciclos não expiram quando nil
+}
+
+type CouponCyclesInterface interface {
+	RegisterPayment(ctx context.Context, gatewaySubscriptionID, paymentID string, dueDate time.Time) error
+}
+
+func NewProcessWebhookEventUseCase(
+	customerRepo entity.CustomerRepositoryInterface,
+	activateSubUC ActivateSubscriptionInterface,
+	lifecycleUC SubscriptionLifecycleInterface,
+) *ProcessWebhookEventUseCase {
+	return &ProcessWebhookEventUseCase{
+		CustomerRepo:  customerRepo,
+		ActivateSubUC: activateSubUC,
+		LifecycleUC:   lifecycleUC,
+	}
+}
+
+// Execute processa um evento da inbox. Retornar erro faz o dispatcher reagendar o evento;
+// eventos que não exigem ação retornam nil e são marcados como processados.
+func (uc *ProcessWebhookEventUseCase) Execute(ctx context.Context, event *entity.WebhookEvent) error {
+	var payload AsaasWebhookPayload
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		log.Printf("⚠️ Webhook inbox: payload inválido (event=%s): %v", event.ID, err)
+		return nil
+	}
+
+	eventName := strings.ToUpper(strings.TrimSpace(payload.Event))
+	paymentStatus := strings.ToUpper(strings.TrimSpace(payload.Payment.Status))
+	paymentID := strings.TrimSpace(payload.Payment.ID)
+	customerRef := payload.customerRef()
+
+	// Pagamento, estorno ou atraso do pró-rata não muda o status da assinatura.
+	if strings.HasPrefix(strings.TrimSpace(payload.Payment.ExternalReference), PlanChangeChargeReferencePrefix) {
+		log.Printf("ℹ️ Webhook: Evento %s da cobrança de troca de plano %s ignorado (customer=%s)", eventName, paymentID, customerRef)
+		return nil
+	}
+
+	if IsLifecycleEvent(eventName) && uc.LifecycleUC != nil {
+		return uc.applyLifecycle(ctx, eventName, customerRef, payload.subscriptionID())
+	}
+
+	if !shouldActivateFromWebhook(eventName, paymentStatus) {
+		if strings.HasPrefix(eventName, "PAYMENT_") {
+			log.Printf("ℹ️ Webhook: Evento ignorado: %s (status=%s, payment_id=%s, customer=%s)", eventName, paymentStatus, paymentID, customerRef)
+		} else {
+			log.Printf("ℹ️ Webhook: Evento ignorado: %s", eventName)
+		}
+		return nil
+	}
+
+	log.Printf("📥 Webhook: Evento de ativação: %s (status=%s, payment_id=%s, customer=%s)", eventName, paymentStatus, paymentID, customerRef)
+
+	localCustomer, err := uc.findCustomer(ctx, customerRef)
+	if err != nil {
+		return err
+	}
+
+	// Antes da ativação: se falhar, o retry do webhook não reenvia as boas-vindas.
+	if uc.CouponCycles != nil {
+		dueDate, _ := time.Parse("2006-01-02", strings.TrimSpace(payload.Payment.DueDate))
+		if err := uc.CouponCycles.RegisterPayment(ctx, payload.subscriptionID(), paymentID, dueDate); err != nil {
+			return err
+		}
+	}
+
+	input := ActivateSubscriptionInput{
+		CustomerID: localCustomer.ID,
+		GatewayID:  paymentID,
+	}
+	if err := uc.ActivateSubUC.Execute(ctx, input); err != nil {
+		log.Printf("❌ Webhook: Detalhes - CustomerID=%s, GatewayID=%s, PaymentID=%s", localCustomer.ID, localCustomer.GatewayID, paymentID)
+		return fmt.Errorf("falha na ativação: %w", err)
+	}
+
+	log.Printf("✅ Webhook: Subscription activated for customer %s (GatewayID=%s, PaymentID=%s)", localCustomer.ID, localCustomer.GatewayID, paymentID)
+	return nil
+}
+
+// applyLifecycle aplica eventos de inadimplência, estorno, chargeback e cancelamento.
+// Diferente da ativação, cliente não encontrado não gera retry: não há acesso a revogar.
+func (uc *ProcessWebhookEventUseCase) applyLifecycle(ctx context.Context, eventName, customerRef, gatewaySubscriptionID string) error {
+	log.Printf("📥 Webhook: Evento de ciclo de vida: %s (customer=%s, subscription=%s)", eventName, customerRef, gatewaySubscriptionID)
+
+	localCustomer, err := uc.findCustomer(ctx, customerRef)
+	if err != nil {
+		log.Printf("ℹ️ Webhook: Evento %s ignorado: %v", eventName, err)
+		return nil
+	}
+
+	_, err = uc.LifecycleUC.Execute(ctx, SubscriptionLifecycleInput{
+		CustomerID:            localCustomer.ID,
+		Event:                 eventName,
+		GatewaySubscriptionID: gatewaySubscriptionID,
+		Origin:                "WEBHOOK_ASAAS",
+	})
+	return err
+}
+
+// findCustomer resolve o cliente local pelo gateway_id do Asaas, com fallback para o ID interno.
+// Um cliente não encontrado é tratado como falha transitória: o webhook pode chegar antes
+// do checkout terminar de gravar o gateway_id.
+func (uc *ProcessWebhookEventUseCase) findCustomer(ctx context.Context, customerRef string) (*entity.Customer, error) {
+	localCustomer, err := uc.CustomerRepo.FindByGatewayID(customerRef)
+	if err == nil {
+		return localCustomer, nil
+	}
+
+	log.Printf("⚠️ Webhook: FindByGatewayID falhou para %q, tentando FindByID como fallback: %v", customerRef, err)
+	localCustomer, err = uc.CustomerRepo.FindByID(ctx, customerRef)
+	if err != nil {
+		return nil, fmt.Errorf("customer não encontrado por GatewayID nem por ID (%s): %w", customerRef, err)
+	}
+
+	log.Printf("✅ Webhook: Customer encontrado via fallback FindByID (%s)", customerRef)
+	return localCustomer, nil
+}
+
+func shouldActivateFromWebhook(eventName, paymentStatus string) bool {
+	// Eventos de cobrança de multa/juros não devem reativar assinatura.
+	isNonActivationEvent := eventName == "PAYMENT_FINE_CHARGED" ||
+		eventName == "PAYMENT_INTEREST_CHARGED" ||
+		eventName == "PAYMENT_PENALTY_CHARGED"
+
+	isPaymentEvent := strings.HasPrefix(eventName, "PAYMENT_")
+	isPaidStatus := paymentStatus == "RECEIVED" || paymentStatus == "CONFIRMED" || paymentStatus == "RECEIVED_IN_CASH"
+	isActivationEvent := eventName == "PAYMENT_RECEIVED" || eventName == "PAYMENT_CONFIRMED" || eventName == "PAYMENT_APPROVED"
+
+	return !isNonActivationEvent && ((isPaymentEvent && isPaidStatus) || isActivationEvent)
+}
@@ -0,0 +1,276 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/infra/queue"
+)
+
+const (
+	SubscriptionStatusPending   = "PENDING"
+	SubscriptionStatusActive    = "ACTIVE"
+	SubscriptionStatusPastDue   = "PAST_DUE"
+	SubscriptionStatusSuspended = "SUSPENDED"
+	SubscriptionStatusCanceled  = "CANCELED"
+	SubscriptionStatusRefunded  = "REFUNDED"
+)
+
+// LifecycleOriginCustomerCancellation marca o cancelamento pedido pelo próprio cliente, que
+// encerra a assinatura mesmo com cancelamento agendado para depois.
+const LifecycleOriginCustomerCancellation = "CUSTOMER_CANCELLATION"
+
+// subscriptionTransitions mapeia evento do Asaas -> status atual -> próximo status.
+// Combinações ausentes não geram transição (evento ignorado para aquele status).
+//
+//	ACTIVE -> PAST_DUE -> SUSPENDED -> CANCELED
+//	ACTIVE -> REFUNDED
+var subscriptionTransitions = map[string]map[string]string{
+	"PAYMENT_OVERDUE": {
+		SubscriptionStatusActive:  SubscriptionStatusPastDue,
+		SubscriptionStatusPastDue: SubscriptionStatusSuspended,
+	},
+	"PAYMENT_REFUNDED": {
+		SubscriptionStatusActive:    SubscriptionStatusRefunded,
+		SubscriptionStatusPastDue:   SubscriptionStatusRefunded,
+		SubscriptionStatusSuspended: SubscriptionStatusRefunded,
+	},
+	"PAYMENT_CHARGEBACK_REQUESTED": {
+		SubscriptionStatusActive:  SubscriptionStatusSuspended,
+		SubscriptionStatusPastDue: SubscriptionStatusSuspended,
+	},
+	"SUBSCRIPTION_DELETED": {
+		SubscriptionStatusPending:   SubscriptionStatusCanceled,
+		SubscriptionStatusActive:    SubscriptionStatusCanceled,
+		SubscriptionStatusPastDue:   SubscriptionStatusCanceled,
+		SubscriptionStatusSuspended: SubscriptionStatusCanceled,
+	},
+	"SUBSCRIPTION_INACTIVATED": {
+		SubscriptionStatusActive:    SubscriptionStatusCanceled,
+		SubscriptionStatusPastDue:   SubscriptionStatusCanceled,
+		SubscriptionStatusSuspended: SubscriptionStatusCanceled,
+	},
+}
+
+// NextSubscriptionStatus retorna o status resultante de aplicar o evento ao status atual.
+func NextSubscriptionStatus(current, event string) (string, bool) {
+	byStatus, ok := subscriptionTransitions[strings.ToUpper(strings.TrimSpace(event))]
+	if !ok {
+		return "", false
+	}
+
+	next, ok := byStatus[strings.ToUpper(strings.TrimSpace(current))]
+	return next, ok
+}
+
+// IsLifecycleEvent indica se o evento participa da máquina de estados da assinatura.
+func IsLifecycleEvent(event string) bool {
+	_, ok := subscriptionTransitions[strings.ToUpper(strings.TrimSpace(event))]
+	return ok
+}
+
+// endsSubscription indica os eventos de remoção da assinatura no Asaas.
+func endsSubscription(event string) bool {
+	switch strings.ToUpper(strings.TrimSpace(event)) {
+	case "SUBSCRIPTION_DELETED", "SUBSCRIPTION_INACTIVATED":
+		return true
+	default:
+		return false
+	}
+}
+
+// revokesAccess indica os status em que o beneficiário perde acesso ao provedor.
+// PAST_DUE ainda mantém o acesso (período de carência).
+func revokesAccess(status string) bool {
+	switch status {
+	case SubscriptionStatusSuspended, SubscriptionStatusCanceled, SubscriptionStatusRefunded:
+		return true
+	default:
+		return false
+	}
+}
+
+type DeactivationPublisher interface {
+	PublishDeactivation(ctx context.Context, payload queue.DeactivationPayload) error
+}
+
+type SubscriptionLifecycleInput struct {
+	CustomerID            string
+	Event                 string
+	GatewaySubscriptionID string // assinatura Asaas do evento, quando informada
+	Origin                string
+}
+
+// CustomerStatusInactive marca no cadastro o cliente que perdeu o acesso. Os estados da
+// máquina (PAST_DUE, SUSPENDED...) ficam só na assinatura.
+const CustomerStatusInactive = "INACTIVE"
+
+type SubscriptionLifecycleUseCase struct {
+	SubRepo       entity.SubscriptionStatusRepository
+	CustomerRepo  entity.CustomerRepositoryInterface
+	PlanRepo      entity.PlanRepositoryInterface
+	DependentRepo entity.DependentRepositoryInterface
+	Queue         DeactivationPublisher
+	Outbox        entity.OutboxRepositoryInterface // opcional; quando definido, substitui a publicação direta na Queue
+}
+
+func NewSubscriptionLifecycleUseCase(
+	subRepo entity.SubscriptionStatusRepository,
+	customerRepo entity.CustomerRepositoryInterface,
+	planRepo entity.PlanRepositoryInterface,
+	dependentRepo entity.DependentRepositoryInterface,
+	queue DeactivationPublisher,
+) *SubscriptionLifecycleUseCase {
+	return &SubscriptionLifecycleUseCase{
+		SubRepo:       subRepo,
+		CustomerRepo:  customerRepo,
+		PlanRepo:      planRepo,
+		DependentRepo: dependentRepo,
+		Queue:         queue,
+	}
+}
+
+// Execute aplica o evento à assinatura mais recente do cliente e retorna o novo status
+// ("" quando não houve transição). Com outbox, o status e o evento de desativação são
+// gravados na mesma transação; sem ele, a desativação é publicada antes da gravação do
+// status para que um retry do webhook volte a publicá-la caso a gravação falhe.
+func (uc *SubscriptionLifecycleUseCase) Execute(ctx context.Context, input SubscriptionLifecycleInput) (string, error) {
+	sub, err := uc.SubRepo.FindLastByCustomerID(ctx, input.CustomerID)
+	if err != nil {
+		return "", fmt.Errorf("falha ao buscar assinatura do cliente: %w", err)
+	}
+
+	gatewaySubID := strings.TrimSpace(input.GatewaySubscriptionID)
+	if gatewaySubID != "" && strings.TrimSpace(sub.PaymentMethodID) != "" && gatewaySubID != strings.TrimSpace(sub.PaymentMethodID) {
+		log.Printf("ℹ️ Lifecycle: evento %s da assinatura %s ignorado; assinatura atual do cliente %s é %s", input.Event, gatewaySubID, input.CustomerID, sub.PaymentMethodID)
+		return "", nil
+	}
+
+	// Com cancelamento agendado a assinatura já foi removida no Asaas, mas o ciclo pago
+	// continua valendo; o ScheduledCancellationWorker encerra quando cancel_at vencer.
+	if endsSubscription(input.Event) && sub.CancelAt != nil && sub.CancelAt.After(time.Now()) && input.Origin != LifecycleOriginCustomerCancellation {
+		log.Printf("ℹ️ Lifecycle: evento %s ignorado; cancelamento do customer %s agendado para %s", input.Event, input.CustomerID, sub.CancelAt.Format("2006-01-02"))
+		return "", nil
+	}
+
+	current := strings.ToUpper(strings.TrimSpace(sub.Status))
+	next, ok := NextSubscriptionStatus(current, input.Event)
+	if !ok {
+		log.Printf("ℹ️ Lifecycle: evento %s não altera assinatura em %s (customer=%s)", input.Event, current, input.CustomerID)
+		return "", nil
+	}
+
+	if revokesAccess(next) && !revokesAccess(current) && uc.Outbox != nil {
+		payload, err := uc.deactivationPayload(ctx, sub, next, input.Origin)
+		if err != nil {
+			return "", err
+		}
+		event, err := entity.NewOutboxEvent(queue.MessageTypeDeactivation, sub.CustomerID, payload)
+		if err != nil {
+			return "", err
+		}
+		if err := uc.Outbox.UpdateStatusWithOutbox(ctx, input.CustomerID, next, CustomerStatusInactive, event); err != nil {
+			return "", fmt.Errorf("erro ao atualizar status da subscription para %s e gravar outbox: %w", next, err)
+		}
+		log.Printf("🔄 Lifecycle: assinatura do customer %s %s -> %s (evento %s); desativação %s gravada no outbox", input.CustomerID, current, next, input.Event, event.ID)
+		return next, nil
+	}
+
+	if revokesAccess(next) && !revokesAccess(current) {
+		if err := uc.publishDeactivation(ctx, sub, next, input.Origin); err != nil {
+			return "", err
+		}
+	}
+
+	if err := uc.SubRepo.UpdateStatusByID(ctx, sub.ID, next); err != nil {
+		return "", fmt.Errorf("erro ao atualizar status da subscription para %s: %w", next, err)
+	}
+
+	// O cadastro só acompanha o acesso: PAST_DUE ainda é cliente ativo.
+	if revokesAccess(next) && !revokesAccess(current) {
+		if err := uc.CustomerRepo.UpdateStatus(ctx, input.CustomerID, CustomerStatusInactive); err != nil {
+			return "", fmt.Errorf("erro ao atualizar status do customer para %s: %w", CustomerStatusInactive, err)
+		}
+	}
+
+	log.Printf("🔄 Lifecycle: assinatura do customer %s %s -> %s (evento %s)", input.CustomerID, current, next, input.Event)
+	return next, nil
+}
+
+func (uc *SubscriptionLifecycleUseCase) publishDeactivation(ctx context.Context, sub *entity.Subscription, reason, origin string) error {
+	if uc.Queue == nil {
+		log.Printf("⚠️ Lifecycle: fila de desativação não configurada; acesso do customer %s não será revogado no provedor", sub.CustomerID)
+		return nil
+	}
+
+	payload, err := uc.deactivationPayload(ctx, sub, reason, origin)
+	if err != nil {
+		return err
+	}
+	if err := uc.Queue.PublishDeactivation(ctx, payload); err != nil {
+		return fmt.Errorf("falha ao publicar desativação: %w", err)
+	}
+
+	log.Printf("📤 Lifecycle: desativação publicada para customer %s (provider=%s, motivo=%s)", sub.CustomerID, payload.Provider, reason)
+	return nil
+}
+
+// deactivationPayload monta a baixa do titular e dos dependentes no provedor do plano atual.
+func (uc *SubscriptionLifecycleUseCase) deactivationPayload(ctx context.Context, sub *entity.Subscription, reason, origin string) (queue.DeactivationPayload, error) {
+	customer, err := uc.CustomerRepo.FindByID(ctx, sub.CustomerID)
+	if err != nil {
+		return queue.DeactivationPayload{}, fmt.Errorf("falha ao buscar dados do cliente: %w", err)
+	}
+
+	plan, err := uc.PlanRepo.FindByID(ctx, sub.PlanID)
+	if err != nil {
+		return queue.DeactivationPayload{}, fmt.Errorf("falha ao buscar plano (%s): %w", sub.PlanID, err)
+	}
+
+	providerPlanCode := strings.TrimSpace(plan.ProviderPlanCode)
+	if providerPlanCode == "" {
+		providerPlanCode = strings.TrimSpace(plan.Name)
+	}
+
+	payload := queue.DeactivationPayload{
+		CustomerID:       customer.ID,
+		PlanID:           plan.ID,
+		ProviderPlanCode: providerPlanCode,
+		Provider:         plan.Provider,
+		Origin:           origin,
+		Reason:           reason,
+		Name:             customer.Name,
+		Email:            customer.Email,
+		CPF:              customer.CPF,
+		Phone:            customer.Phone,
+		BirthDate:        customer.BirthDate,
+		Gender:           genderLabel(customer.Gender),
+	}
+
+	var dependents []*entity.Dependent
+	if uc.DependentRepo != nil {
+		var depErr error
+		dependents, depErr = uc.DependentRepo.FindByCustomerID(ctx, customer.ID)
+		if depErr != nil {
+			log.Printf("⚠️ Lifecycle: falha ao buscar dependentes do cliente (não bloqueia): %v", depErr)
+		}
+		for _, dep := range dependents {
+			if dep == nil {
+				continue
+			}
+			payload.Dependents = append(payload.Dependents, queue.DependentPayload{
+				Name:      dep.Name,
+				CPF:       dep.CPF,
+				BirthDate: dep.BirthDate,
+				Gender:    dep.Gender,
+				Kinship:   dep.Kinship,
+			})
+		}
+	}
+
+	return withEnrollmentDates(payload, sub, dependents), nil
+}
@@ -6,17 +6,32 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/xavierca1/ligue-payments/internal/entity"
 )
 
 type ValidationError struct {
-	Field   string
-	Message string
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
 func (e ValidationError) Error() string {
 	return fmt.Sprintf("%s: %s", e.Field, e.Message)
 }
 
+// newValidationError agrupa os erros de validação num DomainError VALIDATION_ERROR.
+func newValidationError(errs []ValidationError) *DomainError {
+	errMsg := "validation failed: "
+	for _, e := range errs {
+		errMsg += e.Field + " (" + e.Message + "), "
+	}
+	return &DomainError{
+		Code:    "VALIDATION_ERROR",
+		Message: errMsg,
+		Fields:  errs,
+	}
+}
+
 func ValidateCreateCustomerInput(input CreateCustomerInput) []ValidationError {
 	var errors []ValidationError
 
@@ -93,32 +108,12 @@ func ValidateCreateCustomerInput(input CreateCustomerInput) []ValidationError {
 		errors = append(errors, ValidationError{"checkout_action", "must be PAY or RETRY"})
 	}
 
-	if input.PaymentMethod == "CREDIT_CARD" {
-		if input.CardHolder == "" {
-			errors = append(errors, ValidationError{"card_holder", "is required for CREDIT_CARD payment"})
-		}
-		if input.CardNumber == "" {
-			errors = append(errors, ValidationError{"card_number", "is required for CREDIT_CARD payment"})
-		} else if !isValidCardNumber(input.CardNumber) {
-			errors = append(errors, ValidationError{"card_number", "is invalid"})
-		}
-		if input.CardMonth == "" {
-			errors = append(errors, ValidationError{"card_month", "is required for CREDIT_CARD payment"})
-		} else if !isValidMonth(input.CardMonth) {
-			errors = append(errors, ValidationError{"card_month", "must be 01-12"})
-		}
-		if input.CardYear == "" {
-			errors = append(errors, ValidationError{"card_year", "is required for CREDIT_CARD payment"})
-		} else if !isValidYear(input.CardYear) {
-			errors = append(errors, ValidationError{"card_year", "must be a 2 or 4 digit year"})
-		}
-		if input.CardCVV == "" {
-			errors = append(errors, ValidationError{"card_cvv", "is required for CREDIT_CARD payment"})
-		} else if !isValidCVV(input.CardCVV) {
-			errors = append(errors, ValidationError{"card_cvv", "must be 3 or 4 digits"})
-		}
+	if input.PaymentMethod == "CREDIT_CARD" && strings.TrimSpace(input.CreditCardToken) == "" && input.Card != nil {
+		errors = append(errors, input.Card.Validate()...)
 	}
 
+	errors = append(errors, validateDependentInputs(input.Dependents)...)
+
 	if !input.TermsAccepted {
 		errors = append(errors, ValidationError{"terms_accepted", "must be accepted"})
 	}
@@ -131,6 +126,87 @@ func ValidateCreateCustomerInput(input CreateCustomerInput) []ValidationError {
 	return errors
 }
 
+// ValidateCardFields confere o cartão digitado no checkout. Recebe os campos soltos para que
+// número e CVV não precisem entrar em nenhuma struct do use case.
+func ValidateCardFields(holder, number, month, year, cvv string) []ValidationError {
+	var errors []ValidationError
+
+	if holder == "" {
+		errors = append(errors, ValidationError{"card_holder", "is required for CREDIT_CARD payment"})
+	}
+	if number == "" {
+		errors = append(errors, ValidationError{"card_number", "is required for CREDIT_CARD payment"})
+	} else if !isValidCardNumber(number) {
+		errors = append(errors, ValidationError{"card_number", "is invalid"})
+	}
+	if month == "" {
+		errors = append(errors, ValidationError{"card_month", "is required for CREDIT_CARD payment"})
+	} else if !isValidMonth(month) {
+		errors = append(errors, ValidationError{"card_month", "must be 01-12"})
+	}
+	if year == "" {
+		errors = append(errors, ValidationError{"card_year", "is required for CREDIT_CARD payment"})
+	} else if !isValidYear(year) {
+		errors = append(errors, ValidationError{"card_year", "must be a 2 or 4 digit year"})
+	}
+	if cvv == "" {
+		errors = append(errors, ValidationError{"card_cvv", "is required for CREDIT_CARD payment"})
+	} else if !isValidCVV(cvv) {
+		errors = append(errors, ValidationError{"card_cvv", "must be 3 or 4 digits"})
+	}
+
+	return errors
+}
+
+// validateDependentInputs confere os campos de cada dependente. As regras do plano
+// (idade, cônjuges, parentescos aceitos, CPF repetido) ficam em ValidateDependentEligibility.
+func validateDependentInputs(dependents []DependentInput) []ValidationError {
+	var errors []ValidationError
+	for i, dep := range dependents {
+		prefix := fmt.Sprintf("dependents[%d].", i)
+		errors = append(errors, validateDependentInput(dep, prefix)...)
+	}
+	return errors
+}
+
+func validateDependentInput(dep DependentInput, prefix string) []ValidationError {
+	var errors []ValidationError
+
+	if strings.TrimSpace(dep.Name) == "" {
+		errors = append(errors, ValidationError{prefix + "name", "is required"})
+	}
+	if strings.TrimSpace(dep.CPF) == "" {
+		errors = append(errors, ValidationError{prefix + "cpf", "is required"})
+	} else if !isValidCPF(dep.CPF) {
+		errors = append(errors, ValidationError{prefix + "cpf", "CPF inválido"})
+	}
+	if birth, err := time.Parse("2006-01-02", strings.TrimSpace(dep.BirthDate)); err != nil {
+		errors = append(errors, ValidationError{prefix + "birth_date", "must be a valid date (YYYY-MM-DD)"})
+	} else if birth.After(time.Now()) {
+		errors = append(errors, ValidationError{prefix + "birth_date", "must be in the past"})
+	}
+	if _, err := parseDependentGender(dep.Gender); err != nil {
+		errors = append(errors, ValidationError{prefix + "gender", "must be 1, 2 or 3"})
+	}
+	if strings.TrimSpace(dep.Kinship) == "" {
+		errors = append(errors, ValidationError{prefix + "kinship", "is required"})
+	} else if !entity.IsValidKinship(dep.Kinship) {
+		errors = append(errors, ValidationError{prefix + "kinship", "must be FILHO, ENTEADO, CONJUGE, PAI or MAE"})
+	}
+
+	return errors
+}
+
+// ValidateDependentEligibility confere os dependentes do titular contra as regras do plano.
+// fieldPrefix monta o nome do campo a partir da posição do dependente na lista.
+func ValidateDependentEligibility(plan *entity.Plan, titularCPF string, dependents []*entity.Dependent, fieldPrefix func(index int) string) []ValidationError {
+	var errors []ValidationError
+	for _, v := range plan.CheckDependents(titularCPF, dependents, time.Now()) {
+		errors = append(errors, ValidationError{fieldPrefix(v.Index) + v.Field, v.Message})
+	}
+	return errors
+}
+
 func isValidCPF(cpf string) bool {
 
 	cleaned := regexp.MustCompile(`\D`).ReplaceAllString(cpf, "")
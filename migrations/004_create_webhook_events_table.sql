@@ -0,0 +1,32 @@
+-- Migration: Criar inbox de webhooks
+-- Data: 2026-10-17
+-- Descrição: Persiste todo webhook verificado antes do processamento, com deduplicação por event_key
+-- e controle de tentativas para processamento at-least-once
+
+CREATE TABLE IF NOT EXISTS webhook_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    source VARCHAR(32) NOT NULL DEFAULT 'ASAAS',
+    event_key VARCHAR(255) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    payment_id VARCHAR(100),
+    customer_ref VARCHAR(100),
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING', -- PENDING, PROCESSING, PROCESSED, FAILED
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMP WITH TIME ZONE,
+    processed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT uq_webhook_events_source_key UNIQUE (source, event_key),
+    CONSTRAINT chk_webhook_events_status CHECK (status IN ('PENDING', 'PROCESSING', 'PROCESSED', 'FAILED'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_events_due ON webhook_events (status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_events_payment_id ON webhook_events (payment_id);
+
+COMMENT ON TABLE webhook_events IS 'Inbox de webhooks recebidos (Asaas), processados em background com retry';
+COMMENT ON COLUMN webhook_events.event_key IS 'ID do evento no gateway, ou EVENTO:payment_id quando o gateway não envia ID';
+COMMENT ON COLUMN webhook_events.locked_until IS 'Lease do dispatcher; eventos PROCESSING com lease vencido voltam a ser elegíveis';
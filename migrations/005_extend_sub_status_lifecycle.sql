@@ -0,0 +1,9 @@
+-- Migration: Estados do ciclo de vida da assinatura
+-- Data: 2026-10-17
+-- Descrição: Adiciona ao enum sub_status os estados usados pela máquina de estados
+-- dos webhooks Asaas (inadimplência, suspensão, cancelamento e estorno)
+
+ALTER TYPE public.sub_status ADD VALUE IF NOT EXISTS 'PAST_DUE';
+ALTER TYPE public.sub_status ADD VALUE IF NOT EXISTS 'SUSPENDED';
+ALTER TYPE public.sub_status ADD VALUE IF NOT EXISTS 'CANCELED';
+ALTER TYPE public.sub_status ADD VALUE IF NOT EXISTS 'REFUNDED';
@@ -0,0 +1,28 @@
+-- Migration: Criar outbox de eventos para o RabbitMQ
+-- Data: 2026-10-17
+-- Descrição: Eventos de ativação são gravados na mesma transação da mudança de status
+-- da assinatura e publicados depois pelo OutboxRelay, com publisher confirms
+
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    aggregate_id VARCHAR(100) NOT NULL,
+    message_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING', -- PENDING, PUBLISHING, SENT
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMP WITH TIME ZONE,
+    sent_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT chk_outbox_events_status CHECK (status IN ('PENDING', 'PUBLISHING', 'SENT'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_due ON outbox_events (status, next_attempt_at)
+    WHERE status <> 'SENT';
+CREATE INDEX IF NOT EXISTS idx_outbox_events_aggregate ON outbox_events (aggregate_id);
+
+COMMENT ON TABLE outbox_events IS 'Transactional outbox: mensagens para o RabbitMQ gravadas junto com a mudança de estado';
+COMMENT ON COLUMN outbox_events.locked_until IS 'Lease do relay; eventos PUBLISHING com lease vencido voltam a ser elegíveis';
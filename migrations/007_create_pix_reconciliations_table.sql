@@ -0,0 +1,22 @@
+-- Migration: Conciliação de PIX expirados com o Asaas
+-- Data: 2026-10-17
+-- Descrição: Antes de expirar um PIX pendente o worker consulta a cobrança no Asaas;
+-- cada decisão (ativado, expirado ou erro) fica registrada aqui para auditoria
+
+CREATE TABLE IF NOT EXISTS pix_reconciliations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    subscription_id UUID NOT NULL,
+    customer_id UUID NOT NULL,
+    gateway_subscription_id VARCHAR(100),
+    payment_status VARCHAR(50),
+    action VARCHAR(20) NOT NULL, -- ACTIVATED, EXPIRED, ERROR
+    detail TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT chk_pix_reconciliations_action CHECK (action IN ('ACTIVATED', 'EXPIRED', 'ERROR'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_pix_reconciliations_subscription ON pix_reconciliations (subscription_id);
+CREATE INDEX IF NOT EXISTS idx_pix_reconciliations_created_at ON pix_reconciliations (created_at DESC);
+
+COMMENT ON TABLE pix_reconciliations IS 'Decisões do PixExpirationWorker ao conciliar PIX pendentes com o Asaas';
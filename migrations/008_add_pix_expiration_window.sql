@@ -0,0 +1,17 @@
+-- Migration: Janela de pagamento do PIX configurável
+-- Data: 2026-10-17
+-- Descrição: Janela do PIX por plano (ou, na falta, por produto) e prazo gravado em cada
+-- assinatura, usado pelo checkout, pelo vencimento no Asaas e pelo PixExpirationWorker
+
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS pix_expiration_minutes INTEGER
+    CHECK (pix_expiration_minutes IS NULL OR pix_expiration_minutes > 0);
+ALTER TABLE products ADD COLUMN IF NOT EXISTS pix_expiration_minutes INTEGER
+    CHECK (pix_expiration_minutes IS NULL OR pix_expiration_minutes > 0);
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS pix_expires_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_pending_pix ON subscriptions (pix_expires_at)
+    WHERE status = 'PENDING' AND payment_method = 'PIX';
+
+COMMENT ON COLUMN plans.pix_expiration_minutes IS 'Janela de pagamento do PIX; NULL usa a do produto ou PIX_EXPIRATION_MINUTES';
+COMMENT ON COLUMN subscriptions.pix_expires_at IS 'Prazo de pagamento do PIX; NULL (legado) usa created_at + janela padrão';
@@ -0,0 +1,16 @@
+-- Migration: Criar leases dos jobs periódicos
+-- Data: 2026-10-17
+-- Descrição: Eleição de líder por job entre as réplicas do serviço. Cada execução
+-- exige o lease do job; réplicas sem o lease pulam o tick
+
+CREATE TABLE IF NOT EXISTS scheduler_leases (
+    job_name VARCHAR(100) PRIMARY KEY,
+    holder VARCHAR(255) NOT NULL,
+    leased_until TIMESTAMP WITH TIME ZONE NOT NULL,
+    acquired_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE scheduler_leases IS 'Líder de cada job periódico (PixExpirationWorker etc.)';
+COMMENT ON COLUMN scheduler_leases.holder IS 'Réplica que detém o lease (hostname:pid)';
+COMMENT ON COLUMN scheduler_leases.leased_until IS 'Lease vencido libera o job para outra réplica';
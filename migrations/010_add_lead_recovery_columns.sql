@@ -0,0 +1,27 @@
+-- Migration: Cadência de e-mails de recuperação de checkout abandonado
+-- Data: 2026-10-17
+-- Descrição: Garante a tabela leads (capturada por /leads/capture) com as colunas usadas
+-- pelo LeadRecoveryWorker para avançar email_stage e converter leads
+
+CREATE TABLE IF NOT EXISTS leads (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    email VARCHAR(255) NOT NULL UNIQUE,
+    name VARCHAR(255),
+    phone VARCHAR(30),
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING',
+    email_stage INTEGER NOT NULL DEFAULT 0,
+    last_email_sent_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'PENDING';
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS email_stage INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS last_email_sent_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_leads_recovery_due ON leads (email_stage, created_at)
+    WHERE status = 'PENDING';
+CREATE INDEX IF NOT EXISTS idx_leads_email_lower ON leads (LOWER(email));
+
+COMMENT ON COLUMN leads.email_stage IS 'Quantos e-mails de recuperação já foram enviados (0 = nenhum)';
+COMMENT ON COLUMN leads.last_email_sent_at IS 'Envio do último e-mail de recuperação';
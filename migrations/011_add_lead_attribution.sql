@@ -0,0 +1,23 @@
+-- Migration: Atribuição de leads e funil de conversão
+-- Data: 2026-10-17
+-- Descrição: Origem (UTMs/landing page) no lead, plano e momento do checkout, vínculo
+-- customers.lead_id e converted_at para medir captura → checkout → pagamento
+
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS utm_source VARCHAR(255);
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS utm_medium VARCHAR(255);
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS utm_campaign VARCHAR(255);
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS utm_content VARCHAR(255);
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS utm_term VARCHAR(255);
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS landing_page TEXT;
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS plan_id VARCHAR(100);
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS checkout_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS converted_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS lead_id UUID REFERENCES leads(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_leads_created_at ON leads (created_at);
+CREATE INDEX IF NOT EXISTS idx_customers_lead_id ON customers (lead_id);
+
+COMMENT ON COLUMN leads.utm_source IS 'Origem first-touch do lead; NULL aparece como (direto) no funil';
+COMMENT ON COLUMN leads.checkout_at IS 'Primeiro checkout feito com o e-mail do lead';
+COMMENT ON COLUMN customers.lead_id IS 'Lead que originou o cliente (vinculado no checkout)';
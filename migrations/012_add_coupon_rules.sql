@@ -0,0 +1,47 @@
+-- Migration: Regras de cupom
+-- Data: 2026-10-17
+-- Descrição: Desconto em valor fixo, escopo por plano/produto, limites de uso (total e por CPF)
+-- e desconto só nas primeiras N cobranças, com o valor cheio restaurado no Asaas depois
+
+ALTER TABLE coupons ADD COLUMN IF NOT EXISTS discount_type VARCHAR(16) NOT NULL DEFAULT 'PERCENT';
+ALTER TABLE coupons ADD COLUMN IF NOT EXISTS discount_amount_cents INTEGER
+    CHECK (discount_amount_cents IS NULL OR discount_amount_cents > 0);
+ALTER TABLE coupons ADD COLUMN IF NOT EXISTS plan_ids TEXT[];
+ALTER TABLE coupons ADD COLUMN IF NOT EXISTS product_ids TEXT[];
+ALTER TABLE coupons ADD COLUMN IF NOT EXISTS max_redemptions INTEGER
+    CHECK (max_redemptions IS NULL OR max_redemptions > 0);
+ALTER TABLE coupons ADD COLUMN IF NOT EXISTS max_redemptions_per_cpf INTEGER
+    CHECK (max_redemptions_per_cpf IS NULL OR max_redemptions_per_cpf > 0);
+ALTER TABLE coupons ADD COLUMN IF NOT EXISTS duration_cycles INTEGER
+    CHECK (duration_cycles IS NULL OR duration_cycles > 0);
+
+ALTER TABLE coupons DROP CONSTRAINT IF EXISTS chk_coupons_discount_type;
+ALTER TABLE coupons ADD CONSTRAINT chk_coupons_discount_type CHECK (
+    discount_type = 'PERCENT'
+    OR (discount_type = 'FIXED' AND discount_amount_cents IS NOT NULL)
+);
+
+ALTER TABLE coupon_sales ADD COLUMN IF NOT EXISTS customer_cpf VARCHAR(14);
+ALTER TABLE coupon_sales ADD COLUMN IF NOT EXISTS discount_type VARCHAR(16) NOT NULL DEFAULT 'PERCENT';
+ALTER TABLE coupon_sales ADD COLUMN IF NOT EXISTS duration_cycles INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_coupon_sales_code_cpf ON coupon_sales (coupon_code, customer_cpf);
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS full_amount_cents INTEGER;
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS discount_cycles_remaining INTEGER;
+
+-- Pagamentos já contados contra o desconto: PAYMENT_CONFIRMED e PAYMENT_RECEIVED da mesma
+-- cobrança (e retries do webhook) consomem um único ciclo
+CREATE TABLE IF NOT EXISTS subscription_discount_payments (
+    payment_id VARCHAR(100) PRIMARY KEY,
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_discount_cycles ON subscriptions (payment_method_id)
+    WHERE discount_cycles_remaining IS NOT NULL;
+
+COMMENT ON COLUMN coupons.plan_ids IS 'Planos aceitos; NULL/vazio (com product_ids também vazio) vale para todos';
+COMMENT ON COLUMN coupons.duration_cycles IS 'Cobranças com desconto; NULL aplica o desconto em todas';
+COMMENT ON COLUMN subscriptions.discount_cycles_remaining IS 'Cobranças com desconto ainda não pagas; NULL = sem desconto temporário';
+COMMENT ON COLUMN subscriptions.full_amount_cents IS 'Valor cheio aplicado quando discount_cycles_remaining chega a zero';
@@ -0,0 +1,15 @@
+-- Migration: Data de ativação da assinatura
+-- Data: 2026-10-17
+-- Descrição: activated_at guarda a primeira vez que a assinatura ficou ACTIVE; o relatório
+-- de vendedores (/admin/reports/sellers) só conta vendas com assinatura ativada
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS activated_at TIMESTAMP WITH TIME ZONE;
+
+-- Legado: estados que só são alcançados a partir de ACTIVE. CANCELED fica de fora porque
+-- também pode vir de um checkout que nunca foi pago.
+UPDATE subscriptions
+SET activated_at = COALESCE(updated_at, created_at)
+WHERE activated_at IS NULL
+  AND status IN ('ACTIVE', 'PAST_DUE', 'SUSPENDED', 'REFUNDED');
+
+COMMENT ON COLUMN subscriptions.activated_at IS 'Primeira ativação; preenchida ao gravar status ACTIVE e mantida depois';
@@ -0,0 +1,36 @@
+-- Migration: Catálogo de planos
+-- Data: 2026-10-17
+-- Descrição: Ciclo de cobrança, limites e preço de dependentes, flags de venda/exibição
+-- e metadados usados por GET /plans e pelo checkout
+
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS billing_cycle VARCHAR(16) NOT NULL DEFAULT 'MONTHLY';
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS max_dependents INTEGER
+    CHECK (max_dependents IS NULL OR max_dependents >= 0);
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS included_dependents INTEGER NOT NULL DEFAULT 0
+    CHECK (included_dependents >= 0);
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS extra_dependent_price_cents INTEGER NOT NULL DEFAULT 0
+    CHECK (extra_dependent_price_cents >= 0);
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS is_active BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS is_visible BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS features JSONB NOT NULL DEFAULT '[]'::jsonb;
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS badge VARCHAR(64);
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS display_order INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE plans DROP CONSTRAINT IF EXISTS chk_plans_billing_cycle;
+ALTER TABLE plans ADD CONSTRAINT chk_plans_billing_cycle
+    CHECK (billing_cycle IN ('MONTHLY', 'QUARTERLY', 'SEMIANNUALLY', 'YEARLY'));
+
+-- subscriptions.interval passa a receber o ciclo do plano
+ALTER TYPE public.sub_interval ADD VALUE IF NOT EXISTS 'MONTHLY';
+ALTER TYPE public.sub_interval ADD VALUE IF NOT EXISTS 'QUARTERLY';
+ALTER TYPE public.sub_interval ADD VALUE IF NOT EXISTS 'SEMIANNUALLY';
+ALTER TYPE public.sub_interval ADD VALUE IF NOT EXISTS 'YEARLY';
+
+CREATE INDEX IF NOT EXISTS idx_plans_catalog ON plans (product_id, display_order)
+    WHERE is_active = TRUE AND is_visible = TRUE;
+
+COMMENT ON COLUMN plans.max_dependents IS 'Máximo de dependentes no checkout; NULL = sem limite';
+COMMENT ON COLUMN plans.included_dependents IS 'Dependentes incluídos no price_cents';
+COMMENT ON COLUMN plans.extra_dependent_price_cents IS 'Acréscimo por ciclo para cada dependente acima de included_dependents';
+COMMENT ON COLUMN plans.is_visible IS 'FALSE tira do GET /plans, mas o plano continua vendável por ID';
@@ -0,0 +1,23 @@
+-- Migration: Troca de plano
+-- Data: 2026-10-17
+-- Descrição: Histórico de upgrades/downgrades de assinaturas ativas, com o pró-rata cobrado
+-- (cobrança avulsa no Asaas) ou creditado na próxima cobrança
+
+CREATE TABLE IF NOT EXISTS subscription_plan_changes (
+    id UUID PRIMARY KEY,
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    customer_id UUID NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    from_plan_id UUID NOT NULL REFERENCES plans(id),
+    to_plan_id UUID NOT NULL REFERENCES plans(id),
+    from_amount_cents INTEGER NOT NULL,
+    to_amount_cents INTEGER NOT NULL,
+    next_amount_cents INTEGER NOT NULL,
+    prorated_cents INTEGER NOT NULL DEFAULT 0,
+    charge_id VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_plan_changes_customer ON subscription_plan_changes (customer_id, created_at DESC);
+
+COMMENT ON COLUMN subscription_plan_changes.prorated_cents IS 'Positivo: cobrado agora em charge_id; negativo: crédito abatido da próxima cobrança';
+COMMENT ON COLUMN subscription_plan_changes.next_amount_cents IS 'Valor da próxima cobrança; o valor cheio volta depois dela via discount_cycles_remaining';
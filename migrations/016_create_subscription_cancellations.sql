@@ -0,0 +1,29 @@
+-- Migration: Cancelamento pelo cliente
+-- Data: 2026-10-17
+-- Descrição: Motivo e desfecho de cada pedido de cancelamento (oferta de retenção, retido,
+-- agendado para o fim do ciclo ou imediato) e a data de fim de acesso dos agendados
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS cancel_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS subscription_cancellations (
+    id UUID PRIMARY KEY,
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    customer_id UUID NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    reason VARCHAR(32) NOT NULL,
+    comment TEXT,
+    outcome VARCHAR(32) NOT NULL
+        CHECK (outcome IN ('RETENTION_OFFERED', 'RETAINED', 'SCHEDULED', 'CANCELED')),
+    retention_coupon_code VARCHAR(64),
+    effective_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_cancellations_subscription ON subscription_cancellations (subscription_id, outcome);
+CREATE INDEX IF NOT EXISTS idx_subscription_cancellations_reason ON subscription_cancellations (reason, created_at);
+
+-- Cancelamentos agendados vencidos, lidos pelo ScheduledCancellationWorker
+CREATE INDEX IF NOT EXISTS idx_subscriptions_cancel_at ON subscriptions (cancel_at)
+    WHERE cancel_at IS NOT NULL;
+
+COMMENT ON COLUMN subscriptions.cancel_at IS 'Fim do ciclo pago de um cancelamento agendado; a assinatura no Asaas já foi removida';
+COMMENT ON COLUMN subscription_cancellations.reason IS 'PRICE, NOT_USING, SERVICE_QUALITY, OTHER_PROVIDER, FINANCIAL ou OTHER';
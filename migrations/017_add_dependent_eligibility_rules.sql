@@ -0,0 +1,56 @@
+-- Migration: Elegibilidade de dependentes
+-- Data: 2026-10-17
+-- Descrição: Regras por plano para dependentes (idade de filhos, cônjuges, parentescos aceitos)
+-- e a marcação de filhos estudantes
+
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS child_max_age INTEGER NOT NULL DEFAULT 21
+    CHECK (child_max_age >= 0);
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS student_child_max_age INTEGER NOT NULL DEFAULT 24
+    CHECK (student_child_max_age >= 0);
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS max_spouses INTEGER NOT NULL DEFAULT 1
+    CHECK (max_spouses >= 0);
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS allowed_kinships JSONB NOT NULL
+    DEFAULT '["FILHO", "ENTEADO", "CONJUGE"]'::jsonb;
+
+-- Pais só nos planos família: Ligue Mais Cuidado e Ligue Cuidado Total
+UPDATE plans SET allowed_kinships = '["FILHO", "ENTEADO", "CONJUGE", "PAI", "MAE"]'::jsonb
+WHERE id IN ('8f305d38-4c1e-435a-a57b-7c77e2a5f141', '255f7631-751d-4edd-a165-65a0c55824eb');
+
+ALTER TABLE dependents ADD COLUMN IF NOT EXISTS is_student BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Parentescos passam a ser um enum fechado, com o mesmo mapeamento de entity.NormalizeKinship:
+-- maiúsculas, sem acento, femininos e sinônimos no masculino (FILHA → FILHO, ESPOSA → CONJUGE)
+UPDATE dependents SET kinship = CASE norm
+        WHEN 'FILHA' THEN 'FILHO'
+        WHEN 'ENTEADA' THEN 'ENTEADO'
+        WHEN 'ESPOSA' THEN 'CONJUGE'
+        WHEN 'ESPOSO' THEN 'CONJUGE'
+        WHEN 'COMPANHEIRA' THEN 'CONJUGE'
+        WHEN 'COMPANHEIRO' THEN 'CONJUGE'
+        ELSE norm
+    END
+FROM (
+    SELECT id AS dep_id, TRANSLATE(UPPER(TRIM(kinship)), 'ÁÂÃÉÊÍÓÔÕÚÇ', 'AAAEEIOOOUC') AS norm
+    FROM dependents
+) n
+WHERE dependents.id = n.dep_id;
+
+-- O que não tem equivalente (OUTRO, IRMAO, PRIMO...) fica marcado para revisão manual;
+-- corrigir o parentesco pelo PUT do dependente limpa a marcação
+ALTER TABLE dependents ADD COLUMN IF NOT EXISTS kinship_needs_review BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE dependents SET kinship_needs_review = TRUE
+WHERE kinship NOT IN ('FILHO', 'ENTEADO', 'CONJUGE', 'PAI', 'MAE');
+
+CREATE INDEX IF NOT EXISTS idx_dependents_kinship_needs_review ON dependents(customer_id)
+    WHERE kinship_needs_review;
+
+ALTER TABLE dependents DROP CONSTRAINT IF EXISTS dependents_kinship_check;
+ALTER TABLE dependents ADD CONSTRAINT dependents_kinship_check
+    CHECK (kinship IN ('FILHO', 'ENTEADO', 'CONJUGE', 'PAI', 'MAE') OR kinship_needs_review);
+
+COMMENT ON COLUMN plans.child_max_age IS 'Filhos e enteados precisam ter menos que essa idade; 0 = sem limite';
+COMMENT ON COLUMN plans.student_child_max_age IS 'Limite de idade para filhos e enteados estudantes; 0 = usa child_max_age';
+COMMENT ON COLUMN plans.max_spouses IS 'Cônjuges por titular; 0 = sem limite';
+COMMENT ON COLUMN plans.allowed_kinships IS 'Parentescos aceitos (FILHO, ENTEADO, CONJUGE, PAI, MAE); [] = todos';
+COMMENT ON COLUMN dependents.is_student IS 'Filho/enteado estudante, com limite de idade student_child_max_age';
+COMMENT ON COLUMN dependents.kinship_needs_review IS 'Parentesco legado sem equivalente no enum; aguarda correção manual';
@@ -0,0 +1,11 @@
+-- Migration: Cartão tokenizado
+-- Data: 2026-10-17
+-- Descrição: creditCardToken do Asaas guardado no cliente para pagar novas assinaturas sem
+-- redigitar o cartão. Número e CVV nunca são gravados; brand e final são só para exibição
+
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS credit_card_token VARCHAR(128);
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS credit_card_brand VARCHAR(32);
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS credit_card_last_digits VARCHAR(4);
+
+COMMENT ON COLUMN customers.credit_card_token IS 'creditCardToken do Asaas, vinculado ao gateway_id do cliente';
+COMMENT ON COLUMN customers.credit_card_last_digits IS 'Últimos 4 dígitos do cartão, para exibição';
@@ -0,0 +1,11 @@
+-- Migration: Backoff e limite de tentativas na conciliação de PIX
+-- Data: 2026-10-17
+-- Descrição: Cada falha do PixExpirationWorker (consulta ou cancelamento no Asaas, expiração
+-- local) incrementa o contador e adia a próxima tentativa com backoff exponencial; esgotado o
+-- limite a assinatura sai da fila da conciliação e fica PENDING para análise manual
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS pix_reconcile_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS pix_next_reconcile_at TIMESTAMP WITH TIME ZONE;
+
+COMMENT ON COLUMN subscriptions.pix_reconcile_attempts IS 'Falhas consecutivas do PixExpirationWorker nesta assinatura';
+COMMENT ON COLUMN subscriptions.pix_next_reconcile_at IS 'Próxima tentativa de conciliação após falha; NULL = sem espera';
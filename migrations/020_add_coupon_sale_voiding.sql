@@ -0,0 +1,27 @@
+-- Migration: Vendas de cupom anuladas e desconto percentual obrigatório
+-- Data: 2026-10-17
+-- Descrição: Checkout refeito, recusado ou PIX expirado anula a venda do cupom (voided_at),
+-- que deixa de contar nos limites max_redemptions/max_redemptions_per_cpf. Cupom PERCENT
+-- sem percentual deixa de valer 10% por padrão: o valor passa a ser obrigatório
+
+ALTER TABLE coupon_sales ADD COLUMN IF NOT EXISTS voided_at TIMESTAMP WITH TIME ZONE;
+
+-- Vendas já gravadas de assinaturas que nunca foram pagas
+UPDATE coupon_sales cs
+SET voided_at = NOW()
+WHERE cs.voided_at IS NULL
+  AND NOT EXISTS (
+      SELECT 1 FROM subscriptions s
+      WHERE s.id = cs.subscription_id
+        AND (s.activated_at IS NOT NULL OR s.status = 'PENDING')
+  );
+
+CREATE INDEX IF NOT EXISTS idx_coupon_sales_active_code ON coupon_sales ((UPPER(coupon_code))) WHERE voided_at IS NULL;
+
+ALTER TABLE coupons ALTER COLUMN discount_percent DROP DEFAULT;
+ALTER TABLE coupons DROP CONSTRAINT IF EXISTS chk_coupons_percent_required;
+ALTER TABLE coupons ADD CONSTRAINT chk_coupons_percent_required CHECK (
+    discount_type <> 'PERCENT' OR discount_percent BETWEEN 1 AND 100
+) NOT VALID;
+
+COMMENT ON COLUMN coupon_sales.voided_at IS 'Venda anulada (checkout descartado, recusado ou PIX expirado); não conta nos limites do cupom';
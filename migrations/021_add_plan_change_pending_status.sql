@@ -0,0 +1,27 @@
+-- Migration: Troca de plano em duas fases
+-- Data: 2026-10-17
+-- Descrição: A troca é gravada como PENDING antes de cobrar o pró-rata e alterar o valor no Asaas;
+-- o ID dela é a chave de idempotência (externalReference da cobrança), então um retry retoma a
+-- mesma troca em vez de cobrar de novo. O crédito de downgrade passa a valer só a partir da
+-- próxima cobrança (discount_from_due_date).
+
+ALTER TABLE subscription_plan_changes ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'APPLIED';
+ALTER TABLE subscription_plan_changes ADD COLUMN IF NOT EXISTS coupon_code VARCHAR(64);
+ALTER TABLE subscription_plan_changes ADD COLUMN IF NOT EXISTS credit_from_date DATE;
+ALTER TABLE subscription_plan_changes ADD COLUMN IF NOT EXISTS applied_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE subscription_plan_changes SET applied_at = created_at WHERE status = 'APPLIED' AND applied_at IS NULL;
+
+ALTER TABLE subscription_plan_changes DROP CONSTRAINT IF EXISTS chk_subscription_plan_changes_status;
+ALTER TABLE subscription_plan_changes ADD CONSTRAINT chk_subscription_plan_changes_status
+    CHECK (status IN ('PENDING', 'APPLIED'));
+
+-- Uma troca em andamento por assinatura
+CREATE UNIQUE INDEX IF NOT EXISTS idx_subscription_plan_changes_pending
+    ON subscription_plan_changes (subscription_id) WHERE status = 'PENDING';
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS discount_from_due_date DATE;
+
+COMMENT ON COLUMN subscription_plan_changes.status IS 'PENDING: gravada antes das chamadas ao Asaas; APPLIED: plano e valor trocados no banco';
+COMMENT ON COLUMN subscription_plan_changes.coupon_code IS 'Cupom permanente da assinatura mantido no valor do plano novo';
+COMMENT ON COLUMN subscriptions.discount_from_due_date IS 'Só cobranças com vencimento a partir desta data consomem discount_cycles_remaining (crédito de downgrade); NULL = qualquer cobrança';
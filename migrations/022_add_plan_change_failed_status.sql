@@ -0,0 +1,15 @@
+-- Migration: Troca de plano abandonada
+-- Data: 2026-10-17
+-- Descrição: Troca PENDING que não pode ser concluída (assinatura alterada depois do Asaas,
+-- cobrança do pró-rata recusada, ou liberada pelo admin) passa a FAILED: o valor no Asaas volta
+-- ao do banco, a cobrança do pró-rata é cancelada e a assinatura fica livre para outra troca.
+
+ALTER TABLE subscription_plan_changes ADD COLUMN IF NOT EXISTS failure_reason TEXT;
+ALTER TABLE subscription_plan_changes ADD COLUMN IF NOT EXISTS failed_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE subscription_plan_changes DROP CONSTRAINT IF EXISTS chk_subscription_plan_changes_status;
+ALTER TABLE subscription_plan_changes ADD CONSTRAINT chk_subscription_plan_changes_status
+    CHECK (status IN ('PENDING', 'APPLIED', 'FAILED'));
+
+COMMENT ON COLUMN subscription_plan_changes.status IS 'PENDING: gravada antes das chamadas ao Asaas; APPLIED: plano e valor trocados no banco; FAILED: abandonada, Asaas revertido';
+COMMENT ON COLUMN subscription_plan_changes.failure_reason IS 'Motivo do abandono da troca (FAILED)';
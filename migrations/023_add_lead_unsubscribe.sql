@@ -0,0 +1,8 @@
+-- Migration: Descadastro da recuperação de checkout
+-- Data: 2026-10-17
+-- Descrição: Lead que clica em "não quero mais receber" no e-mail de recuperação sai da
+-- cadência. A marcação sobrevive a novas capturas e checkouts com o mesmo e-mail.
+
+ALTER TABLE leads ADD COLUMN IF NOT EXISTS unsubscribed_at TIMESTAMP WITH TIME ZONE;
+
+COMMENT ON COLUMN leads.unsubscribed_at IS 'Quando o lead pediu para não receber mais e-mails de recuperação; NULL = pode receber';
@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/xavierca1/ligue-payments/internal/infra/queue"
+)
+
+// TestRetryPolicySchedulesNextAttempt - Primeira falha vai para a primeira fila de retry com o motivo nos headers
+func TestRetryPolicySchedulesNextAttempt(t *testing.T) {
+	policy := queue.RetryPolicy{MaxAttempts: 3}
+	d := amqp.Delivery{Type: queue.MessageTypeActivation, Body: []byte(`{"customer_id":"cust-1"}`)}
+
+	routingKey, msg, parked := policy.Next(d, "doc24 timeout")
+
+	assert.False(t, parked)
+	assert.Equal(t, "q.activations.retry.1", routingKey)
+	assert.Equal(t, queue.MessageTypeActivation, msg.Type)
+	assert.Equal(t, d.Body, msg.Body)
+	assert.Equal(t, 1, queue.AttemptsFromHeaders(msg.Headers))
+	assert.Equal(t, "doc24 timeout", msg.Headers[queue.HeaderLastError])
+	assert.Len(t, msg.Headers[queue.HeaderErrorHistory], 1)
+}
+
+// TestRetryPolicyParksAfterMaxAttempts - Esgotadas as tentativas, a mensagem vai para a fila de estacionamento
+func TestRetryPolicyParksAfterMaxAttempts(t *testing.T) {
+	policy := queue.RetryPolicy{MaxAttempts: 3}
+	d := amqp.Delivery{Headers: amqp.Table{
+		queue.HeaderRetryCount:   int64(2),
+		queue.HeaderErrorHistory: []interface{}{"tentativa 1: a", "tentativa 2: b"},
+	}}
+
+	routingKey, msg, parked := policy.Next(d, "c")
+
+	assert.True(t, parked)
+	assert.Equal(t, queue.ParkingQueueName, routingKey)
+	assert.Equal(t, 3, queue.AttemptsFromHeaders(msg.Headers))
+	assert.Len(t, msg.Headers[queue.HeaderErrorHistory], 3)
+}
+
+// TestRetryQueueNameUsesLastTier - Tentativas além das faixas configuradas reaproveitam o maior atraso
+func TestRetryQueueNameUsesLastTier(t *testing.T) {
+	last := len(queue.RetryDelays)
+	assert.Equal(t, queue.RetryQueueName(last), queue.RetryQueueName(last+3))
+	assert.Equal(t, "q.activations.retry.2", queue.RetryQueueName(2))
+}
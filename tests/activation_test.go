@@ -21,9 +21,14 @@ func (m *MockDoc24Client) CreateBeneficiary(ctx context.Context, input queue.Act
 	return args.Error(0)
 }
 
-func (m *MockDoc24Client) GetBeneficiaryID(cpf string) string {
-	args := m.Called(cpf)
-	return args.String(0)
+func (m *MockDoc24Client) DeactivateBeneficiary(ctx context.Context, input queue.DeactivationPayload) error {
+	args := m.Called(ctx, input)
+	return args.Error(0)
+}
+
+func (m *MockDoc24Client) GetBeneficiaryID(ctx context.Context, cpf string) (string, error) {
+	args := m.Called(ctx, cpf)
+	return args.String(0), args.Error(1)
 }
 
 // MockKommoService
@@ -0,0 +1,348 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/infra/worker"
+	"github.com/xavierca1/ligue-payments/internal/usecase"
+)
+
+type MockCancellationRepository struct {
+	mock.Mock
+}
+
+func (m *MockCancellationRepository) Save(ctx context.Context, cancellation *entity.SubscriptionCancellation) error {
+	args := m.Called(ctx, cancellation)
+	return args.Error(0)
+}
+
+func (m *MockCancellationRepository) RetentionAccepted(ctx context.Context, subscriptionID string) (bool, error) {
+	args := m.Called(ctx, subscriptionID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockCancellationRepository) ApplyRetention(ctx context.Context, cancellation *entity.SubscriptionCancellation, discount entity.RetentionDiscount) error {
+	args := m.Called(ctx, cancellation, discount)
+	return args.Error(0)
+}
+
+func (m *MockCancellationRepository) ScheduleCancellation(ctx context.Context, cancellation *entity.SubscriptionCancellation) error {
+	args := m.Called(ctx, cancellation)
+	return args.Error(0)
+}
+
+func (m *MockCancellationRepository) UnscheduleCancellation(ctx context.Context, cancellation *entity.SubscriptionCancellation) error {
+	args := m.Called(ctx, cancellation)
+	return args.Error(0)
+}
+
+func (m *MockCancellationRepository) FindDueCancellations(ctx context.Context, now time.Time, limit int) ([]*entity.Subscription, error) {
+	args := m.Called(ctx, now, limit)
+	return args.Get(0).([]*entity.Subscription), args.Error(1)
+}
+
+type MockCancellationGateway struct {
+	mock.Mock
+}
+
+func (m *MockCancellationGateway) UpdateSubscriptionValue(gatewaySubscriptionID string, valueCents int) error {
+	args := m.Called(gatewaySubscriptionID, valueCents)
+	return args.Error(0)
+}
+
+func (m *MockCancellationGateway) DeleteSubscription(subscriptionID string) error {
+	args := m.Called(subscriptionID)
+	return args.Error(0)
+}
+
+type MockSubscriptionLifecycle struct {
+	mock.Mock
+}
+
+func (m *MockSubscriptionLifecycle) Execute(ctx context.Context, input usecase.SubscriptionLifecycleInput) (string, error) {
+	args := m.Called(ctx, input)
+	return args.String(0), args.Error(1)
+}
+
+var cancellationPlan = &entity.Plan{ID: "plan-dia", Name: "Ligue Saúde em Dia", PriceCents: 4990, ProductID: "prod-tele"}
+
+var cancellingCustomer = &entity.Customer{ID: "cust-1", Name: "João Silva", Email: "joao@example.com", CPF: "12345678900"}
+
+// subscriptionToCancel é a assinatura de cust-1 no plano cancellationPlan, 10 dias antes da próxima cobrança.
+func subscriptionToCancel(status string) *entity.Subscription {
+	return &entity.Subscription{
+		ID:              "sub-1",
+		CustomerID:      "cust-1",
+		PlanID:          "plan-dia",
+		Amount:          4990,
+		Status:          status,
+		PaymentMethodID: "sub_asaas_1",
+		NextBillingDate: time.Now().AddDate(0, 0, 10),
+	}
+}
+
+// useRetentionCoupon registra o cupom FICA20 (20% por 3 ciclos) no tracker global durante o teste.
+func useRetentionCoupon(t *testing.T) *MockCouponTracker {
+	tracker := new(MockCouponTracker)
+	tracker.On("GetActiveCoupon", mock.Anything, "FICA20").Return(&usecase.CouponDetails{
+		Code: "FICA20", SellerName: "Retenção", DiscountType: usecase.CouponDiscountPercent, DiscountPercent: 20, DurationCycles: 3,
+	}, nil)
+	useCouponTracker(t, tracker)
+	return tracker
+}
+
+// TestCancelSubscriptionValidatesRequest - Motivo fora da lista e CPF de outra pessoa são recusados
+func TestCancelSubscriptionValidatesRequest(t *testing.T) {
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(cancellingCustomer, nil)
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(subscriptionToCancel("CANCELED"), nil)
+	gateway := new(MockCancellationGateway)
+
+	uc := usecase.NewCancelSubscriptionUseCase(subRepo, customerRepo, new(MockPlanRepository), new(MockCancellationRepository), gateway, new(MockSubscriptionLifecycle))
+
+	_, err := uc.Execute(context.Background(), usecase.CancelSubscriptionInput{CustomerID: "cust-1", CPF: "123.456.789-00", Reason: "CANSEI"})
+	assert.Equal(t, "INVALID_CANCELLATION_REASON", err.(*usecase.DomainError).Code)
+
+	_, err = uc.Execute(context.Background(), usecase.CancelSubscriptionInput{CustomerID: "cust-1", CPF: "999.999.999-99", Reason: "PRICE"})
+	assert.Equal(t, "CPF_MISMATCH", err.(*usecase.DomainError).Code)
+
+	_, err = uc.Execute(context.Background(), usecase.CancelSubscriptionInput{CustomerID: "cust-1", CPF: "123.456.789-00", Reason: "PRICE"})
+	assert.Equal(t, "SUBSCRIPTION_ALREADY_CANCELED", err.(*usecase.DomainError).Code)
+
+	gateway.AssertNotCalled(t, "DeleteSubscription", mock.Anything)
+}
+
+// TestCancelSubscriptionOffersRetention - Sem confirmação, o primeiro pedido devolve a oferta e não cancela
+func TestCancelSubscriptionOffersRetention(t *testing.T) {
+	useRetentionCoupon(t)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(cancellingCustomer, nil)
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(subscriptionToCancel("ACTIVE"), nil)
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, "plan-dia").Return(cancellationPlan, nil)
+	cancels := new(MockCancellationRepository)
+	cancels.On("RetentionAccepted", mock.Anything, "sub-1").Return(false, nil)
+	cancels.On("Save", mock.Anything, mock.MatchedBy(func(c *entity.SubscriptionCancellation) bool {
+		return c.Outcome == entity.CancellationRetentionOffered && c.Reason == "PRICE" && c.RetentionCouponCode == "FICA20"
+	})).Return(nil)
+	gateway := new(MockCancellationGateway)
+	lifecycle := new(MockSubscriptionLifecycle)
+
+	uc := usecase.NewCancelSubscriptionUseCase(subRepo, customerRepo, planRepo, cancels, gateway, lifecycle)
+	uc.RetentionCouponCode = "FICA20"
+	output, err := uc.Execute(context.Background(), usecase.CancelSubscriptionInput{CustomerID: "cust-1", CPF: "12345678900", Reason: "price"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, usecase.CancellationStatusRetentionOffered, output.Status)
+	assert.Equal(t, 998, output.RetentionOffer.DiscountAmountCents)
+	assert.Equal(t, 3992, output.RetentionOffer.FinalAmountCents)
+	assert.Equal(t, 3, output.RetentionOffer.DurationCycles)
+	cancels.AssertExpectations(t)
+	gateway.AssertNotCalled(t, "DeleteSubscription", mock.Anything)
+	lifecycle.AssertNotCalled(t, "Execute", mock.Anything, mock.Anything)
+}
+
+// TestCancelSubscriptionAcceptRetention - Aceitar a oferta aplica o desconto no Asaas e no banco
+func TestCancelSubscriptionAcceptRetention(t *testing.T) {
+	tracker := useRetentionCoupon(t)
+	tracker.On("TrackSale", mock.Anything, mock.MatchedBy(func(sale usecase.CouponSaleRecord) bool {
+		return sale.CouponCode == "FICA20" && sale.SubscriptionID == "sub-1" && sale.FinalAmountCents == 3992
+	})).Return(nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(cancellingCustomer, nil)
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(subscriptionToCancel("ACTIVE"), nil)
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, "plan-dia").Return(cancellationPlan, nil)
+	cancels := new(MockCancellationRepository)
+	cancels.On("RetentionAccepted", mock.Anything, "sub-1").Return(false, nil)
+	cancels.On("ApplyRetention", mock.Anything, mock.MatchedBy(func(c *entity.SubscriptionCancellation) bool {
+		return c.Outcome == entity.CancellationRetained
+	}), mock.MatchedBy(func(d entity.RetentionDiscount) bool {
+		return d.AmountCents == 3992 && d.FullAmountCents == 4990 && d.Cycles != nil && *d.Cycles == 3
+	})).Return(nil)
+	gateway := new(MockCancellationGateway)
+	gateway.On("UpdateSubscriptionValue", "sub_asaas_1", 3992).Return(nil)
+
+	uc := usecase.NewCancelSubscriptionUseCase(subRepo, customerRepo, planRepo, cancels, gateway, new(MockSubscriptionLifecycle))
+	uc.RetentionCouponCode = "FICA20"
+	output, err := uc.Execute(context.Background(), usecase.CancelSubscriptionInput{CustomerID: "cust-1", CPF: "12345678900", Reason: "PRICE", AcceptRetention: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, usecase.CancellationStatusRetained, output.Status)
+	cancels.AssertExpectations(t)
+	tracker.AssertExpectations(t)
+	gateway.AssertExpectations(t)
+	gateway.AssertNotCalled(t, "DeleteSubscription", mock.Anything)
+}
+
+// TestCancelSubscriptionSchedulesAtPeriodEnd - Confirmado, remove no Asaas e mantém o acesso até o fim do ciclo
+func TestCancelSubscriptionSchedulesAtPeriodEnd(t *testing.T) {
+	sub := subscriptionToCancel("ACTIVE")
+	_, cycleEnd := cancellationPlan.CurrentCycle(sub.NextBillingDate, time.Now())
+
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(cancellingCustomer, nil)
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(sub, nil)
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, "plan-dia").Return(cancellationPlan, nil)
+	cancels := new(MockCancellationRepository)
+	cancels.On("RetentionAccepted", mock.Anything, "sub-1").Return(true, nil) // já retido uma vez: sem nova oferta
+	scheduled := false
+	cancels.On("ScheduleCancellation", mock.Anything, mock.MatchedBy(func(c *entity.SubscriptionCancellation) bool {
+		return c.Outcome == entity.CancellationScheduled && c.EffectiveAt != nil && c.EffectiveAt.Equal(cycleEnd) && c.Comment == "caro demais"
+	})).Run(func(mock.Arguments) { scheduled = true }).Return(nil)
+	gateway := new(MockCancellationGateway)
+	gateway.On("DeleteSubscription", "sub_asaas_1").Run(func(mock.Arguments) {
+		assert.True(t, scheduled, "cancel_at precisa estar gravado antes da remoção no Asaas")
+	}).Return(nil)
+	mailer := new(MockEmailService)
+	mailer.On("SendCancellationEmail", "João Silva", "joao@example.com", "Ligue Saúde em Dia", cycleEnd).Return(nil)
+	lifecycle := new(MockSubscriptionLifecycle)
+
+	uc := usecase.NewCancelSubscriptionUseCase(subRepo, customerRepo, planRepo, cancels, gateway, lifecycle)
+	uc.EmailService = mailer
+	uc.RetentionCouponCode = "FICA20"
+	output, err := uc.Execute(context.Background(), usecase.CancelSubscriptionInput{CustomerID: "cust-1", CPF: "12345678900", Reason: "PRICE", Comment: " caro demais "})
+
+	assert.NoError(t, err)
+	assert.Equal(t, usecase.CancellationStatusScheduled, output.Status)
+	assert.True(t, output.AccessUntil.Equal(cycleEnd))
+	cancels.AssertExpectations(t)
+	gateway.AssertExpectations(t)
+	mailer.AssertExpectations(t)
+	lifecycle.AssertNotCalled(t, "Execute", mock.Anything, mock.Anything)
+}
+
+// TestCancelSubscriptionScheduleFailures - Falha no banco não toca o Asaas; falha no Asaas desfaz o agendamento
+func TestCancelSubscriptionScheduleFailures(t *testing.T) {
+	input := usecase.CancelSubscriptionInput{CustomerID: "cust-1", CPF: "12345678900", Reason: "PRICE", Confirm: true}
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(cancellingCustomer, nil)
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(subscriptionToCancel("ACTIVE"), nil)
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, "plan-dia").Return(cancellationPlan, nil)
+
+	t.Run("falha no banco", func(t *testing.T) {
+		cancels := new(MockCancellationRepository)
+		cancels.On("ScheduleCancellation", mock.Anything, mock.Anything).Return(assert.AnError)
+		gateway := new(MockCancellationGateway)
+
+		uc := usecase.NewCancelSubscriptionUseCase(subRepo, customerRepo, planRepo, cancels, gateway, new(MockSubscriptionLifecycle))
+		_, err := uc.Execute(context.Background(), input)
+
+		assert.Equal(t, "DATABASE_ERROR", err.(*usecase.TechnicalError).Code)
+		gateway.AssertNotCalled(t, "DeleteSubscription", mock.Anything)
+	})
+
+	t.Run("falha no Asaas", func(t *testing.T) {
+		var scheduled *entity.SubscriptionCancellation
+		cancels := new(MockCancellationRepository)
+		cancels.On("ScheduleCancellation", mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { scheduled = args.Get(1).(*entity.SubscriptionCancellation) }).
+			Return(nil)
+		cancels.On("UnscheduleCancellation", mock.Anything, mock.MatchedBy(func(c *entity.SubscriptionCancellation) bool {
+			return c == scheduled
+		})).Return(nil)
+		gateway := new(MockCancellationGateway)
+		gateway.On("DeleteSubscription", "sub_asaas_1").Return(errors.New("timeout"))
+		mailer := new(MockEmailService)
+
+		uc := usecase.NewCancelSubscriptionUseCase(subRepo, customerRepo, planRepo, cancels, gateway, new(MockSubscriptionLifecycle))
+		uc.EmailService = mailer
+		_, err := uc.Execute(context.Background(), input)
+
+		assert.Equal(t, "GATEWAY_ERROR", err.(*usecase.TechnicalError).Code)
+		cancels.AssertExpectations(t)
+		mailer.AssertNotCalled(t, "SendCancellationEmail", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+// TestCancelSubscriptionImmediate - Cancelamento imediato passa pelo lifecycle, que revoga o acesso
+func TestCancelSubscriptionImmediate(t *testing.T) {
+	useRetentionCoupon(t)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(cancellingCustomer, nil)
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(subscriptionToCancel("ACTIVE"), nil)
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, "plan-dia").Return(cancellationPlan, nil)
+	cancels := new(MockCancellationRepository)
+	cancels.On("RetentionAccepted", mock.Anything, "sub-1").Return(false, nil)
+	cancels.On("Save", mock.Anything, mock.MatchedBy(func(c *entity.SubscriptionCancellation) bool {
+		return c.Outcome == entity.CancellationCanceled && c.Reason == "NOT_USING"
+	})).Return(nil)
+	gateway := new(MockCancellationGateway)
+	gateway.On("DeleteSubscription", "sub_asaas_1").Return(nil)
+	lifecycle := new(MockSubscriptionLifecycle)
+	lifecycle.On("Execute", mock.Anything, usecase.SubscriptionLifecycleInput{
+		CustomerID:            "cust-1",
+		Event:                 "SUBSCRIPTION_DELETED",
+		GatewaySubscriptionID: "sub_asaas_1",
+		Origin:                usecase.LifecycleOriginCustomerCancellation,
+	}).Return("CANCELED", nil)
+	mailer := new(MockEmailService)
+	mailer.On("SendCancellationEmail", "João Silva", "joao@example.com", "Ligue Saúde em Dia", mock.Anything).Return(nil)
+
+	uc := usecase.NewCancelSubscriptionUseCase(subRepo, customerRepo, planRepo, cancels, gateway, lifecycle)
+	uc.EmailService = mailer
+	uc.RetentionCouponCode = "FICA20"
+	output, err := uc.Execute(context.Background(), usecase.CancelSubscriptionInput{CustomerID: "cust-1", CPF: "12345678900", Reason: "NOT_USING", Immediate: true, Confirm: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, usecase.CancellationStatusCanceled, output.Status)
+	lifecycle.AssertExpectations(t)
+	cancels.AssertExpectations(t)
+	cancels.AssertNotCalled(t, "ScheduleCancellation", mock.Anything, mock.Anything)
+	mailer.AssertExpectations(t)
+}
+
+// TestSubscriptionLifecycleKeepsScheduledCancellation - O SUBSCRIPTION_DELETED do Asaas não encerra o ciclo já pago
+func TestSubscriptionLifecycleKeepsScheduledCancellation(t *testing.T) {
+	ctx := context.Background()
+	cancelAt := time.Now().Add(72 * time.Hour)
+	mockSubRepo := new(MockSubscriptionRepository)
+	mockQueue := new(MockQueueProducer)
+	mockSubRepo.On("FindLastByCustomerID", ctx, "cust-1").Return(&entity.Subscription{CustomerID: "cust-1", Status: "ACTIVE", PaymentMethodID: "sub_1", CancelAt: &cancelAt}, nil)
+
+	uc := usecase.NewSubscriptionLifecycleUseCase(mockSubRepo, new(MockCustomerRepository), new(MockPlanRepository), nil, mockQueue)
+	next, err := uc.Execute(ctx, usecase.SubscriptionLifecycleInput{CustomerID: "cust-1", Event: "SUBSCRIPTION_DELETED", GatewaySubscriptionID: "sub_1", Origin: "WEBHOOK_ASAAS"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "", next)
+	mockSubRepo.AssertNotCalled(t, "UpdateStatusByID", mock.Anything, mock.Anything, mock.Anything)
+	mockQueue.AssertNotCalled(t, "PublishDeactivation", mock.Anything, mock.Anything)
+}
+
+// TestScheduledCancellationWorker - Cancelamentos vencidos são concluídos pelo lifecycle
+func TestScheduledCancellationWorker(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockCancellationRepository)
+	lifecycle := new(MockSubscriptionLifecycle)
+
+	repo.On("FindDueCancellations", ctx, mock.Anything, 50).Return([]*entity.Subscription{
+		{ID: "sub-1", CustomerID: "cust-1", PaymentMethodID: "sub_asaas_1"},
+		{ID: "sub-2", CustomerID: "cust-2", PaymentMethodID: "sub_asaas_2"},
+	}, nil)
+	lifecycle.On("Execute", ctx, mock.MatchedBy(func(input usecase.SubscriptionLifecycleInput) bool {
+		return input.CustomerID == "cust-1" && input.Event == "SUBSCRIPTION_DELETED" && input.Origin == "SCHEDULED_CANCELLATION"
+	})).Return("CANCELED", nil)
+	lifecycle.On("Execute", ctx, mock.MatchedBy(func(input usecase.SubscriptionLifecycleInput) bool {
+		return input.CustomerID == "cust-2"
+	})).Return("", nil)
+
+	w := worker.NewScheduledCancellationWorker(repo, lifecycle)
+
+	assert.Equal(t, 1, w.Run(ctx))
+	lifecycle.AssertNumberOfCalls(t, "Execute", 2)
+}
@@ -0,0 +1,264 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/infra/http/handlers"
+	"github.com/xavierca1/ligue-payments/internal/infra/integration/asaas"
+	"github.com/xavierca1/ligue-payments/internal/usecase"
+)
+
+// MockCardRepository
+type MockCardRepository struct {
+	mock.Mock
+}
+
+func (m *MockCardRepository) SaveCardToken(ctx context.Context, customerID string, card entity.CreditCardToken) error {
+	args := m.Called(ctx, customerID, card)
+	return args.Error(0)
+}
+
+// fakeCardTokenizer faz o papel do cartão digitado no handler.
+type fakeCardTokenizer struct {
+	validation []usecase.ValidationError
+	token      *entity.CreditCardToken
+	err        error
+	calls      []string
+}
+
+func (f *fakeCardTokenizer) Validate() []usecase.ValidationError { return f.validation }
+
+func (f *fakeCardTokenizer) Tokenize(gatewayCustomerID string) (*entity.CreditCardToken, error) {
+	f.calls = append(f.calls, gatewayCustomerID)
+	return f.token, f.err
+}
+
+var cardPlan = &entity.Plan{ID: "plan-card", ProductID: "prod-card", PriceCents: 4990}
+
+// TestCheckoutTokenizesTypedCard - Cartão digitado é tokenizado no customer do Asaas e só o token vai para a assinatura
+func TestCheckoutTokenizesTypedCard(t *testing.T) {
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, "plan-card").Return(cardPlan, nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByCPF", mock.Anything, mock.Anything).Return(nil, errors.New("sql: no rows in result set"))
+	customerRepo.On("FindByEmailAndProductID", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("sql: no rows in result set"))
+	customerRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+	customerRepo.On("UpdateGatewayID", mock.Anything, mock.Anything, "asaas-cust-new").Return(nil)
+	gateway := new(MockPaymentGateway)
+	gateway.On("CreateCustomer", mock.Anything).Return("asaas-cust-new", nil)
+	gateway.On("Subscribe", asaas.SubscribeInput{
+		CustomerID: "asaas-cust-new", Price: 49.90, Cycle: "MONTHLY", CreditCardToken: "tok-123", RemoteIP: "200.10.20.30",
+	}).Return("asaas-sub-1", "ACTIVE", nil)
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+	cards := new(MockCardRepository)
+	cards.On("SaveCardToken", mock.Anything, mock.Anything, entity.CreditCardToken{Token: "tok-123", Brand: "VISA", LastDigits: "0366"}).Return(nil)
+
+	uc := usecase.NewCreateCustomerUseCase(customerRepo, subRepo, planRepo, gateway, new(MockQueueProducer), new(MockEmailService), nil, "", nil)
+	uc.CardRepo = cards
+
+	card := &fakeCardTokenizer{token: &entity.CreditCardToken{Token: "tok-123", Brand: "VISA", LastDigits: "0366"}}
+	input := checkoutInput("plan-card", "CREDIT_CARD")
+	input.RemoteIP = "200.10.20.30"
+	input.Card = card
+
+	output, err := uc.Execute(context.Background(), input)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ACTIVE", output.Status)
+	assert.Equal(t, []string{"asaas-cust-new"}, card.calls)
+	gateway.AssertExpectations(t)
+	cards.AssertCalled(t, "SaveCardToken", mock.Anything, output.ID, entity.CreditCardToken{Token: "tok-123", Brand: "VISA", LastDigits: "0366"})
+}
+
+// TestCheckoutUsesFrontendToken - Token enviado pelo front dispensa a tokenização no backend
+func TestCheckoutUsesFrontendToken(t *testing.T) {
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, "plan-card").Return(cardPlan, nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByCPF", mock.Anything, mock.Anything).Return(nil, errors.New("sql: no rows in result set"))
+	customerRepo.On("FindByEmailAndProductID", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("sql: no rows in result set"))
+	customerRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+	customerRepo.On("UpdateGatewayID", mock.Anything, mock.Anything, "asaas-cust-new").Return(nil)
+	gateway := new(MockPaymentGateway)
+	gateway.On("CreateCustomer", mock.Anything).Return("asaas-cust-new", nil)
+	gateway.On("Subscribe", mock.MatchedBy(func(in asaas.SubscribeInput) bool {
+		return in.CreditCardToken == "tok-front"
+	})).Return("asaas-sub-1", "ACTIVE", nil)
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+	uc := usecase.NewCreateCustomerUseCase(customerRepo, subRepo, planRepo, gateway, new(MockQueueProducer), new(MockEmailService), nil, "", nil)
+
+	input := checkoutInput("plan-card", "CREDIT_CARD")
+	input.CreditCardToken = " tok-front "
+	_, err := uc.Execute(context.Background(), input)
+
+	assert.NoError(t, err)
+	gateway.AssertExpectations(t)
+}
+
+// TestCheckoutReturningCustomerNeedsNewCard - Cartão salvo não é cobrado pelo checkout público, só com CPF/e-mail
+func TestCheckoutReturningCustomerNeedsNewCard(t *testing.T) {
+	existing := &entity.Customer{
+		ID: "cust-1", CPF: "52998224725", Status: "CANCELED", GatewayID: "asaas-cust-1",
+		Card: entity.CreditCardToken{Token: "tok-saved", Brand: "MASTERCARD", LastDigits: "4444"},
+	}
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, "plan-card").Return(cardPlan, nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByCPF", mock.Anything, "529.982.247-25").Return(existing, nil)
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(&entity.Subscription{ID: "sub-old", Status: "CANCELED"}, nil)
+	gateway := new(MockPaymentGateway)
+
+	uc := usecase.NewCreateCustomerUseCase(customerRepo, subRepo, planRepo, gateway, new(MockQueueProducer), new(MockEmailService), nil, "", nil)
+
+	_, err := uc.Execute(context.Background(), checkoutInput("plan-card", "CREDIT_CARD"))
+
+	de, ok := err.(*usecase.DomainError)
+	assert.True(t, ok)
+	assert.Equal(t, "VALIDATION_ERROR", de.Code)
+	assert.Equal(t, "credit_card_token", de.Fields[0].Field)
+	gateway.AssertNotCalled(t, "Subscribe", mock.Anything)
+
+	subRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+	gateway.On("Subscribe", mock.MatchedBy(func(in asaas.SubscribeInput) bool {
+		return in.CustomerID == "asaas-cust-1" && in.CreditCardToken == "tok-new"
+	})).Return("asaas-sub-1", "ACTIVE", nil)
+
+	input := checkoutInput("plan-card", "CREDIT_CARD")
+	input.CreditCardToken = "tok-new"
+	_, err = uc.Execute(context.Background(), input)
+
+	assert.NoError(t, err)
+	gateway.AssertNotCalled(t, "CreateCustomer", mock.Anything)
+	gateway.AssertExpectations(t)
+}
+
+// TestCheckoutCreditCardRequiresCard - Sem token ou cartão digitado o checkout é recusado antes do Asaas
+func TestCheckoutCreditCardRequiresCard(t *testing.T) {
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, "plan-card").Return(cardPlan, nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByCPF", mock.Anything, mock.Anything).Return(nil, errors.New("sql: no rows in result set"))
+	customerRepo.On("FindByEmailAndProductID", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("sql: no rows in result set"))
+	gateway := new(MockPaymentGateway)
+
+	uc := usecase.NewCreateCustomerUseCase(customerRepo, new(MockSubscriptionRepository), planRepo, gateway, new(MockQueueProducer), new(MockEmailService), nil, "", nil)
+
+	_, err := uc.Execute(context.Background(), checkoutInput("plan-card", "CREDIT_CARD"))
+
+	de, ok := err.(*usecase.DomainError)
+	assert.True(t, ok)
+	assert.Equal(t, "VALIDATION_ERROR", de.Code)
+	assert.Equal(t, []usecase.ValidationError{{Field: "credit_card_token", Message: "is required for CREDIT_CARD payment"}}, de.Fields)
+	customerRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	gateway.AssertNotCalled(t, "CreateCustomer", mock.Anything)
+}
+
+// TestCheckoutCardValidationAndRefusal - Cartão inválido volta como VALIDATION_ERROR; recusa na tokenização vira PAYMENT_FAILED
+func TestCheckoutCardValidationAndRefusal(t *testing.T) {
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, "plan-card").Return(cardPlan, nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByCPF", mock.Anything, mock.Anything).Return(nil, errors.New("sql: no rows in result set"))
+	customerRepo.On("FindByEmailAndProductID", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("sql: no rows in result set"))
+	customerRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+	customerRepo.On("UpdateGatewayID", mock.Anything, mock.Anything, "asaas-cust-new").Return(nil)
+	customerRepo.On("Delete", mock.Anything, mock.Anything).Return(nil)
+	gateway := new(MockPaymentGateway)
+	gateway.On("CreateCustomer", mock.Anything).Return("asaas-cust-new", nil)
+	cards := new(MockCardRepository)
+
+	uc := usecase.NewCreateCustomerUseCase(customerRepo, new(MockSubscriptionRepository), planRepo, gateway, new(MockQueueProducer), new(MockEmailService), nil, "", nil)
+	uc.CardRepo = cards
+
+	input := checkoutInput("plan-card", "CREDIT_CARD")
+	input.Card = &fakeCardTokenizer{validation: usecase.ValidateCardFields("MARIA", "4532015112830367", "13", "26", "12")}
+	_, err := uc.Execute(context.Background(), input)
+
+	de, ok := err.(*usecase.DomainError)
+	assert.True(t, ok)
+	assert.Equal(t, []usecase.ValidationError{
+		{Field: "card_number", Message: "is invalid"},
+		{Field: "card_month", Message: "must be 01-12"},
+		{Field: "card_cvv", Message: "must be 3 or 4 digits"},
+	}, de.Fields)
+
+	input.Card = &fakeCardTokenizer{err: errors.New("cartão recusado")}
+	_, err = uc.Execute(context.Background(), input)
+
+	de, ok = err.(*usecase.DomainError)
+	assert.True(t, ok)
+	assert.Equal(t, "PAYMENT_FAILED", de.Code)
+	gateway.AssertNotCalled(t, "Subscribe", mock.Anything)
+	cards.AssertNotCalled(t, "SaveCardToken", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// fakeTokenizationGateway simula /creditCard/tokenizeCreditCard do Asaas.
+type fakeTokenizationGateway struct {
+	inputs []asaas.TokenizeCreditCardInput
+}
+
+func (g *fakeTokenizationGateway) TokenizeCreditCard(input asaas.TokenizeCreditCardInput) (*asaas.CreditCardToken, error) {
+	g.inputs = append(g.inputs, input)
+	return &asaas.CreditCardToken{Token: "tok-edge", Brand: "VISA", LastDigits: "0366"}, nil
+}
+
+// TestCheckoutHandlerTokenizesAtEdge - O handler tokeniza o cartão do payload; o use case só recebe o token
+func TestCheckoutHandlerTokenizesAtEdge(t *testing.T) {
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, "plan-card").Return(cardPlan, nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByCPF", mock.Anything, mock.Anything).Return(nil, errors.New("sql: no rows in result set"))
+	customerRepo.On("FindByEmailAndProductID", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("sql: no rows in result set"))
+	customerRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+	customerRepo.On("UpdateGatewayID", mock.Anything, mock.Anything, "asaas-cust-new").Return(nil)
+	gateway := new(MockPaymentGateway)
+	gateway.On("CreateCustomer", mock.Anything).Return("asaas-cust-new", nil)
+	gateway.On("Subscribe", mock.MatchedBy(func(in asaas.SubscribeInput) bool {
+		return in.CreditCardToken == "tok-edge" && in.RemoteIP == "200.10.20.30"
+	})).Return("asaas-sub-1", "ACTIVE", nil)
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+	cards := new(MockCardRepository)
+	cards.On("SaveCardToken", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	uc := usecase.NewCreateCustomerUseCase(customerRepo, subRepo, planRepo, gateway, new(MockQueueProducer), new(MockEmailService), nil, "", nil)
+	uc.CardRepo = cards
+	tokenizer := &fakeTokenizationGateway{}
+	handler := handlers.NewCustomerHandler(uc, nil, nil)
+	handler.CardGateway = tokenizer
+
+	payload := map[string]any{
+		"name": "Maria Santos", "email": "maria@example.com", "cpf": "529.982.247-25", "phone": "(21) 98888-8888",
+		"birth_date": "1985-03-22", "gender": "2", "plan_id": "plan-card", "payment_method": "CREDIT_CARD",
+		"street": "Avenida B", "number": "456", "district": "Centro", "city": "Rio de Janeiro", "state": "RJ", "zip_code": "20040-020",
+		"terms_accepted": true, "terms_accepted_at": time.Now().Format(time.RFC3339),
+		"card_holder": "MARIA SANTOS", "card_number": "4532 0151 1283 0366", "card_month": "12", "card_year": "2030", "card_cvv": "123",
+	}
+	body, _ := json.Marshal(payload)
+	req := httptest.NewRequest("POST", "/checkout", bytes.NewReader(body))
+	req.Header.Set("X-Forwarded-For", "200.10.20.30, 10.0.0.1")
+	w := httptest.NewRecorder()
+
+	handler.CreateCheckoutHandler(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Len(t, tokenizer.inputs, 1)
+	assert.Equal(t, "asaas-cust-new", tokenizer.inputs[0].CustomerID)
+	assert.Equal(t, "4532015112830366", tokenizer.inputs[0].CardNumber)
+	assert.Equal(t, "200.10.20.30", tokenizer.inputs[0].RemoteIP)
+	gateway.AssertExpectations(t)
+}
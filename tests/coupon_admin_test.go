@@ -0,0 +1,214 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/infra/http/handlers"
+)
+
+type MockCouponRepository struct {
+	mock.Mock
+}
+
+func (m *MockCouponRepository) Create(ctx context.Context, coupon *entity.Coupon) error {
+	args := m.Called(ctx, coupon)
+	return args.Error(0)
+}
+
+func (m *MockCouponRepository) Update(ctx context.Context, coupon *entity.Coupon) error {
+	args := m.Called(ctx, coupon)
+	return args.Error(0)
+}
+
+func (m *MockCouponRepository) FindByCode(ctx context.Context, code string) (*entity.Coupon, error) {
+	args := m.Called(ctx, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.Coupon), args.Error(1)
+}
+
+func (m *MockCouponRepository) List(ctx context.Context, activeOnly bool) ([]*entity.Coupon, error) {
+	args := m.Called(ctx, activeOnly)
+	return args.Get(0).([]*entity.Coupon), args.Error(1)
+}
+
+func (m *MockCouponRepository) SellerReport(ctx context.Context, from, to time.Time) ([]entity.SellerSalesRow, error) {
+	args := m.Called(ctx, from, to)
+	return args.Get(0).([]entity.SellerSalesRow), args.Error(1)
+}
+
+func couponAdminRouter(repo *MockCouponRepository) http.Handler {
+	handler := handlers.NewCouponAdminHandler(repo)
+	r := chi.NewRouter()
+	r.Get("/admin/coupons", handler.List)
+	r.Post("/admin/coupons", handler.Create)
+	r.Patch("/admin/coupons/{code}", handler.Update)
+	r.Delete("/admin/coupons/{code}", handler.Deactivate)
+	r.Get("/admin/reports/sellers", handler.SellerReport)
+	return r
+}
+
+func doCouponAdminRequest(router http.Handler, method, target string, body interface{}) *httptest.ResponseRecorder {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(method, target, bytes.NewReader(payload)))
+	return rec
+}
+
+// TestCouponValidateRules - Tipo, faixa do desconto e vigência
+func TestCouponValidateRules(t *testing.T) {
+	valid := entity.Coupon{Code: " promo10 ", SellerName: "Ana", DiscountPercent: 10}
+	valid.Normalize()
+	assert.NoError(t, valid.Validate())
+	assert.Equal(t, "PROMO10", valid.Code)
+	assert.Equal(t, entity.CouponDiscountPercent, valid.DiscountType)
+
+	fixedWithoutAmount := entity.Coupon{Code: "FIXO", SellerName: "Ana", DiscountType: "fixed"}
+	fixedWithoutAmount.Normalize()
+	assert.Error(t, fixedWithoutAmount.Validate())
+
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, -1)
+	inverted := entity.Coupon{Code: "DATAS", SellerName: "Ana", DiscountPercent: 10, StartsAt: &start, EndsAt: &end}
+	inverted.Normalize()
+	assert.Error(t, inverted.Validate())
+
+	badCode := entity.Coupon{Code: "com espaço", SellerName: "Ana", DiscountPercent: 10}
+	badCode.Normalize()
+	assert.Error(t, badCode.Validate())
+}
+
+// TestCouponAdminCreate - Cria cupom ativo com código normalizado
+func TestCouponAdminCreate(t *testing.T) {
+	repo := new(MockCouponRepository)
+	repo.On("Create", mock.Anything, mock.MatchedBy(func(c *entity.Coupon) bool {
+		return c.Code == "VINTE" && c.DiscountType == entity.CouponDiscountFixed && c.DiscountAmountCents == 2000 && c.IsActive
+	})).Return(nil)
+
+	rec := doCouponAdminRequest(couponAdminRouter(repo), http.MethodPost, "/admin/coupons", map[string]interface{}{
+		"code":                  "vinte",
+		"seller_name":           "Ana",
+		"discount_type":         "FIXED",
+		"discount_amount_cents": 2000,
+		"plan_ids":              []string{"plan-basic"},
+	})
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	repo.AssertExpectations(t)
+}
+
+// TestCouponAdminCreateDuplicate - Código existente retorna 409
+func TestCouponAdminCreateDuplicate(t *testing.T) {
+	repo := new(MockCouponRepository)
+	repo.On("Create", mock.Anything, mock.Anything).Return(entity.ErrCouponAlreadyExists)
+
+	rec := doCouponAdminRequest(couponAdminRouter(repo), http.MethodPost, "/admin/coupons", map[string]interface{}{
+		"code": "PROMO10", "seller_name": "Ana", "discount_percent": 10,
+	})
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "COUPON_ALREADY_EXISTS")
+}
+
+// TestCouponAdminUpdateKeepsOmittedFields - PATCH altera só os campos enviados
+func TestCouponAdminUpdateKeepsOmittedFields(t *testing.T) {
+	repo := new(MockCouponRepository)
+	repo.On("FindByCode", mock.Anything, "PROMO10").Return(&entity.Coupon{
+		ID: "c-1", Code: "PROMO10", SellerName: "Ana", DiscountType: "PERCENT", DiscountPercent: 10, MaxRedemptions: 50, IsActive: true,
+	}, nil)
+	repo.On("Update", mock.Anything, mock.MatchedBy(func(c *entity.Coupon) bool {
+		return c.DiscountPercent == 15 && c.MaxRedemptions == 50 && c.SellerName == "Ana"
+	})).Return(nil)
+
+	rec := doCouponAdminRequest(couponAdminRouter(repo), http.MethodPatch, "/admin/coupons/PROMO10", map[string]interface{}{"discount_percent": 15})
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	repo.AssertExpectations(t)
+}
+
+// TestCouponAdminUpdateRejectsCodeChange - O código é referenciado pelas vendas
+func TestCouponAdminUpdateRejectsCodeChange(t *testing.T) {
+	repo := new(MockCouponRepository)
+	repo.On("FindByCode", mock.Anything, "PROMO10").Return(&entity.Coupon{ID: "c-1", Code: "PROMO10", SellerName: "Ana", DiscountPercent: 10}, nil)
+
+	rec := doCouponAdminRequest(couponAdminRouter(repo), http.MethodPatch, "/admin/coupons/PROMO10", map[string]interface{}{"code": "OUTRO"})
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+// TestCouponAdminDeactivate - DELETE desativa em vez de excluir
+func TestCouponAdminDeactivate(t *testing.T) {
+	repo := new(MockCouponRepository)
+	repo.On("FindByCode", mock.Anything, "PROMO10").Return(&entity.Coupon{ID: "c-1", Code: "PROMO10", SellerName: "Ana", DiscountPercent: 10, IsActive: true}, nil)
+	repo.On("Update", mock.Anything, mock.MatchedBy(func(c *entity.Coupon) bool { return !c.IsActive })).Return(nil)
+
+	rec := doCouponAdminRequest(couponAdminRouter(repo), http.MethodDelete, "/admin/coupons/PROMO10", nil)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	repo.AssertExpectations(t)
+}
+
+// TestCouponAdminNotFound - Cupom inexistente retorna 404
+func TestCouponAdminNotFound(t *testing.T) {
+	repo := new(MockCouponRepository)
+	repo.On("FindByCode", mock.Anything, "NADA").Return(nil, entity.ErrCouponNotFound)
+
+	rec := doCouponAdminRequest(couponAdminRouter(repo), http.MethodDelete, "/admin/coupons/NADA", nil)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// TestSellerReportCSV - Exporta o período com totais em reais
+func TestSellerReportCSV(t *testing.T) {
+	repo := new(MockCouponRepository)
+	from := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	repo.On("SellerReport", mock.Anything, from, to).Return([]entity.SellerSalesRow{
+		{SellerName: "Ana", Sales: 2, GrossCents: 9980, DiscountCents: 998, NetCents: 8982},
+		{SellerName: "Bruno", Sales: 1, GrossCents: 4990, DiscountCents: 2000, NetCents: 2990},
+	}, nil)
+
+	rec := doCouponAdminRequest(couponAdminRouter(repo), http.MethodGet, "/admin/reports/sellers?from=2026-09-01&to=2026-09-30&format=csv", nil)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/csv")
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	assert.Equal(t, []string{
+		"seller_name,sales,gross_brl,discount_brl,net_brl",
+		"Ana,2,99.80,9.98,89.82",
+		"Bruno,1,49.90,20.00,29.90",
+		"TOTAL,3,149.70,29.98,119.72",
+	}, lines)
+}
+
+// TestSellerReportJSON - Sem format, retorna JSON em centavos
+func TestSellerReportJSON(t *testing.T) {
+	repo := new(MockCouponRepository)
+	repo.On("SellerReport", mock.Anything, mock.Anything, mock.Anything).Return([]entity.SellerSalesRow{
+		{SellerName: "Ana", Sales: 2, GrossCents: 9980, DiscountCents: 998, NetCents: 8982},
+	}, nil)
+
+	rec := doCouponAdminRequest(couponAdminRouter(repo), http.MethodGet, "/admin/reports/sellers?from=2026-09-01&to=2026-09-30", nil)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var response handlers.SellerReportResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "2026-09-30", response.To)
+	assert.Equal(t, int64(8982), response.Totals.NetCents)
+}
@@ -0,0 +1,379 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/infra/http/handlers"
+	"github.com/xavierca1/ligue-payments/internal/usecase"
+)
+
+type MockCouponTracker struct {
+	mock.Mock
+}
+
+func (m *MockCouponTracker) GetActiveCoupon(ctx context.Context, code string) (*usecase.CouponDetails, error) {
+	args := m.Called(ctx, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*usecase.CouponDetails), args.Error(1)
+}
+
+func (m *MockCouponTracker) CountRedemptions(ctx context.Context, code, cpf string) (usecase.CouponRedemptions, error) {
+	args := m.Called(ctx, code, cpf)
+	return args.Get(0).(usecase.CouponRedemptions), args.Error(1)
+}
+
+func (m *MockCouponTracker) TrackSale(ctx context.Context, sale usecase.CouponSaleRecord) error {
+	args := m.Called(ctx, sale)
+	return args.Error(0)
+}
+
+func (m *MockCouponTracker) VoidSales(ctx context.Context, subscriptionID string) error {
+	args := m.Called(ctx, subscriptionID)
+	return args.Error(0)
+}
+
+type MockDiscountCycleRepository struct {
+	mock.Mock
+}
+
+func (m *MockDiscountCycleRepository) ConsumeDiscountCycle(ctx context.Context, gatewaySubscriptionID, paymentID string, dueDate time.Time) (*entity.DiscountCycleState, error) {
+	args := m.Called(ctx, gatewaySubscriptionID, paymentID, dueDate)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.DiscountCycleState), args.Error(1)
+}
+
+func (m *MockDiscountCycleRepository) RestoreFullAmount(ctx context.Context, subscriptionID string) error {
+	args := m.Called(ctx, subscriptionID)
+	return args.Error(0)
+}
+
+type MockSubscriptionValueUpdater struct {
+	mock.Mock
+}
+
+func (m *MockSubscriptionValueUpdater) UpdateSubscriptionValue(gatewaySubscriptionID string, valueCents int) error {
+	args := m.Called(gatewaySubscriptionID, valueCents)
+	return args.Error(0)
+}
+
+type MockCouponCycles struct {
+	mock.Mock
+}
+
+func (m *MockCouponCycles) RegisterPayment(ctx context.Context, gatewaySubscriptionID, paymentID string, dueDate time.Time) error {
+	args := m.Called(ctx, gatewaySubscriptionID, paymentID, dueDate)
+	return args.Error(0)
+}
+
+func useCouponTracker(t *testing.T, tracker usecase.CouponTracker) {
+	usecase.SetCouponTracker(tracker)
+	t.Cleanup(func() { usecase.SetCouponTracker(nil) })
+}
+
+func assertDomainCode(t *testing.T, err error, code string) {
+	t.Helper()
+	de, ok := err.(*usecase.DomainError)
+	if assert.True(t, ok, "esperado DomainError, recebido %v", err) {
+		assert.Equal(t, code, de.Code)
+	}
+}
+
+var couponTestPlan = &entity.Plan{ID: "plan-basic", ProductID: "prod-tele", PriceCents: 4990}
+
+// TestEvaluateCouponPercentAndFixed - Percentual e valor fixo, com o fixo limitado ao preço
+func TestEvaluateCouponPercentAndFixed(t *testing.T) {
+	ctx := context.Background()
+	tracker := new(MockCouponTracker)
+	useCouponTracker(t, tracker)
+
+	tracker.On("GetActiveCoupon", ctx, "DEZ").Return(&usecase.CouponDetails{Code: "DEZ", DiscountType: usecase.CouponDiscountPercent, DiscountPercent: 10}, nil)
+	tracker.On("GetActiveCoupon", ctx, "VINTE").Return(&usecase.CouponDetails{Code: "VINTE", DiscountType: usecase.CouponDiscountFixed, DiscountAmountCents: 2000}, nil)
+	tracker.On("GetActiveCoupon", ctx, "TUDO").Return(&usecase.CouponDetails{Code: "TUDO", DiscountType: usecase.CouponDiscountFixed, DiscountAmountCents: 9000}, nil)
+
+	percent, err := usecase.EvaluateCoupon(ctx, "DEZ", couponTestPlan, 0, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 499, percent.DiscountAmountCents)
+	assert.Equal(t, 4491, percent.FinalAmountCents)
+
+	fixed, err := usecase.EvaluateCoupon(ctx, "VINTE", couponTestPlan, 0, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 2000, fixed.DiscountAmountCents)
+	assert.Equal(t, 2990, fixed.FinalAmountCents)
+
+	capped, err := usecase.EvaluateCoupon(ctx, "TUDO", couponTestPlan, 0, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 4990, capped.DiscountAmountCents)
+	assert.Equal(t, 0, capped.FinalAmountCents)
+
+	tracker.AssertNotCalled(t, "CountRedemptions", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestEvaluateCouponPlanScope - Cupom restrito vale para o plano ou para o produto listado
+func TestEvaluateCouponPlanScope(t *testing.T) {
+	ctx := context.Background()
+	tracker := new(MockCouponTracker)
+	useCouponTracker(t, tracker)
+
+	tracker.On("GetActiveCoupon", ctx, "PREMIUM").Return(&usecase.CouponDetails{Code: "PREMIUM", DiscountPercent: 15, PlanIDs: []string{"plan-premium"}}, nil)
+	tracker.On("GetActiveCoupon", ctx, "TELE").Return(&usecase.CouponDetails{Code: "TELE", DiscountPercent: 15, ProductIDs: []string{"prod-tele"}}, nil)
+
+	_, err := usecase.EvaluateCoupon(ctx, "PREMIUM", couponTestPlan, 0, "")
+	assertDomainCode(t, err, "COUPON_NOT_APPLICABLE")
+
+	_, err = usecase.EvaluateCoupon(ctx, "TELE", couponTestPlan, 0, "")
+	assert.NoError(t, err)
+
+	// Sem plano só vigência e limites são verificados
+	_, err = usecase.EvaluateCoupon(ctx, "PREMIUM", nil, 0, "")
+	assert.NoError(t, err)
+}
+
+// TestEvaluateCouponUsageCaps - Limite global e por CPF
+func TestEvaluateCouponUsageCaps(t *testing.T) {
+	ctx := context.Background()
+	tracker := new(MockCouponTracker)
+	useCouponTracker(t, tracker)
+
+	tracker.On("GetActiveCoupon", ctx, "CEM").Return(&usecase.CouponDetails{Code: "CEM", DiscountPercent: 10, MaxRedemptions: 100}, nil)
+	tracker.On("CountRedemptions", ctx, "CEM", "").Return(usecase.CouponRedemptions{Total: 100}, nil)
+
+	tracker.On("GetActiveCoupon", ctx, "UMAVEZ").Return(&usecase.CouponDetails{Code: "UMAVEZ", DiscountPercent: 10, MaxPerCPF: 1}, nil)
+	tracker.On("CountRedemptions", ctx, "UMAVEZ", "123.456.789-00").Return(usecase.CouponRedemptions{Total: 3, ByCPF: 1}, nil)
+	tracker.On("CountRedemptions", ctx, "UMAVEZ", "98765432100").Return(usecase.CouponRedemptions{Total: 3, ByCPF: 0}, nil)
+
+	_, err := usecase.EvaluateCoupon(ctx, "CEM", couponTestPlan, 0, "")
+	assertDomainCode(t, err, "COUPON_EXHAUSTED")
+
+	_, err = usecase.EvaluateCoupon(ctx, "UMAVEZ", couponTestPlan, 0, "123.456.789-00")
+	assertDomainCode(t, err, "COUPON_ALREADY_USED")
+
+	_, err = usecase.EvaluateCoupon(ctx, "UMAVEZ", couponTestPlan, 0, "98765432100")
+	assert.NoError(t, err)
+}
+
+// TestEvaluateCouponCountFailureIsTechnical - Falha ao contar usos não aceita o cupom
+func TestEvaluateCouponCountFailureIsTechnical(t *testing.T) {
+	ctx := context.Background()
+	tracker := new(MockCouponTracker)
+	useCouponTracker(t, tracker)
+
+	tracker.On("GetActiveCoupon", ctx, "CEM").Return(&usecase.CouponDetails{Code: "CEM", DiscountPercent: 10, MaxRedemptions: 100}, nil)
+	tracker.On("CountRedemptions", ctx, "CEM", "").Return(usecase.CouponRedemptions{}, errors.New("connection refused"))
+
+	_, err := usecase.EvaluateCoupon(ctx, "CEM", couponTestPlan, 0, "")
+	_, ok := err.(*usecase.TechnicalError)
+	assert.True(t, ok)
+}
+
+// TestCouponHandlerValidateWithPlan - /coupons/validate usa as regras do checkout e retorna os valores
+func TestCouponHandlerValidateWithPlan(t *testing.T) {
+	tracker := new(MockCouponTracker)
+	useCouponTracker(t, tracker)
+	planRepo := new(MockPlanRepository)
+
+	tracker.On("GetActiveCoupon", mock.Anything, "VINTE").Return(&usecase.CouponDetails{Code: "VINTE", DiscountType: usecase.CouponDiscountFixed, DiscountAmountCents: 2000, DurationCycles: 3}, nil)
+	planRepo.On("FindByID", mock.Anything, "plan-basic").Return(couponTestPlan, nil)
+
+	handler := handlers.NewCouponHandler(planRepo)
+	body, _ := json.Marshal(map[string]string{"coupon_code": "VINTE", "plan_id": "plan-basic"})
+	rec := httptest.NewRecorder()
+	handler.Validate(rec, httptest.NewRequest(http.MethodPost, "/coupons/validate", bytes.NewReader(body)))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "FIXED", response["discount_type"])
+	assert.Equal(t, float64(4990), response["original_amount_cents"])
+	assert.Equal(t, float64(2990), response["final_amount_cents"])
+	assert.Equal(t, float64(3), response["duration_cycles"])
+}
+
+// TestCouponHandlerValidateWithDependents - O desconto cotado é sobre o preço com dependentes, como no checkout
+func TestCouponHandlerValidateWithDependents(t *testing.T) {
+	tracker := new(MockCouponTracker)
+	useCouponTracker(t, tracker)
+	planRepo := new(MockPlanRepository)
+
+	familyPlan := &entity.Plan{ID: "plan-family", ProductID: "prod-tele", PriceCents: 4990, IncludedDependents: 1, ExtraDependentPriceCents: 1000}
+	tracker.On("GetActiveCoupon", mock.Anything, "DEZ").Return(&usecase.CouponDetails{Code: "DEZ", DiscountType: usecase.CouponDiscountPercent, DiscountPercent: 10}, nil)
+	planRepo.On("FindByID", mock.Anything, "plan-family").Return(familyPlan, nil)
+
+	handler := handlers.NewCouponHandler(planRepo)
+	body, _ := json.Marshal(map[string]any{"coupon_code": "DEZ", "plan_id": "plan-family", "dependents": 3})
+	rec := httptest.NewRecorder()
+	handler.Validate(rec, httptest.NewRequest(http.MethodPost, "/coupons/validate", bytes.NewReader(body)))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, float64(6990), response["original_amount_cents"])
+	assert.Equal(t, float64(699), response["discount_amount_cents"])
+	assert.Equal(t, float64(6291), response["final_amount_cents"])
+
+	evaluation, err := usecase.EvaluateCoupon(context.Background(), "DEZ", familyPlan, 3, "")
+	assert.NoError(t, err)
+	assert.Equal(t, familyPlan.PriceFor(3)-699, evaluation.FinalAmountCents)
+}
+
+// TestCouponHandlerValidateNotApplicable - Cupom de outro plano retorna o código da regra
+func TestCouponHandlerValidateNotApplicable(t *testing.T) {
+	tracker := new(MockCouponTracker)
+	useCouponTracker(t, tracker)
+	planRepo := new(MockPlanRepository)
+
+	tracker.On("GetActiveCoupon", mock.Anything, "PREMIUM").Return(&usecase.CouponDetails{Code: "PREMIUM", DiscountPercent: 15, PlanIDs: []string{"plan-premium"}}, nil)
+	planRepo.On("FindByID", mock.Anything, "plan-basic").Return(couponTestPlan, nil)
+
+	handler := handlers.NewCouponHandler(planRepo)
+	body, _ := json.Marshal(map[string]string{"coupon_code": "PREMIUM", "plan_id": "plan-basic"})
+	rec := httptest.NewRecorder()
+	handler.Validate(rec, httptest.NewRequest(http.MethodPost, "/coupons/validate", bytes.NewReader(body)))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "COUPON_NOT_APPLICABLE")
+}
+
+// TestCouponCyclesKeepsDiscountWhileCyclesRemain - Ainda há ciclos: nada muda no Asaas
+func TestCouponCyclesKeepsDiscountWhileCyclesRemain(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockDiscountCycleRepository)
+	gateway := new(MockSubscriptionValueUpdater)
+
+	repo.On("ConsumeDiscountCycle", ctx, "sub_asaas", "pay_1", time.Time{}).Return(&entity.DiscountCycleState{SubscriptionID: "sub-1", FullAmountCents: 4990, CyclesRemaining: 2}, nil)
+
+	uc := usecase.NewCouponCyclesUseCase(repo, gateway)
+	assert.NoError(t, uc.RegisterPayment(ctx, "sub_asaas", "pay_1", time.Time{}))
+	gateway.AssertNotCalled(t, "UpdateSubscriptionValue", mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "RestoreFullAmount", mock.Anything, mock.Anything)
+}
+
+// TestCouponCyclesRestoresFullAmount - Último ciclo pago: valor cheio no Asaas e localmente
+func TestCouponCyclesRestoresFullAmount(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockDiscountCycleRepository)
+	gateway := new(MockSubscriptionValueUpdater)
+
+	repo.On("ConsumeDiscountCycle", ctx, "sub_asaas", "pay_3", time.Time{}).Return(&entity.DiscountCycleState{SubscriptionID: "sub-1", FullAmountCents: 4990, CyclesRemaining: 0}, nil)
+	gateway.On("UpdateSubscriptionValue", "sub_asaas", 4990).Return(nil)
+	repo.On("RestoreFullAmount", ctx, "sub-1").Return(nil)
+
+	uc := usecase.NewCouponCyclesUseCase(repo, gateway)
+	assert.NoError(t, uc.RegisterPayment(ctx, "sub_asaas", "pay_3", time.Time{}))
+	gateway.AssertExpectations(t)
+	repo.AssertExpectations(t)
+}
+
+// TestCouponCyclesGatewayFailureIsRetryable - Falha no Asaas não encerra o desconto localmente
+func TestCouponCyclesGatewayFailureIsRetryable(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockDiscountCycleRepository)
+	gateway := new(MockSubscriptionValueUpdater)
+
+	repo.On("ConsumeDiscountCycle", ctx, "sub_asaas", "pay_3", time.Time{}).Return(&entity.DiscountCycleState{SubscriptionID: "sub-1", FullAmountCents: 4990, CyclesRemaining: 0}, nil)
+	gateway.On("UpdateSubscriptionValue", "sub_asaas", 4990).Return(errors.New("timeout"))
+
+	uc := usecase.NewCouponCyclesUseCase(repo, gateway)
+	assert.Error(t, uc.RegisterPayment(ctx, "sub_asaas", "pay_3", time.Time{}))
+	repo.AssertNotCalled(t, "RestoreFullAmount", mock.Anything, mock.Anything)
+}
+
+// TestProcessWebhookEventRegistersCouponCycleBeforeActivation - Erro no desconto gera retry sem ativar
+func TestProcessWebhookEventRegistersCouponCycleBeforeActivation(t *testing.T) {
+	ctx := context.Background()
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockActivate := new(MockActivateSubscriptionUseCase)
+	cycles := new(MockCouponCycles)
+
+	mockCustomerRepo.On("FindByGatewayID", "cus_1").Return(&entity.Customer{ID: "cust-1", GatewayID: "cus_1"}, nil)
+	cycles.On("RegisterPayment", ctx, "sub_asaas", "pay_1", time.Date(2026, 11, 10, 0, 0, 0, 0, time.UTC)).Return(errors.New("timeout"))
+
+	event, _ := usecase.NewAsaasWebhookEvent([]byte(`{"event":"PAYMENT_CONFIRMED","payment":{"id":"pay_1","customer":"cus_1","status":"CONFIRMED","subscription":"sub_asaas","dueDate":"2026-11-10"}}`))
+	uc := usecase.NewProcessWebhookEventUseCase(mockCustomerRepo, mockActivate, nil)
+	uc.CouponCycles = cycles
+
+	assert.Error(t, uc.Execute(ctx, event))
+	mockActivate.AssertNotCalled(t, "Execute", mock.Anything, mock.Anything)
+}
+
+// TestCheckoutCouponCapCheckedAtReservation - O limite conferido na gravação da venda barra o checkout antes da cobrança
+func TestCheckoutCouponCapCheckedAtReservation(t *testing.T) {
+	tracker := new(MockCouponTracker)
+	useCouponTracker(t, tracker)
+	tracker.On("GetActiveCoupon", mock.Anything, "CEM").Return(&usecase.CouponDetails{Code: "CEM", DiscountType: usecase.CouponDiscountPercent, DiscountPercent: 10, MaxRedemptions: 100}, nil)
+	tracker.On("CountRedemptions", mock.Anything, "CEM", mock.Anything).Return(usecase.CouponRedemptions{Total: 99}, nil)
+	tracker.On("TrackSale", mock.Anything, mock.Anything).Return(entity.ErrCouponExhausted)
+
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, "plan-card").Return(cardPlan, nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByCPF", mock.Anything, mock.Anything).Return(nil, errors.New("sql: no rows in result set"))
+	customerRepo.On("FindByEmailAndProductID", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("sql: no rows in result set"))
+	customerRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+	customerRepo.On("UpdateGatewayID", mock.Anything, mock.Anything, "asaas-cust-new").Return(nil)
+	customerRepo.On("Delete", mock.Anything, mock.Anything).Return(nil)
+	gateway := new(MockPaymentGateway)
+	gateway.On("CreateCustomer", mock.Anything).Return("asaas-cust-new", nil)
+	subRepo := new(MockSubscriptionRepository)
+
+	uc := usecase.NewCreateCustomerUseCase(customerRepo, subRepo, planRepo, gateway, new(MockQueueProducer), new(MockEmailService), nil, "", nil)
+
+	input := checkoutInput("plan-card", "CREDIT_CARD")
+	input.CreditCardToken = "tok-front"
+	input.CouponCode = "cem"
+
+	_, err := uc.Execute(context.Background(), input)
+
+	assertDomainCode(t, err, "COUPON_EXHAUSTED")
+	gateway.AssertNotCalled(t, "Subscribe", mock.Anything)
+	subRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+// TestCheckoutVoidsCouponSaleWhenPaymentFails - Cobrança recusada anula a venda reservada
+func TestCheckoutVoidsCouponSaleWhenPaymentFails(t *testing.T) {
+	tracker := new(MockCouponTracker)
+	useCouponTracker(t, tracker)
+	tracker.On("GetActiveCoupon", mock.Anything, "DEZ").Return(&usecase.CouponDetails{Code: "DEZ", DiscountType: usecase.CouponDiscountPercent, DiscountPercent: 10}, nil)
+
+	var reserved string
+	tracker.On("TrackSale", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		reserved = args.Get(1).(usecase.CouponSaleRecord).SubscriptionID
+	}).Return(nil)
+	tracker.On("VoidSales", mock.Anything, mock.Anything).Return(nil)
+
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, "plan-card").Return(cardPlan, nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByCPF", mock.Anything, mock.Anything).Return(nil, errors.New("sql: no rows in result set"))
+	customerRepo.On("FindByEmailAndProductID", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("sql: no rows in result set"))
+	customerRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+	customerRepo.On("UpdateGatewayID", mock.Anything, mock.Anything, "asaas-cust-new").Return(nil)
+	gateway := new(MockPaymentGateway)
+	gateway.On("CreateCustomer", mock.Anything).Return("asaas-cust-new", nil)
+	gateway.On("Subscribe", mock.Anything).Return("", "", errors.New("cartão recusado"))
+
+	uc := usecase.NewCreateCustomerUseCase(customerRepo, new(MockSubscriptionRepository), planRepo, gateway, new(MockQueueProducer), new(MockEmailService), nil, "", nil)
+
+	input := checkoutInput("plan-card", "CREDIT_CARD")
+	input.CreditCardToken = "tok-front"
+	input.CouponCode = "DEZ"
+
+	_, err := uc.Execute(context.Background(), input)
+
+	assertDomainCode(t, err, "PAYMENT_FAILED")
+	assert.NotEmpty(t, reserved)
+	tracker.AssertCalled(t, "VoidSales", mock.Anything, reserved)
+}
@@ -114,6 +114,11 @@ func (m *MockSubscriptionRepository) UpdateStatus(customerID string, status stri
 	return args.Error(0)
 }
 
+func (m *MockSubscriptionRepository) UpdateStatusByID(ctx context.Context, subscriptionID, status string) error {
+	args := m.Called(ctx, subscriptionID, status)
+	return args.Error(0)
+}
+
 func (m *MockSubscriptionRepository) FindLastByCustomerID(ctx context.Context, customerID string) (*entity.Subscription, error) {
 	args := m.Called(ctx, customerID)
 	if args.Get(0) == nil {
@@ -173,6 +178,26 @@ func (m *MockQueueProducer) PublishActivation(ctx context.Context, payload queue
 	return args.Error(0)
 }
 
+func (m *MockQueueProducer) PublishDeactivation(ctx context.Context, payload queue.DeactivationPayload) error {
+	args := m.Called(ctx, payload)
+	return args.Error(0)
+}
+
+func (m *MockQueueProducer) PublishUpdate(ctx context.Context, payload queue.ActivationPayload) error {
+	args := m.Called(ctx, payload)
+	return args.Error(0)
+}
+
+func (m *MockQueueProducer) PublishAddDependent(ctx context.Context, payload queue.ActivationPayload) error {
+	args := m.Called(ctx, payload)
+	return args.Error(0)
+}
+
+func (m *MockQueueProducer) PublishRemoveDependent(ctx context.Context, payload queue.DeactivationPayload) error {
+	args := m.Called(ctx, payload)
+	return args.Error(0)
+}
+
 // MockEmailService
 type MockEmailService struct {
 	mock.Mock
@@ -228,6 +253,16 @@ func (m *MockEmailService) SendWelcomeEmailWithContractAndDependents(name, email
 	return nil
 }
 
+func (m *MockEmailService) SendCheckoutRecoveryEmail(name, email, checkoutURL, unsubscribeURL string, stage int) error {
+	args := m.Called(name, email, checkoutURL, unsubscribeURL, stage)
+	return args.Error(0)
+}
+
+func (m *MockEmailService) SendCancellationEmail(name, email, planName string, accessUntil time.Time) error {
+	args := m.Called(name, email, planName, accessUntil)
+	return args.Error(0)
+}
+
 type MockWhatsAppService struct {
 	mock.Mock
 }
@@ -275,6 +310,29 @@ func (m *MockDependentRepository) Update(ctx context.Context, dependent *entity.
 
 // ============ TESTES ============
 
+// checkoutInput é um checkout válido do titular João Silva, sem dependentes.
+func checkoutInput(planID, paymentMethod string) usecase.CreateCustomerInput {
+	return usecase.CreateCustomerInput{
+		Name:            "João Silva",
+		Email:           "joao@example.com",
+		CPF:             "529.982.247-25",
+		Phone:           "(11) 99999-9999",
+		BirthDate:       "1990-05-15",
+		Gender:          "1",
+		PlanID:          planID,
+		PaymentMethod:   paymentMethod,
+		Street:          "Rua A",
+		Number:          "123",
+		District:        "Centro",
+		City:            "São Paulo",
+		State:           "SP",
+		ZipCode:         "01310-100",
+		TermsAccepted:   true,
+		TermsAcceptedAt: time.Now().Format(time.RFC3339),
+		TermsVersion:    "1.0",
+	}
+}
+
 // TestCreateCustomerPixFlowSuccess - Teste completo do fluxo PIX com sucesso
 func TestCreateCustomerPixFlowSuccess(t *testing.T) {
 	ctx := context.Background()
@@ -420,11 +478,7 @@ func TestCreateCustomerCreditCardFlowSuccess(t *testing.T) {
 		City:            "Rio de Janeiro",
 		State:           "RJ",
 		ZipCode:         "20040-020",
-		CardHolder:      "MARIA SANTOS",
-		CardNumber:      "4532015112830366", // Número válido de teste (Luhn check)
-		CardMonth:       "12",
-		CardYear:        "26",
-		CardCVV:         "123",
+		CreditCardToken: "tok-maria-4b1f", // cartão já tokenizado pelo front
 		TermsAccepted:   true,
 		TermsAcceptedAt: time.Now().Format(time.RFC3339),
 		TermsVersion:    "1.0",
@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/xavierca1/ligue-payments/internal/infra/queue"
+)
+
+// TestInspectDeadLetterFromRetryHeaders - Motivo e data vêm dos headers gravados pelo retry
+func TestInspectDeadLetterFromRetryHeaders(t *testing.T) {
+	d := amqp.Delivery{
+		Body: []byte(`{"customer_id":"cust-1","provider":"DOC24","name":"Maria"}`),
+		Headers: amqp.Table{
+			queue.HeaderRetryCount:  int32(5),
+			queue.HeaderLastError:   "doc24 retornou estado=0",
+			queue.HeaderLastErrorAt: "2026-10-10T12:00:00Z",
+		},
+	}
+
+	dl := queue.InspectDeadLetter(d)
+
+	assert.Equal(t, "cust-1", dl.CustomerID)
+	assert.Equal(t, "DOC24", dl.Provider)
+	assert.Equal(t, queue.MessageTypeActivation, dl.Type)
+	assert.Equal(t, 5, dl.Attempts)
+	assert.Equal(t, "doc24 retornou estado=0", dl.Reason)
+	assert.Equal(t, time.Date(2026, 10, 10, 12, 0, 0, 0, time.UTC), dl.FailedAt.UTC())
+}
+
+// TestInspectDeadLetterFromXDeath - Sem headers de retry, usa o x-death do broker
+func TestInspectDeadLetterFromXDeath(t *testing.T) {
+	deathTime := time.Date(2026, 10, 9, 8, 0, 0, 0, time.UTC)
+	d := amqp.Delivery{
+		Type: queue.MessageTypeDeactivation,
+		Body: []byte(`{"customer_id":"cust-2","provider":"TEM"}`),
+		Headers: amqp.Table{
+			"x-death": []interface{}{amqp.Table{"reason": "rejected", "queue": "q.activations", "time": deathTime}},
+		},
+	}
+
+	dl := queue.InspectDeadLetter(d)
+
+	assert.Equal(t, queue.MessageTypeDeactivation, dl.Type)
+	assert.Equal(t, "rejected em q.activations", dl.Reason)
+	assert.Equal(t, deathTime, dl.FailedAt)
+}
+
+// TestDeadLetterFilter - Filtros por provedor, período e customer
+func TestDeadLetterFilter(t *testing.T) {
+	dl := queue.DeadLetter{CustomerID: "cust-1", Provider: "DOC24", FailedAt: time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC)}
+
+	assert.True(t, queue.DeadLetterFilter{}.Match(dl))
+	assert.True(t, queue.DeadLetterFilter{Provider: "doc24"}.Match(dl))
+	assert.False(t, queue.DeadLetterFilter{Provider: "TEM"}.Match(dl))
+	assert.False(t, queue.DeadLetterFilter{Since: time.Date(2026, 10, 11, 0, 0, 0, 0, time.UTC)}.Match(dl))
+	assert.False(t, queue.DeadLetterFilter{Until: time.Date(2026, 10, 9, 0, 0, 0, 0, time.UTC)}.Match(dl))
+	assert.True(t, queue.DeadLetterFilter{CustomerIDs: []string{"x", "cust-1"}}.Match(dl))
+	assert.False(t, queue.DeadLetterFilter{CustomerIDs: []string{"x"}}.Match(dl))
+}
+
+// TestReplayPublishingResetsAttempts - Reprocessamento zera tentativas e remove x-death, mantendo o histórico
+func TestReplayPublishingResetsAttempts(t *testing.T) {
+	d := amqp.Delivery{
+		Type: queue.MessageTypeActivation,
+		Body: []byte(`{}`),
+		Headers: amqp.Table{
+			queue.HeaderRetryCount:   int32(5),
+			queue.HeaderErrorHistory: []interface{}{"tentativa 1: x"},
+			"x-death":                []interface{}{},
+		},
+	}
+
+	msg := queue.ReplayPublishing(d)
+
+	assert.Equal(t, 0, queue.AttemptsFromHeaders(msg.Headers))
+	assert.NotContains(t, msg.Headers, "x-death")
+	assert.Contains(t, msg.Headers, queue.HeaderErrorHistory)
+	assert.Equal(t, "application/json", msg.ContentType)
+	assert.Equal(t, amqp.Persistent, msg.DeliveryMode)
+}
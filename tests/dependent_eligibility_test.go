@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/usecase"
+)
+
+// eligibilityPlan usa as regras padrão da migration 017: filhos até 21 (24 se estudantes),
+// um cônjuge e pais só em planos família.
+func eligibilityPlan(family bool) *entity.Plan {
+	kinships := []string{entity.KinshipChild, entity.KinshipStepchild, entity.KinshipSpouse}
+	if family {
+		kinships = append(kinships, entity.KinshipFather, entity.KinshipMother)
+	}
+	return &entity.Plan{
+		ID: "plan-1",
+		DependentRules: entity.DependentRules{
+			ChildMaxAge: 21, StudentChildMaxAge: 24, MaxSpouses: 1, AllowedKinships: kinships,
+		},
+	}
+}
+
+// birthDateForAge devolve a data de nascimento de quem faz age anos hoje.
+func birthDateForAge(age int) string {
+	return time.Now().AddDate(-age, 0, 0).Format("2006-01-02")
+}
+
+// TestNormalizeKinship - Acentos, femininos e sinônimos caem no enum fechado
+func TestNormalizeKinship(t *testing.T) {
+	assert.Equal(t, entity.KinshipSpouse, entity.NormalizeKinship(" cônjuge "))
+	assert.Equal(t, entity.KinshipSpouse, entity.NormalizeKinship("Esposa"))
+	assert.Equal(t, entity.KinshipChild, entity.NormalizeKinship("filha"))
+	assert.Equal(t, entity.KinshipMother, entity.NormalizeKinship("MÃE"))
+	assert.True(t, entity.IsValidKinship("Enteada"))
+	assert.False(t, entity.IsValidKinship("PRIMO"))
+
+	_, err := entity.NewDependent("cust-1", "52998224725", "Ana", "11144477735", "2015-01-01", 2, "PRIMO")
+	assert.Error(t, err)
+
+	dep, err := entity.NewDependent("cust-1", "52998224725", "Ana", "11144477735", "2015-01-01", 2, "Filha")
+	assert.NoError(t, err)
+	assert.Equal(t, entity.KinshipChild, dep.Kinship)
+}
+
+// TestCheckDependentsChildAge - Filho com 21 anos é recusado, a menos que seja estudante (até 24)
+func TestCheckDependentsChildAge(t *testing.T) {
+	plan := eligibilityPlan(false)
+
+	violations := plan.CheckDependents("52998224725", []*entity.Dependent{
+		{CPF: "11144477735", BirthDate: birthDateForAge(20), Kinship: "FILHO"},
+		{CPF: "39053344705", BirthDate: birthDateForAge(21), Kinship: "FILHO"},
+		{CPF: "24843803480", BirthDate: birthDateForAge(23), Kinship: "ENTEADO", Student: true},
+		{CPF: "71428793860", BirthDate: birthDateForAge(24), Kinship: "FILHO", Student: true},
+	}, time.Now())
+
+	assert.Len(t, violations, 2)
+	assert.Equal(t, 1, violations[0].Index)
+	assert.Equal(t, "birth_date", violations[0].Field)
+	assert.Contains(t, violations[0].Message, "24 se estudantes")
+	assert.Equal(t, 3, violations[1].Index)
+}
+
+// TestCheckDependentsSpousesAndParents - Segundo cônjuge e pais fora do plano família são recusados
+func TestCheckDependentsSpousesAndParents(t *testing.T) {
+	dependents := []*entity.Dependent{
+		{CPF: "11144477735", BirthDate: "1990-01-01", Kinship: "CONJUGE"},
+		{CPF: "39053344705", BirthDate: "1991-01-01", Kinship: "ESPOSA"},
+		{CPF: "24843803480", BirthDate: "1960-01-01", Kinship: "MAE"},
+	}
+
+	violations := eligibilityPlan(false).CheckDependents("52998224725", dependents, time.Now())
+	assert.Len(t, violations, 2)
+	assert.Equal(t, entity.DependentViolation{Index: 1, Field: "kinship", Message: "o plano permite no máximo 1 cônjuge(s)"}, violations[0])
+	assert.Equal(t, 2, violations[1].Index)
+	assert.Contains(t, violations[1].Message, "MAE")
+
+	violations = eligibilityPlan(true).CheckDependents("52998224725", dependents, time.Now())
+	assert.Len(t, violations, 1)
+	assert.Equal(t, 1, violations[0].Index)
+}
+
+// TestCheckDependentsDuplicateCPF - CPF do titular ou repetido entre dependentes
+func TestCheckDependentsDuplicateCPF(t *testing.T) {
+	violations := eligibilityPlan(true).CheckDependents("529.982.247-25", []*entity.Dependent{
+		{CPF: "52998224725", BirthDate: "2015-01-01", Kinship: "FILHO"},
+		{CPF: "111.444.777-35", BirthDate: "2015-01-01", Kinship: "FILHO"},
+		{CPF: "11144477735", BirthDate: "2016-01-01", Kinship: "FILHO"},
+	}, time.Now())
+
+	assert.Len(t, violations, 2)
+	for i, v := range violations {
+		assert.Equal(t, "cpf", v.Field)
+		assert.Equal(t, []int{0, 2}[i], v.Index)
+	}
+}
+
+// TestCheckDependentsZeroRulesAcceptEnum - Plano sem regras aceita qualquer parentesco do enum
+func TestCheckDependentsZeroRulesAcceptEnum(t *testing.T) {
+	plan := &entity.Plan{}
+
+	violations := plan.CheckDependents("52998224725", []*entity.Dependent{
+		{CPF: "11144477735", BirthDate: birthDateForAge(30), Kinship: "FILHO"},
+		{CPF: "39053344705", BirthDate: "1960-01-01", Kinship: "PAI"},
+		{CPF: "24843803480", BirthDate: "1990-01-01", Kinship: "CONJUGE"},
+		{CPF: "71428793860", BirthDate: "1990-01-01", Kinship: "CONJUGE"},
+		{CPF: "86288366757", BirthDate: "1990-01-01", Kinship: "SOGRO"},
+	}, time.Now())
+
+	assert.Equal(t, []entity.DependentViolation{
+		{Index: 4, Field: "kinship", Message: "deve ser FILHO, ENTEADO, CONJUGE, PAI ou MAE"},
+	}, violations)
+}
+
+// TestValidateCreateCustomerInputDependents - Campos de cada dependente voltam como ValidationError indexado
+func TestValidateCreateCustomerInputDependents(t *testing.T) {
+	input := usecase.CreateCustomerInput{
+		Name: "João Silva", Email: "joao@example.com", CPF: "529.982.247-25", Phone: "(11) 99999-9999",
+		BirthDate: "1990-05-15", PlanID: "plan-1", PaymentMethod: "PIX",
+		Street: "Rua A", Number: "123", District: "Centro", City: "São Paulo", State: "SP", ZipCode: "01310-100",
+		TermsAccepted: true, TermsAcceptedAt: time.Now().Format(time.RFC3339),
+		Dependents: []usecase.DependentInput{
+			{Name: "Pedro Silva", CPF: "111.444.777-35", BirthDate: "2015-07-20", Gender: "1", Kinship: "Filho"},
+			{Name: "Tio Silva", CPF: "390.533.447-05", BirthDate: "1970-01-01", Gender: "1", Kinship: "TIO"},
+		},
+	}
+
+	errs := usecase.ValidateCreateCustomerInput(input)
+
+	assert.Equal(t, []usecase.ValidationError{
+		{Field: "dependents[1].kinship", Message: "must be FILHO, ENTEADO, CONJUGE, PAI or MAE"},
+	}, errs)
+}
+
+// TestValidateDependentEligibility - Regras do plano viram ValidationError com o campo do dependente
+func TestValidateDependentEligibility(t *testing.T) {
+	errs := usecase.ValidateDependentEligibility(eligibilityPlan(false), "52998224725", []*entity.Dependent{
+		{CPF: "11144477735", BirthDate: birthDateForAge(22), Kinship: "FILHO"},
+	}, func(i int) string { return fmt.Sprintf("dependents[%d].", i) })
+
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "dependents[0].birth_date", errs[0].Field)
+}
+
+// TestAddDependentEligibilityIgnoresExisting - Só o dependente novo é conferido; cônjuge excedente recai sobre ele
+func TestAddDependentEligibilityIgnoresExisting(t *testing.T) {
+	spouse := existingDependent("dep-1", "39053344705")
+	spouse.Kinship = entity.KinshipSpouse
+	grownChild := existingDependent("dep-2", "24843803480")
+	grownChild.BirthDate = birthDateForAge(30)
+	current := []*entity.Dependent{spouse, grownChild}
+	plan := familyPlan()
+	plan.DependentRules = eligibilityPlan(false).DependentRules
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(familySubscription(plan, current), nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(dependentsHolder, nil)
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, plan.ID).Return(plan, nil)
+	dependentRepo := new(MockDependentRepository)
+	dependentRepo.On("FindByCustomerID", mock.Anything, "cust-1").Return(current, nil)
+	changes := new(MockDependentChangeRepository)
+	uc := usecase.NewManageDependentsUseCase(subRepo, customerRepo, planRepo, dependentRepo, changes, new(MockSubscriptionValueUpdater))
+
+	input := newDependentInput
+	input.Kinship = "esposa"
+	_, err := uc.Add(context.Background(), "cust-1", input)
+
+	de, ok := err.(*usecase.DomainError)
+	assert.True(t, ok)
+	assert.Equal(t, "VALIDATION_ERROR", de.Code)
+	assert.Equal(t, []usecase.ValidationError{{Field: "kinship", Message: "o plano permite no máximo 1 cônjuge(s)"}}, de.Fields)
+	changes.AssertNotCalled(t, "AddToSubscription", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,403 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/infra/queue"
+	"github.com/xavierca1/ligue-payments/internal/usecase"
+)
+
+type MockDependentChangeRepository struct {
+	mock.Mock
+}
+
+func (m *MockDependentChangeRepository) AddToSubscription(ctx context.Context, change entity.DependentSubscriptionChange) error {
+	args := m.Called(ctx, change)
+	return args.Error(0)
+}
+
+func (m *MockDependentChangeRepository) RemoveFromSubscription(ctx context.Context, change entity.DependentSubscriptionChange) error {
+	args := m.Called(ctx, change)
+	return args.Error(0)
+}
+
+func (m *MockDependentChangeRepository) UpdateWithEvents(ctx context.Context, dependent *entity.Dependent, events []*entity.OutboxEvent) error {
+	args := m.Called(ctx, dependent, events)
+	return args.Error(0)
+}
+
+// subscriptionChange confere o ajuste gravado junto com o dependente.
+func subscriptionChange(delta, expectedAmount, expectedDependents int) interface{} {
+	return mock.MatchedBy(func(c entity.DependentSubscriptionChange) bool {
+		return c.SubscriptionID == "sub-1" && c.DeltaCents == delta &&
+			c.ExpectedAmountCents == expectedAmount && c.ExpectedDependents == expectedDependents
+	})
+}
+
+// eventDependentCPFs decodifica o payload do evento de outbox e devolve os CPFs dos dependentes.
+func eventDependentCPFs(t *testing.T, event *entity.OutboxEvent) []string {
+	var payload struct {
+		CPF        string `json:"cpf"`
+		Dependents []struct {
+			CPF string `json:"cpf"`
+		} `json:"dependents"`
+	}
+	assert.NoError(t, json.Unmarshal(event.Payload, &payload))
+	cpfs := make([]string, 0, len(payload.Dependents))
+	for _, dep := range payload.Dependents {
+		cpfs = append(cpfs, dep.CPF)
+	}
+	return cpfs
+}
+
+// familyPlan aceita até 3 dependentes, com 1 incluso e R$ 15,00 por dependente extra.
+func familyPlan() *entity.Plan {
+	maxDependents := 3
+	return &entity.Plan{
+		ID: "plan-familia", Name: "Família", PriceCents: 5990, Provider: "DOC24",
+		MaxDependents: &maxDependents, IncludedDependents: 1, ExtraDependentPriceCents: 1500,
+	}
+}
+
+// familySubscription é a assinatura ativa do titular cust-1, cobrando pelos dependentes atuais.
+func familySubscription(plan *entity.Plan, current []*entity.Dependent) *entity.Subscription {
+	return &entity.Subscription{
+		ID:              "sub-1",
+		CustomerID:      "cust-1",
+		PlanID:          plan.ID,
+		Amount:          plan.PriceFor(len(current)),
+		Status:          "ACTIVE",
+		PaymentMethodID: "sub_asaas_1",
+	}
+}
+
+var dependentsHolder = &entity.Customer{
+	ID: "cust-1", Name: "João Silva", Email: "joao@example.com", CPF: "52998224725", ProviderID: "doc24-1", Gender: 1,
+}
+
+func existingDependent(id, cpf string) *entity.Dependent {
+	return &entity.Dependent{
+		ID: id, CustomerID: "cust-1", NroDocumentoTitular: "52998224725", Name: "Dependente " + id,
+		CPF: cpf, BirthDate: "2015-03-10", Gender: 2, Kinship: "FILHO", CreatedAt: time.Now(),
+	}
+}
+
+var newDependentInput = usecase.DependentInput{
+	Name: "Maria Silva", CPF: "11144477735", BirthDate: "2018-06-01", Gender: "2", Kinship: "FILHO",
+}
+
+// TestAddDependentChargesExtra - Dependente acima dos inclusos aumenta a assinatura no Asaas e no banco
+func TestAddDependentChargesExtra(t *testing.T) {
+	plan := familyPlan()
+	current := []*entity.Dependent{existingDependent("dep-1", "39053344705")}
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(familySubscription(plan, current), nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(dependentsHolder, nil)
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, plan.ID).Return(plan, nil)
+	dependentRepo := new(MockDependentRepository)
+	dependentRepo.On("FindByCustomerID", mock.Anything, "cust-1").Return(current, nil)
+
+	gateway := new(MockSubscriptionValueUpdater)
+	gateway.On("UpdateSubscriptionValue", "sub_asaas_1", 7490).Return(nil)
+	var change entity.DependentSubscriptionChange
+	changes := new(MockDependentChangeRepository)
+	changes.On("AddToSubscription", mock.Anything, subscriptionChange(1500, 5990, 1)).
+		Run(func(args mock.Arguments) { change = args.Get(1).(entity.DependentSubscriptionChange) }).
+		Return(nil)
+	mailer := new(MockEmailService)
+	mailer.On("SendWelcomeEmailWithCardAndDependents", "João Silva", "joao@example.com", "52998224725", "Família", "doc24-1", mock.Anything).Return(nil)
+
+	uc := usecase.NewManageDependentsUseCase(subRepo, customerRepo, planRepo, dependentRepo, changes, gateway)
+	uc.EmailService = mailer
+	out, err := uc.Add(context.Background(), "cust-1", newDependentInput)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1500, out.PriceChangeCents)
+	assert.Equal(t, 7490, out.AmountCents)
+	assert.Len(t, out.Dependents, 2)
+	gateway.AssertExpectations(t)
+	changes.AssertExpectations(t)
+	mailer.AssertExpectations(t)
+	if assert.Len(t, change.Events, 1) {
+		assert.Equal(t, queue.MessageTypeAddDependent, change.Events[0].MessageType)
+		assert.Equal(t, "cust-1", change.Events[0].AggregateID)
+		assert.Equal(t, []string{"11144477735"}, eventDependentCPFs(t, change.Events[0]))
+	}
+}
+
+// TestAddIncludedDependentKeepsPrice - Dependente dentro dos inclusos não altera o valor
+func TestAddIncludedDependentKeepsPrice(t *testing.T) {
+	plan := familyPlan()
+	current := []*entity.Dependent{}
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(familySubscription(plan, current), nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(dependentsHolder, nil)
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, plan.ID).Return(plan, nil)
+	dependentRepo := new(MockDependentRepository)
+	dependentRepo.On("FindByCustomerID", mock.Anything, "cust-1").Return(current, nil)
+	changes := new(MockDependentChangeRepository)
+	changes.On("AddToSubscription", mock.Anything, subscriptionChange(0, 5990, 0)).Return(nil)
+	gateway := new(MockSubscriptionValueUpdater)
+
+	uc := usecase.NewManageDependentsUseCase(subRepo, customerRepo, planRepo, dependentRepo, changes, gateway)
+	out, err := uc.Add(context.Background(), "cust-1", newDependentInput)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, out.PriceChangeCents)
+	changes.AssertExpectations(t)
+	gateway.AssertNotCalled(t, "UpdateSubscriptionValue", mock.Anything, mock.Anything)
+}
+
+// TestAddDependentRespectsPlanLimit - Acima do máximo do plano nada é cobrado nem gravado
+func TestAddDependentRespectsPlanLimit(t *testing.T) {
+	plan := familyPlan()
+	current := []*entity.Dependent{
+		existingDependent("dep-1", "39053344705"),
+		existingDependent("dep-2", "24843803480"),
+		existingDependent("dep-3", "71428793860"),
+	}
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(familySubscription(plan, current), nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(dependentsHolder, nil)
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, plan.ID).Return(plan, nil)
+	dependentRepo := new(MockDependentRepository)
+	dependentRepo.On("FindByCustomerID", mock.Anything, "cust-1").Return(current, nil)
+	changes := new(MockDependentChangeRepository)
+	gateway := new(MockSubscriptionValueUpdater)
+
+	uc := usecase.NewManageDependentsUseCase(subRepo, customerRepo, planRepo, dependentRepo, changes, gateway)
+	_, err := uc.Add(context.Background(), "cust-1", newDependentInput)
+
+	de, ok := err.(*usecase.DomainError)
+	assert.True(t, ok)
+	assert.Equal(t, "DEPENDENTS_LIMIT", de.Code)
+	gateway.AssertNotCalled(t, "UpdateSubscriptionValue", mock.Anything, mock.Anything)
+	changes.AssertNotCalled(t, "AddToSubscription", mock.Anything, mock.Anything)
+}
+
+// TestAddDependentRejectsDuplicateCPF - CPF já cadastrado como dependente
+func TestAddDependentRejectsDuplicateCPF(t *testing.T) {
+	plan := familyPlan()
+	current := []*entity.Dependent{existingDependent("dep-1", "111.444.777-35")}
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(familySubscription(plan, current), nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(dependentsHolder, nil)
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, plan.ID).Return(plan, nil)
+	dependentRepo := new(MockDependentRepository)
+	dependentRepo.On("FindByCustomerID", mock.Anything, "cust-1").Return(current, nil)
+
+	uc := usecase.NewManageDependentsUseCase(subRepo, customerRepo, planRepo, dependentRepo, new(MockDependentChangeRepository), new(MockSubscriptionValueUpdater))
+	_, err := uc.Add(context.Background(), "cust-1", newDependentInput)
+
+	de, ok := err.(*usecase.DomainError)
+	assert.True(t, ok)
+	assert.Equal(t, "VALIDATION_ERROR", de.Code)
+	assert.Len(t, de.Fields, 1)
+	assert.Equal(t, "cpf", de.Fields[0].Field)
+}
+
+// TestAddDependentRevertsGatewayOnDatabaseError - Falha no banco devolve o valor anterior ao Asaas
+func TestAddDependentRevertsGatewayOnDatabaseError(t *testing.T) {
+	plan := familyPlan()
+	current := []*entity.Dependent{existingDependent("dep-1", "39053344705")}
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(familySubscription(plan, current), nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(dependentsHolder, nil)
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, plan.ID).Return(plan, nil)
+	dependentRepo := new(MockDependentRepository)
+	dependentRepo.On("FindByCustomerID", mock.Anything, "cust-1").Return(current, nil)
+
+	gateway := new(MockSubscriptionValueUpdater)
+	gateway.On("UpdateSubscriptionValue", "sub_asaas_1", 7490).Return(nil).Once()
+	gateway.On("UpdateSubscriptionValue", "sub_asaas_1", 5990).Return(nil).Once()
+	changes := new(MockDependentChangeRepository)
+	changes.On("AddToSubscription", mock.Anything, subscriptionChange(1500, 5990, 1)).Return(assert.AnError)
+
+	uc := usecase.NewManageDependentsUseCase(subRepo, customerRepo, planRepo, dependentRepo, changes, gateway)
+	_, err := uc.Add(context.Background(), "cust-1", newDependentInput)
+
+	_, ok := err.(*usecase.TechnicalError)
+	assert.True(t, ok)
+	gateway.AssertExpectations(t)
+}
+
+// TestAddDependentConflictResyncsGateway - Outra mudança gravada no meio: o Asaas volta ao valor do banco
+func TestAddDependentConflictResyncsGateway(t *testing.T) {
+	plan := familyPlan()
+	current := []*entity.Dependent{
+		existingDependent("dep-1", "39053344705"),
+		existingDependent("dep-2", "24843803480"),
+	}
+	sub := familySubscription(plan, current)
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(sub, nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(dependentsHolder, nil)
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, plan.ID).Return(plan, nil)
+	dependentRepo := new(MockDependentRepository)
+	dependentRepo.On("FindByCustomerID", mock.Anything, "cust-1").Return(current, nil)
+
+	gateway := new(MockSubscriptionValueUpdater)
+	gateway.On("UpdateSubscriptionValue", "sub_asaas_1", 8990).Return(nil).Once()
+	gateway.On("UpdateSubscriptionValue", "sub_asaas_1", 5990).Return(nil).Once()
+	changes := new(MockDependentChangeRepository)
+	changes.On("AddToSubscription", mock.Anything, subscriptionChange(1500, 7490, 2)).
+		Run(func(args mock.Arguments) { sub.Amount = 5990 }). // remoção concorrente já gravada
+		Return(entity.ErrDependentsChanged)
+	mailer := new(MockEmailService)
+
+	uc := usecase.NewManageDependentsUseCase(subRepo, customerRepo, planRepo, dependentRepo, changes, gateway)
+	uc.EmailService = mailer
+	_, err := uc.Add(context.Background(), "cust-1", newDependentInput)
+
+	de, ok := err.(*usecase.DomainError)
+	assert.True(t, ok)
+	assert.Equal(t, "DEPENDENTS_CONFLICT", de.Code)
+	gateway.AssertExpectations(t)
+	mailer.AssertNotCalled(t, "SendWelcomeEmailWithCardAndDependents", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestRemoveDependentLowersPriceAndDeprovisions - Remoção reduz o valor e publica a baixa no provedor
+func TestRemoveDependentLowersPriceAndDeprovisions(t *testing.T) {
+	plan := familyPlan()
+	current := []*entity.Dependent{
+		existingDependent("dep-1", "39053344705"),
+		existingDependent("dep-2", "24843803480"),
+	}
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(familySubscription(plan, current), nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(dependentsHolder, nil)
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, plan.ID).Return(plan, nil)
+	dependentRepo := new(MockDependentRepository)
+	dependentRepo.On("FindByCustomerID", mock.Anything, "cust-1").Return(current, nil)
+
+	gateway := new(MockSubscriptionValueUpdater)
+	gateway.On("UpdateSubscriptionValue", "sub_asaas_1", 5990).Return(nil)
+	var change entity.DependentSubscriptionChange
+	changes := new(MockDependentChangeRepository)
+	changes.On("RemoveFromSubscription", mock.Anything, subscriptionChange(-1500, 7490, 2)).
+		Run(func(args mock.Arguments) { change = args.Get(1).(entity.DependentSubscriptionChange) }).
+		Return(nil)
+
+	uc := usecase.NewManageDependentsUseCase(subRepo, customerRepo, planRepo, dependentRepo, changes, gateway)
+	out, err := uc.Remove(context.Background(), "cust-1", "dep-2")
+
+	assert.NoError(t, err)
+	assert.Equal(t, -1500, out.PriceChangeCents)
+	assert.Len(t, out.Dependents, 1)
+	assert.Equal(t, "dep-2", change.Dependent.ID)
+	if assert.Len(t, change.Events, 1) {
+		var payload queue.DeactivationPayload
+		assert.NoError(t, json.Unmarshal(change.Events[0].Payload, &payload))
+		assert.Equal(t, queue.MessageTypeRemoveDependent, change.Events[0].MessageType)
+		assert.Equal(t, "DEPENDENT_REMOVED", payload.Reason)
+		assert.Equal(t, []string{"24843803480"}, eventDependentCPFs(t, change.Events[0]))
+	}
+	gateway.AssertExpectations(t)
+}
+
+// TestRemoveUnknownDependent - Dependente de outro titular não é encontrado
+func TestRemoveUnknownDependent(t *testing.T) {
+	plan := familyPlan()
+	current := []*entity.Dependent{existingDependent("dep-1", "39053344705")}
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(familySubscription(plan, current), nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(dependentsHolder, nil)
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, plan.ID).Return(plan, nil)
+	dependentRepo := new(MockDependentRepository)
+	dependentRepo.On("FindByCustomerID", mock.Anything, "cust-1").Return(current, nil)
+
+	uc := usecase.NewManageDependentsUseCase(subRepo, customerRepo, planRepo, dependentRepo, new(MockDependentChangeRepository), new(MockSubscriptionValueUpdater))
+	_, err := uc.Remove(context.Background(), "cust-1", "dep-outro")
+
+	de, ok := err.(*usecase.DomainError)
+	assert.True(t, ok)
+	assert.Equal(t, "DEPENDENT_NOT_FOUND", de.Code)
+}
+
+// TestUpdateDependentCPFReprovisions - Trocar o CPF dá baixa no documento antigo e inclui o novo
+func TestUpdateDependentCPFReprovisions(t *testing.T) {
+	plan := familyPlan()
+	current := []*entity.Dependent{existingDependent("dep-1", "39053344705")}
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(familySubscription(plan, current), nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(dependentsHolder, nil)
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, plan.ID).Return(plan, nil)
+	dependentRepo := new(MockDependentRepository)
+	dependentRepo.On("FindByCustomerID", mock.Anything, "cust-1").Return(current, nil)
+
+	var events []*entity.OutboxEvent
+	changes := new(MockDependentChangeRepository)
+	changes.On("UpdateWithEvents", mock.Anything, mock.MatchedBy(func(d *entity.Dependent) bool {
+		return d.ID == "dep-1" && d.CPF == "11144477735"
+	}), mock.Anything).Run(func(args mock.Arguments) { events = args.Get(2).([]*entity.OutboxEvent) }).Return(nil)
+	gateway := new(MockSubscriptionValueUpdater)
+
+	uc := usecase.NewManageDependentsUseCase(subRepo, customerRepo, planRepo, dependentRepo, changes, gateway)
+	out, err := uc.Update(context.Background(), "cust-1", "dep-1", newDependentInput)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "dep-1", out.Dependent.ID)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, queue.MessageTypeRemoveDependent, events[0].MessageType)
+		assert.Equal(t, []string{"39053344705"}, eventDependentCPFs(t, events[0]))
+		assert.Equal(t, queue.MessageTypeAddDependent, events[1].MessageType)
+		assert.Equal(t, []string{"11144477735"}, eventDependentCPFs(t, events[1]))
+	}
+	gateway.AssertNotCalled(t, "UpdateSubscriptionValue", mock.Anything, mock.Anything)
+}
+
+// TestDependentsRequireActiveSubscription - Assinatura pendente não aceita mudanças
+func TestDependentsRequireActiveSubscription(t *testing.T) {
+	plan := familyPlan()
+	sub := familySubscription(plan, nil)
+	sub.Status = "PENDING"
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(sub, nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(dependentsHolder, nil)
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, plan.ID).Return(plan, nil)
+	dependentRepo := new(MockDependentRepository)
+	dependentRepo.On("FindByCustomerID", mock.Anything, "cust-1").Return([]*entity.Dependent{}, nil)
+
+	uc := usecase.NewManageDependentsUseCase(subRepo, customerRepo, planRepo, dependentRepo, new(MockDependentChangeRepository), new(MockSubscriptionValueUpdater))
+	_, err := uc.Add(context.Background(), "cust-1", newDependentInput)
+
+	de, ok := err.(*usecase.DomainError)
+	assert.True(t, ok)
+	assert.Equal(t, "SUBSCRIPTION_NOT_ACTIVE", de.Code)
+}
@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xavierca1/ligue-payments/internal/infra/integration/doc24"
+	"github.com/xavierca1/ligue-payments/internal/infra/queue"
+)
+
+// doc24StubTransport responde às chamadas da Doc24 sem rede e registra os afiliados enviados
+type doc24StubTransport struct {
+	afiliados  []map[string]string
+	failOnBaja string // CPF cuja baixa deve falhar
+}
+
+func (s *doc24StubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, "/authentication") {
+		return stubJSON(`{"token":"tok","expires_in":3600}`), nil
+	}
+
+	var body struct {
+		Afiliado map[string]string `json:"afiliado"`
+	}
+	raw, _ := io.ReadAll(req.Body)
+	json.Unmarshal(raw, &body)
+	s.afiliados = append(s.afiliados, body.Afiliado)
+
+	if s.failOnBaja != "" && body.Afiliado["nro_documento"] == s.failOnBaja {
+		return stubJSON(`{"estado":0,"mensaje":"ERROR"}`), nil
+	}
+	return stubJSON(`{"estado":1,"mensaje":"OK"}`), nil
+}
+
+func stubJSON(body string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}
+}
+
+func deactivationPayload() queue.DeactivationPayload {
+	return queue.DeactivationPayload{
+		CustomerID:       "cust-1",
+		Provider:         "DOC24",
+		ProviderPlanCode: "ligue saude em dia familiar",
+		Reason:           "CANCELED",
+		Name:             "Maria Souza",
+		CPF:              "123.456.789-00",
+		Gender:           "Feminino",
+		Dependents: []queue.DependentPayload{
+			{Name: "Pedro Souza", CPF: "987.654.321-00", Gender: 1},
+		},
+	}
+}
+
+// TestDoc24DeactivateBeneficiarySendsBaja - Dependentes e titular recebem fecha_baja, dependentes primeiro
+func TestDoc24DeactivateBeneficiarySendsBaja(t *testing.T) {
+	transport := &doc24StubTransport{}
+	client := doc24.NewClient("id", "secret")
+	client.HTTPClient = &http.Client{Transport: transport}
+
+	err := client.DeactivateBeneficiary(context.Background(), deactivationPayload())
+
+	assert.NoError(t, err)
+	assert.Len(t, transport.afiliados, 2)
+	assert.Equal(t, "98765432100", transport.afiliados[0]["nro_documento"])
+	assert.Equal(t, "12345678900", transport.afiliados[0]["nro_documento_titular"])
+	assert.Equal(t, "12345678900", transport.afiliados[1]["nro_documento"])
+	assert.Equal(t, "F", transport.afiliados[1]["sexo"])
+	for _, a := range transport.afiliados {
+		assert.NotEmpty(t, a["fecha_baja"])
+		assert.Equal(t, "ligue saude em dia familiar", a["plan"])
+	}
+}
+
+// TestDoc24DeactivateBeneficiaryDependentFailure - Falha na baixa de dependente interrompe e retorna erro
+func TestDoc24DeactivateBeneficiaryDependentFailure(t *testing.T) {
+	transport := &doc24StubTransport{failOnBaja: "98765432100"}
+	client := doc24.NewClient("id", "secret")
+	client.HTTPClient = &http.Client{Transport: transport}
+
+	err := client.DeactivateBeneficiary(context.Background(), deactivationPayload())
+
+	assert.Error(t, err)
+	assert.Len(t, transport.afiliados, 1, "titular não deve ser baixado após falha no dependente")
+}
+
+// TestDoc24RemoveDependentsKeepsTitular - Só os dependentes recebem fecha_baja; o titular segue ativo
+func TestDoc24RemoveDependentsKeepsTitular(t *testing.T) {
+	transport := &doc24StubTransport{}
+	client := doc24.NewClient("id", "secret")
+	client.HTTPClient = &http.Client{Transport: transport}
+
+	payload := deactivationPayload()
+	payload.Reason = "DEPENDENT_REMOVED"
+	err := client.RemoveDependents(context.Background(), payload)
+
+	assert.NoError(t, err)
+	assert.Len(t, transport.afiliados, 1)
+	assert.Equal(t, "98765432100", transport.afiliados[0]["nro_documento"])
+	assert.Equal(t, "12345678900", transport.afiliados[0]["nro_documento_titular"])
+	assert.NotEmpty(t, transport.afiliados[0]["fecha_baja"])
+}
+
+// TestDoc24DeactivateBeneficiaryKeepsFechaAlta - A baixa reenvia a data de alta original, não a de hoje
+func TestDoc24DeactivateBeneficiaryKeepsFechaAlta(t *testing.T) {
+	transport := &doc24StubTransport{}
+	client := doc24.NewClient("id", "secret")
+	client.HTTPClient = &http.Client{Transport: transport}
+
+	payload := deactivationPayload()
+	payload.ActivatedAt = "2025-03-10"
+	payload.Dependents[0].ActivatedAt = "2025-06-01"
+	err := client.DeactivateBeneficiary(context.Background(), payload)
+
+	assert.NoError(t, err)
+	assert.Len(t, transport.afiliados, 2)
+	assert.Equal(t, "2025-06-01", transport.afiliados[0]["fecha_alta"])
+	assert.Equal(t, "2025-03-10", transport.afiliados[1]["fecha_alta"])
+	assert.Equal(t, time.Now().Format("2006-01-02"), transport.afiliados[1]["fecha_baja"])
+}
+
+// TestDoc24UpdateBeneficiaryKeepsFechaAlta - Correção de dados e troca de plano não trocam a data de alta
+func TestDoc24UpdateBeneficiaryKeepsFechaAlta(t *testing.T) {
+	transport := &doc24StubTransport{}
+	client := doc24.NewClient("id", "secret")
+	client.HTTPClient = &http.Client{Transport: transport}
+
+	err := client.UpdateBeneficiary(context.Background(), queue.ActivationPayload{
+		CustomerID:       "cust-1",
+		Provider:         "DOC24",
+		ProviderPlanCode: "ligue saude em dia familiar",
+		ActivatedAt:      "2025-03-10",
+		Name:             "Maria Souza",
+		CPF:              "123.456.789-00",
+		Dependents: []queue.DependentPayload{
+			{Name: "Pedro Souza", CPF: "987.654.321-00", Gender: 1},
+			{Name: "Ana Souza", CPF: "111.222.333-44", Gender: 2, ActivatedAt: "2025-06-01"},
+		},
+	})
+
+	assert.NoError(t, err)
+	if assert.Len(t, transport.afiliados, 3) {
+		assert.Equal(t, "2025-03-10", transport.afiliados[0]["fecha_alta"])
+		assert.Equal(t, "2025-03-10", transport.afiliados[1]["fecha_alta"])
+		assert.Equal(t, "2025-06-01", transport.afiliados[2]["fecha_alta"])
+	}
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/infra/http/handlers"
+	"github.com/xavierca1/ligue-payments/internal/infra/integration/asaas"
+	"github.com/xavierca1/ligue-payments/internal/usecase"
+)
+
+// TestCreateCustomerAttachesCheckoutToLead - O checkout vincula o customer ao lead com plano e origem
+func TestCreateCustomerAttachesCheckoutToLead(t *testing.T) {
+	ctx := context.Background()
+	mockPlanRepo := new(MockPlanRepository)
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockSubRepo := new(MockSubscriptionRepository)
+	mockGateway := new(MockPaymentGateway)
+	mockLeadRepo := new(MockLeadRepository)
+
+	mockCustomerRepo.On("FindByCPF", mock.Anything, mock.Anything).Return(nil, errors.New("sql: no rows in result set"))
+	mockCustomerRepo.On("FindByEmailAndProductID", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("sql: no rows in result set"))
+	mockCustomerRepo.On("Create", ctx, mock.Anything).Return(nil)
+	mockCustomerRepo.On("UpdateGatewayID", ctx, mock.Anything, "asaas-cust-123").Return(nil)
+	mockPlanRepo.On("FindByID", ctx, "plan-123").Return(&entity.Plan{ID: "plan-123", Name: "Plano Premium", PriceCents: 29900, Provider: "DOC24", ProductID: "prod-123"}, nil)
+	mockGateway.On("CreateCustomer", mock.Anything).Return("asaas-cust-123", nil)
+	mockGateway.On("SubscribePix", mock.Anything).Return("asaas-sub-456", &asaas.PixOutput{CopyPaste: "00020126580014br.gov.bcb.pix", URL: "data:image/png;base64,iVBORw0KG..."}, nil)
+	mockSubRepo.On("Create", ctx, mock.Anything).Return(nil)
+	mockLeadRepo.On("AttachCheckout", ctx, mock.MatchedBy(func(lead *entity.Lead) bool {
+		return lead.Email == "joao@example.com" && lead.PlanID == "plan-123" &&
+			lead.UTMSource == "instagram" && lead.UTMCampaign == "outubro"
+	}), mock.Anything).Return(nil)
+
+	uc := usecase.NewCreateCustomerUseCase(mockCustomerRepo, mockSubRepo, mockPlanRepo, mockGateway, nil, nil, nil, "", nil)
+	uc.LeadRepo = mockLeadRepo
+
+	input := checkoutInput("plan-123", "PIX")
+	input.LeadAttribution = entity.LeadAttribution{UTMSource: "instagram", UTMCampaign: "outubro"}
+	output, err := uc.Execute(ctx, input)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, output)
+	mockLeadRepo.AssertExpectations(t)
+}
+
+// TestCaptureLeadStoresAttribution - UTMs e landing page chegam ao repositório na captura
+func TestCaptureLeadStoresAttribution(t *testing.T) {
+	mockLeadRepo := new(MockLeadRepository)
+	mockLeadRepo.On("Upsert", mock.Anything, mock.MatchedBy(func(lead *entity.Lead) bool {
+		return lead.Email == "ana@example.com" && lead.UTMSource == "google" &&
+			lead.UTMMedium == "cpc" && lead.LandingPage == "/planos/familia"
+	})).Return(nil)
+
+	body, _ := json.Marshal(map[string]string{
+		"email":        "ana@example.com",
+		"utm_source":   "google",
+		"utm_medium":   "cpc",
+		"landing_page": "/planos/familia",
+	})
+	rec := httptest.NewRecorder()
+	handlers.NewLeadHandler(mockLeadRepo).CaptureLead(rec, httptest.NewRequest(http.MethodPost, "/leads/capture", bytes.NewReader(body)))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mockLeadRepo.AssertExpectations(t)
+}
+
+// TestFunnelReportComputesRatesAndTotals - O relatório soma as linhas e calcula as taxas
+func TestFunnelReportComputesRatesAndTotals(t *testing.T) {
+	mockLeadRepo := new(MockLeadRepository)
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	mockLeadRepo.On("FunnelReport", mock.Anything, from, to).Return([]entity.LeadFunnelRow{
+		{Source: "instagram", PlanID: "plan-1", PlanName: "Individual", Captured: 40, Checkout: 10, Converted: 4},
+		{Source: "(direto)", Captured: 10, Checkout: 0, Converted: 0},
+	}, nil)
+
+	rec := httptest.NewRecorder()
+	handlers.NewLeadHandler(mockLeadRepo).FunnelReport(rec, httptest.NewRequest(http.MethodGet, "/admin/reports/funnel?from=2026-10-01&to=2026-10-15", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var response handlers.FunnelReportResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "2026-10-01", response.From)
+	assert.Equal(t, "2026-10-15", response.To)
+	assert.Len(t, response.Rows, 2)
+	assert.Equal(t, 25.0, response.Rows[0].CheckoutRate)
+	assert.Equal(t, 10.0, response.Rows[0].ConversionRate)
+	assert.Equal(t, 50, response.Totals.Captured)
+	assert.Equal(t, 8.0, response.Totals.ConversionRate)
+}
+
+// TestFunnelReportRejectsInvalidDate - Datas fora do formato retornam 400
+func TestFunnelReportRejectsInvalidDate(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handlers.NewLeadHandler(new(MockLeadRepository)).FunnelReport(rec, httptest.NewRequest(http.MethodGet, "/admin/reports/funnel?from=01/10/2026", nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
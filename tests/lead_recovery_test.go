@@ -0,0 +1,205 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/infra/worker"
+	"github.com/xavierca1/ligue-payments/internal/usecase"
+)
+
+type MockLeadRepository struct {
+	mock.Mock
+}
+
+func (m *MockLeadRepository) Upsert(ctx context.Context, lead *entity.Lead) error {
+	args := m.Called(ctx, lead)
+	return args.Error(0)
+}
+
+func (m *MockLeadRepository) AttachCheckout(ctx context.Context, lead *entity.Lead, customerID string) error {
+	args := m.Called(ctx, lead, customerID)
+	return args.Error(0)
+}
+
+func (m *MockLeadRepository) FunnelReport(ctx context.Context, from, to time.Time) ([]entity.LeadFunnelRow, error) {
+	args := m.Called(ctx, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]entity.LeadFunnelRow), args.Error(1)
+}
+
+func (m *MockLeadRepository) FindDueForRecovery(ctx context.Context, stage int, sinceCapture, sinceLastEmail, maxAge time.Duration, limit int) ([]*entity.Lead, error) {
+	args := m.Called(ctx, stage, sinceCapture, sinceLastEmail, maxAge, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.Lead), args.Error(1)
+}
+
+func (m *MockLeadRepository) AdvanceEmailStage(ctx context.Context, id string, fromStage int) (bool, error) {
+	args := m.Called(ctx, id, fromStage)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockLeadRepository) RevertEmailStage(ctx context.Context, id string, fromStage int, lastEmailSentAt *time.Time) error {
+	args := m.Called(ctx, id, fromStage, lastEmailSentAt)
+	return args.Error(0)
+}
+
+func (m *MockLeadRepository) Unsubscribe(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockLeadRepository) MarkConvertedByEmail(ctx context.Context, email string) error {
+	args := m.Called(ctx, email)
+	return args.Error(0)
+}
+
+func (m *MockLeadRepository) MarkConvertedFromActiveCustomers(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// TestLeadRecoverySendsDueStagesAndAdvances - Cada etapa vencida recebe o e-mail seguinte e avança
+func TestLeadRecoverySendsDueStagesAndAdvances(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockLeadRepository)
+	mailer := new(MockEmailService)
+
+	first := &entity.Lead{ID: "lead-1", Email: "ana@example.com", Name: "Ana Souza", EmailStage: 0}
+	second := &entity.Lead{ID: "lead-2", Email: "bia@example.com", Name: "Bia Lima", EmailStage: 1}
+
+	repo.On("MarkConvertedFromActiveCustomers", ctx).Return(int64(0), nil)
+	repo.On("FindDueForRecovery", ctx, 0, time.Hour, time.Duration(0), 7*24*time.Hour, 50).Return([]*entity.Lead{first}, nil)
+	repo.On("FindDueForRecovery", ctx, 1, 24*time.Hour, 23*time.Hour, 7*24*time.Hour, 50).Return([]*entity.Lead{second}, nil)
+	repo.On("FindDueForRecovery", ctx, 2, 72*time.Hour, 48*time.Hour, 7*24*time.Hour, 50).Return([]*entity.Lead{}, nil)
+	repo.On("AdvanceEmailStage", ctx, "lead-1", 0).Return(true, nil)
+	repo.On("AdvanceEmailStage", ctx, "lead-2", 1).Return(true, nil)
+	mailer.On("SendCheckoutRecoveryEmail", "Ana Souza", "ana@example.com", "https://checkout.example.com", "https://api.example.com/leads/lead-1/unsubscribe", 1).Return(nil)
+	mailer.On("SendCheckoutRecoveryEmail", "Bia Lima", "bia@example.com", "https://checkout.example.com", "https://api.example.com/leads/lead-2/unsubscribe", 2).Return(nil)
+
+	w := worker.NewLeadRecoveryWorker(repo, mailer, "https://checkout.example.com")
+	w.UnsubscribeBaseURL = "https://api.example.com/"
+	sent := w.RecoverPending(ctx)
+
+	assert.Equal(t, 2, sent)
+	repo.AssertExpectations(t)
+	mailer.AssertExpectations(t)
+}
+
+// TestLeadRecoveryRevertsStageOnSendFailure - Falha no envio devolve a etapa para o próximo tick
+func TestLeadRecoveryRevertsStageOnSendFailure(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockLeadRepository)
+	mailer := new(MockEmailService)
+
+	lead := &entity.Lead{ID: "lead-1", Email: "ana@example.com", Name: "Ana Souza"}
+
+	repo.On("MarkConvertedFromActiveCustomers", ctx).Return(int64(0), nil)
+	repo.On("FindDueForRecovery", ctx, 0, time.Hour, time.Duration(0), entity.DefaultLeadRecoveryMaxAge, 50).Return([]*entity.Lead{lead}, nil)
+	repo.On("AdvanceEmailStage", ctx, "lead-1", 0).Return(true, nil)
+	mailer.On("SendCheckoutRecoveryEmail", "Ana Souza", "ana@example.com", "", "https://api.example.com/leads/lead-1/unsubscribe", 1).Return(errors.New("smtp timeout"))
+	repo.On("RevertEmailStage", ctx, "lead-1", 0, (*time.Time)(nil)).Return(nil)
+
+	w := worker.NewLeadRecoveryWorker(repo, mailer, "")
+	w.Cadence = []time.Duration{time.Hour}
+	w.UnsubscribeBaseURL = "https://api.example.com"
+
+	assert.Equal(t, 0, w.RecoverPending(ctx))
+	repo.AssertExpectations(t)
+	mailer.AssertExpectations(t)
+}
+
+// TestLeadRecoverySkipsLeadClaimedElsewhere - Etapa já reservada por outro tick não é reenviada
+func TestLeadRecoverySkipsLeadClaimedElsewhere(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockLeadRepository)
+	mailer := new(MockEmailService)
+
+	lead := &entity.Lead{ID: "lead-1", Email: "ana@example.com", Name: "Ana Souza"}
+
+	repo.On("MarkConvertedFromActiveCustomers", ctx).Return(int64(0), nil)
+	repo.On("FindDueForRecovery", ctx, 0, time.Hour, time.Duration(0), entity.DefaultLeadRecoveryMaxAge, 50).Return([]*entity.Lead{lead}, nil)
+	repo.On("AdvanceEmailStage", ctx, "lead-1", 0).Return(false, nil)
+
+	w := worker.NewLeadRecoveryWorker(repo, mailer, "")
+	w.Cadence = []time.Duration{time.Hour}
+	w.UnsubscribeBaseURL = "https://api.example.com"
+
+	assert.Equal(t, 0, w.RecoverPending(ctx))
+	mailer.AssertNotCalled(t, "SendCheckoutRecoveryEmail", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestLeadRecoveryRequiresUnsubscribeURL - Sem link de descadastro nenhum e-mail sai
+func TestLeadRecoveryRequiresUnsubscribeURL(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockLeadRepository)
+	mailer := new(MockEmailService)
+
+	w := worker.NewLeadRecoveryWorker(repo, mailer, "https://checkout.example.com")
+
+	assert.Equal(t, 0, w.RecoverPending(ctx))
+	repo.AssertNotCalled(t, "FindDueForRecovery", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mailer.AssertNotCalled(t, "SendCheckoutRecoveryEmail", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestLeadRecoveryStopsWhenConversionSweepFails - Sem a varredura de convertidos nada é enviado
+func TestLeadRecoveryStopsWhenConversionSweepFails(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockLeadRepository)
+	mailer := new(MockEmailService)
+
+	repo.On("MarkConvertedFromActiveCustomers", ctx).Return(int64(0), errors.New("db down"))
+
+	w := worker.NewLeadRecoveryWorker(repo, mailer, "")
+	w.UnsubscribeBaseURL = "https://api.example.com"
+
+	assert.Equal(t, 0, w.RecoverPending(ctx))
+	repo.AssertNotCalled(t, "FindDueForRecovery", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mailer.AssertNotCalled(t, "SendCheckoutRecoveryEmail", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestParseLeadRecoveryCadence(t *testing.T) {
+	cadence, err := worker.ParseLeadRecoveryCadence("1h, 24h,72h")
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Duration{time.Hour, 24 * time.Hour, 72 * time.Hour}, cadence)
+
+	_, err = worker.ParseLeadRecoveryCadence("24h,1h")
+	assert.Error(t, err)
+
+	_, err = worker.ParseLeadRecoveryCadence("amanhã")
+	assert.Error(t, err)
+}
+
+// TestActivateSubscriptionMarksLeadConverted - Cliente ACTIVE sai da cadência de recuperação
+func TestActivateSubscriptionMarksLeadConverted(t *testing.T) {
+	ctx := context.Background()
+	mockSubRepo := new(MockSubscriptionRepository)
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPlanRepo := new(MockPlanRepository)
+	mockOutbox := new(MockOutboxRepository)
+	mockLeadRepo := new(MockLeadRepository)
+
+	mockCustomerRepo.On("FindByID", ctx, "cust-1").Return(&entity.Customer{ID: "cust-1", Name: "Maria", Email: "maria@example.com"}, nil)
+	mockSubRepo.On("FindLastByCustomerID", ctx, "cust-1").Return(&entity.Subscription{ID: "sub-1", PlanID: "plan-1", Status: "PENDING"}, nil)
+	mockPlanRepo.On("FindByID", ctx, "plan-1").Return(&entity.Plan{ID: "plan-1", Provider: "DOC24"}, nil)
+	mockOutbox.On("UpdateStatusWithOutbox", ctx, "cust-1", "ACTIVE", "ACTIVE", mock.Anything).Return(nil)
+	mockLeadRepo.On("MarkConvertedByEmail", ctx, "maria@example.com").Return(nil)
+
+	uc := usecase.NewActivateSubscriptionUseCase(mockSubRepo, mockCustomerRepo, mockPlanRepo, nil, nil, nil, nil)
+	uc.Outbox = mockOutbox
+	uc.LeadRepo = mockLeadRepo
+
+	err := uc.Execute(ctx, usecase.ActivateSubscriptionInput{CustomerID: "cust-1"})
+
+	assert.NoError(t, err)
+	mockLeadRepo.AssertExpectations(t)
+}
@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/infra/queue"
+	"github.com/xavierca1/ligue-payments/internal/infra/worker"
+	"github.com/xavierca1/ligue-payments/internal/usecase"
+)
+
+type MockOutboxRepository struct {
+	mock.Mock
+}
+
+func (m *MockOutboxRepository) UpdateStatusWithOutbox(ctx context.Context, customerID, status, customerStatus string, event *entity.OutboxEvent) error {
+	args := m.Called(ctx, customerID, status, customerStatus, event)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) ClaimPending(ctx context.Context, limit int) ([]*entity.OutboxEvent, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.OutboxEvent), args.Error(1)
+}
+
+func (m *MockOutboxRepository) MarkSent(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepository) MarkRetry(ctx context.Context, id string, attempts int, nextAttemptAt time.Time, reason string) error {
+	args := m.Called(ctx, id, attempts, nextAttemptAt, reason)
+	return args.Error(0)
+}
+
+type MockOutboxPublisher struct {
+	mock.Mock
+}
+
+func (m *MockOutboxPublisher) PublishRaw(ctx context.Context, messageType string, body []byte) error {
+	args := m.Called(ctx, messageType, body)
+	return args.Error(0)
+}
+
+// TestActivateSubscriptionWritesOutbox - Com outbox, a ativação grava o evento na transação e não publica direto
+func TestActivateSubscriptionWritesOutbox(t *testing.T) {
+	ctx := context.Background()
+	mockSubRepo := new(MockSubscriptionRepository)
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPlanRepo := new(MockPlanRepository)
+	mockQueue := new(MockQueueProducer)
+	mockOutbox := new(MockOutboxRepository)
+
+	mockCustomerRepo.On("FindByID", ctx, "cust-1").Return(&entity.Customer{ID: "cust-1", Name: "Maria", CPF: "12345678900"}, nil)
+	mockSubRepo.On("FindLastByCustomerID", ctx, "cust-1").Return(&entity.Subscription{ID: "sub-1", CustomerID: "cust-1", PlanID: "plan-1", Status: "PENDING"}, nil)
+	mockPlanRepo.On("FindByID", ctx, "plan-1").Return(&entity.Plan{ID: "plan-1", Name: "Individual", Provider: "DOC24", ProviderPlanCode: "individual"}, nil)
+	mockOutbox.On("UpdateStatusWithOutbox", ctx, "cust-1", "ACTIVE", "ACTIVE", mock.MatchedBy(func(e *entity.OutboxEvent) bool {
+		var payload queue.ActivationPayload
+		if err := json.Unmarshal(e.Payload, &payload); err != nil {
+			return false
+		}
+		return e.MessageType == queue.MessageTypeActivation && e.AggregateID == "cust-1" &&
+			e.Status == entity.OutboxEventPending && payload.Provider == "DOC24" && payload.CPF == "12345678900"
+	})).Return(nil)
+
+	uc := usecase.NewActivateSubscriptionUseCase(mockSubRepo, mockCustomerRepo, mockPlanRepo, nil, mockQueue, nil, nil)
+	uc.Outbox = mockOutbox
+
+	err := uc.Execute(ctx, usecase.ActivateSubscriptionInput{CustomerID: "cust-1"})
+
+	assert.NoError(t, err)
+	mockOutbox.AssertExpectations(t)
+	mockQueue.AssertNotCalled(t, "PublishActivation", mock.Anything, mock.Anything)
+	mockSubRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything)
+}
+
+// TestActivateSubscriptionOutboxFailureReturnsError - Falha na transação devolve erro para o webhook ser reprocessado
+func TestActivateSubscriptionOutboxFailureReturnsError(t *testing.T) {
+	ctx := context.Background()
+	mockSubRepo := new(MockSubscriptionRepository)
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPlanRepo := new(MockPlanRepository)
+	mockOutbox := new(MockOutboxRepository)
+
+	mockCustomerRepo.On("FindByID", ctx, "cust-1").Return(&entity.Customer{ID: "cust-1"}, nil)
+	mockSubRepo.On("FindLastByCustomerID", ctx, "cust-1").Return(&entity.Subscription{ID: "sub-1", PlanID: "plan-1"}, nil)
+	mockPlanRepo.On("FindByID", ctx, "plan-1").Return(&entity.Plan{ID: "plan-1", Provider: "DOC24"}, nil)
+	mockOutbox.On("UpdateStatusWithOutbox", ctx, "cust-1", "ACTIVE", "ACTIVE", mock.Anything).Return(errors.New("deadlock"))
+
+	uc := usecase.NewActivateSubscriptionUseCase(mockSubRepo, mockCustomerRepo, mockPlanRepo, nil, nil, nil, nil)
+	uc.Outbox = mockOutbox
+
+	err := uc.Execute(ctx, usecase.ActivateSubscriptionInput{CustomerID: "cust-1"})
+
+	assert.ErrorContains(t, err, "deadlock")
+}
+
+// TestOutboxRelayMarksSent - Evento confirmado pelo broker vira SENT
+func TestOutboxRelayMarksSent(t *testing.T) {
+	ctx := context.Background()
+	mockOutbox := new(MockOutboxRepository)
+	mockPublisher := new(MockOutboxPublisher)
+
+	event := &entity.OutboxEvent{ID: "evt-1", AggregateID: "cust-1", MessageType: queue.MessageTypeActivation, Payload: []byte(`{"customer_id":"cust-1"}`)}
+	mockOutbox.On("ClaimPending", ctx, mock.Anything).Return([]*entity.OutboxEvent{event}, nil)
+	mockPublisher.On("PublishRaw", ctx, queue.MessageTypeActivation, event.Payload).Return(nil)
+	mockOutbox.On("MarkSent", ctx, "evt-1").Return(nil)
+
+	relay := worker.NewOutboxRelay(mockOutbox, mockPublisher)
+
+	assert.Equal(t, 1, relay.RelayPending(ctx))
+	mockOutbox.AssertExpectations(t)
+	mockOutbox.AssertNotCalled(t, "MarkRetry", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestOutboxRelayReschedulesOnNack - Sem confirmação o evento volta para PENDING com backoff
+func TestOutboxRelayReschedulesOnNack(t *testing.T) {
+	ctx := context.Background()
+	mockOutbox := new(MockOutboxRepository)
+	mockPublisher := new(MockOutboxPublisher)
+
+	event := &entity.OutboxEvent{ID: "evt-1", AggregateID: "cust-1", MessageType: queue.MessageTypeActivation, Attempts: 2}
+	mockOutbox.On("ClaimPending", ctx, mock.Anything).Return([]*entity.OutboxEvent{event}, nil)
+	mockPublisher.On("PublishRaw", ctx, queue.MessageTypeActivation, mock.Anything).Return(errors.New("RabbitMQ recusou a mensagem (nack)"))
+	mockOutbox.On("MarkRetry", ctx, "evt-1", 3, mock.MatchedBy(func(next time.Time) bool {
+		// BaseBackoff 5s dobrando: terceira tentativa espera 20s
+		delay := time.Until(next)
+		return delay > 15*time.Second && delay <= 20*time.Second
+	}), "RabbitMQ recusou a mensagem (nack)").Return(nil)
+
+	relay := worker.NewOutboxRelay(mockOutbox, mockPublisher)
+	relay.RelayPending(ctx)
+
+	mockOutbox.AssertExpectations(t)
+	mockOutbox.AssertNotCalled(t, "MarkSent", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/infra/integration/asaas"
+)
+
+func TestPixExpirationPolicyUsesDefaultWindow(t *testing.T) {
+	policy := entity.NewPixExpirationPolicy(45 * time.Minute)
+	from := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 45*time.Minute, policy.Window(&entity.Plan{ID: "plan-123"}))
+	assert.Equal(t, from.Add(45*time.Minute), policy.ExpiresAt(nil, from))
+}
+
+func TestPixExpirationPolicyPlanOverridesDefault(t *testing.T) {
+	policy := entity.NewPixExpirationPolicy(30 * time.Minute)
+	plan := &entity.Plan{ID: "plan-123", PixExpirationMinutes: 120}
+	from := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 2*time.Hour, policy.Window(plan))
+	assert.Equal(t, from.Add(2*time.Hour), policy.ExpiresAt(plan, from))
+}
+
+func TestPixExpirationPolicyFallsBackWhenUnset(t *testing.T) {
+	assert.Equal(t, entity.DefaultPixExpirationWindow, entity.NewPixExpirationPolicy(0).Window(nil))
+	assert.Equal(t, entity.DefaultPixExpirationWindow, entity.PixExpirationPolicy{}.Window(nil))
+}
+
+func TestSubscribePixRequiresExpiration(t *testing.T) {
+	client := asaas.NewClient("key", "http://127.0.0.1:0")
+
+	_, _, err := client.SubscribePix(asaas.SubscribePixInput{CustomerID: "cus_1", Price: 4990, Cycle: "MONTHLY"})
+
+	assert.ErrorContains(t, err, "prazo de expiração do PIX")
+}
@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/infra/integration/asaas"
+	"github.com/xavierca1/ligue-payments/internal/infra/worker"
+	"github.com/xavierca1/ligue-payments/internal/usecase"
+)
+
+type MockPixReconciliationRepository struct {
+	mock.Mock
+}
+
+func (m *MockPixReconciliationRepository) FindStalePending(ctx context.Context, olderThan time.Duration, maxAttempts, limit int) ([]*entity.Subscription, error) {
+	args := m.Called(ctx, olderThan, maxAttempts, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.Subscription), args.Error(1)
+}
+
+func (m *MockPixReconciliationRepository) Expire(ctx context.Context, subscriptionID string) (bool, error) {
+	args := m.Called(ctx, subscriptionID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPixReconciliationRepository) RegisterFailure(ctx context.Context, subscriptionID string, baseDelay, maxDelay time.Duration) (int, error) {
+	args := m.Called(ctx, subscriptionID, baseDelay, maxDelay)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPixReconciliationRepository) Record(ctx context.Context, reconciliation *entity.PixReconciliation) error {
+	args := m.Called(ctx, reconciliation)
+	return args.Error(0)
+}
+
+type MockPixPaymentGateway struct {
+	mock.Mock
+}
+
+func (m *MockPixPaymentGateway) GetSubscriptionPayments(subscriptionID string) ([]asaas.Payment, error) {
+	args := m.Called(subscriptionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]asaas.Payment), args.Error(1)
+}
+
+func (m *MockPixPaymentGateway) DeleteSubscription(subscriptionID string) error {
+	args := m.Called(subscriptionID)
+	return args.Error(0)
+}
+
+func stalePix() *entity.Subscription {
+	return &entity.Subscription{ID: "sub-1", CustomerID: "cust-1", PaymentMethod: "PIX", PaymentMethodID: "asaas-sub-1", Status: "PENDING", CreatedAt: time.Now().Add(-40 * time.Minute)}
+}
+
+func recordedAction(action string) interface{} {
+	return mock.MatchedBy(func(r *entity.PixReconciliation) bool {
+		return r.Action == action && r.SubscriptionID == "sub-1" && r.GatewaySubscriptionID == "asaas-sub-1"
+	})
+}
+
+// TestPixReconcilePaidActivates - Pagamento recebido com webhook atrasado ativa em vez de expirar
+func TestPixReconcilePaidActivates(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockPixReconciliationRepository)
+	gateway := new(MockPixPaymentGateway)
+	activator := new(MockActivateSubscriptionUseCase)
+
+	repo.On("FindStalePending", ctx, 30*time.Minute, mock.Anything, mock.Anything).Return([]*entity.Subscription{stalePix()}, nil)
+	gateway.On("GetSubscriptionPayments", "asaas-sub-1").Return([]asaas.Payment{{ID: "pay_1", Status: "RECEIVED"}}, nil)
+	activator.On("Execute", ctx, usecase.ActivateSubscriptionInput{CustomerID: "cust-1", GatewayID: "pay_1"}).Return(nil)
+	repo.On("Record", ctx, recordedAction(entity.PixReconciliationActivated)).Return(nil)
+
+	w := worker.NewPixExpirationWorker(repo, gateway, activator, entity.NewPixExpirationPolicy(30*time.Minute))
+	assert.Equal(t, 1, w.ReconcileExpired(ctx))
+
+	repo.AssertExpectations(t)
+	activator.AssertExpectations(t)
+	gateway.AssertNotCalled(t, "DeleteSubscription", mock.Anything)
+	repo.AssertNotCalled(t, "Expire", mock.Anything, mock.Anything)
+}
+
+// TestPixReconcileUnpaidCancelsAndExpires - Sem pagamento, cancela no Asaas antes de expirar
+func TestPixReconcileUnpaidCancelsAndExpires(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockPixReconciliationRepository)
+	gateway := new(MockPixPaymentGateway)
+	activator := new(MockActivateSubscriptionUseCase)
+
+	repo.On("FindStalePending", ctx, 30*time.Minute, mock.Anything, mock.Anything).Return([]*entity.Subscription{stalePix()}, nil)
+	gateway.On("GetSubscriptionPayments", "asaas-sub-1").Return([]asaas.Payment{{ID: "pay_1", Status: "PENDING"}}, nil)
+	gateway.On("DeleteSubscription", "asaas-sub-1").Return(nil)
+	repo.On("Expire", ctx, "sub-1").Return(true, nil)
+	repo.On("Record", ctx, mock.MatchedBy(func(r *entity.PixReconciliation) bool {
+		return r.Action == entity.PixReconciliationExpired && r.PaymentStatus == "PENDING"
+	})).Return(nil)
+
+	w := worker.NewPixExpirationWorker(repo, gateway, activator, entity.NewPixExpirationPolicy(30*time.Minute))
+	w.ReconcileExpired(ctx)
+
+	repo.AssertExpectations(t)
+	gateway.AssertExpectations(t)
+	activator.AssertNotCalled(t, "Execute", mock.Anything, mock.Anything)
+}
+
+// TestPixReconcileCancelFailureKeepsPending - Falha ao cancelar no Asaas não expira localmente
+func TestPixReconcileCancelFailureKeepsPending(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockPixReconciliationRepository)
+	gateway := new(MockPixPaymentGateway)
+
+	repo.On("FindStalePending", ctx, 30*time.Minute, mock.Anything, mock.Anything).Return([]*entity.Subscription{stalePix()}, nil)
+	gateway.On("GetSubscriptionPayments", "asaas-sub-1").Return([]asaas.Payment{}, nil)
+	gateway.On("DeleteSubscription", "asaas-sub-1").Return(errors.New("asaas fora"))
+	repo.On("RegisterFailure", ctx, "sub-1", time.Minute, time.Hour).Return(2, nil)
+	repo.On("Record", ctx, mock.MatchedBy(func(r *entity.PixReconciliation) bool {
+		return r.Action == entity.PixReconciliationError && strings.Contains(r.Detail, "tentativa 2/8")
+	})).Return(nil)
+
+	w := worker.NewPixExpirationWorker(repo, gateway, new(MockActivateSubscriptionUseCase), entity.NewPixExpirationPolicy(30*time.Minute))
+	w.ReconcileExpired(ctx)
+
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "Expire", mock.Anything, mock.Anything)
+}
+
+// TestPixReconcileAlreadyDeletedExpires - Assinatura que já sumiu do Asaas (404) é expirada sem novo DELETE
+func TestPixReconcileAlreadyDeletedExpires(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockPixReconciliationRepository)
+	gateway := new(MockPixPaymentGateway)
+
+	repo.On("FindStalePending", ctx, 30*time.Minute, mock.Anything, mock.Anything).Return([]*entity.Subscription{stalePix()}, nil)
+	gateway.On("GetSubscriptionPayments", "asaas-sub-1").Return(nil, fmt.Errorf("erro ao listar pagamentos: %w", asaas.ErrNotFound))
+	repo.On("Expire", ctx, "sub-1").Return(true, nil)
+	repo.On("Record", ctx, recordedAction(entity.PixReconciliationExpired)).Return(nil)
+
+	w := worker.NewPixExpirationWorker(repo, gateway, new(MockActivateSubscriptionUseCase), entity.NewPixExpirationPolicy(30*time.Minute))
+	w.ReconcileExpired(ctx)
+
+	repo.AssertExpectations(t)
+	gateway.AssertNotCalled(t, "DeleteSubscription", mock.Anything)
+	repo.AssertNotCalled(t, "RegisterFailure", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestAsaasGetSubscriptionPaymentsNotFound - 404 do Asaas no GET chega ao worker como ErrNotFound
+func TestAsaasGetSubscriptionPaymentsNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/subscriptions/asaas-sub-1/payments", r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors":[{"code":"invalid_action","description":"Assinatura removida"}]}`))
+	}))
+	defer server.Close()
+
+	client := asaas.NewClient("key", server.URL)
+	_, err := client.GetSubscriptionPayments("asaas-sub-1")
+
+	assert.ErrorIs(t, err, asaas.ErrNotFound)
+}
+
+// TestPixReconcileGivesUpAfterMaxAttempts - Na última tentativa o registro avisa que a conciliação foi encerrada
+func TestPixReconcileGivesUpAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockPixReconciliationRepository)
+	gateway := new(MockPixPaymentGateway)
+
+	repo.On("FindStalePending", ctx, 30*time.Minute, 8, mock.Anything).Return([]*entity.Subscription{stalePix()}, nil)
+	gateway.On("GetSubscriptionPayments", "asaas-sub-1").Return(nil, errors.New("timeout"))
+	repo.On("RegisterFailure", ctx, "sub-1", time.Minute, time.Hour).Return(8, nil)
+	repo.On("Record", ctx, mock.MatchedBy(func(r *entity.PixReconciliation) bool {
+		return r.Action == entity.PixReconciliationError && strings.Contains(r.Detail, "requer análise manual")
+	})).Return(nil)
+
+	w := worker.NewPixExpirationWorker(repo, gateway, new(MockActivateSubscriptionUseCase), entity.NewPixExpirationPolicy(30*time.Minute))
+	w.ReconcileExpired(ctx)
+
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "Expire", mock.Anything, mock.Anything)
+}
+
+// TestIsPaidStatus - Status do Asaas que indicam dinheiro recebido
+func TestIsPaidStatus(t *testing.T) {
+	for _, status := range []string{"RECEIVED", "CONFIRMED", "received_in_cash"} {
+		assert.True(t, asaas.IsPaidStatus(status), status)
+	}
+	for _, status := range []string{"PENDING", "OVERDUE", "REFUNDED", ""} {
+		assert.False(t, asaas.IsPaidStatus(status), status)
+	}
+}
@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/infra/http/handlers"
+	"github.com/xavierca1/ligue-payments/internal/usecase"
+)
+
+type MockPlanCatalogRepository struct {
+	mock.Mock
+}
+
+func (m *MockPlanCatalogRepository) ListCatalog(ctx context.Context, productID string) ([]*entity.Plan, error) {
+	args := m.Called(ctx, productID)
+	return args.Get(0).([]*entity.Plan), args.Error(1)
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+// TestPlanBillingCycle - Ciclo padrão mensal e próxima cobrança pelo ciclo do plano
+func TestPlanBillingCycle(t *testing.T) {
+	start := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	monthly := &entity.Plan{}
+	assert.Equal(t, entity.BillingCycleMonthly, monthly.Cycle())
+	assert.Equal(t, start.AddDate(0, 1, 0), monthly.NextBillingDate(start))
+
+	yearly := &entity.Plan{BillingCycle: "yearly"}
+	assert.Equal(t, entity.BillingCycleYearly, yearly.Cycle())
+	assert.Equal(t, 12, yearly.CycleMonths())
+	assert.Equal(t, start.AddDate(1, 0, 0), yearly.NextBillingDate(start))
+
+	assert.True(t, entity.IsValidBillingCycle("SEMIANNUALLY"))
+	assert.False(t, entity.IsValidBillingCycle("WEEKLY"))
+}
+
+// TestPlanDependentsPricing - Dependentes incluídos, extras cobrados e limite
+func TestPlanDependentsPricing(t *testing.T) {
+	plan := &entity.Plan{PriceCents: 4990, IncludedDependents: 1, ExtraDependentPriceCents: 1500, MaxDependents: intPtr(3)}
+
+	assert.Equal(t, 4990, plan.PriceFor(0))
+	assert.Equal(t, 4990, plan.PriceFor(1))
+	assert.Equal(t, 7990, plan.PriceFor(3))
+	assert.True(t, plan.AcceptsDependents(3))
+	assert.False(t, plan.AcceptsDependents(4))
+
+	unlimited := &entity.Plan{PriceCents: 4990}
+	assert.True(t, unlimited.AcceptsDependents(10))
+}
+
+// TestPlanCatalogList - Catálogo filtrado por produto com preço mensal equivalente
+func TestPlanCatalogList(t *testing.T) {
+	repo := new(MockPlanCatalogRepository)
+	repo.On("ListCatalog", mock.Anything, "prod-tele").Return([]*entity.Plan{
+		{ID: "plan-m", ProductID: "prod-tele", Name: "Mensal", PriceCents: 4990, Features: []string{"Consultas 24h"}},
+		{ID: "plan-a", ProductID: "prod-tele", Name: "Anual", PriceCents: 47880, BillingCycle: "YEARLY", MaxDependents: intPtr(4), Badge: "Mais vendido"},
+	}, nil)
+
+	rec := httptest.NewRecorder()
+	handlers.NewPlanHandler(repo).List(rec, httptest.NewRequest(http.MethodGet, "/plans?product_id=prod-tele", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var response struct {
+		Plans []handlers.PlanCatalogItem `json:"plans"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	if assert.Len(t, response.Plans, 2) {
+		assert.Equal(t, "MONTHLY", response.Plans[0].BillingCycle)
+		assert.Nil(t, response.Plans[0].MaxDependents)
+		assert.Equal(t, "YEARLY", response.Plans[1].BillingCycle)
+		assert.Equal(t, 3990, response.Plans[1].MonthlyPriceCents)
+		assert.Equal(t, 4, *response.Plans[1].MaxDependents)
+	}
+}
+
+// TestCreateCustomerRejectsInactivePlan - Plano inativo não é vendido
+func TestCreateCustomerRejectsInactivePlan(t *testing.T) {
+	mockPlanRepo := new(MockPlanRepository)
+	mockPlanRepo.On("FindByID", mock.Anything, "plan-old").Return(&entity.Plan{ID: "plan-old", PriceCents: 4990, ProductID: "prod-tele", Inactive: true}, nil)
+	mockGateway := new(MockPaymentGateway)
+
+	uc := usecase.NewCreateCustomerUseCase(new(MockCustomerRepository), new(MockSubscriptionRepository), mockPlanRepo, mockGateway, new(MockQueueProducer), new(MockEmailService), nil, "", nil)
+
+	_, err := uc.Execute(context.Background(), checkoutInput("plan-old", "PIX"))
+
+	assertDomainCode(t, err, "PLAN_UNAVAILABLE")
+	mockGateway.AssertNotCalled(t, "CreateCustomer", mock.Anything)
+}
+
+// TestCreateCustomerRejectsTooManyDependents - Limite de dependentes do plano
+func TestCreateCustomerRejectsTooManyDependents(t *testing.T) {
+	mockPlanRepo := new(MockPlanRepository)
+	mockPlanRepo.On("FindByID", mock.Anything, "plan-ind").Return(&entity.Plan{ID: "plan-ind", PriceCents: 4990, ProductID: "prod-tele", MaxDependents: intPtr(0)}, nil)
+	mockGateway := new(MockPaymentGateway)
+
+	uc := usecase.NewCreateCustomerUseCase(new(MockCustomerRepository), new(MockSubscriptionRepository), mockPlanRepo, mockGateway, new(MockQueueProducer), new(MockEmailService), nil, "", nil)
+
+	input := checkoutInput("plan-ind", "PIX")
+	input.Dependents = []usecase.DependentInput{
+		{Name: "Maria Silva", CPF: "987.654.321-00", BirthDate: "2015-03-10", Gender: "2", Kinship: "FILHO"},
+	}
+	_, err := uc.Execute(context.Background(), input)
+
+	assertDomainCode(t, err, "DEPENDENTS_LIMIT")
+	mockGateway.AssertNotCalled(t, "CreateCustomer", mock.Anything)
+}
@@ -0,0 +1,643 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/infra/integration/asaas"
+	"github.com/xavierca1/ligue-payments/internal/infra/integration/docuseal"
+	"github.com/xavierca1/ligue-payments/internal/infra/queue"
+	"github.com/xavierca1/ligue-payments/internal/usecase"
+)
+
+type MockPlanChangeRepository struct {
+	mock.Mock
+}
+
+func (m *MockPlanChangeRepository) FindPendingPlanChange(ctx context.Context, subscriptionID string) (*entity.PlanChange, error) {
+	args := m.Called(ctx, subscriptionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*entity.PlanChange), args.Error(1)
+}
+
+func (m *MockPlanChangeRepository) CreatePendingPlanChange(ctx context.Context, change *entity.PlanChange) error {
+	args := m.Called(ctx, change)
+	return args.Error(0)
+}
+
+func (m *MockPlanChangeRepository) SetPlanChangeCharge(ctx context.Context, changeID, chargeID string) error {
+	args := m.Called(ctx, changeID, chargeID)
+	return args.Error(0)
+}
+
+func (m *MockPlanChangeRepository) ApplyPlanChange(ctx context.Context, change *entity.PlanChange) error {
+	args := m.Called(ctx, change)
+	return args.Error(0)
+}
+
+func (m *MockPlanChangeRepository) FailPlanChange(ctx context.Context, changeID, reason string) error {
+	args := m.Called(ctx, changeID, reason)
+	return args.Error(0)
+}
+
+type MockPlanChangeGateway struct {
+	mock.Mock
+}
+
+func (m *MockPlanChangeGateway) UpdateSubscriptionValue(gatewaySubscriptionID string, valueCents int) error {
+	args := m.Called(gatewaySubscriptionID, valueCents)
+	return args.Error(0)
+}
+
+func (m *MockPlanChangeGateway) CreatePayment(input asaas.CreatePaymentInput) (*asaas.PaymentOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*asaas.PaymentOutput), args.Error(1)
+}
+
+func (m *MockPlanChangeGateway) FindPaymentByExternalReference(externalReference string) (*asaas.PaymentOutput, error) {
+	args := m.Called(externalReference)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*asaas.PaymentOutput), args.Error(1)
+}
+
+func (m *MockPlanChangeGateway) DeletePayment(paymentID string) error {
+	args := m.Called(paymentID)
+	return args.Error(0)
+}
+
+type MockSubscriptionCouponFinder struct {
+	mock.Mock
+}
+
+func (m *MockSubscriptionCouponFinder) FindSubscriptionCoupon(ctx context.Context, subscriptionID string) (*usecase.CouponSaleRecord, error) {
+	args := m.Called(ctx, subscriptionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*usecase.CouponSaleRecord), args.Error(1)
+}
+
+// TestProratePlanChange - Diferença proporcional aos dias restantes do ciclo
+func TestProratePlanChange(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2026, 1, 16, 14, 30, 0, 0, time.UTC)
+
+	upgrade := entity.ProratePlanChange(4990, 7990, start, end, now)
+	assert.Equal(t, 31, upgrade.CycleDays)
+	assert.Equal(t, 16, upgrade.RemainingDays)
+	assert.Equal(t, 1548, upgrade.AmountCents) // 3000 * 16/31
+
+	downgrade := entity.ProratePlanChange(7990, 4990, start, end, now)
+	assert.Equal(t, -1548, downgrade.AmountCents)
+
+	lastDay := entity.ProratePlanChange(4990, 7990, start, end, end)
+	assert.Equal(t, 0, lastDay.AmountCents)
+}
+
+// TestPlanCurrentCycle - Ciclo atual a partir de uma data de cobrança passada ou futura
+func TestPlanCurrentCycle(t *testing.T) {
+	plan := &entity.Plan{}
+	now := time.Date(2026, 5, 20, 12, 0, 0, 0, time.UTC)
+
+	start, end := plan.CurrentCycle(time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC), now)
+	assert.Equal(t, time.Date(2026, 5, 10, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2026, 6, 10, 0, 0, 0, 0, time.UTC), end)
+
+	start, end = plan.CurrentCycle(time.Date(2026, 9, 10, 0, 0, 0, 0, time.UTC), now)
+	assert.Equal(t, time.Date(2026, 5, 10, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2026, 6, 10, 0, 0, 0, 0, time.UTC), end)
+}
+
+// activeSubscriptionOnPlan é a assinatura ativa de cartão do cliente cust-1, no meio do ciclo.
+func activeSubscriptionOnPlan(plan *entity.Plan) *entity.Subscription {
+	return &entity.Subscription{
+		ID:              "sub-1",
+		CustomerID:      "cust-1",
+		PlanID:          plan.ID,
+		Amount:          plan.PriceCents,
+		Status:          "ACTIVE",
+		PaymentMethod:   "CREDIT_CARD",
+		PaymentMethodID: "sub_asaas_1",
+		NextBillingDate: time.Now().AddDate(0, 0, 15),
+	}
+}
+
+// planRepoWith responde FindByID para cada plano informado.
+func planRepoWith(plans ...*entity.Plan) *MockPlanRepository {
+	planRepo := new(MockPlanRepository)
+	for _, plan := range plans {
+		planRepo.On("FindByID", mock.Anything, plan.ID).Return(plan, nil)
+	}
+	return planRepo
+}
+
+func currentCycleProration(current *entity.Plan, sub *entity.Subscription, toAmount int) entity.PlanChangeProration {
+	now := time.Now()
+	start, end := current.CurrentCycle(sub.NextBillingDate, now)
+	return entity.ProratePlanChange(sub.Amount, toAmount, start, end, now)
+}
+
+// TestChangePlanUpgradeChargesProration - Upgrade cobra o pró-rata à parte e atualiza o provedor
+func TestChangePlanUpgradeChargesProration(t *testing.T) {
+	current := &entity.Plan{ID: "plan-dia", Name: "Ligue Saúde em Dia", PriceCents: 4990, ProductID: "prod-tele", Provider: "DOC24", ProviderPlanCode: "DIA"}
+	target := &entity.Plan{ID: "plan-total", Name: "Ligue Cuidado Total", PriceCents: 9990, ProductID: "prod-tele", Provider: "DOC24", ProviderPlanCode: "TOTAL"}
+	sub := activeSubscriptionOnPlan(current)
+	prorated := currentCycleProration(current, sub, 9990).AmountCents
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(sub, nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(&entity.Customer{
+		ID: "cust-1", Name: "João Silva", Email: "joao@example.com", CPF: "12345678900", GatewayID: "cus_1", Gender: 1,
+	}, nil)
+	dependentRepo := new(MockDependentRepository)
+	dependentRepo.On("FindByCustomerID", mock.Anything, "cust-1").Return([]*entity.Dependent{}, nil)
+
+	changes := new(MockPlanChangeRepository)
+	changes.On("FindPendingPlanChange", mock.Anything, "sub-1").Return(nil, nil)
+	changes.On("CreatePendingPlanChange", mock.Anything, mock.Anything).Return(nil)
+	changes.On("SetPlanChangeCharge", mock.Anything, mock.Anything, "pay_prorata").Return(nil)
+	changes.On("ApplyPlanChange", mock.Anything, mock.MatchedBy(func(change *entity.PlanChange) bool {
+		return change.FromPlanID == "plan-dia" && change.ToPlanID == "plan-total" &&
+			change.NextAmountCents == 9990 && change.ProratedCents == prorated && change.ChargeID == "pay_prorata"
+	})).Return(nil)
+	gateway := new(MockPlanChangeGateway)
+	gateway.On("CreatePayment", mock.MatchedBy(func(input asaas.CreatePaymentInput) bool {
+		return input.CustomerID == "cus_1" && input.ValueCents == prorated &&
+			strings.HasPrefix(input.ExternalReference, usecase.PlanChangeChargeReferencePrefix)
+	})).Return(&asaas.PaymentOutput{ID: "pay_prorata", InvoiceURL: "https://asaas.test/i/pay_prorata"}, nil)
+	gateway.On("UpdateSubscriptionValue", "sub_asaas_1", 9990).Return(nil)
+	publisher := new(MockQueueProducer)
+	publisher.On("PublishUpdate", mock.Anything, mock.MatchedBy(func(payload queue.ActivationPayload) bool {
+		return payload.ProviderPlanCode == "TOTAL" && payload.Provider == "DOC24" && payload.Origin == "PLAN_CHANGE"
+	})).Return(nil)
+
+	uc := usecase.NewChangePlanUseCase(subRepo, customerRepo, planRepoWith(current, target), dependentRepo, changes, gateway, publisher)
+	output, err := uc.Execute(context.Background(), usecase.ChangePlanInput{CustomerID: "cust-1", PlanID: "plan-total"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, prorated, output.ProratedCents)
+	assert.Equal(t, "https://asaas.test/i/pay_prorata", output.ChargeInvoiceURL)
+	assert.NotEmpty(t, output.ChangeID)
+	gateway.AssertExpectations(t)
+	changes.AssertExpectations(t)
+	publisher.AssertExpectations(t)
+	publisher.AssertNotCalled(t, "PublishActivation", mock.Anything, mock.Anything)
+}
+
+// TestChangePlanDowngradeCreditsNextCharge - Downgrade abate o crédito da próxima cobrança e troca de provedor
+func TestChangePlanDowngradeCreditsNextCharge(t *testing.T) {
+	current := &entity.Plan{ID: "plan-total", Name: "Ligue Cuidado Total", PriceCents: 9990, ProductID: "prod-tele", Provider: "TEM", ProviderPlanCode: "TOTAL"}
+	target := &entity.Plan{ID: "plan-dia", Name: "Ligue Saúde em Dia", PriceCents: 4990, ProductID: "prod-tele", Provider: "DOC24", ProviderPlanCode: "DIA"}
+	sub := activeSubscriptionOnPlan(current)
+	credit := -currentCycleProration(current, sub, 4990).AmountCents
+	_, cycleEnd := current.CurrentCycle(sub.NextBillingDate, time.Now())
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(sub, nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(&entity.Customer{ID: "cust-1", Name: "João Silva", CPF: "12345678900", GatewayID: "cus_1"}, nil)
+	dependentRepo := new(MockDependentRepository)
+	dependentRepo.On("FindByCustomerID", mock.Anything, "cust-1").Return([]*entity.Dependent{}, nil)
+
+	changes := new(MockPlanChangeRepository)
+	changes.On("FindPendingPlanChange", mock.Anything, "sub-1").Return(nil, nil)
+	changes.On("CreatePendingPlanChange", mock.Anything, mock.Anything).Return(nil)
+	changes.On("ApplyPlanChange", mock.Anything, mock.MatchedBy(func(change *entity.PlanChange) bool {
+		return change.ToAmountCents == 4990 && change.NextAmountCents == 4990-credit && change.CreditCents() == credit &&
+			change.CreditFromDate.Equal(cycleEnd)
+	})).Return(nil)
+	gateway := new(MockPlanChangeGateway)
+	gateway.On("UpdateSubscriptionValue", "sub_asaas_1", 4990-credit).Return(nil)
+	publisher := new(MockQueueProducer)
+	publisher.On("PublishDeactivation", mock.Anything, mock.MatchedBy(func(payload queue.DeactivationPayload) bool {
+		return payload.Provider == "TEM" && payload.ProviderPlanCode == "TOTAL" && payload.Reason == "PLAN_CHANGE"
+	})).Return(nil)
+	publisher.On("PublishActivation", mock.Anything, mock.MatchedBy(func(payload queue.ActivationPayload) bool {
+		return payload.Provider == "DOC24" && payload.ProviderPlanCode == "DIA"
+	})).Return(nil)
+
+	uc := usecase.NewChangePlanUseCase(subRepo, customerRepo, planRepoWith(current, target), dependentRepo, changes, gateway, publisher)
+	output, err := uc.Execute(context.Background(), usecase.ChangePlanInput{CustomerID: "cust-1", PlanID: "plan-dia"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, -credit, output.ProratedCents)
+	gateway.AssertNotCalled(t, "CreatePayment", mock.Anything)
+	gateway.AssertExpectations(t)
+	changes.AssertExpectations(t)
+	publisher.AssertExpectations(t)
+}
+
+// TestChangePlanResumesPendingChange - Retry retoma a troca pendente sem cobrar o pró-rata de novo
+func TestChangePlanResumesPendingChange(t *testing.T) {
+	current := &entity.Plan{ID: "plan-dia", Name: "Ligue Saúde em Dia", PriceCents: 4990, ProductID: "prod-tele", Provider: "DOC24"}
+	target := &entity.Plan{ID: "plan-total", Name: "Ligue Cuidado Total", PriceCents: 9990, ProductID: "prod-tele", Provider: "DOC24"}
+	pending := &entity.PlanChange{
+		ID: "chg-1", SubscriptionID: "sub-1", CustomerID: "cust-1", FromPlanID: "plan-dia", ToPlanID: "plan-total",
+		FromAmountCents: 4990, ToAmountCents: 9990, NextAmountCents: 9990, ProratedCents: 2500, Status: entity.PlanChangePending,
+	}
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(activeSubscriptionOnPlan(current), nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(&entity.Customer{ID: "cust-1", Name: "João Silva", CPF: "12345678900", GatewayID: "cus_1"}, nil)
+	dependentRepo := new(MockDependentRepository)
+	dependentRepo.On("FindByCustomerID", mock.Anything, "cust-1").Return([]*entity.Dependent{}, nil)
+
+	changes := new(MockPlanChangeRepository)
+	changes.On("FindPendingPlanChange", mock.Anything, "sub-1").Return(pending, nil)
+	changes.On("SetPlanChangeCharge", mock.Anything, "chg-1", "pay_prorata").Return(nil)
+	changes.On("ApplyPlanChange", mock.Anything, mock.MatchedBy(func(change *entity.PlanChange) bool {
+		return change.ID == "chg-1" && change.ChargeID == "pay_prorata"
+	})).Return(nil)
+	gateway := new(MockPlanChangeGateway)
+	gateway.On("FindPaymentByExternalReference", usecase.PlanChangeChargeReferencePrefix+"chg-1").
+		Return(&asaas.PaymentOutput{ID: "pay_prorata", InvoiceURL: "https://asaas.test/i/pay_prorata"}, nil)
+	gateway.On("UpdateSubscriptionValue", "sub_asaas_1", 9990).Return(nil)
+	publisher := new(MockQueueProducer)
+	publisher.On("PublishUpdate", mock.Anything, mock.Anything).Return(nil)
+
+	uc := usecase.NewChangePlanUseCase(subRepo, customerRepo, planRepoWith(current, target), dependentRepo, changes, gateway, publisher)
+	output, err := uc.Execute(context.Background(), usecase.ChangePlanInput{CustomerID: "cust-1", PlanID: "plan-total"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "chg-1", output.ChangeID)
+	assert.Equal(t, 2500, output.ProratedCents)
+	assert.Equal(t, "https://asaas.test/i/pay_prorata", output.ChargeInvoiceURL)
+	gateway.AssertNotCalled(t, "CreatePayment", mock.Anything)
+	changes.AssertNotCalled(t, "CreatePendingPlanChange", mock.Anything, mock.Anything)
+	changes.AssertExpectations(t)
+}
+
+// TestChangePlanDatabaseFailureKeepsChangePending - Falha no banco depois do Asaas deixa a troca pendente para o retry
+func TestChangePlanDatabaseFailureKeepsChangePending(t *testing.T) {
+	current := &entity.Plan{ID: "plan-dia", PriceCents: 4990, ProductID: "prod-tele", Provider: "DOC24"}
+	target := &entity.Plan{ID: "plan-total", PriceCents: 9990, ProductID: "prod-tele", Provider: "DOC24"}
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(activeSubscriptionOnPlan(current), nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(&entity.Customer{ID: "cust-1", Name: "João Silva", GatewayID: "cus_1"}, nil)
+	dependentRepo := new(MockDependentRepository)
+	dependentRepo.On("FindByCustomerID", mock.Anything, "cust-1").Return([]*entity.Dependent{}, nil)
+
+	var created *entity.PlanChange
+	changes := new(MockPlanChangeRepository)
+	changes.On("FindPendingPlanChange", mock.Anything, "sub-1").Return(nil, nil)
+	changes.On("CreatePendingPlanChange", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		created = args.Get(1).(*entity.PlanChange)
+	}).Return(nil)
+	changes.On("SetPlanChangeCharge", mock.Anything, mock.Anything, "pay_prorata").Return(nil)
+	changes.On("ApplyPlanChange", mock.Anything, mock.Anything).Return(errors.New("connection reset"))
+	gateway := new(MockPlanChangeGateway)
+	gateway.On("CreatePayment", mock.Anything).Return(&asaas.PaymentOutput{ID: "pay_prorata"}, nil)
+	gateway.On("UpdateSubscriptionValue", "sub_asaas_1", 9990).Return(nil)
+	publisher := new(MockQueueProducer)
+
+	uc := usecase.NewChangePlanUseCase(subRepo, customerRepo, planRepoWith(current, target), dependentRepo, changes, gateway, publisher)
+	_, err := uc.Execute(context.Background(), usecase.ChangePlanInput{CustomerID: "cust-1", PlanID: "plan-total"})
+
+	assert.Error(t, err)
+	if assert.NotNil(t, created) {
+		assert.Equal(t, entity.PlanChangePending, created.Status)
+		assert.Equal(t, "pay_prorata", created.ChargeID)
+	}
+	changes.AssertNotCalled(t, "FailPlanChange", mock.Anything, mock.Anything, mock.Anything)
+	publisher.AssertNotCalled(t, "PublishUpdate", mock.Anything, mock.Anything)
+}
+
+// TestChangePlanConflictAfterGatewayAbandonsChange - Assinatura alterada depois do Asaas: reverte o valor, cancela o pró-rata e marca FAILED
+func TestChangePlanConflictAfterGatewayAbandonsChange(t *testing.T) {
+	current := &entity.Plan{ID: "plan-dia", PriceCents: 4990, ProductID: "prod-tele", Provider: "DOC24"}
+	target := &entity.Plan{ID: "plan-total", PriceCents: 9990, ProductID: "prod-tele", Provider: "DOC24"}
+	sub := &entity.Subscription{
+		ID: "sub-1", CustomerID: "cust-1", PlanID: current.ID, Amount: 4990, Status: "ACTIVE",
+		PaymentMethod: "CREDIT_CARD", PaymentMethodID: "sub_asaas_1", NextBillingDate: time.Now().AddDate(0, 0, 15),
+	}
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(sub, nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(&entity.Customer{ID: "cust-1", Name: "João Silva", GatewayID: "cus_1"}, nil)
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, current.ID).Return(current, nil)
+	planRepo.On("FindByID", mock.Anything, target.ID).Return(target, nil)
+	dependentRepo := new(MockDependentRepository)
+	dependentRepo.On("FindByCustomerID", mock.Anything, "cust-1").Return([]*entity.Dependent{}, nil)
+
+	var created *entity.PlanChange
+	changes := new(MockPlanChangeRepository)
+	changes.On("FindPendingPlanChange", mock.Anything, "sub-1").Return(nil, nil)
+	changes.On("CreatePendingPlanChange", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		created = args.Get(1).(*entity.PlanChange)
+	}).Return(nil)
+	changes.On("SetPlanChangeCharge", mock.Anything, mock.Anything, "pay_prorata").Return(nil)
+	changes.On("ApplyPlanChange", mock.Anything, mock.Anything).Return(entity.ErrPlanChangeConflict)
+	changes.On("FailPlanChange", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	gateway := new(MockPlanChangeGateway)
+	gateway.On("CreatePayment", mock.Anything).Return(&asaas.PaymentOutput{ID: "pay_prorata"}, nil)
+	gateway.On("UpdateSubscriptionValue", "sub_asaas_1", 9990).Return(nil).Once()
+	gateway.On("UpdateSubscriptionValue", "sub_asaas_1", 4990).Return(nil).Once()
+	gateway.On("DeletePayment", "pay_prorata").Return(nil)
+	publisher := new(MockQueueProducer)
+
+	uc := usecase.NewChangePlanUseCase(subRepo, customerRepo, planRepo, dependentRepo, changes, gateway, publisher)
+	_, err := uc.Execute(context.Background(), usecase.ChangePlanInput{CustomerID: "cust-1", PlanID: "plan-total"})
+
+	assertDomainCode(t, err, "PLAN_CHANGE_CONFLICT")
+	gateway.AssertExpectations(t)
+	if assert.NotNil(t, created) {
+		changes.AssertCalled(t, "FailPlanChange", mock.Anything, created.ID, mock.Anything)
+		assert.Equal(t, entity.PlanChangeFailed, created.Status)
+	}
+	publisher.AssertNotCalled(t, "PublishUpdate", mock.Anything, mock.Anything)
+}
+
+// TestChangePlanAbandonByAdmin - Admin libera troca pendente cuja cobrança sumiu da resposta; sem troca pendente é 404
+func TestChangePlanAbandonByAdmin(t *testing.T) {
+	sub := &entity.Subscription{ID: "sub-1", CustomerID: "cust-1", Amount: 4990, Status: "ACTIVE", PaymentMethodID: "sub_asaas_1"}
+	pending := &entity.PlanChange{ID: "chg-1", SubscriptionID: "sub-1", ProratedCents: 2500, Status: entity.PlanChangePending}
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(sub, nil)
+	changes := new(MockPlanChangeRepository)
+	changes.On("FindPendingPlanChange", mock.Anything, "sub-1").Return(pending, nil).Once()
+	changes.On("FindPendingPlanChange", mock.Anything, "sub-1").Return(nil, nil).Once()
+	changes.On("FailPlanChange", mock.Anything, "chg-1", mock.Anything).Return(nil)
+	gateway := new(MockPlanChangeGateway)
+	gateway.On("UpdateSubscriptionValue", "sub_asaas_1", 4990).Return(nil)
+	gateway.On("FindPaymentByExternalReference", usecase.PlanChangeChargeReferencePrefix+"chg-1").Return(&asaas.PaymentOutput{ID: "pay_prorata"}, nil)
+	gateway.On("DeletePayment", "pay_prorata").Return(asaas.ErrNotFound)
+
+	uc := usecase.NewChangePlanUseCase(subRepo, nil, nil, nil, changes, gateway, nil)
+
+	change, err := uc.Abandon(context.Background(), "cust-1")
+	assert.NoError(t, err)
+	if assert.NotNil(t, change) {
+		assert.Equal(t, entity.PlanChangeFailed, change.Status)
+	}
+	gateway.AssertExpectations(t)
+
+	_, err = uc.Abandon(context.Background(), "cust-1")
+	assertDomainCode(t, err, "PLAN_CHANGE_NOT_FOUND")
+	changes.AssertExpectations(t)
+}
+
+// TestChangePlanKeepsPermanentCoupon - Cupom sem limite de ciclos continua valendo no plano novo
+func TestChangePlanKeepsPermanentCoupon(t *testing.T) {
+	current := &entity.Plan{ID: "plan-dia", PriceCents: 4990, ProductID: "prod-tele", Provider: "DOC24"}
+	target := &entity.Plan{ID: "plan-total", PriceCents: 9990, ProductID: "prod-tele", Provider: "DOC24"}
+	sub := activeSubscriptionOnPlan(current)
+	sub.Amount = 3992 // 20% sobre 4990
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(sub, nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(&entity.Customer{ID: "cust-1", Name: "João Silva", GatewayID: "cus_1"}, nil)
+	dependentRepo := new(MockDependentRepository)
+	dependentRepo.On("FindByCustomerID", mock.Anything, "cust-1").Return([]*entity.Dependent{}, nil)
+	coupons := new(MockSubscriptionCouponFinder)
+	coupons.On("FindSubscriptionCoupon", mock.Anything, "sub-1").Return(&usecase.CouponSaleRecord{
+		CouponCode: "VINTE", DiscountType: usecase.CouponDiscountPercent, DiscountPercent: 20,
+	}, nil)
+
+	changes := new(MockPlanChangeRepository)
+	changes.On("FindPendingPlanChange", mock.Anything, "sub-1").Return(nil, nil)
+	changes.On("CreatePendingPlanChange", mock.Anything, mock.Anything).Return(nil)
+	changes.On("SetPlanChangeCharge", mock.Anything, mock.Anything, "pay_prorata").Return(nil)
+	changes.On("ApplyPlanChange", mock.Anything, mock.MatchedBy(func(change *entity.PlanChange) bool {
+		return change.ToAmountCents == 7992 && change.CouponCode == "VINTE"
+	})).Return(nil)
+	gateway := new(MockPlanChangeGateway)
+	gateway.On("CreatePayment", mock.Anything).Return(&asaas.PaymentOutput{ID: "pay_prorata"}, nil)
+	gateway.On("UpdateSubscriptionValue", "sub_asaas_1", 7992).Return(nil)
+	publisher := new(MockQueueProducer)
+	publisher.On("PublishUpdate", mock.Anything, mock.Anything).Return(nil)
+
+	uc := usecase.NewChangePlanUseCase(subRepo, customerRepo, planRepoWith(current, target), dependentRepo, changes, gateway, publisher)
+	uc.Coupons = coupons
+	output, err := uc.Execute(context.Background(), usecase.ChangePlanInput{CustomerID: "cust-1", PlanID: "plan-total"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "VINTE", output.CouponCode)
+	assert.Equal(t, currentCycleProration(current, sub, 7992).AmountCents, output.ProratedCents)
+	gateway.AssertExpectations(t)
+	changes.AssertExpectations(t)
+}
+
+// TestChangePlanPreview - Preview calcula o pró-rata sem cobrar nem alterar a assinatura
+func TestChangePlanPreview(t *testing.T) {
+	current := &entity.Plan{ID: "plan-dia", PriceCents: 4990, ProductID: "prod-tele", Provider: "DOC24"}
+	target := &entity.Plan{ID: "plan-total", PriceCents: 9990, ProductID: "prod-tele", Provider: "DOC24"}
+	sub := activeSubscriptionOnPlan(current)
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(sub, nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(&entity.Customer{ID: "cust-1", GatewayID: "cus_1"}, nil)
+	changes := new(MockPlanChangeRepository)
+	gateway := new(MockPlanChangeGateway)
+
+	uc := usecase.NewChangePlanUseCase(subRepo, customerRepo, planRepoWith(current, target), nil, changes, gateway, nil)
+	output, err := uc.Execute(context.Background(), usecase.ChangePlanInput{CustomerID: "cust-1", PlanID: "plan-total", Preview: true})
+
+	assert.NoError(t, err)
+	assert.True(t, output.Preview)
+	assert.Equal(t, currentCycleProration(current, sub, 9990).AmountCents, output.ProratedCents)
+	assert.Empty(t, output.ChangeID)
+	gateway.AssertNotCalled(t, "CreatePayment", mock.Anything)
+	gateway.AssertNotCalled(t, "UpdateSubscriptionValue", mock.Anything, mock.Anything)
+	changes.AssertNotCalled(t, "CreatePendingPlanChange", mock.Anything, mock.Anything)
+}
+
+// TestChangePlanRejections - Regras de elegibilidade da troca
+func TestChangePlanRejections(t *testing.T) {
+	current := &entity.Plan{ID: "plan-dia", PriceCents: 4990, ProductID: "prod-tele"}
+	total := &entity.Plan{ID: "plan-total", PriceCents: 9990, ProductID: "prod-tele"}
+
+	changePlan := func(sub *entity.Subscription, planRepo *MockPlanRepository, changes *MockPlanChangeRepository, planID string) error {
+		subRepo := new(MockSubscriptionRepository)
+		subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(sub, nil)
+		customerRepo := new(MockCustomerRepository)
+		customerRepo.On("FindByID", mock.Anything, "cust-1").Return(&entity.Customer{ID: "cust-1", GatewayID: "cus_1"}, nil)
+		uc := usecase.NewChangePlanUseCase(subRepo, customerRepo, planRepo, nil, changes, new(MockPlanChangeGateway), nil)
+		_, err := uc.Execute(context.Background(), usecase.ChangePlanInput{CustomerID: "cust-1", PlanID: planID})
+		return err
+	}
+
+	t.Run("assinatura não ativa", func(t *testing.T) {
+		sub := activeSubscriptionOnPlan(current)
+		sub.Status = "PAST_DUE"
+		err := changePlan(sub, new(MockPlanRepository), new(MockPlanChangeRepository), "plan-total")
+		assertDomainCode(t, err, "SUBSCRIPTION_NOT_ACTIVE")
+	})
+
+	t.Run("mesmo plano", func(t *testing.T) {
+		err := changePlan(activeSubscriptionOnPlan(current), new(MockPlanRepository), new(MockPlanChangeRepository), "plan-dia")
+		assertDomainCode(t, err, "SAME_PLAN")
+	})
+
+	t.Run("ciclo diferente", func(t *testing.T) {
+		annual := &entity.Plan{ID: "plan-anual", PriceCents: 47880, ProductID: "prod-tele", BillingCycle: "YEARLY"}
+		err := changePlan(activeSubscriptionOnPlan(current), planRepoWith(current, annual), new(MockPlanChangeRepository), "plan-anual")
+		assertDomainCode(t, err, "BILLING_CYCLE_MISMATCH")
+	})
+
+	t.Run("plano inativo", func(t *testing.T) {
+		old := &entity.Plan{ID: "plan-old", PriceCents: 3990, ProductID: "prod-tele", Inactive: true}
+		err := changePlan(activeSubscriptionOnPlan(current), planRepoWith(current, old), new(MockPlanChangeRepository), "plan-old")
+		assertDomainCode(t, err, "PLAN_UNAVAILABLE")
+	})
+
+	t.Run("desconto temporário em andamento", func(t *testing.T) {
+		sub := activeSubscriptionOnPlan(current)
+		cycles := 2
+		sub.FullAmountCents, sub.DiscountCyclesRemaining = 4990, &cycles
+		err := changePlan(sub, planRepoWith(current, total), new(MockPlanChangeRepository), "plan-total")
+		assertDomainCode(t, err, "DISCOUNT_IN_PROGRESS")
+	})
+
+	t.Run("troca pendente para outro plano", func(t *testing.T) {
+		changes := new(MockPlanChangeRepository)
+		changes.On("FindPendingPlanChange", mock.Anything, "sub-1").Return(&entity.PlanChange{ID: "chg-0", FromPlanID: "plan-dia", ToPlanID: "plan-mais"}, nil)
+		err := changePlan(activeSubscriptionOnPlan(current), planRepoWith(current, total), changes, "plan-total")
+		assertDomainCode(t, err, "PLAN_CHANGE_PENDING")
+		changes.AssertNotCalled(t, "CreatePendingPlanChange", mock.Anything, mock.Anything)
+	})
+
+	t.Run("outro produto", func(t *testing.T) {
+		pet := &entity.Plan{ID: "plan-pet", PriceCents: 2990, ProductID: "prod-pet"}
+		err := changePlan(activeSubscriptionOnPlan(current), planRepoWith(current, pet), new(MockPlanChangeRepository), "plan-pet")
+		assertDomainCode(t, err, "PLAN_PRODUCT_MISMATCH")
+	})
+}
+
+// TestChangePlanRejectsDependentsOutsideTargetPlan - Plano novo que não aceita PAI/MAE nem filho acima da idade recusa a troca
+func TestChangePlanRejectsDependentsOutsideTargetPlan(t *testing.T) {
+	current := &entity.Plan{ID: "plan-familia", PriceCents: 9990, ProductID: "prod-tele", DependentRules: entity.DependentRules{
+		AllowedKinships: []string{entity.KinshipChild, entity.KinshipSpouse, entity.KinshipFather, entity.KinshipMother},
+	}}
+	target := &entity.Plan{ID: "plan-casal", PriceCents: 6990, ProductID: "prod-tele", DependentRules: entity.DependentRules{
+		ChildMaxAge: 21, AllowedKinships: []string{entity.KinshipChild, entity.KinshipSpouse},
+	}}
+	sub := &entity.Subscription{ID: "sub-1", CustomerID: "cust-1", PlanID: current.ID, Amount: 9990, Status: "ACTIVE", PaymentMethodID: "sub_asaas_1", NextBillingDate: time.Now().AddDate(0, 0, 15)}
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(sub, nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(&entity.Customer{ID: "cust-1", CPF: "12345678900", GatewayID: "cus_1"}, nil)
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, current.ID).Return(current, nil)
+	planRepo.On("FindByID", mock.Anything, target.ID).Return(target, nil)
+	dependentRepo := new(MockDependentRepository)
+	dependentRepo.On("FindByCustomerID", mock.Anything, "cust-1").Return([]*entity.Dependent{
+		{ID: "dep-mae", Kinship: entity.KinshipMother, CPF: "11122233344", BirthDate: "1960-01-01"},
+		{ID: "dep-filho", Kinship: entity.KinshipChild, CPF: "55566677788", BirthDate: time.Now().AddDate(-25, 0, 0).Format("2006-01-02")},
+	}, nil)
+	changes := new(MockPlanChangeRepository)
+	gateway := new(MockPlanChangeGateway)
+
+	uc := usecase.NewChangePlanUseCase(subRepo, customerRepo, planRepo, dependentRepo, changes, gateway, nil)
+	_, err := uc.Execute(context.Background(), usecase.ChangePlanInput{CustomerID: "cust-1", PlanID: "plan-casal"})
+
+	assertDomainCode(t, err, "VALIDATION_ERROR")
+	var domainErr *usecase.DomainError
+	if assert.ErrorAs(t, err, &domainErr) {
+		fields := map[string]bool{}
+		for _, f := range domainErr.Fields {
+			fields[f.Field] = true
+		}
+		assert.True(t, fields["dependents[dep-mae].kinship"])
+		assert.True(t, fields["dependents[dep-filho].birth_date"])
+	}
+	changes.AssertNotCalled(t, "CreatePendingPlanChange", mock.Anything, mock.Anything)
+	gateway.AssertNotCalled(t, "UpdateSubscriptionValue", mock.Anything, mock.Anything)
+}
+
+// TestProcessWebhookEventIgnoresPlanChangeCharge - Cobrança de pró-rata não reativa nem estorna a assinatura
+func TestProcessWebhookEventIgnoresPlanChangeCharge(t *testing.T) {
+	ctx := context.Background()
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockActivate := new(MockActivateSubscriptionUseCase)
+
+	for _, body := range []string{
+		`{"event":"PAYMENT_RECEIVED","payment":{"id":"pay_p","customer":"cus_1","status":"RECEIVED","externalReference":"plan_change:chg-1"}}`,
+		`{"event":"PAYMENT_REFUNDED","payment":{"id":"pay_p","customer":"cus_1","status":"REFUNDED","externalReference":"plan_change:chg-1"}}`,
+	} {
+		event, _ := usecase.NewAsaasWebhookEvent([]byte(body))
+		uc := usecase.NewProcessWebhookEventUseCase(mockCustomerRepo, mockActivate, nil)
+		assert.NoError(t, uc.Execute(ctx, event))
+	}
+
+	mockActivate.AssertNotCalled(t, "Execute", mock.Anything, mock.Anything)
+	mockCustomerRepo.AssertNotCalled(t, "FindByGatewayID", mock.Anything)
+}
+
+// TestChangePlanAddendumAnnualPeriodicity - Aditivo de plano anual vai ao DocuSeal com "Anual", não "Mensal"
+func TestChangePlanAddendumAnnualPeriodicity(t *testing.T) {
+	var submitted docuseal.CreateSubmissionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&submitted))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"uuid":"sub-docuseal-1","status":"completed"}`))
+	}))
+	defer server.Close()
+
+	current := &entity.Plan{ID: "plan-dia", Name: "Ligue Saúde em Dia", PriceCents: 47880, BillingCycle: entity.BillingCycleYearly, Provider: "DOC24"}
+	target := &entity.Plan{ID: "plan-anual", Name: "Ligue Viver Bem", PriceCents: 59880, BillingCycle: entity.BillingCycleYearly, Provider: "DOC24"}
+	sub := &entity.Subscription{
+		ID: "sub-1", CustomerID: "cust-1", PlanID: current.ID, Amount: 47880, Status: "ACTIVE",
+		PaymentMethod: "CREDIT_CARD", PaymentMethodID: "sub_asaas_1", NextBillingDate: time.Now().AddDate(0, 0, 15),
+	}
+
+	subRepo := new(MockSubscriptionRepository)
+	subRepo.On("FindLastByCustomerID", mock.Anything, "cust-1").Return(sub, nil)
+	customerRepo := new(MockCustomerRepository)
+	customerRepo.On("FindByID", mock.Anything, "cust-1").Return(&entity.Customer{ID: "cust-1", Name: "João Silva", Email: "joao@example.com", GatewayID: "cus_1"}, nil)
+	planRepo := new(MockPlanRepository)
+	planRepo.On("FindByID", mock.Anything, current.ID).Return(current, nil)
+	planRepo.On("FindByID", mock.Anything, target.ID).Return(target, nil)
+	dependentRepo := new(MockDependentRepository)
+	dependentRepo.On("FindByCustomerID", mock.Anything, "cust-1").Return([]*entity.Dependent{}, nil)
+	changes := new(MockPlanChangeRepository)
+	changes.On("FindPendingPlanChange", mock.Anything, "sub-1").Return(nil, nil)
+	changes.On("CreatePendingPlanChange", mock.Anything, mock.Anything).Return(nil)
+	changes.On("SetPlanChangeCharge", mock.Anything, mock.Anything, "pay_prorata").Return(nil)
+	changes.On("ApplyPlanChange", mock.Anything, mock.Anything).Return(nil)
+	gateway := new(MockPlanChangeGateway)
+	gateway.On("CreatePayment", mock.Anything).Return(&asaas.PaymentOutput{ID: "pay_prorata"}, nil)
+	gateway.On("UpdateSubscriptionValue", "sub_asaas_1", 59880).Return(nil)
+	publisher := new(MockQueueProducer)
+	publisher.On("PublishUpdate", mock.Anything, mock.Anything).Return(nil)
+
+	uc := usecase.NewChangePlanUseCase(subRepo, customerRepo, planRepo, dependentRepo, changes, gateway, publisher)
+	uc.DocuSealUseCase = usecase.NewGenerateContractWithDocuSealUseCase(nil, docuseal.NewClient(server.URL, "key"))
+
+	output, err := uc.Execute(context.Background(), usecase.ChangePlanInput{CustomerID: "cust-1", PlanID: "plan-anual"})
+
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "sub-docuseal-1", output.AddendumSubmissionID)
+	if assert.Len(t, submitted.Submitters, 1) {
+		assert.Equal(t, "Anual", submitted.Submitters[0].Values["monthly"])
+	}
+}
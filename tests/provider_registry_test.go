@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xavierca1/ligue-payments/internal/infra/integration/doc24"
+	"github.com/xavierca1/ligue-payments/internal/infra/integration/tem"
+	"github.com/xavierca1/ligue-payments/internal/infra/queue"
+)
+
+// activateOnlyProvider implementa apenas a ativação
+type activateOnlyProvider struct{}
+
+func (activateOnlyProvider) CreateBeneficiary(ctx context.Context, input queue.ActivationPayload) error {
+	return nil
+}
+
+func (activateOnlyProvider) GetBeneficiaryID(ctx context.Context, cpf string) (string, error) {
+	return cpf, nil
+}
+
+// TestProviderRegistryCapabilities - Capacidades são declaradas pelas interfaces que o adapter implementa
+func TestProviderRegistryCapabilities(t *testing.T) {
+	registry := queue.NewProviderRegistry()
+	assert.NoError(t, registry.Register("doc24", doc24.NewClient("id", "secret")))
+	assert.NoError(t, registry.Register("TEM", tem.NewClient("", "id", "secret")))
+	assert.NoError(t, registry.Register("PARCEIRO", activateOnlyProvider{}))
+
+	for _, provider := range []string{"DOC24", "tem"} {
+		_, err := registry.Activator(provider)
+		assert.NoError(t, err)
+		_, err = registry.Deactivator(provider)
+		assert.NoError(t, err)
+		_, err = registry.Updater(provider)
+		assert.NoError(t, err)
+		_, err = registry.DependentAdder(provider)
+		assert.NoError(t, err)
+		_, err = registry.DependentRemover(provider)
+		assert.NoError(t, err)
+	}
+
+	_, err := registry.Activator("parceiro")
+	assert.NoError(t, err)
+
+	_, err = registry.Deactivator("PARCEIRO")
+	assert.True(t, errors.Is(err, queue.ErrCapabilityNotSupported))
+	_, err = registry.Updater("PARCEIRO")
+	assert.True(t, errors.Is(err, queue.ErrCapabilityNotSupported))
+}
+
+// TestProviderRegistryUnknownProvider - Provedor desconhecido retorna erro (mensagem vai para a DLQ)
+func TestProviderRegistryUnknownProvider(t *testing.T) {
+	registry := queue.NewProviderRegistry()
+
+	_, err := registry.Activator("NOVO")
+	assert.True(t, errors.Is(err, queue.ErrUnknownProvider))
+	_, err = registry.Deactivator("NOVO")
+	assert.True(t, errors.Is(err, queue.ErrUnknownProvider))
+
+	assert.Error(t, registry.Register("", activateOnlyProvider{}))
+	assert.Error(t, registry.Register("VAZIO", struct{}{}))
+}
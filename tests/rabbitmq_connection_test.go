@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xavierca1/ligue-payments/internal/infra/http/handlers"
+	"github.com/xavierca1/ligue-payments/internal/infra/queue"
+)
+
+// TestProducerFailsWhileDisconnected - Sem conexão o publish retorna erro em vez de descartar a mensagem
+func TestProducerFailsWhileDisconnected(t *testing.T) {
+	manager := queue.NewConnectionManager("guest", "guest", "127.0.0.1", "1")
+
+	_, err := manager.Channel()
+	assert.ErrorIs(t, err, queue.ErrNotConnected)
+	assert.True(t, manager.IsClosed())
+
+	producer := queue.NewProducer(manager)
+	err = producer.PublishActivation(context.Background(), queue.ActivationPayload{CustomerID: "cust-1"})
+	assert.ErrorIs(t, err, queue.ErrNotConnected)
+
+	err = producer.PublishRaw(context.Background(), queue.MessageTypeActivation, []byte(`{}`))
+	assert.ErrorIs(t, err, queue.ErrNotConnected)
+}
+
+// TestConnectionManagerStopsRetryingOnCancel - Broker inacessível: o supervisor tenta de novo até o ctx ser cancelado
+func TestConnectionManagerStopsRetryingOnCancel(t *testing.T) {
+	manager := queue.NewConnectionManager("guest", "guest", "127.0.0.1", "1")
+	manager.MinBackoff = 10 * time.Millisecond
+	manager.MaxBackoff = 20 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		manager.Start(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("ConnectionManager não encerrou após o cancelamento do contexto")
+	}
+	assert.True(t, manager.IsClosed())
+}
+
+// TestHealthReportsRabbitMQDisconnected - Health check reflete a conexão do supervisor
+func TestHealthReportsRabbitMQDisconnected(t *testing.T) {
+	manager := queue.NewConnectionManager("guest", "guest", "127.0.0.1", "1")
+	handler := handlers.NewHealthHandler(nil, manager)
+
+	rec := httptest.NewRecorder()
+	handler.Handle(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	var body handlers.HealthResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "unhealthy: connection closed", body.Dependencies["rabbitmq"])
+	assert.Equal(t, "degraded", body.Status)
+}
@@ -0,0 +1,27 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xavierca1/ligue-payments/internal/infra/http/handlers"
+)
+
+// TestReadyReportsDraining - Durante o encerramento /ready responde 503
+func TestReadyReportsDraining(t *testing.T) {
+	handler := handlers.NewHealthHandler(nil, nil)
+
+	rec := httptest.NewRecorder()
+	handler.Ready(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ready"}`, rec.Body.String())
+
+	handler.SetDraining(true)
+
+	rec = httptest.NewRecorder()
+	handler.Ready(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.JSONEq(t, `{"status":"draining"}`, rec.Body.String())
+}
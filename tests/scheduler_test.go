@@ -0,0 +1,188 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xavierca1/ligue-payments/internal/infra/worker"
+)
+
+type MockSchedulerLeaseRepository struct {
+	mock.Mock
+}
+
+func (m *MockSchedulerLeaseRepository) TryAcquire(ctx context.Context, job, holder string, ttl time.Duration) (bool, error) {
+	args := m.Called(ctx, job, holder, ttl)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockSchedulerLeaseRepository) Release(ctx context.Context, job, holder string) error {
+	args := m.Called(ctx, job, holder)
+	return args.Error(0)
+}
+
+// sharedLeases simula a tabela scheduler_leases compartilhada entre réplicas.
+type sharedLeases struct {
+	mu     sync.Mutex
+	holder map[string]string
+	until  map[string]time.Time
+}
+
+func newSharedLeases() *sharedLeases {
+	return &sharedLeases{holder: map[string]string{}, until: map[string]time.Time{}}
+}
+
+func (s *sharedLeases) TryAcquire(ctx context.Context, job, holder string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if current, ok := s.holder[job]; ok && current != holder && now.Before(s.until[job]) {
+		return false, nil
+	}
+	s.holder[job] = holder
+	s.until[job] = now.Add(ttl)
+	return true, nil
+}
+
+func (s *sharedLeases) Release(ctx context.Context, job, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.holder[job] == holder {
+		s.until[job] = time.Now()
+	}
+	return nil
+}
+
+// TestSchedulerRunsOnlyOnLeader - Com o lease em outra réplica, o job não roda
+func TestSchedulerRunsOnlyOnLeader(t *testing.T) {
+	leases := newSharedLeases()
+	replicaA := worker.NewScheduler(leases, "replica-a")
+	replicaB := worker.NewScheduler(leases, "replica-b")
+
+	var runsA, runsB int
+	replicaA.Every("pix_expiration", time.Minute, func(ctx context.Context) { runsA++ })
+	replicaB.Every("pix_expiration", time.Minute, func(ctx context.Context) { runsB++ })
+
+	assert.True(t, replicaA.RunOnce(context.Background(), "pix_expiration"))
+	assert.False(t, replicaB.RunOnce(context.Background(), "pix_expiration"))
+	assert.True(t, replicaA.RunOnce(context.Background(), "pix_expiration"), "o líder renova o próprio lease")
+
+	assert.Equal(t, 2, runsA)
+	assert.Equal(t, 0, runsB)
+}
+
+// TestSchedulerFailoverAfterLeaseExpires - Lease vencido libera o job para outra réplica
+func TestSchedulerFailoverAfterLeaseExpires(t *testing.T) {
+	leases := newSharedLeases()
+	replicaA := worker.NewScheduler(leases, "replica-a")
+	replicaB := worker.NewScheduler(leases, "replica-b")
+
+	var runsB int
+	replicaA.Every("pix_expiration", 20*time.Millisecond, func(ctx context.Context) {})
+	replicaB.Every("pix_expiration", 20*time.Millisecond, func(ctx context.Context) { runsB++ })
+
+	assert.True(t, replicaA.RunOnce(context.Background(), "pix_expiration"))
+	assert.False(t, replicaB.RunOnce(context.Background(), "pix_expiration"))
+
+	time.Sleep(50 * time.Millisecond) // lease dura dois intervalos
+	assert.True(t, replicaB.RunOnce(context.Background(), "pix_expiration"))
+	assert.Equal(t, 1, runsB)
+}
+
+// TestSchedulerRenewsLeaseDuringLongRun - Job mais longo que o intervalo mantém o lease
+func TestSchedulerRenewsLeaseDuringLongRun(t *testing.T) {
+	leases := newSharedLeases()
+	replicaA := worker.NewScheduler(leases, "replica-a")
+	replicaB := worker.NewScheduler(leases, "replica-b")
+
+	var running atomic.Bool
+	var overlaps, runsB atomic.Int32
+	replicaA.Every("pix_expiration", 20*time.Millisecond, func(ctx context.Context) {
+		running.Store(true)
+		defer running.Store(false)
+		time.Sleep(100 * time.Millisecond)
+	})
+	replicaB.Every("pix_expiration", 20*time.Millisecond, func(ctx context.Context) { runsB.Add(1) })
+
+	done := make(chan bool)
+	go func() { done <- replicaA.RunOnce(context.Background(), "pix_expiration") }()
+
+	assert.Eventually(t, running.Load, time.Second, time.Millisecond)
+	for running.Load() {
+		if replicaB.RunOnce(context.Background(), "pix_expiration") {
+			overlaps.Add(1)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	assert.True(t, <-done)
+	assert.Equal(t, int32(0), overlaps.Load())
+	assert.Equal(t, int32(0), runsB.Load())
+}
+
+// TestSchedulerCancelsRunWhenLeaseLost - Lease tomado durante a execução cancela o contexto do job
+func TestSchedulerCancelsRunWhenLeaseLost(t *testing.T) {
+	leases := new(MockSchedulerLeaseRepository)
+	leases.On("TryAcquire", mock.Anything, "pix_expiration", "replica-a", 60*time.Millisecond).Return(true, nil).Once()
+	leases.On("TryAcquire", mock.Anything, "pix_expiration", "replica-a", 60*time.Millisecond).Return(false, nil)
+
+	scheduler := worker.NewScheduler(leases, "replica-a")
+	canceled := false
+	scheduler.Every("pix_expiration", 30*time.Millisecond, func(ctx context.Context) {
+		select {
+		case <-ctx.Done():
+			canceled = true
+		case <-time.After(time.Second):
+		}
+	})
+
+	assert.True(t, scheduler.RunOnce(context.Background(), "pix_expiration"))
+	assert.True(t, canceled)
+}
+
+// TestSchedulerSkipsWhenLeaseUnavailable - Erro no banco não executa o job
+func TestSchedulerSkipsWhenLeaseUnavailable(t *testing.T) {
+	leases := new(MockSchedulerLeaseRepository)
+	leases.On("TryAcquire", mock.Anything, "pix_expiration", "replica-a", 2*time.Minute).Return(false, errors.New("connection refused"))
+
+	scheduler := worker.NewScheduler(leases, "replica-a")
+	ran := false
+	scheduler.Every("pix_expiration", time.Minute, func(ctx context.Context) { ran = true })
+
+	assert.False(t, scheduler.RunOnce(context.Background(), "pix_expiration"))
+	assert.False(t, ran)
+}
+
+// TestSchedulerReleasesLeaseOnShutdown - Ao encerrar, o líder libera o lease e Start retorna
+func TestSchedulerReleasesLeaseOnShutdown(t *testing.T) {
+	leases := new(MockSchedulerLeaseRepository)
+	leases.On("TryAcquire", mock.Anything, "pix_expiration", "replica-a", 20*time.Millisecond).Return(true, nil)
+	leases.On("Release", mock.Anything, "pix_expiration", "replica-a").Return(nil)
+
+	scheduler := worker.NewScheduler(leases, "replica-a")
+	var runs atomic.Int32
+	scheduler.Every("pix_expiration", 10*time.Millisecond, func(ctx context.Context) { runs.Add(1) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		scheduler.Start(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return runs.Load() > 0 }, time.Second, 5*time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Scheduler não encerrou")
+	}
+	leases.AssertCalled(t, "Release", mock.Anything, "pix_expiration", "replica-a")
+}
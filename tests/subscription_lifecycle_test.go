@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/infra/queue"
+	"github.com/xavierca1/ligue-payments/internal/usecase"
+)
+
+// TestNextSubscriptionStatus - Tabela de transições da máquina de estados
+func TestNextSubscriptionStatus(t *testing.T) {
+	cases := []struct {
+		current string
+		event   string
+		next    string
+		ok      bool
+	}{
+		{"ACTIVE", "PAYMENT_OVERDUE", "PAST_DUE", true},
+		{"PAST_DUE", "PAYMENT_OVERDUE", "SUSPENDED", true},
+		{"SUSPENDED", "SUBSCRIPTION_DELETED", "CANCELED", true},
+		{"ACTIVE", "PAYMENT_REFUNDED", "REFUNDED", true},
+		{"ACTIVE", "PAYMENT_CHARGEBACK_REQUESTED", "SUSPENDED", true},
+		{"PENDING", "PAYMENT_OVERDUE", "", false},
+		{"CANCELED", "PAYMENT_REFUNDED", "", false},
+		{"ACTIVE", "PAYMENT_RECEIVED", "", false},
+	}
+
+	for _, c := range cases {
+		next, ok := usecase.NextSubscriptionStatus(c.current, c.event)
+		assert.Equal(t, c.ok, ok, "%s + %s", c.current, c.event)
+		assert.Equal(t, c.next, next, "%s + %s", c.current, c.event)
+	}
+}
+
+// TestSubscriptionLifecycleOverdueKeepsAccess - PAST_DUE atualiza só a assinatura, sem publicar desativação nem mexer no cadastro
+func TestSubscriptionLifecycleOverdueKeepsAccess(t *testing.T) {
+	ctx := context.Background()
+	mockSubRepo := new(MockSubscriptionRepository)
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockQueue := new(MockQueueProducer)
+
+	mockSubRepo.On("FindLastByCustomerID", ctx, "cust-1").Return(&entity.Subscription{ID: "sub-local-1", CustomerID: "cust-1", Status: "ACTIVE", PaymentMethodID: "sub_1"}, nil)
+	mockSubRepo.On("UpdateStatusByID", ctx, "sub-local-1", "PAST_DUE").Return(nil)
+
+	uc := usecase.NewSubscriptionLifecycleUseCase(mockSubRepo, mockCustomerRepo, new(MockPlanRepository), new(MockDependentRepository), mockQueue)
+	next, err := uc.Execute(ctx, usecase.SubscriptionLifecycleInput{CustomerID: "cust-1", Event: "PAYMENT_OVERDUE", GatewaySubscriptionID: "sub_1"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "PAST_DUE", next)
+	mockQueue.AssertNotCalled(t, "PublishDeactivation", mock.Anything, mock.Anything)
+	mockSubRepo.AssertExpectations(t)
+	mockCustomerRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestSubscriptionLifecycleRefundPublishesDeactivation - Estorno publica desativação antes de gravar o status
+func TestSubscriptionLifecycleRefundPublishesDeactivation(t *testing.T) {
+	ctx := context.Background()
+	mockSubRepo := new(MockSubscriptionRepository)
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPlanRepo := new(MockPlanRepository)
+	mockDependentRepo := new(MockDependentRepository)
+	mockQueue := new(MockQueueProducer)
+
+	mockSubRepo.On("FindLastByCustomerID", ctx, "cust-1").Return(&entity.Subscription{ID: "sub-local-1", CustomerID: "cust-1", PlanID: "plan-1", Status: "ACTIVE"}, nil)
+	mockCustomerRepo.On("FindByID", ctx, "cust-1").Return(&entity.Customer{ID: "cust-1", Name: "Maria", CPF: "12345678900"}, nil)
+	mockPlanRepo.On("FindByID", ctx, "plan-1").Return(&entity.Plan{ID: "plan-1", Provider: "DOC24", ProviderPlanCode: "individual"}, nil)
+	mockDependentRepo.On("FindByCustomerID", ctx, "cust-1").Return([]*entity.Dependent{{Name: "Pedro", CPF: "98765432100"}}, nil)
+	mockQueue.On("PublishDeactivation", ctx, mock.MatchedBy(func(p queue.DeactivationPayload) bool {
+		return p.CustomerID == "cust-1" && p.Provider == "DOC24" && p.Reason == "REFUNDED" && len(p.Dependents) == 1
+	})).Return(nil)
+
+	uc := usecase.NewSubscriptionLifecycleUseCase(mockSubRepo, mockCustomerRepo, mockPlanRepo, mockDependentRepo, mockQueue)
+
+	// Falha ao publicar não deve gravar o status, para que o retry do webhook republique
+	failingQueue := new(MockQueueProducer)
+	failingQueue.On("PublishDeactivation", ctx, mock.Anything).Return(errors.New("broker indisponível"))
+	uc.Queue = failingQueue
+	_, err := uc.Execute(ctx, usecase.SubscriptionLifecycleInput{CustomerID: "cust-1", Event: "PAYMENT_REFUNDED"})
+	assert.Error(t, err)
+	mockSubRepo.AssertNotCalled(t, "UpdateStatusByID", mock.Anything, mock.Anything, mock.Anything)
+
+	mockSubRepo.On("UpdateStatusByID", ctx, "sub-local-1", "REFUNDED").Return(nil)
+	mockCustomerRepo.On("UpdateStatus", ctx, "cust-1", usecase.CustomerStatusInactive).Return(nil)
+	uc.Queue = mockQueue
+	next, err := uc.Execute(ctx, usecase.SubscriptionLifecycleInput{CustomerID: "cust-1", Event: "PAYMENT_REFUNDED"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "REFUNDED", next)
+	mockQueue.AssertExpectations(t)
+	mockSubRepo.AssertCalled(t, "UpdateStatusByID", ctx, "sub-local-1", "REFUNDED")
+	mockCustomerRepo.AssertCalled(t, "UpdateStatus", ctx, "cust-1", usecase.CustomerStatusInactive)
+}
+
+// TestSubscriptionLifecycleWritesDeactivationToOutbox - Com outbox, status e desativação vão na mesma transação, sem publicar direto
+func TestSubscriptionLifecycleWritesDeactivationToOutbox(t *testing.T) {
+	ctx := context.Background()
+	mockSubRepo := new(MockSubscriptionRepository)
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockPlanRepo := new(MockPlanRepository)
+	mockDependentRepo := new(MockDependentRepository)
+	mockQueue := new(MockQueueProducer)
+	mockOutbox := new(MockOutboxRepository)
+
+	mockSubRepo.On("FindLastByCustomerID", ctx, "cust-1").Return(&entity.Subscription{ID: "sub-local-1", CustomerID: "cust-1", PlanID: "plan-1", Status: "ACTIVE"}, nil)
+	mockCustomerRepo.On("FindByID", ctx, "cust-1").Return(&entity.Customer{ID: "cust-1", Name: "Maria", CPF: "12345678900"}, nil)
+	mockPlanRepo.On("FindByID", ctx, "plan-1").Return(&entity.Plan{ID: "plan-1", Provider: "DOC24", ProviderPlanCode: "individual"}, nil)
+	mockDependentRepo.On("FindByCustomerID", ctx, "cust-1").Return([]*entity.Dependent{}, nil)
+	mockOutbox.On("UpdateStatusWithOutbox", ctx, "cust-1", "REFUNDED", usecase.CustomerStatusInactive, mock.Anything).Return(errors.New("deadlock")).Once()
+
+	uc := usecase.NewSubscriptionLifecycleUseCase(mockSubRepo, mockCustomerRepo, mockPlanRepo, mockDependentRepo, mockQueue)
+	uc.Outbox = mockOutbox
+
+	// Transação desfeita: nada foi publicado e o retry do webhook grava tudo de novo
+	_, err := uc.Execute(ctx, usecase.SubscriptionLifecycleInput{CustomerID: "cust-1", Event: "PAYMENT_REFUNDED"})
+	assert.Error(t, err)
+
+	mockOutbox.On("UpdateStatusWithOutbox", ctx, "cust-1", "REFUNDED", usecase.CustomerStatusInactive, mock.MatchedBy(func(e *entity.OutboxEvent) bool {
+		return e.MessageType == queue.MessageTypeDeactivation && e.AggregateID == "cust-1"
+	})).Return(nil).Once()
+	next, err := uc.Execute(ctx, usecase.SubscriptionLifecycleInput{CustomerID: "cust-1", Event: "PAYMENT_REFUNDED"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "REFUNDED", next)
+	mockOutbox.AssertExpectations(t)
+	mockQueue.AssertNotCalled(t, "PublishDeactivation", mock.Anything, mock.Anything)
+	mockSubRepo.AssertNotCalled(t, "UpdateStatusByID", mock.Anything, mock.Anything, mock.Anything)
+	mockCustomerRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestSubscriptionLifecycleIgnoresStaleSubscription - Evento de assinatura Asaas antiga não altera a atual
+func TestSubscriptionLifecycleIgnoresStaleSubscription(t *testing.T) {
+	ctx := context.Background()
+	mockSubRepo := new(MockSubscriptionRepository)
+	mockQueue := new(MockQueueProducer)
+
+	mockSubRepo.On("FindLastByCustomerID", ctx, "cust-1").Return(&entity.Subscription{CustomerID: "cust-1", Status: "ACTIVE", PaymentMethodID: "sub_new"}, nil)
+
+	uc := usecase.NewSubscriptionLifecycleUseCase(mockSubRepo, new(MockCustomerRepository), new(MockPlanRepository), nil, mockQueue)
+	next, err := uc.Execute(ctx, usecase.SubscriptionLifecycleInput{CustomerID: "cust-1", Event: "SUBSCRIPTION_DELETED", GatewaySubscriptionID: "sub_old"})
+
+	assert.NoError(t, err)
+	assert.Empty(t, next)
+	mockSubRepo.AssertNotCalled(t, "UpdateStatusByID", mock.Anything, mock.Anything, mock.Anything)
+	mockQueue.AssertNotCalled(t, "PublishDeactivation", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,227 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xavierca1/ligue-payments/internal/infra/integration/tem"
+	"github.com/xavierca1/ligue-payments/internal/infra/queue"
+)
+
+// temStubTransport simula a API da TEM Saúde e registra as chamadas recebidas
+type temStubTransport struct {
+	calls  []string
+	bodies []map[string]interface{}
+	// missing lista CPFs que a busca por CPF responde com 404
+	missing []string
+}
+
+func (s *temStubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.calls = append(s.calls, req.Method+" "+req.URL.Path)
+
+	if strings.HasSuffix(req.URL.Path, "/oauth/token") {
+		return stubJSON(`{"access_token":"tok","expires_in":3600}`), nil
+	}
+	if cpf := strings.TrimPrefix(req.URL.Path, "/v1/beneficiarios/cpf/"); cpf != req.URL.Path {
+		for _, missing := range s.missing {
+			if missing == cpf {
+				return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(`{}`)), Header: http.Header{}}, nil
+			}
+		}
+		return stubJSON(`{"id":"tem-` + cpf + `","status":"ATIVO"}`), nil
+	}
+
+	var body map[string]interface{}
+	raw, _ := io.ReadAll(req.Body)
+	json.Unmarshal(raw, &body)
+	s.bodies = append(s.bodies, body)
+
+	if req.URL.Path == "/v1/beneficiarios" && body["cpf_titular"] == nil {
+		return stubJSON(`{"id":"tem-42","status":"ATIVO"}`), nil
+	}
+	return stubJSON(`{}`), nil
+}
+
+// TestTemCreateBeneficiaryWithDependents - Titular e dependentes são cadastrados e o ID vem da TEM
+func TestTemCreateBeneficiaryWithDependents(t *testing.T) {
+	transport := &temStubTransport{}
+	client := tem.NewClient("https://tem.test/v1", "id", "secret")
+	client.HTTPClient = &http.Client{Transport: transport}
+
+	err := client.CreateBeneficiary(context.Background(), queue.ActivationPayload{
+		CustomerID:       "cust-1",
+		ProviderPlanCode: "tem familiar",
+		Name:             "Maria Souza",
+		CPF:              "123.456.789-00",
+		Gender:           "Feminino",
+		Dependents: []queue.DependentPayload{
+			{Name: "Pedro Souza", CPF: "987.654.321-00", Gender: 1, Kinship: "FILHO"},
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"POST /v1/oauth/token", "POST /v1/beneficiarios", "POST /v1/beneficiarios"}, transport.calls)
+	assert.Equal(t, "12345678900", transport.bodies[0]["cpf"])
+	assert.Equal(t, "F", transport.bodies[0]["sexo"])
+	assert.Equal(t, "12345678900", transport.bodies[1]["cpf_titular"])
+
+	// Outra instância (réplica ou restart) chega ao mesmo ID consultando a TEM pelo CPF
+	other := tem.NewClient("https://tem.test/v1", "id", "secret")
+	other.HTTPClient = &http.Client{Transport: transport}
+	id, err := other.GetBeneficiaryID(context.Background(), "123.456.789-00")
+	assert.NoError(t, err)
+	assert.Equal(t, "tem-12345678900", id)
+}
+
+// TestTemDeactivateBeneficiary - Dependentes são inativados antes do titular, reaproveitando o token
+func TestTemDeactivateBeneficiary(t *testing.T) {
+	transport := &temStubTransport{}
+	client := tem.NewClient("https://tem.test/v1", "id", "secret")
+	client.HTTPClient = &http.Client{Transport: transport}
+
+	payload := queue.DeactivationPayload{
+		CustomerID: "cust-1",
+		Reason:     "CANCELED",
+		CPF:        "123.456.789-00",
+		Dependents: []queue.DependentPayload{{Name: "Pedro", CPF: "987.654.321-00"}},
+	}
+
+	assert.NoError(t, client.DeactivateBeneficiary(context.Background(), payload))
+	assert.NoError(t, client.DeactivateBeneficiary(context.Background(), payload))
+
+	assert.Equal(t, []string{
+		"POST /v1/oauth/token",
+		"GET /v1/beneficiarios/cpf/98765432100",
+		"POST /v1/beneficiarios/tem-98765432100/inativar",
+		"GET /v1/beneficiarios/cpf/12345678900",
+		"POST /v1/beneficiarios/tem-12345678900/inativar",
+		"GET /v1/beneficiarios/cpf/98765432100",
+		"POST /v1/beneficiarios/tem-98765432100/inativar",
+		"GET /v1/beneficiarios/cpf/12345678900",
+		"POST /v1/beneficiarios/tem-12345678900/inativar",
+	}, transport.calls)
+	assert.Equal(t, "CANCELED", transport.bodies[0]["motivo"])
+}
+
+// TestTemDeactivateSkipsUnknownBeneficiary - CPF sem cadastro na TEM não trava a desativação
+func TestTemDeactivateSkipsUnknownBeneficiary(t *testing.T) {
+	transport := &temStubTransport{missing: []string{"98765432100"}}
+	client := tem.NewClient("https://tem.test/v1", "id", "secret")
+	client.HTTPClient = &http.Client{Transport: transport}
+
+	err := client.DeactivateBeneficiary(context.Background(), queue.DeactivationPayload{
+		CustomerID: "cust-1",
+		CPF:        "123.456.789-00",
+		Dependents: []queue.DependentPayload{{Name: "Pedro", CPF: "987.654.321-00"}},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"POST /v1/oauth/token",
+		"GET /v1/beneficiarios/cpf/98765432100",
+		"GET /v1/beneficiarios/cpf/12345678900",
+		"POST /v1/beneficiarios/tem-12345678900/inativar",
+	}, transport.calls)
+}
+
+// TestTemUpdateBeneficiaryUsesProviderID - Atualização endereça o titular pelo ID da TEM
+func TestTemUpdateBeneficiaryUsesProviderID(t *testing.T) {
+	transport := &temStubTransport{}
+	client := tem.NewClient("https://tem.test/v1", "id", "secret")
+	client.HTTPClient = &http.Client{Transport: transport}
+
+	err := client.UpdateBeneficiary(context.Background(), queue.ActivationPayload{
+		CustomerID:       "cust-1",
+		ProviderPlanCode: "tem familiar",
+		Name:             "Maria Souza",
+		CPF:              "123.456.789-00",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"POST /v1/oauth/token",
+		"GET /v1/beneficiarios/cpf/12345678900",
+		"PUT /v1/beneficiarios/tem-12345678900",
+	}, transport.calls)
+
+	transport.missing = []string{"12345678900"}
+	err = client.UpdateBeneficiary(context.Background(), queue.ActivationPayload{CustomerID: "cust-1", CPF: "123.456.789-00"})
+	assert.ErrorIs(t, err, tem.ErrBeneficiaryNotFound)
+}
+
+// TestTemRequiresCredentials - Sem credenciais a integração falha em vez de ackar silenciosamente
+func TestTemRequiresCredentials(t *testing.T) {
+	client := tem.NewClient("", "", "")
+	err := client.CreateBeneficiary(context.Background(), queue.ActivationPayload{ProviderPlanCode: "x"})
+	assert.Error(t, err)
+	assert.Equal(t, tem.DefaultBaseURL, client.BaseURL)
+}
+
+// temFlakyTransport responde 401 na primeira chamada autenticada e 500 com PII no dependente
+type temFlakyTransport struct {
+	temStubTransport
+	rejectedOnce bool
+	failCPF      string
+}
+
+func (s *temFlakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/oauth/token") && !s.rejectedOnce {
+		s.rejectedOnce = true
+		s.calls = append(s.calls, req.Method+" "+req.URL.Path)
+		return &http.Response{StatusCode: http.StatusUnauthorized, Body: io.NopCloser(strings.NewReader(`{}`)), Header: http.Header{}}, nil
+	}
+	if s.failCPF != "" && req.Body != nil {
+		raw, _ := io.ReadAll(req.Body)
+		req.Body = io.NopCloser(strings.NewReader(string(raw)))
+		if strings.Contains(string(raw), s.failCPF) {
+			s.calls = append(s.calls, req.Method+" "+req.URL.Path)
+			return &http.Response{StatusCode: http.StatusInternalServerError, Header: http.Header{},
+				Body: io.NopCloser(strings.NewReader(`{"erro":"cpf ` + s.failCPF + ` de pedro@example.com inválido"}`))}, nil
+		}
+	}
+	return s.temStubTransport.RoundTrip(req)
+}
+
+// TestTemRetriesOnceAfterUnauthorized - 401 renova o token e repete a chamada uma vez
+func TestTemRetriesOnceAfterUnauthorized(t *testing.T) {
+	transport := &temFlakyTransport{}
+	client := tem.NewClient("https://tem.test/v1", "id", "secret")
+	client.HTTPClient = &http.Client{Transport: transport}
+
+	err := client.DeactivateBeneficiary(context.Background(), queue.DeactivationPayload{CustomerID: "cust-1", CPF: "123.456.789-00"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"POST /v1/oauth/token",
+		"GET /v1/beneficiarios/cpf/12345678900",
+		"POST /v1/oauth/token",
+		"GET /v1/beneficiarios/cpf/12345678900",
+		"POST /v1/beneficiarios/tem-12345678900/inativar",
+	}, transport.calls)
+}
+
+// TestTemCreateBeneficiaryDependentFailure - Falha em dependente retorna erro, com o corpo mascarado
+func TestTemCreateBeneficiaryDependentFailure(t *testing.T) {
+	transport := &temFlakyTransport{rejectedOnce: true, failCPF: "98765432100"}
+	client := tem.NewClient("https://tem.test/v1", "id", "secret")
+	client.HTTPClient = &http.Client{Transport: transport}
+
+	err := client.CreateBeneficiary(context.Background(), queue.ActivationPayload{
+		CustomerID:       "cust-1",
+		ProviderPlanCode: "tem familiar",
+		Name:             "Maria Souza",
+		CPF:              "123.456.789-00",
+		Dependents:       []queue.DependentPayload{{Name: "Pedro Souza", CPF: "987.654.321-00"}},
+	})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Pedro Souza")
+	assert.NotContains(t, err.Error(), "98765432100")
+	assert.NotContains(t, err.Error(), "pedro@example.com")
+	assert.Contains(t, err.Error(), "***00")
+}
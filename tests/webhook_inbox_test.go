@@ -0,0 +1,174 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/xavierca1/ligue-payments/internal/entity"
+	"github.com/xavierca1/ligue-payments/internal/infra/http/handlers"
+	"github.com/xavierca1/ligue-payments/internal/infra/worker"
+	"github.com/xavierca1/ligue-payments/internal/usecase"
+)
+
+// MockWebhookEventRepository - Mock da inbox de webhooks
+type MockWebhookEventRepository struct {
+	mock.Mock
+}
+
+func (m *MockWebhookEventRepository) Save(ctx context.Context, event *entity.WebhookEvent) (bool, error) {
+	args := m.Called(ctx, event)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWebhookEventRepository) ClaimDue(ctx context.Context, limit int) ([]*entity.WebhookEvent, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.WebhookEvent), args.Error(1)
+}
+
+func (m *MockWebhookEventRepository) MarkProcessed(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockWebhookEventRepository) MarkRetry(ctx context.Context, id string, attempts int, nextAttemptAt time.Time, reason string) error {
+	args := m.Called(ctx, id, attempts, nextAttemptAt, reason)
+	return args.Error(0)
+}
+
+func (m *MockWebhookEventRepository) MarkFailed(ctx context.Context, id string, attempts int, reason string) error {
+	args := m.Called(ctx, id, attempts, reason)
+	return args.Error(0)
+}
+
+func (m *MockWebhookEventRepository) Requeue(ctx context.Context, idOrKey string) error {
+	args := m.Called(ctx, idOrKey)
+	return args.Error(0)
+}
+
+type stubWebhookProcessor struct {
+	err   error
+	panic bool
+	calls int
+}
+
+func (s *stubWebhookProcessor) Execute(ctx context.Context, event *entity.WebhookEvent) error {
+	s.calls++
+	if s.panic {
+		panic("boom")
+	}
+	return s.err
+}
+
+// TestNewAsaasWebhookEventKeys - Chave de deduplicação usa o ID do evento e cai para EVENTO:payment_id
+func TestNewAsaasWebhookEventKeys(t *testing.T) {
+	withID, err := usecase.NewAsaasWebhookEvent([]byte(`{"id":"evt_123","event":"payment_received","payment":{"id":"pay_1","customer":"cus_1"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "evt_123", withID.EventKey)
+	assert.Equal(t, "PAYMENT_RECEIVED", withID.EventType)
+	assert.Equal(t, "pay_1", withID.PaymentID)
+	assert.Equal(t, "cus_1", withID.CustomerRef)
+
+	withoutID, err := usecase.NewAsaasWebhookEvent([]byte(`{"event":"PAYMENT_CONFIRMED","payment":{"id":"pay_2"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "PAYMENT_CONFIRMED:pay_2", withoutID.EventKey)
+
+	_, err = usecase.NewAsaasWebhookEvent([]byte(`not-json`))
+	assert.Error(t, err)
+}
+
+// TestProcessWebhookEventActivatesPaidPayment - Evento pago resolve o cliente e ativa a assinatura
+func TestProcessWebhookEventActivatesPaidPayment(t *testing.T) {
+	ctx := context.Background()
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockActivate := new(MockActivateSubscriptionUseCase)
+
+	mockCustomerRepo.On("FindByGatewayID", "cus_1").Return(&entity.Customer{ID: "cust-1", GatewayID: "cus_1"}, nil)
+	mockActivate.On("Execute", ctx, usecase.ActivateSubscriptionInput{CustomerID: "cust-1", GatewayID: "pay_1"}).Return(nil)
+
+	event, _ := usecase.NewAsaasWebhookEvent([]byte(`{"event":"PAYMENT_RECEIVED","payment":{"id":"pay_1","customer":"cus_1","status":"RECEIVED"}}`))
+	uc := usecase.NewProcessWebhookEventUseCase(mockCustomerRepo, mockActivate, nil)
+
+	assert.NoError(t, uc.Execute(ctx, event))
+	mockActivate.AssertExpectations(t)
+}
+
+// TestProcessWebhookEventUnknownCustomerIsRetryable - Cliente ainda não encontrado deve gerar retry
+func TestProcessWebhookEventUnknownCustomerIsRetryable(t *testing.T) {
+	ctx := context.Background()
+	mockCustomerRepo := new(MockCustomerRepository)
+	mockActivate := new(MockActivateSubscriptionUseCase)
+
+	mockCustomerRepo.On("FindByGatewayID", "cus_x").Return(nil, errors.New("not found"))
+	mockCustomerRepo.On("FindByID", ctx, "cus_x").Return(nil, errors.New("not found"))
+
+	event, _ := usecase.NewAsaasWebhookEvent([]byte(`{"event":"PAYMENT_CONFIRMED","payment":{"id":"pay_1","customer":"cus_x","status":"CONFIRMED"}}`))
+	uc := usecase.NewProcessWebhookEventUseCase(mockCustomerRepo, mockActivate, nil)
+
+	assert.Error(t, uc.Execute(ctx, event))
+	mockActivate.AssertNotCalled(t, "Execute", mock.Anything, mock.Anything)
+}
+
+// TestWebhookDispatcherRetriesThenFails - Falhas são reagendadas até esgotar as tentativas
+func TestWebhookDispatcherRetriesThenFails(t *testing.T) {
+	ctx := context.Background()
+
+	retryRepo := new(MockWebhookEventRepository)
+	retryRepo.On("ClaimDue", ctx, mock.Anything).Return([]*entity.WebhookEvent{{ID: "evt-1", Attempts: 0}}, nil)
+	retryRepo.On("MarkRetry", ctx, "evt-1", 1, mock.Anything, "timeout").Return(nil)
+
+	dispatcher := worker.NewWebhookDispatcher(retryRepo, &stubWebhookProcessor{err: errors.New("timeout")})
+	assert.Equal(t, 1, dispatcher.DispatchDue(ctx))
+	retryRepo.AssertCalled(t, "MarkRetry", ctx, "evt-1", 1, mock.Anything, "timeout")
+
+	failRepo := new(MockWebhookEventRepository)
+	failRepo.On("ClaimDue", ctx, mock.Anything).Return([]*entity.WebhookEvent{{ID: "evt-2", Attempts: 7}}, nil)
+	failRepo.On("MarkFailed", ctx, "evt-2", 8, mock.Anything).Return(nil)
+
+	dispatcher = worker.NewWebhookDispatcher(failRepo, &stubWebhookProcessor{panic: true})
+	dispatcher.DispatchDue(ctx)
+	failRepo.AssertCalled(t, "MarkFailed", ctx, "evt-2", 8, mock.Anything)
+	failRepo.AssertNotCalled(t, "MarkProcessed", mock.Anything, mock.Anything)
+}
+
+// TestWebhookDispatcherFailsEventThatKeepsCrashing - Lease vencido conta como tentativa; esgotado, vira FAILED sem reprocessar
+func TestWebhookDispatcherFailsEventThatKeepsCrashing(t *testing.T) {
+	ctx := context.Background()
+	repo := new(MockWebhookEventRepository)
+	repo.On("ClaimDue", ctx, mock.Anything).Return([]*entity.WebhookEvent{{ID: "evt-3", Attempts: 8}}, nil)
+	repo.On("MarkFailed", ctx, "evt-3", 8, mock.Anything).Return(nil)
+
+	processor := &stubWebhookProcessor{}
+	dispatcher := worker.NewWebhookDispatcher(repo, processor)
+	dispatcher.DispatchDue(ctx)
+
+	assert.Equal(t, 0, processor.calls)
+	repo.AssertCalled(t, "MarkFailed", ctx, "evt-3", 8, mock.Anything)
+	repo.AssertNotCalled(t, "MarkRetry", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// TestWebhookHandlerStoresInvalidJSON - Corpo que não é JSON fica na inbox como FAILED em vez de ser descartado
+func TestWebhookHandlerStoresInvalidJSON(t *testing.T) {
+	t.Setenv("ASAAS_WEBHOOK_SKIP_SIGNATURE", "true")
+	inbox := new(MockWebhookEventRepository)
+	inbox.On("Save", mock.Anything, mock.MatchedBy(func(e *entity.WebhookEvent) bool {
+		return e.Status == entity.WebhookEventFailed && e.EventType == "INVALID_PAYLOAD" &&
+			e.LastError != "" && json.Valid(e.Payload)
+	})).Return(true, nil)
+
+	w := httptest.NewRecorder()
+	handlers.NewWebhookHandler(inbox).Handle(w, httptest.NewRequest("POST", "/asaas/webhook", strings.NewReader(`{"event":"PAYMENT_RECEIVED",`)))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	inbox.AssertExpectations(t)
+}
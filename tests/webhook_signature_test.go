@@ -37,11 +37,10 @@ func TestWebhookSignatureVerification(t *testing.T) {
 
 	mockCustomerRepo := new(MockCustomerRepository)
 	mockActivateSubUC := new(MockActivateSubscriptionUseCase)
+	mockInbox := new(MockWebhookEventRepository)
+	mockInbox.On("Save", mock.Anything, mock.Anything).Return(true, nil)
 
-	handler := handlers.NewWebhookHandler(
-		mockCustomerRepo,
-		mockActivateSubUC,
-	)
+	handler := handlers.NewWebhookHandler(mockInbox)
 
 	t.Run("Valid Signature", func(t *testing.T) {
 		payload := map[string]interface{}{
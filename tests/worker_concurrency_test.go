@@ -0,0 +1,35 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xavierca1/ligue-payments/internal/infra/queue"
+)
+
+// TestParseProviderLimits - Formato PROVEDOR=N, separado por vírgula
+func TestParseProviderLimits(t *testing.T) {
+	limits, err := queue.ParseProviderLimits(" doc24=3, TEM=1 ,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"DOC24": 3, "TEM": 1}, limits)
+
+	for _, raw := range []string{"DOC24", "DOC24=0", "=2", "TEM=abc"} {
+		_, err := queue.ParseProviderLimits(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+// TestWorkerProviderLimit - Limite por provedor nunca passa do total de consumidores
+func TestWorkerProviderLimit(t *testing.T) {
+	worker := queue.NewWorker(nil, queue.NewProviderRegistry(), nil)
+	worker.Concurrency = 4
+	worker.DefaultProviderLimit = 2
+	worker.ProviderLimits = map[string]int{"DOC24": 3, "TEM": 10}
+
+	assert.Equal(t, 3, worker.ProviderLimit("doc24"))
+	assert.Equal(t, 4, worker.ProviderLimit("TEM"))
+	assert.Equal(t, 2, worker.ProviderLimit("OUTRO"))
+
+	worker.DefaultProviderLimit = 0
+	assert.Equal(t, 4, worker.ProviderLimit("OUTRO"))
+}